@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"flag"
 	"github.com/temirov/GAuss/examples/user_auth/pkg/dash"
 	"html/template"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/gauss/middleware"
 	"github.com/temirov/GAuss/pkg/session"
 	"github.com/temirov/utils/system"
 )
@@ -31,7 +33,17 @@ func main() {
 	googleClientID := system.GetEnvOrFail("GOOGLE_CLIENT_ID")
 	googleClientSecret := system.GetEnvOrFail("GOOGLE_CLIENT_SECRET")
 
-	session.NewSession([]byte(clientSecret))
+	// SESSION_ENCRYPTION_KEY is optional. When set, it is hashed into a 32-byte
+	// key so session cookies, including the stored OAuth token, are encrypted
+	// with AES-256 in addition to being signed.
+	if encryptionSecret := os.Getenv("SESSION_ENCRYPTION_KEY"); encryptionSecret != "" {
+		encryptionKey := sha256.Sum256([]byte(encryptionSecret))
+		if err := session.NewSessionWithEncryption([]byte(clientSecret), encryptionKey[:]); err != nil {
+			log.Fatalf("Failed to initialize encrypted sessions: %v", err)
+		}
+	} else {
+		session.NewSession([]byte(clientSecret))
+	}
 
 	customLoginTemplate := *loginTemplateFlag
 
@@ -62,17 +74,18 @@ func main() {
 	dashService := dash.NewService()
 	dashHandlers := dash.NewHandlers(dashService, templates)
 
-	mux.Handle(DashboardPath, gauss.AuthMiddleware(http.HandlerFunc(dashHandlers.Dashboard)))
+	requireLogin := middleware.Auth(authService)
+	mux.Handle(DashboardPath, requireLogin(http.HandlerFunc(dashHandlers.Dashboard)))
 
 	// Register root handler with middleware.
-	mux.Handle(Root, gauss.AuthMiddleware(http.HandlerFunc(rootHandler)))
+	mux.Handle(Root, requireLogin(http.HandlerFunc(rootHandler)))
 
 	log.Printf("Server starting on :8080 (public base %s)", publicBaseURL)
 	log.Fatal(http.ListenAndServe("localhost:8080", mux))
 }
 
 func rootHandler(responseWriter http.ResponseWriter, request *http.Request) {
-	webSession, _ := session.Store().Get(request, constants.SessionName)
+	webSession, _ := session.Store().Get(request, session.Name())
 	if webSession.Values[constants.SessionKeyUserEmail] != nil {
 		// User is logged in, redirect to dashboard.
 		http.Redirect(responseWriter, request, DashboardPath, http.StatusFound)
@@ -1,7 +1,6 @@
 package dash
 
 import (
-	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/session"
 	"html/template"
 	"net/http"
@@ -24,7 +23,7 @@ func NewHandlers(service *Service, templates *template.Template) *Handlers {
 
 // Dashboard renders the dashboard.html template using data from the session.
 func (handlers *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
-	webSession, _ := session.Store().Get(r, constants.SessionName)
+	webSession, _ := session.Store().Get(r, session.Name())
 	data := handlers.service.GetUserData(webSession)
 	handlers.templates.ExecuteTemplate(w, "dashboard.html", data)
 }
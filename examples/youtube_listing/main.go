@@ -11,6 +11,7 @@ import (
 
 	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/gauss/middleware"
 	"github.com/temirov/GAuss/pkg/session"
 	"github.com/temirov/utils/system"
 	"golang.org/x/oauth2"
@@ -53,11 +54,13 @@ func main() {
 		log.Fatalf("Failed to parse templates: %v", err)
 	}
 
-	mux.Handle(mainPagePath, requestLogger(gauss.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	requireYouTube := middleware.RequireScopes(authService, gauss.ScopeYouTubeReadonly)
+	refreshToken := middleware.TokenRefresh(authService)
+	mux.Handle(mainPagePath, requestLogger(requireYouTube(refreshToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		renderYouTube(w, r, authService, templates)
-	}))))
+	})))))
 
-	mux.Handle(Root, gauss.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle(Root, middleware.Auth(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, mainPagePath, http.StatusFound)
 	})))
 
@@ -79,7 +82,7 @@ func requestLogger(next http.Handler) http.Handler {
 func renderYouTube(w http.ResponseWriter, r *http.Request, svc *gauss.Service, tmpl *template.Template) {
 	log.Printf("YouTube render started: user_agent=%s", r.UserAgent())
 
-	sess, err := session.Store().Get(r, constants.SessionName)
+	sess, err := session.Store().Get(r, session.Name())
 	if err != nil {
 		log.Printf("Session get failed: %v", err)
 		http.Error(w, "Session error", http.StatusInternalServerError)
@@ -0,0 +1,39 @@
+// Package echo adapts GAuss authentication to the Echo web framework.
+package echo
+
+import (
+	"net/http"
+
+	echoframework "github.com/labstack/echo/v4"
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+// contextKeyUser stores the authenticated *gauss.GoogleUser in the Echo
+// context.
+const contextKeyUser = "gauss_user"
+
+// AuthMiddleware returns Echo middleware that behaves like
+// gauss.NewAuthMiddleware, including its session binding checks.
+// Unauthenticated requests are redirected to the service's LoginURL through
+// the Echo context, and any error writing that response is returned so Echo's
+// HTTPErrorHandler formats it. Authenticated requests continue with the user
+// available through UserFromContext.
+func AuthMiddleware(service *gauss.Service) echoframework.MiddlewareFunc {
+	return func(nextHandler echoframework.HandlerFunc) echoframework.HandlerFunc {
+		return func(echoContext echoframework.Context) error {
+			user, authenticated := service.AuthenticatedUser(echoContext.Response(), echoContext.Request())
+			if !authenticated {
+				return echoContext.Redirect(http.StatusFound, service.LoginURL(echoContext.Request()))
+			}
+			echoContext.Set(contextKeyUser, user)
+			return nextHandler(echoContext)
+		}
+	}
+}
+
+// UserFromContext returns the user stored by AuthMiddleware. The boolean
+// result is false when the middleware did not run or found no session.
+func UserFromContext(echoContext echoframework.Context) (*gauss.GoogleUser, bool) {
+	user, isUser := echoContext.Get(contextKeyUser).(*gauss.GoogleUser)
+	return user, isUser
+}
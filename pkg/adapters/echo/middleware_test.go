@@ -0,0 +1,61 @@
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echoframework "github.com/labstack/echo/v4"
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+func newTestServer(t *testing.T) *echoframework.Echo {
+	t.Helper()
+	session.NewSession([]byte("secret"))
+	svc, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", gauss.WithLoginPath("/account/signin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := echoframework.New()
+	server.Use(AuthMiddleware(svc))
+	server.GET("/dashboard", func(echoContext echoframework.Context) error {
+		user, found := UserFromContext(echoContext)
+		if !found {
+			return echoframework.ErrInternalServerError
+		}
+		return echoContext.String(http.StatusOK, user.Email)
+	})
+	return server
+}
+
+func TestAuthMiddlewareRedirectsWithoutSession(t *testing.T) {
+	server := newTestServer(t)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "/account/signin?next=%2Fdashboard" {
+		t.Fatalf("expected redirect to /account/signin with the return path, got %q", location)
+	}
+}
+
+func TestAuthMiddlewareExposesUser(t *testing.T) {
+	server := newTestServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	initRR := httptest.NewRecorder()
+	webSession, _ := session.Store().Get(req, constants.SessionName)
+	webSession.Values[constants.SessionKeyUserEmail] = "e@example.com"
+	if err := webSession.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(initRR.Result().Cookies()[0])
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "e@example.com" {
+		t.Fatalf("expected the user email, got %d %q", rr.Code, rr.Body.String())
+	}
+}
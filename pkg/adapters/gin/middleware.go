@@ -0,0 +1,49 @@
+// Package gin adapts GAuss authentication to the Gin web framework.
+package gin
+
+import (
+	"net/http"
+
+	ginframework "github.com/gin-gonic/gin"
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+// contextKeyUser stores the authenticated *gauss.GoogleUser in the Gin context.
+const contextKeyUser = "gauss_user"
+
+// AuthMiddleware returns a Gin handler that requires a GAuss session. It runs
+// the net/http middleware from gauss.NewAuthMiddleware against the request and
+// response writer underlying the Gin context, so unauthenticated requests are
+// redirected to the service's login path and aborted. Authenticated requests
+// continue with the user available through UserFromContext, read from the
+// session store and name of service, including WithSessionNamespace and
+// WithSessionStore.
+func AuthMiddleware(service *gauss.Service) ginframework.HandlerFunc {
+	authMiddleware := gauss.NewAuthMiddleware(service)
+	return func(ginContext *ginframework.Context) {
+		authenticated := false
+		authMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			authenticated = true
+			ginContext.Request = request
+			if user, found := gauss.UserFromContext(request.Context()); found {
+				ginContext.Set(contextKeyUser, &gauss.GoogleUser{ID: user.ID, Email: user.Email, Name: user.Name, Picture: user.Picture})
+			}
+		})).ServeHTTP(ginContext.Writer, ginContext.Request)
+		if !authenticated {
+			ginContext.Abort()
+			return
+		}
+		ginContext.Next()
+	}
+}
+
+// UserFromContext returns the user stored by AuthMiddleware. The boolean
+// result is false when the middleware did not run or found no session.
+func UserFromContext(ginContext *ginframework.Context) (*gauss.GoogleUser, bool) {
+	contextValue, found := ginContext.Get(contextKeyUser)
+	if !found {
+		return nil, false
+	}
+	user, isUser := contextValue.(*gauss.GoogleUser)
+	return user, isUser
+}
@@ -0,0 +1,75 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ginframework "github.com/gin-gonic/gin"
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+func newTestEngine(t *testing.T, options ...gauss.ServiceOption) *ginframework.Engine {
+	t.Helper()
+	ginframework.SetMode(ginframework.TestMode)
+	session.NewSession([]byte("secret"))
+	svc, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := ginframework.New()
+	engine.Use(AuthMiddleware(svc))
+	engine.GET("/dashboard", func(ginContext *ginframework.Context) {
+		user, found := UserFromContext(ginContext)
+		if !found {
+			ginContext.Status(http.StatusInternalServerError)
+			return
+		}
+		ginContext.String(http.StatusOK, user.Email)
+	})
+	return engine
+}
+
+func TestAuthMiddlewareRedirectsWithoutSession(t *testing.T) {
+	engine := newTestEngine(t)
+	rr := httptest.NewRecorder()
+	engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != constants.LoginPath+"?next=%2Fdashboard" {
+		t.Fatalf("expected redirect to %s with the return path, got %q", constants.LoginPath, location)
+	}
+}
+
+func TestAuthMiddlewareExposesUser(t *testing.T) {
+	testCases := []struct {
+		name        string
+		sessionName string
+		options     []gauss.ServiceOption
+	}{
+		{name: "default session", sessionName: constants.SessionName},
+		{name: "session namespace", sessionName: "admin_session", options: []gauss.ServiceOption{gauss.WithSessionNamespace("admin_session")}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			engine := newTestEngine(t, testCase.options...)
+			req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+			initRR := httptest.NewRecorder()
+			webSession, _ := session.Store().Get(req, testCase.sessionName)
+			webSession.Values[constants.SessionKeyUserEmail] = "e@example.com"
+			if err := webSession.Save(req, initRR); err != nil {
+				t.Fatal(err)
+			}
+			req.AddCookie(initRR.Result().Cookies()[0])
+
+			rr := httptest.NewRecorder()
+			engine.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK || rr.Body.String() != "e@example.com" {
+				t.Fatalf("expected the user email, got %d %q", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
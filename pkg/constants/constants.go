@@ -9,13 +9,27 @@ const (
 	GoogleAuthPath = "/auth/google"
 	// CallbackPath receives the OAuth2 redirect from Google.
 	CallbackPath = "/auth/google/callback"
+	// ScopesPath asks a logged-in user to grant additional scopes.
+	ScopesPath = "/auth/google/scopes"
+	// DisconnectPath revokes the Google grant and clears the user session.
+	DisconnectPath = "/auth/google/disconnect"
 	// LogoutPath clears the user session.
 	LogoutPath = "/logout"
+	// WellKnownPath serves the OpenID Connect discovery document.
+	WellKnownPath = "/.well-known/openid-configuration"
+	// DebugPath serves the redirect URI diagnostics.
+	DebugPath = "/auth/debug"
+	// ThemeScriptPath serves the script behind the theme switch of the
+	// embedded login page.
+	ThemeScriptPath = "/auth/theme.js"
 	// TemplatesPath points to embedded login templates.
 	TemplatesPath = "templates/*.html"
 	// DefaultTemplateName is the embedded login template name.
 	DefaultTemplateName = "login.html"
 
+	// SessionKeyUserID stores Google's stable subject identifier for the
+	// logged-in user. Prefer it to the email as the primary user key.
+	SessionKeyUserID = "user_id"
 	// SessionKeyUserEmail stores the logged-in user's email in the session.
 	SessionKeyUserEmail = "user_email"
 	// SessionKeyUserName stores the logged-in user's display name.
@@ -24,7 +38,66 @@ const (
 	SessionKeyUserPicture = "user_picture"
 	// SessionKeyOAuthToken stores the OAuth2 token JSON string.
 	SessionKeyOAuthToken = "oauth_token"
+	// SessionKeyGrantedScopes stores the space separated scopes Google granted
+	// at login.
+	SessionKeyGrantedScopes = "granted_scopes"
+	// SessionKeySessionID stores the random identifier assigned to a session
+	// when the user logs in. It changes on every login.
+	SessionKeySessionID = "session_id"
+	// SessionKeyBoundIP stores a hash of the client address seen at login
+	// when IP session binding is enabled.
+	SessionKeyBoundIP = "bound_ip"
+	// SessionKeyBoundUserAgent stores a hash of the User-Agent seen at login
+	// when User-Agent session binding is enabled.
+	SessionKeyBoundUserAgent = "bound_user_agent"
+	// SessionKeyLoginTime stores the Unix time in seconds of the login that
+	// created the session.
+	SessionKeyLoginTime = "login_time"
+	// SessionKeyAuthenticatedAt is the session key holding the Unix time in
+	// seconds at which the user authenticated. It is SessionKeyLoginTime
+	// under the name used with gauss.WithMaxSessionLifetime.
+	SessionKeyAuthenticatedAt = SessionKeyLoginTime
+	// SessionKeyLastSeen stores the Unix time in seconds of the last request
+	// made with the session when an idle timeout is configured.
+	SessionKeyLastSeen = "last_seen"
+	// SessionKeyReturnURL stores the URL to return to after the next login
+	// when it starts without a next parameter, as recorded by the auth
+	// middleware or cookie.SetReturnURL.
+	SessionKeyReturnURL = "oauth_requested_url"
+	// SessionKeyTokenValidatedAt stores the Unix time in seconds at which
+	// the session's OAuth token was last confirmed valid with Google when
+	// gauss.WithTokenRevalidation is enabled.
+	SessionKeyTokenValidatedAt = "token_validated_at"
 
 	// SessionName is the cookie name used for sessions.
 	SessionName = "gauss_session"
 )
+
+// Paths lists the routes served by GAuss. Pass it to gauss.WithPaths to move
+// them, for example when the application already serves /login itself.
+type Paths struct {
+	// LoginPath serves the login page.
+	LoginPath string
+	// GoogleAuthPath starts the OAuth2 flow with Google.
+	GoogleAuthPath string
+	// CallbackPath receives the OAuth2 redirect from Google.
+	CallbackPath string
+	// ScopesPath asks a logged-in user to grant additional scopes.
+	ScopesPath string
+	// DisconnectPath revokes the Google grant.
+	DisconnectPath string
+	// LogoutPath clears the user session.
+	LogoutPath string
+}
+
+// DefaultPaths returns the routes GAuss serves unless configured otherwise.
+func DefaultPaths() Paths {
+	return Paths{
+		LoginPath:      LoginPath,
+		GoogleAuthPath: GoogleAuthPath,
+		CallbackPath:   CallbackPath,
+		ScopesPath:     ScopesPath,
+		DisconnectPath: DisconnectPath,
+		LogoutPath:     LogoutPath,
+	}
+}
@@ -0,0 +1,96 @@
+package gauss
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerWWWAuthenticate     = "WWW-Authenticate"
+	headerAccept              = "Accept"
+	headerXRequestedWith      = "X-Requested-With"
+	wwwAuthenticateChallenge  = `Bearer realm="gauss"`
+	xmlHTTPRequest            = "XMLHttpRequest"
+	mediaTypeJSON             = "application/json"
+	mediaTypeHTML             = "text/html"
+	mediaTypeJavaScript       = "text/javascript; charset=utf-8"
+	unauthenticatedErrorValue = "unauthenticated"
+)
+
+// unauthenticatedResponse is the JSON body sent to unauthenticated API
+// requests.
+type unauthenticatedResponse struct {
+	Error    string `json:"error"`
+	LoginURL string `json:"login_url"`
+}
+
+// WithAPIMode returns a MiddlewareOption that answers every unauthenticated
+// request with 401 Unauthorized, a WWW-Authenticate header and the JSON body
+// {"error":"unauthenticated","login_url":"/auth/google"} instead of
+// redirecting, for routes serving fetch or XMLHttpRequest clients. login_url
+// is the path configured with WithRedirectTo, or the Service's Google auth
+// path.
+func WithAPIMode() MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		middlewareConfig.apiMode = true
+	}
+}
+
+// WithAPIDetection returns a MiddlewareOption that answers like WithAPIMode
+// only the requests that look like API calls: those sent with
+// X-Requested-With: XMLHttpRequest or whose Accept header asks for JSON but
+// not HTML. Browser navigations are still redirected.
+func WithAPIDetection() MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		middlewareConfig.apiDetection = true
+	}
+}
+
+// answersWithJSON reports whether an unauthenticated request gets the JSON
+// response rather than a redirect.
+func (middlewareConfig *MiddlewareConfig) answersWithJSON(request *http.Request) bool {
+	return middlewareConfig.apiMode || (middlewareConfig.apiDetection && isAPIRequest(request))
+}
+
+// apiLoginURL returns the login_url reported to unauthenticated API requests.
+func (middlewareConfig *MiddlewareConfig) apiLoginURL(serviceInstance *Service) string {
+	if middlewareConfig.redirectTo != "" {
+		return middlewareConfig.redirectTo
+	}
+	return serviceInstance.googleAuthPath
+}
+
+// isAPIRequest reports whether request was sent by script rather than by a
+// browser navigation.
+func isAPIRequest(request *http.Request) bool {
+	if strings.EqualFold(request.Header.Get(headerXRequestedWith), xmlHTTPRequest) {
+		return true
+	}
+	acceptsJSON, acceptsHTML := false, false
+	for _, acceptedRange := range strings.Split(request.Header.Get(headerAccept), ",") {
+		mediaType, _, parseError := mime.ParseMediaType(strings.TrimSpace(acceptedRange))
+		if parseError != nil {
+			continue
+		}
+		switch {
+		case mediaType == mediaTypeJSON || strings.HasSuffix(mediaType, "+json"):
+			acceptsJSON = true
+		case mediaType == mediaTypeHTML:
+			acceptsHTML = true
+		}
+	}
+	return acceptsJSON && !acceptsHTML
+}
+
+// writeUnauthenticatedJSON answers request with 401 Unauthorized and the
+// unauthenticated JSON body pointing at loginURL.
+func writeUnauthenticatedJSON(responseWriter http.ResponseWriter, request *http.Request, loginURL string) {
+	responseWriter.Header().Set(headerWWWAuthenticate, wwwAuthenticateChallenge)
+	responseWriter.Header().Set(headerContentType, mediaTypeJSON)
+	responseWriter.WriteHeader(http.StatusUnauthorized)
+	if encodeError := json.NewEncoder(responseWriter).Encode(unauthenticatedResponse{Error: unauthenticatedErrorValue, LoginURL: loginURL}); encodeError != nil {
+		logRequestf(request, "Failed to write the unauthenticated response: %v", encodeError)
+	}
+}
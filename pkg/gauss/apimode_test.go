@@ -0,0 +1,62 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestAuthMiddlewareAPIResponses(t *testing.T) {
+	testCases := []struct {
+		name          string
+		options       []MiddlewareOption
+		headers       map[string]string
+		expectJSON    bool
+		expectedLogin string
+	}{
+		{name: "default redirects JSON clients", headers: map[string]string{"Accept": "application/json"}},
+		{name: "api mode answers browsers", options: []MiddlewareOption{WithAPIMode()}, headers: map[string]string{"Accept": "text/html"}, expectJSON: true, expectedLogin: constants.GoogleAuthPath},
+		{name: "detection answers accept json", options: []MiddlewareOption{WithAPIDetection()}, headers: map[string]string{"Accept": "application/json"}, expectJSON: true, expectedLogin: constants.GoogleAuthPath},
+		{name: "detection answers problem json", options: []MiddlewareOption{WithAPIDetection()}, headers: map[string]string{"Accept": "application/problem+json;q=0.9"}, expectJSON: true, expectedLogin: constants.GoogleAuthPath},
+		{name: "detection answers xhr", options: []MiddlewareOption{WithAPIDetection()}, headers: map[string]string{"X-Requested-With": "XMLHttpRequest"}, expectJSON: true, expectedLogin: constants.GoogleAuthPath},
+		{name: "detection redirects browsers", options: []MiddlewareOption{WithAPIDetection()}, headers: map[string]string{"Accept": "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.8"}},
+		{name: "detection redirects without accept", options: []MiddlewareOption{WithAPIDetection()}},
+		{name: "login url follows redirect option", options: []MiddlewareOption{WithAPIMode(), WithRedirectTo("/account/signin")}, expectJSON: true, expectedLogin: "/account/signin"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			handler := NewAuthMiddleware(h.service, testCase.options...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+			for headerName, headerValue := range testCase.headers {
+				req.Header.Set(headerName, headerValue)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if !testCase.expectJSON {
+				if rr.Code != http.StatusFound {
+					t.Fatalf("expected a redirect, got %d", rr.Code)
+				}
+				return
+			}
+			if rr.Code != http.StatusUnauthorized || rr.Header().Get("Location") != "" {
+				t.Fatalf("expected 401 without a redirect, got %d %q", rr.Code, rr.Header().Get("Location"))
+			}
+			if rr.Header().Get("WWW-Authenticate") == "" || rr.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("unexpected headers %v", rr.Header())
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if body["error"] != "unauthenticated" || body["login_url"] != testCase.expectedLogin {
+				t.Fatalf("unexpected body %v", body)
+			}
+		})
+	}
+}
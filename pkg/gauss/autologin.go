@@ -0,0 +1,71 @@
+package gauss
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	autoLoginCookieName    = "gauss_auto_login"
+	autoLoginMaxAttempts   = 3
+	autoLoginWindowSeconds = 60
+)
+
+// WithAutoLogin returns a ServiceOption that skips the login page: the login
+// page handler and NewAuthMiddleware send unauthenticated GET and HEAD
+// requests straight to the Google auth path. The login page is still rendered
+// when it has an error to show, and after autoLoginMaxAttempts automatic
+// redirects within a minute, so a failing flow cannot loop forever.
+func WithAutoLogin() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.autoLogin = true
+	}
+}
+
+// autoLoginRedirect sends request to the Google auth path, carrying returnTo
+// when it is not empty, and reports true when WithAutoLogin applies to it.
+// Every redirect is counted in a short-lived cookie that stops further
+// redirects once autoLoginMaxAttempts is reached.
+func (serviceInstance *Service) autoLoginRedirect(responseWriter http.ResponseWriter, request *http.Request, returnTo string) bool {
+	if !serviceInstance.autoLogin || (request.Method != http.MethodGet && request.Method != http.MethodHead) {
+		return false
+	}
+	attempts := autoLoginAttempts(request)
+	if attempts >= autoLoginMaxAttempts {
+		return false
+	}
+	http.SetCookie(responseWriter, &http.Cookie{
+		Name:     autoLoginCookieName,
+		Value:    strconv.Itoa(attempts + 1),
+		Path:     "/",
+		MaxAge:   autoLoginWindowSeconds,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(responseWriter, request, withReturnTo(serviceInstance.mountedURL(request, serviceInstance.googleAuthPath), returnTo), http.StatusFound)
+	return true
+}
+
+// clearAutoLoginAttempts deletes the attempt counter after a successful login.
+func (serviceInstance *Service) clearAutoLoginAttempts(responseWriter http.ResponseWriter, request *http.Request) {
+	if !serviceInstance.autoLogin {
+		return
+	}
+	if _, cookieError := request.Cookie(autoLoginCookieName); cookieError != nil {
+		return
+	}
+	http.SetCookie(responseWriter, &http.Cookie{Name: autoLoginCookieName, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+// autoLoginAttempts returns the automatic redirects counted for request.
+func autoLoginAttempts(request *http.Request) int {
+	attemptCookie, cookieError := request.Cookie(autoLoginCookieName)
+	if cookieError != nil {
+		return 0
+	}
+	attempts, parseError := strconv.Atoi(attemptCookie.Value)
+	if parseError != nil || attempts < 0 {
+		return autoLoginMaxAttempts
+	}
+	return attempts
+}
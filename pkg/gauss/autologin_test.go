@@ -0,0 +1,90 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// requestWithCookies builds a GET request for target carrying cookies.
+func requestWithCookies(target string, cookies []*http.Cookie) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestAutoLoginRedirectsToGoogle(t *testing.T) {
+	h := newTestHandlers(t, WithAutoLogin())
+	protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testCases := []struct {
+		name             string
+		handler          http.Handler
+		target           string
+		expectedLocation string
+	}{
+		{name: "login page", handler: http.HandlerFunc(h.loginHandler), target: constants.LoginPath, expectedLocation: constants.GoogleAuthPath},
+		{name: "login page with return path", handler: http.HandlerFunc(h.loginHandler), target: constants.LoginPath + "?next=%2Freports", expectedLocation: constants.GoogleAuthPath + "?next=%2Freports"},
+		{name: "protected page", handler: protected, target: "/dashboard", expectedLocation: constants.GoogleAuthPath + "?next=%2Fdashboard"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			testCase.handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, testCase.target, nil))
+			if rr.Code != http.StatusFound || rr.Header().Get("Location") != testCase.expectedLocation {
+				t.Fatalf("expected a redirect to %s, got %d %s", testCase.expectedLocation, rr.Code, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestAutoLoginRendersErrors(t *testing.T) {
+	h := newTestHandlers(t, WithAutoLogin())
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath+"?error="+string(ErrCodeTokenExchange), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the login page with the error, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	failure := httptest.NewRecorder()
+	h.Callback(failure, httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil))
+	flashed := httptest.NewRecorder()
+	h.loginHandler(flashed, requestWithCookies(constants.LoginPath, lastCookies(failure)))
+	if flashed.Code != http.StatusOK {
+		t.Fatalf("expected the login page for a flashed error, got %d", flashed.Code)
+	}
+}
+
+func TestAutoLoginStopsAfterRepeatedAttempts(t *testing.T) {
+	h := newTestHandlers(t, WithAutoLogin())
+	var cookies []*http.Cookie
+	for attempt := 0; attempt < autoLoginMaxAttempts; attempt++ {
+		rr := httptest.NewRecorder()
+		h.loginHandler(rr, requestWithCookies(constants.LoginPath, cookies))
+		if rr.Code != http.StatusFound {
+			t.Fatalf("attempt %d: expected an automatic redirect, got %d", attempt, rr.Code)
+		}
+		cookies = rr.Result().Cookies()
+	}
+
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, requestWithCookies(constants.LoginPath, cookies))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the login page once attempts are exhausted, got %d", rr.Code)
+	}
+}
+
+func TestAutoLoginIsOffByDefault(t *testing.T) {
+	h := newTestHandlers(t)
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the login page, got %d", rr.Code)
+	}
+}
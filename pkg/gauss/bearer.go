@@ -0,0 +1,205 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTokenInfoCacheTTL is how long VerifyAccessToken trusts a valid
+	// token before asking Google again.
+	defaultTokenInfoCacheTTL = time.Minute
+
+	headerAuthorization        = "Authorization"
+	bearerAuthorizationPrefix  = "Bearer "
+	tokenInfoAccessTokenParam  = "access_token"
+	invalidTokenErrorValue     = "invalid_token"
+	invalidTokenChallenge      = `Bearer realm="gauss", error="invalid_token"`
+	spanNameVerifyAccessToken  = "gauss.VerifyAccessToken"
+	tokenInfoEmailVerifiedTrue = "true"
+)
+
+var (
+	// ErrInvalidAccessToken is returned by VerifyAccessToken when Google
+	// rejects the token or reports it as expired.
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	// ErrAudienceMismatch is returned by VerifyAccessToken when the token was
+	// issued to another OAuth client.
+	ErrAudienceMismatch = errors.New("access token was issued to another client")
+)
+
+// TokenInfo describes a Google access token verified by VerifyAccessToken.
+type TokenInfo struct {
+	// Subject is the Google ID of the user who granted the token.
+	Subject string
+	// Email is the user's email address, present when the token was granted
+	// the email scope.
+	Email         string
+	EmailVerified bool
+	Scopes        []string
+	Expiry        time.Time
+}
+
+// tokenInfoResponse is the JSON body returned by Google's tokeninfo endpoint,
+// which encodes numbers and booleans as strings.
+type tokenInfoResponse struct {
+	Audience      string `json:"aud"`
+	Subject       string `json:"sub"`
+	Scope         string `json:"scope"`
+	Expiry        string `json:"exp"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+}
+
+// WithTokenInfoCacheTTL returns a ServiceOption that sets how long
+// VerifyAccessToken remembers a valid token, one minute by default, so that
+// repeated API calls with the same token do not each call Google. Entries
+// never outlive the token. Zero or negative values disable the cache.
+// Rejected tokens are never cached.
+func WithTokenInfoCacheTTL(ttl time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.tokenInfoCacheTTL = ttl
+	}
+}
+
+// NewBearerTokenMiddleware returns middleware that authenticates API requests
+// carrying a Google access token in an "Authorization: Bearer" header instead
+// of a session cookie, for clients such as mobile apps that sign in with
+// Google themselves. The token is checked with VerifyAccessToken, which
+// requires it to be issued to the Service's client ID, and the user is
+// attached to the request context for UserFromContext, with the token's
+// scopes in User.Scopes. User.Email is set only when Google reports the
+// address as verified, so email-based authorization never trusts an
+// unverified one. Requests without a token or with an invalid, expired or
+// foreign one receive 401 Unauthorized with a WWW-Authenticate header and a
+// JSON error body.
+func NewBearerTokenMiddleware(serviceInstance *Service) func(http.Handler) http.Handler {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			accessToken, found := bearerToken(request)
+			if !found {
+				writeBearerRejection(responseWriter, request, wwwAuthenticateChallenge, unauthenticatedErrorValue)
+				return
+			}
+			tokenInfo, verifyError := serviceInstance.VerifyAccessToken(request.Context(), accessToken)
+			if verifyError != nil {
+				logRequestf(request, "Rejected bearer token: %v", verifyError)
+				writeBearerRejection(responseWriter, request, invalidTokenChallenge, invalidTokenErrorValue)
+				return
+			}
+			user := &User{ID: tokenInfo.Subject, Scopes: tokenInfo.Scopes}
+			if tokenInfo.EmailVerified {
+				user.Email = tokenInfo.Email
+			}
+			nextHandler.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), userContextKey{}, user)))
+		})
+	}
+}
+
+// VerifyAccessToken asks Google's tokeninfo endpoint whether accessToken is a
+// valid, unexpired access token issued to the Service's client ID, and
+// returns what Google reports about it. Valid tokens are cached as set with
+// WithTokenInfoCacheTTL. The error wraps ErrInvalidAccessToken or
+// ErrAudienceMismatch when the token is refused.
+func (serviceInstance *Service) VerifyAccessToken(ctx context.Context, accessToken string) (tokenInfo *TokenInfo, err error) {
+	if accessToken == "" {
+		return nil, ErrInvalidAccessToken
+	}
+	if cachedInfo, found := serviceInstance.tokenInfoCache.load(accessTokenCacheKey(accessToken), serviceInstance.now()); found {
+		cachedInfo.Scopes = append([]string(nil), cachedInfo.Scopes...)
+		return &cachedInfo, nil
+	}
+
+	ctx, span := serviceInstance.startSpan(ctx, spanNameVerifyAccessToken)
+	defer func() {
+		endSpan(span, err, "token verification failed")
+	}()
+
+	response, fetchError := serviceInstance.fetchTokenInfo(ctx, accessToken)
+	if fetchError != nil {
+		return nil, fetchError
+	}
+	if response.Audience != serviceInstance.config.ClientID {
+		return nil, fmt.Errorf("%w: audience %q", ErrAudienceMismatch, response.Audience)
+	}
+	expiryUnixTime, parseError := strconv.ParseInt(response.Expiry, 10, 64)
+	currentTime := serviceInstance.now()
+	if parseError != nil || !currentTime.Before(time.Unix(expiryUnixTime, 0)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidAccessToken)
+	}
+	tokenInfo = &TokenInfo{
+		Subject:       response.Subject,
+		Email:         response.Email,
+		EmailVerified: response.EmailVerified == tokenInfoEmailVerifiedTrue,
+		Scopes:        strings.Fields(response.Scope),
+		Expiry:        time.Unix(expiryUnixTime, 0),
+	}
+	if serviceInstance.tokenInfoCache != nil {
+		cacheExpiry := currentTime.Add(serviceInstance.tokenInfoCacheTTL)
+		if tokenInfo.Expiry.Before(cacheExpiry) {
+			cacheExpiry = tokenInfo.Expiry
+		}
+		cachedInfo := *tokenInfo
+		cachedInfo.Scopes = append([]string(nil), tokenInfo.Scopes...)
+		serviceInstance.tokenInfoCache.store(accessTokenCacheKey(accessToken), cachedInfo, cacheExpiry, currentTime)
+	}
+	return tokenInfo, nil
+}
+
+// fetchTokenInfo asks the tokeninfo endpoint about accessToken, posting it in
+// a form body so it does not appear in request logs. The error is
+// ErrInvalidAccessToken when Google answers that the token is not valid, and
+// describes the failure otherwise.
+func (serviceInstance *Service) fetchTokenInfo(ctx context.Context, accessToken string) (*tokenInfoResponse, error) {
+	formValues := url.Values{tokenInfoAccessTokenParam: {accessToken}}
+	tokenInfoRequest, requestError := http.NewRequestWithContext(ctx, http.MethodPost, serviceInstance.googleEndpoints.TokenInfoURL, strings.NewReader(formValues.Encode()))
+	if requestError != nil {
+		return nil, fmt.Errorf("failed to build tokeninfo request: %w", requestError)
+	}
+	tokenInfoRequest.Header.Set(headerContentType, "application/x-www-form-urlencoded")
+	tokenInfoHTTPResponse, httpError := serviceInstance.httpClient(ctx).Do(tokenInfoRequest)
+	if httpError != nil {
+		return nil, fmt.Errorf("failed to verify access token: %w", httpError)
+	}
+	defer tokenInfoHTTPResponse.Body.Close()
+
+	if tokenInfoHTTPResponse.StatusCode == http.StatusBadRequest {
+		return nil, ErrInvalidAccessToken
+	}
+	if tokenInfoHTTPResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo returned status %d", tokenInfoHTTPResponse.StatusCode)
+	}
+	var response tokenInfoResponse
+	if decodeError := json.NewDecoder(tokenInfoHTTPResponse.Body).Decode(&response); decodeError != nil {
+		return nil, fmt.Errorf("failed to decode tokeninfo: %w", decodeError)
+	}
+	return &response, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(request *http.Request) (string, bool) {
+	authorization := request.Header.Get(headerAuthorization)
+	if len(authorization) <= len(bearerAuthorizationPrefix) || !strings.EqualFold(authorization[:len(bearerAuthorizationPrefix)], bearerAuthorizationPrefix) {
+		return "", false
+	}
+	accessToken := strings.TrimSpace(authorization[len(bearerAuthorizationPrefix):])
+	return accessToken, accessToken != ""
+}
+
+// writeBearerRejection answers request with 401 Unauthorized, challenge in
+// the WWW-Authenticate header and errorCode in a JSON body.
+func writeBearerRejection(responseWriter http.ResponseWriter, request *http.Request, challenge string, errorCode string) {
+	responseWriter.Header().Set(headerWWWAuthenticate, challenge)
+	responseWriter.Header().Set(headerContentType, mediaTypeJSON)
+	responseWriter.WriteHeader(http.StatusUnauthorized)
+	if encodeError := json.NewEncoder(responseWriter).Encode(accessRejection{Error: errorCode, Message: signInRequiredMessage}); encodeError != nil {
+		logRequestf(request, "Failed to write the bearer token rejection: %v", encodeError)
+	}
+}
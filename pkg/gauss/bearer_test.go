@@ -0,0 +1,168 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bearerTestTime is the clock used by the bearer token tests.
+var bearerTestTime = time.Unix(1700000000, 0)
+
+// useMockTokenInfo serves tokeninfo answers for the tokens in responses,
+// answers 400 for any other token and returns the number of calls made. The
+// token must be posted in a form body.
+func useMockTokenInfo(t *testing.T, options []ServiceOption, responses map[string]string) (*Service, *int) {
+	t.Helper()
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		if r.Method != http.MethodPost || r.URL.RawQuery != "" {
+			t.Errorf("expected the token in a POST body, got %s %s", r.Method, r.URL)
+		}
+		response, found := responses[r.PostFormValue("access_token")]
+		if !found {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_token"}`)
+			return
+		}
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	h := newTestHandlers(t, append([]ServiceOption{WithGoogleEndpoints(GoogleEndpoints{TokenInfoURL: server.URL})}, options...)...)
+	h.service.now = func() time.Time { return bearerTestTime }
+	return h.service, &callCount
+}
+
+// tokenInfoJSON returns a tokeninfo body for audience expiring at expiry.
+func tokenInfoJSON(audience string, expiry time.Time) string {
+	return fmt.Sprintf(`{"aud":%q,"sub":"42","scope":"openid https://www.googleapis.com/auth/userinfo.email","exp":"%d","email":"e@example.com","email_verified":"true"}`, audience, expiry.Unix())
+}
+
+func TestBearerTokenMiddleware(t *testing.T) {
+	responses := map[string]string{
+		"good":       tokenInfoJSON("id", bearerTestTime.Add(time.Hour)),
+		"foreign":    tokenInfoJSON("other-client", bearerTestTime.Add(time.Hour)),
+		"expired":    tokenInfoJSON("id", bearerTestTime.Add(-time.Second)),
+		"unverified": strings.Replace(tokenInfoJSON("id", bearerTestTime.Add(time.Hour)), `"email_verified":"true"`, `"email_verified":"false"`, 1),
+	}
+	testCases := []struct {
+		name              string
+		authorization     string
+		expectedStatus    int
+		expectedChallenge string
+		expectedError     string
+		expectedEmail     string
+	}{
+		{name: "valid token", authorization: "Bearer good", expectedStatus: http.StatusOK, expectedEmail: "e@example.com"},
+		{name: "lowercase scheme", authorization: "bearer good", expectedStatus: http.StatusOK, expectedEmail: "e@example.com"},
+		{name: "unverified email", authorization: "Bearer unverified", expectedStatus: http.StatusOK},
+		{name: "missing token", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer realm="gauss"`, expectedError: "unauthenticated"},
+		{name: "basic credentials", authorization: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer realm="gauss"`, expectedError: "unauthenticated"},
+		{name: "unknown token", authorization: "Bearer forged", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer realm="gauss", error="invalid_token"`, expectedError: "invalid_token"},
+		{name: "wrong audience", authorization: "Bearer foreign", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer realm="gauss", error="invalid_token"`, expectedError: "invalid_token"},
+		{name: "expired token", authorization: "Bearer expired", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer realm="gauss", error="invalid_token"`, expectedError: "invalid_token"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc, _ := useMockTokenInfo(t, nil, responses)
+			var seenUsers []*User
+			request := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+			if testCase.authorization != "" {
+				request.Header.Set("Authorization", testCase.authorization)
+			}
+			rr := httptest.NewRecorder()
+			NewBearerTokenMiddleware(svc)(contextUserRecorder(&seenUsers)).ServeHTTP(rr, request)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if challenge := rr.Header().Get("WWW-Authenticate"); challenge != testCase.expectedChallenge {
+				t.Fatalf("expected challenge %q, got %q", testCase.expectedChallenge, challenge)
+			}
+			if testCase.expectedStatus != http.StatusOK {
+				var body accessRejection
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error != testCase.expectedError {
+					t.Fatalf("expected error %q, got %q (%v)", testCase.expectedError, rr.Body.String(), err)
+				}
+				if len(seenUsers) != 0 {
+					t.Fatal("expected the handler not to run")
+				}
+				return
+			}
+			if len(seenUsers) != 1 || seenUsers[0] == nil {
+				t.Fatalf("expected a context user, got %v", seenUsers)
+			}
+			user := seenUsers[0]
+			if user.ID != "42" || user.Email != testCase.expectedEmail || len(user.Scopes) != 2 || user.Scopes[1] != "https://www.googleapis.com/auth/userinfo.email" {
+				t.Fatalf("unexpected context user %+v", user)
+			}
+		})
+	}
+}
+
+func TestVerifyAccessTokenErrors(t *testing.T) {
+	svc, _ := useMockTokenInfo(t, nil, map[string]string{
+		"foreign": tokenInfoJSON("other-client", bearerTestTime.Add(time.Hour)),
+	})
+	if _, err := svc.VerifyAccessToken(context.Background(), "forged"); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("expected ErrInvalidAccessToken, got %v", err)
+	}
+	if _, err := svc.VerifyAccessToken(context.Background(), "foreign"); !errors.Is(err, ErrAudienceMismatch) {
+		t.Fatalf("expected ErrAudienceMismatch, got %v", err)
+	}
+}
+
+func TestVerifyAccessTokenCache(t *testing.T) {
+	testCases := []struct {
+		name              string
+		options           []ServiceOption
+		tokenExpiry       time.Duration
+		elapsed           time.Duration
+		expectedCallCount int
+		expectedError     error
+	}{
+		{name: "cached within TTL", tokenExpiry: time.Hour, elapsed: 30 * time.Second, expectedCallCount: 1},
+		{name: "refetched after TTL", tokenExpiry: time.Hour, elapsed: time.Minute, expectedCallCount: 2},
+		{name: "custom TTL", options: []ServiceOption{WithTokenInfoCacheTTL(10 * time.Minute)}, tokenExpiry: time.Hour, elapsed: 5 * time.Minute, expectedCallCount: 1},
+		{name: "cache disabled", options: []ServiceOption{WithTokenInfoCacheTTL(0)}, tokenExpiry: time.Hour, elapsed: time.Second, expectedCallCount: 2},
+		{name: "entry ends with token", tokenExpiry: 20 * time.Second, elapsed: 20 * time.Second, expectedCallCount: 2, expectedError: ErrInvalidAccessToken},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc, callCount := useMockTokenInfo(t, testCase.options, map[string]string{
+				"good": tokenInfoJSON("id", bearerTestTime.Add(testCase.tokenExpiry)),
+			})
+			if _, err := svc.VerifyAccessToken(context.Background(), "good"); err != nil {
+				t.Fatalf("first verification failed: %v", err)
+			}
+			currentTime := bearerTestTime.Add(testCase.elapsed)
+			svc.now = func() time.Time { return currentTime }
+			_, err := svc.VerifyAccessToken(context.Background(), "good")
+			if !errors.Is(err, testCase.expectedError) {
+				t.Fatalf("expected error %v, got %v", testCase.expectedError, err)
+			}
+			if *callCount != testCase.expectedCallCount {
+				t.Fatalf("expected %d tokeninfo calls, got %d", testCase.expectedCallCount, *callCount)
+			}
+		})
+	}
+}
+
+func TestVerifyAccessTokenDoesNotCacheRejections(t *testing.T) {
+	svc, callCount := useMockTokenInfo(t, nil, nil)
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := svc.VerifyAccessToken(context.Background(), "forged"); err == nil {
+			t.Fatal("expected the token to be rejected")
+		}
+	}
+	if *callCount != 2 {
+		t.Fatalf("expected every rejected token to be checked, got %d calls", *callCount)
+	}
+}
@@ -0,0 +1,155 @@
+package gauss
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	bruteForceInitialBlock = time.Second
+	bruteForceMaximumBlock = time.Minute
+)
+
+// WithBruteForceProtection returns a ServiceOption that blocks clients whose
+// callbacks keep failing because their state does not match the session or
+// Google rejects their authorization code. Denied consent and token endpoint
+// errors are not counted. Once a client has failed more than maxFailures
+// times, each further failure blocks it for a period that starts at one
+// second and doubles up to one minute.
+// Blocked callbacks receive 429 Too Many Requests with a Retry-After header.
+// A client's failures are forgotten resetAfter after its last one. Clients
+// are identified like WithRateLimit does. NewService rejects non-positive
+// values.
+func WithBruteForceProtection(maxFailures int, resetAfter time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.bruteForceMaxFailures = maxFailures
+		serviceInstance.bruteForceResetAfter = resetAfter
+		serviceInstance.bruteForceEnabled = true
+	}
+}
+
+// BruteForceProtector tracks authentication failures per client and blocks
+// clients that fail too often with an exponentially growing timeout.
+type BruteForceProtector struct {
+	maxFailures int
+	resetAfter  time.Duration
+	now         func() time.Time
+	records     *ttlCache[*failureRecord]
+}
+
+// failureRecord holds the failures of one client. Records expire once the
+// client is neither blocked nor has failed within the reset period.
+type failureRecord struct {
+	mutex        sync.Mutex
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// NewBruteForceProtector returns a BruteForceProtector that starts blocking a
+// client after more than maxFailures failures and forgets them resetAfter
+// after the last one. Both values must be positive.
+func NewBruteForceProtector(maxFailures int, resetAfter time.Duration) (*BruteForceProtector, error) {
+	return newBruteForceProtector(maxFailures, resetAfter, time.Now)
+}
+
+// newBruteForceProtector is NewBruteForceProtector with an injected clock.
+func newBruteForceProtector(maxFailures int, resetAfter time.Duration, now func() time.Time) (*BruteForceProtector, error) {
+	if maxFailures <= 0 || resetAfter <= 0 {
+		return nil, errors.New("brute force protection max failures and reset period must be positive")
+	}
+	return &BruteForceProtector{maxFailures: maxFailures, resetAfter: resetAfter, now: now, records: newTTLCache[*failureRecord](resetAfter)}, nil
+}
+
+// Blocked reports whether key is currently blocked and for how much longer.
+func (protector *BruteForceProtector) Blocked(key string) (time.Duration, bool) {
+	currentTime := protector.now()
+	record, found := protector.records.load(key, currentTime)
+	if !found {
+		return 0, false
+	}
+	record.mutex.Lock()
+	defer record.mutex.Unlock()
+	remaining := record.blockedUntil.Sub(currentTime)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// RecordFailure counts a failure for key and blocks it once it has failed
+// more than the allowed number of times.
+func (protector *BruteForceProtector) RecordFailure(key string) {
+	currentTime := protector.now()
+	record := protector.records.loadOrStore(key, &failureRecord{}, currentTime.Add(protector.resetAfter), currentTime)
+
+	record.mutex.Lock()
+	defer record.mutex.Unlock()
+	if currentTime.Sub(record.lastFailure) >= protector.resetAfter {
+		record.failures = 0
+	}
+	record.failures++
+	record.lastFailure = currentTime
+	if excessFailures := record.failures - protector.maxFailures; excessFailures > 0 {
+		record.blockedUntil = currentTime.Add(bruteForceBlockDuration(excessFailures))
+	}
+	recordExpiry := currentTime.Add(protector.resetAfter)
+	if record.blockedUntil.After(recordExpiry) {
+		recordExpiry = record.blockedUntil
+	}
+	protector.records.store(key, record, recordExpiry, currentTime)
+}
+
+// Reset forgets the failures recorded for key.
+func (protector *BruteForceProtector) Reset(key string) {
+	protector.records.delete(key)
+}
+
+// bruteForceBlockDuration returns how long a client is blocked after its
+// excessFailures-th failure over the limit.
+func bruteForceBlockDuration(excessFailures int) time.Duration {
+	if excessFailures > 6 {
+		return bruteForceMaximumBlock
+	}
+	return min(bruteForceInitialBlock<<(excessFailures-1), bruteForceMaximumBlock)
+}
+
+// clientKey identifies the client that sent request for rate limiting and
+// brute force protection.
+func (serviceInstance *Service) clientKey(request *http.Request) string {
+	if serviceInstance.rateLimitKeyFunc != nil {
+		return serviceInstance.rateLimitKeyFunc(request)
+	}
+	return ClientIP(request)
+}
+
+// rejectBlockedClient answers request with 429 Too Many Requests and reports
+// true when brute force protection has blocked its client.
+func (serviceInstance *Service) rejectBlockedClient(responseWriter http.ResponseWriter, request *http.Request) bool {
+	protector := serviceInstance.bruteForceProtector
+	if protector == nil {
+		return false
+	}
+	retryAfter, blocked := protector.Blocked(serviceInstance.clientKey(request))
+	if !blocked {
+		return false
+	}
+	logRequestf(request, "Rejecting callback from a client blocked after repeated failures")
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+	responseWriter.Header().Set(headerRetryAfter, strconv.Itoa(retryAfterSeconds))
+	http.Error(responseWriter, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	return true
+}
+
+// recordCallbackFailure counts a callback with a mismatched state or an
+// invalid code against the client that sent request when brute force
+// protection is enabled.
+func (serviceInstance *Service) recordCallbackFailure(request *http.Request) {
+	if protector := serviceInstance.bruteForceProtector; protector != nil {
+		protector.RecordFailure(serviceInstance.clientKey(request))
+	}
+}
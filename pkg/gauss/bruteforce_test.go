@@ -0,0 +1,165 @@
+package gauss
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestBruteForceProtectionBlocksRepeatedCallbackFailures(t *testing.T) {
+	h := newTestHandlers(t, WithBruteForceProtection(2, time.Minute))
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.service.bruteForceProtector.now = func() time.Time { return currentTime }
+
+	sendCallback := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=replayed&code=c1", nil)
+		req.RemoteAddr = remoteAddr
+		seedState(t, req, "stored")
+		rr := httptest.NewRecorder()
+		h.Callback(rr, req)
+		return rr
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		assertErrorRedirect(t, sendCallback("192.0.2.1:1234"), ErrCodeStateMismatch)
+	}
+	assertErrorRedirect(t, sendCallback("192.0.2.1:1234"), ErrCodeStateMismatch)
+
+	testSteps := []struct {
+		name           string
+		advance        time.Duration
+		remoteAddr     string
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{name: "blocked after exceeding the threshold", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "1"},
+		{name: "other client unaffected", remoteAddr: "192.0.2.2:1234", wantStatus: http.StatusFound},
+		{name: "block expires and the next failure doubles it", advance: time.Second, remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusFound},
+		{name: "doubled block", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "2"},
+	}
+	for _, testStep := range testSteps {
+		currentTime = currentTime.Add(testStep.advance)
+		rr := sendCallback(testStep.remoteAddr)
+		if rr.Code != testStep.wantStatus {
+			t.Fatalf("%s: expected %d, got %d", testStep.name, testStep.wantStatus, rr.Code)
+		}
+		if retryAfter := rr.Header().Get("Retry-After"); retryAfter != testStep.wantRetryAfter {
+			t.Fatalf("%s: expected Retry-After %q, got %q", testStep.name, testStep.wantRetryAfter, retryAfter)
+		}
+	}
+}
+
+func TestBruteForceProtectionIgnoresUnrelatedCallbackFailures(t *testing.T) {
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode AuthErrorCode
+	}{
+		{name: "denied consent", query: "?state=stored&error=access_denied", expectedCode: ErrCodeAuthorizationFailed},
+		{name: "token endpoint outage", query: "?state=stored&code=c1", expectedCode: ErrCodeTokenExchange},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithBruteForceProtection(1, time.Minute))
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				},
+				func(w http.ResponseWriter, r *http.Request) {},
+			)
+			for attempt := 0; attempt < 3; attempt++ {
+				req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+testCase.query, nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				seedState(t, req, "stored")
+				rr := httptest.NewRecorder()
+				h.Callback(rr, req)
+				assertErrorRedirect(t, rr, testCase.expectedCode)
+			}
+		})
+	}
+}
+
+func TestBruteForceProtectionCountsInvalidCodes(t *testing.T) {
+	h := newTestHandlers(t, WithBruteForceProtection(1, time.Minute))
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {},
+	)
+	expectedStatuses := []int{http.StatusFound, http.StatusFound, http.StatusTooManyRequests}
+	for attempt, expectedStatus := range expectedStatuses {
+		req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=stored&code=forged", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		seedState(t, req, "stored")
+		rr := httptest.NewRecorder()
+		h.Callback(rr, req)
+		if rr.Code != expectedStatus {
+			t.Fatalf("attempt %d: expected %d, got %d", attempt+1, expectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestBruteForceProtectorBackoffAndReset(t *testing.T) {
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	protector, err := newBruteForceProtector(1, 5*time.Minute, func() time.Time { return currentTime })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protector.RecordFailure("client")
+	if _, blocked := protector.Blocked("client"); blocked {
+		t.Fatal("expected no block within the threshold")
+	}
+	wantBlocks := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for failureIndex, wantBlock := range wantBlocks {
+		protector.RecordFailure("client")
+		if remaining, blocked := protector.Blocked("client"); !blocked || remaining != wantBlock {
+			t.Fatalf("failure %d: expected a %s block, got %s (blocked %t)", failureIndex+2, wantBlock, remaining, blocked)
+		}
+	}
+
+	currentTime = currentTime.Add(10 * time.Minute)
+	protector.RecordFailure("client")
+	if _, blocked := protector.Blocked("client"); blocked {
+		t.Fatal("expected failures to be forgotten after the reset period")
+	}
+
+	protector.RecordFailure("client")
+	protector.Reset("client")
+	if _, blocked := protector.Blocked("client"); blocked {
+		t.Fatal("expected Reset to lift the block")
+	}
+}
+
+func TestBruteForceProtectorSweepsExpiredRecords(t *testing.T) {
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	protector, err := newBruteForceProtector(3, time.Minute, func() time.Time { return currentTime })
+	if err != nil {
+		t.Fatal(err)
+	}
+	protector.RecordFailure("stale")
+	currentTime = currentTime.Add(2 * time.Minute)
+	protector.RecordFailure("fresh")
+
+	if _, found := protector.records.entries.Load("stale"); found {
+		t.Fatal("expected the stale record to be swept")
+	}
+	if _, found := protector.records.entries.Load("fresh"); !found {
+		t.Fatal("expected the fresh record to remain")
+	}
+}
+
+func TestWithBruteForceProtectionRejectsInvalidValues(t *testing.T) {
+	for _, option := range []ServiceOption{WithBruteForceProtection(0, time.Minute), WithBruteForceProtection(3, 0)} {
+		if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", option); err == nil {
+			t.Fatal("expected an error for non-positive values")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	calendarPageTokenParameter = "pageToken"
+	spanNameGetCalendarList    = "gauss.GetCalendarList"
+)
+
+// CalendarEntry is one of the calendars in the user's calendar list.
+type CalendarEntry struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+}
+
+// calendarListPage is one page of the Calendar API calendar list.
+type calendarListPage struct {
+	Items         []CalendarEntry `json:"items"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+// GetCalendarList returns every calendar in the calendar list of the user
+// who granted oauthToken, which needs ScopeCalendarReadonly or ScopeCalendar.
+// It is a thin wrapper over the Calendar API using GetClient, and shows the
+// pattern for calling other Google APIs.
+func (serviceInstance *Service) GetCalendarList(ctx context.Context, oauthToken *oauth2.Token) (calendarEntries []CalendarEntry, err error) {
+	ctx, span := serviceInstance.startSpan(ctx, spanNameGetCalendarList)
+	defer func() {
+		endSpan(span, err, "calendar list request failed")
+	}()
+
+	httpClient := serviceInstance.GetClient(ctx, oauthToken)
+	calendarEntries = []CalendarEntry{}
+	pageToken := ""
+	for {
+		pageURL := serviceInstance.googleEndpoints.CalendarListURL
+		if pageToken != "" {
+			pageURL += "?" + url.Values{calendarPageTokenParameter: {pageToken}}.Encode()
+		}
+		page, pageError := fetchCalendarListPage(ctx, httpClient, pageURL)
+		if pageError != nil {
+			return nil, pageError
+		}
+		calendarEntries = append(calendarEntries, page.Items...)
+		if page.NextPageToken == "" {
+			return calendarEntries, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// fetchCalendarListPage retrieves one page of the calendar list with
+// httpClient.
+func fetchCalendarListPage(ctx context.Context, httpClient *http.Client, pageURL string) (*calendarListPage, error) {
+	pageRequest, requestError := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if requestError != nil {
+		return nil, fmt.Errorf("failed to build calendar list request: %w", requestError)
+	}
+	httpResponse, httpError := httpClient.Do(pageRequest)
+	if httpError != nil {
+		return nil, fmt.Errorf("failed to get calendar list: %w", httpError)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google API returned status %d", httpResponse.StatusCode)
+	}
+
+	var page calendarListPage
+	if decodeError := json.NewDecoder(httpResponse.Body).Decode(&page); decodeError != nil {
+		return nil, fmt.Errorf("failed to decode calendar list: %w", decodeError)
+	}
+	return &page, nil
+}
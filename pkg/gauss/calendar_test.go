@@ -0,0 +1,65 @@
+package gauss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// useCalendarListEndpoint serves the Calendar API calendar list for the
+// duration of the test, answering with pages keyed by page token, and returns
+// the option pointing a Service at it.
+func useCalendarListEndpoint(t *testing.T, pages map[string]string) ServiceOption {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer calendar-token" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		pageJSON, found := pages[r.URL.Query().Get("pageToken")]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, pageJSON)
+	}))
+	t.Cleanup(server.Close)
+	return WithGoogleEndpoints(GoogleEndpoints{CalendarListURL: server.URL + "/calendarList"})
+}
+
+func TestGetCalendarList(t *testing.T) {
+	calendarListOption := useCalendarListEndpoint(t, map[string]string{
+		"":      `{"items":[{"id":"primary@example.com","summary":"Ada","accessRole":"owner"}],"nextPageToken":"page2"}`,
+		"page2": `{"items":[{"id":"team@group.calendar.google.com","summary":"Team"}]}`,
+	})
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", ScopeStrings([]Scope{ScopeEmail, ScopeCalendarReadonly}), "", calendarListOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendarEntries, err := svc.GetCalendarList(context.Background(), &oauth2.Token{AccessToken: "calendar-token"})
+	if err != nil {
+		t.Fatalf("GetCalendarList error: %v", err)
+	}
+	expectedEntries := []CalendarEntry{
+		{ID: "primary@example.com", Summary: "Ada"},
+		{ID: "team@group.calendar.google.com", Summary: "Team"},
+	}
+	if len(calendarEntries) != len(expectedEntries) {
+		t.Fatalf("expected %+v, got %+v", expectedEntries, calendarEntries)
+	}
+	for index, expectedEntry := range expectedEntries {
+		if calendarEntries[index] != expectedEntry {
+			t.Fatalf("expected %+v, got %+v", expectedEntries, calendarEntries)
+		}
+	}
+
+	if _, err := svc.GetCalendarList(context.Background(), &oauth2.Token{AccessToken: "other-token"}); err == nil {
+		t.Fatal("expected an error when the Calendar API refuses the token")
+	}
+}
@@ -0,0 +1,78 @@
+package gauss
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	callbackParameterState = "state"
+	callbackParameterCode  = "code"
+	callbackParameterError = "error"
+	callbackFormMaxBytes   = 64 << 10
+)
+
+var (
+	// ErrMissingState indicates that the OAuth2 callback did not carry a state
+	// parameter.
+	ErrMissingState = errors.New("missing state parameter")
+	// ErrStateMismatch indicates that the state returned with an authorization
+	// code is not the one issued when the authorization started.
+	ErrStateMismatch = errors.New("state mismatch")
+	// ErrMissingCode indicates that the OAuth2 callback did not carry an
+	// authorization code.
+	ErrMissingCode = errors.New("missing authorization code")
+	// ErrAuthorizationFailed indicates that Google reported an error, such as
+	// access_denied, instead of issuing an authorization code.
+	ErrAuthorizationFailed = errors.New("authorization failed")
+)
+
+// ParseCallbackRequest extracts the authorization code and state from an
+// OAuth2 callback request. GET requests are read from the query string and
+// POST requests (response_mode=form_post) from the form body. The returned
+// error wraps ErrMissingState, ErrAuthorizationFailed or ErrMissingCode when
+// the corresponding validation fails, in that order; any other error means the
+// request body could not be parsed. The state is returned whenever present so
+// callers can still compare it when the code is missing.
+func ParseCallbackRequest(request *http.Request) (code string, state string, err error) {
+	callbackParameters, parametersError := readCallbackParameters(request)
+	if parametersError != nil {
+		return "", "", fmt.Errorf("failed to parse callback form: %w", parametersError)
+	}
+
+	state = callbackParameters.Get(callbackParameterState)
+	if state == "" {
+		return "", "", ErrMissingState
+	}
+
+	if providerError := callbackParameters.Get(callbackParameterError); providerError != "" {
+		return "", state, fmt.Errorf("%w: %s", ErrAuthorizationFailed, providerError)
+	}
+
+	code = callbackParameters.Get(callbackParameterCode)
+	if code == "" {
+		return "", state, ErrMissingCode
+	}
+
+	return code, state, nil
+}
+
+// isCallbackValidationError reports whether err is one of the sentinel errors
+// returned by ParseCallbackRequest for well-formed but incomplete callbacks.
+func isCallbackValidationError(err error) bool {
+	return errors.Is(err, ErrMissingState) || errors.Is(err, ErrMissingCode) || errors.Is(err, ErrAuthorizationFailed)
+}
+
+// readCallbackParameters returns the OAuth2 response parameters from the query
+// string for GET requests or from the form body for POST requests.
+func readCallbackParameters(request *http.Request) (url.Values, error) {
+	if request.Method != http.MethodPost {
+		return request.URL.Query(), nil
+	}
+	if parseError := request.ParseForm(); parseError != nil {
+		return nil, parseError
+	}
+	return request.PostForm, nil
+}
@@ -0,0 +1,75 @@
+package gauss
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseCallbackRequest(t *testing.T) {
+	testCases := []struct {
+		name      string
+		request   func() *http.Request
+		wantCode  string
+		wantState string
+		wantError error
+	}{
+		{
+			name: "query parameters",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/cb?state=s1&code=c1", nil)
+			},
+			wantCode:  "c1",
+			wantState: "s1",
+		},
+		{
+			name: "form post parameters",
+			request: func() *http.Request {
+				form := url.Values{"state": {"s1"}, "code": {"c1"}}
+				request := httptest.NewRequest(http.MethodPost, "/cb", strings.NewReader(form.Encode()))
+				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return request
+			},
+			wantCode:  "c1",
+			wantState: "s1",
+		},
+		{
+			name: "missing state",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/cb?code=c1", nil)
+			},
+			wantError: ErrMissingState,
+		},
+		{
+			name: "missing code",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/cb?state=s1", nil)
+			},
+			wantState: "s1",
+			wantError: ErrMissingCode,
+		},
+		{
+			name: "provider error",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/cb?state=s1&error=access_denied", nil)
+			},
+			wantState: "s1",
+			wantError: ErrAuthorizationFailed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			code, state, err := ParseCallbackRequest(testCase.request())
+			if !errors.Is(err, testCase.wantError) {
+				t.Fatalf("expected error %v, got %v", testCase.wantError, err)
+			}
+			if code != testCase.wantCode || state != testCase.wantState {
+				t.Fatalf("expected code %q state %q, got code %q state %q", testCase.wantCode, testCase.wantState, code, state)
+			}
+		})
+	}
+}
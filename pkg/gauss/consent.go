@@ -0,0 +1,133 @@
+package gauss
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+// ConsentMode selects the prompt Google shows when a login starts.
+type ConsentMode int
+
+const (
+	// ConsentAlways shows the consent screen on every login so Google always
+	// issues a refresh token. It is the default.
+	ConsentAlways ConsentMode = iota
+	// ConsentOnce shows the consent screen only when the session holds no
+	// token with a refresh token; otherwise the account chooser is shown.
+	ConsentOnce
+	// ConsentNever asks Google not to show any screen. When Google answers
+	// that interaction is required, the login is retried with the consent
+	// screen.
+	ConsentNever
+)
+
+const (
+	promptParameter     = "prompt"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account"
+	promptNone          = "none"
+)
+
+// interactionRequiredErrors lists the errors Google returns for prompt=none
+// when the user has to interact.
+var interactionRequiredErrors = map[string]struct{}{
+	"interaction_required":       {},
+	"login_required":             {},
+	"consent_required":           {},
+	"account_selection_required": {},
+}
+
+// WithConsentScreen returns a ServiceOption that selects the prompt shown by
+// Google when Login starts a flow. A login that Google answers without a
+// refresh token is retried once with the consent screen, except under
+// ConsentOnce and ConsentNever when the session already holds a refresh
+// token for the same account, which is kept instead. A login answered with
+// interaction_required under ConsentNever is also retried once with the
+// consent screen. NewService rejects unknown modes.
+func WithConsentScreen(mode ConsentMode) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.consentMode = mode
+	}
+}
+
+// validateConsentMode reports an error for modes other than the ConsentMode
+// constants.
+func (serviceInstance *Service) validateConsentMode() error {
+	if serviceInstance.consentMode < ConsentAlways || serviceInstance.consentMode > ConsentNever {
+		return fmt.Errorf("unsupported consent mode %d", serviceInstance.consentMode)
+	}
+	return nil
+}
+
+// loginPrompt returns the prompt for a login started with webSession. A
+// consent retry always uses the consent screen.
+func (serviceInstance *Service) loginPrompt(webSession *sessions.Session) string {
+	if _, consentRetry := webSession.Values[sessionKeyConsentRetry].(bool); consentRetry {
+		return promptConsent
+	}
+	switch serviceInstance.consentMode {
+	case ConsentOnce:
+		if sessionHasRefreshToken(webSession) {
+			return promptSelectAccount
+		}
+		return promptConsent
+	case ConsentNever:
+		return promptNone
+	default:
+		return promptConsent
+	}
+}
+
+// defaultPrompt returns the prompt used when no session is available.
+func (serviceInstance *Service) defaultPrompt() string {
+	if serviceInstance.consentMode == ConsentNever {
+		return promptNone
+	}
+	return promptConsent
+}
+
+// retriesInteraction reports whether a callback that failed with
+// providerError should be retried with the consent screen.
+func (serviceInstance *Service) retriesInteraction(providerError string) bool {
+	if serviceInstance.consentMode == ConsentAlways {
+		return false
+	}
+	_, interactionRequired := interactionRequiredErrors[providerError]
+	return interactionRequired
+}
+
+// keepsStoredRefreshToken reports whether a login that Google answers without
+// a refresh token keeps the one stored in webSession instead of retrying with
+// the consent screen. Google omits the refresh token when it shows no consent
+// screen, which is what ConsentOnce and ConsentNever ask for.
+func (serviceInstance *Service) keepsStoredRefreshToken(webSession *sessions.Session) bool {
+	return serviceInstance.consentMode != ConsentAlways && sessionHasRefreshToken(webSession)
+}
+
+// storedRefreshTokenFor returns the refresh token stored in webSession when
+// the session belongs to googleUser, and an empty string when the user chose
+// a different account. A nil googleUser, for logins without profile scopes,
+// matches the stored session.
+func storedRefreshTokenFor(webSession *sessions.Session, googleUser *GoogleUser) string {
+	if googleUser != nil {
+		if storedUserID, _ := webSession.Values[constants.SessionKeyUserID].(string); storedUserID != googleUser.ID {
+			return ""
+		}
+	}
+	storedTokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string)
+	var storedToken oauth2.Token
+	if json.Unmarshal([]byte(storedTokenJSON), &storedToken) != nil {
+		return ""
+	}
+	return storedToken.RefreshToken
+}
+
+// sessionHasRefreshToken reports whether webSession stores a token that
+// carries a refresh token.
+func sessionHasRefreshToken(webSession *sessions.Session) bool {
+	return storedRefreshTokenFor(webSession, nil) != ""
+}
@@ -0,0 +1,166 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+	"golang.org/x/oauth2"
+)
+
+// promptOf returns the prompt parameter of the Google redirect in rr.
+func promptOf(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	return location.Query().Get("prompt")
+}
+
+func TestConsentScreenPrompt(t *testing.T) {
+	testCases := []struct {
+		name           string
+		options        []ServiceOption
+		storedToken    *oauth2.Token
+		expectedPrompt string
+	}{
+		{name: "default", expectedPrompt: "consent"},
+		{name: "always", options: []ServiceOption{WithConsentScreen(ConsentAlways)}, storedToken: &oauth2.Token{AccessToken: "a", RefreshToken: "r"}, expectedPrompt: "consent"},
+		{name: "once without token", options: []ServiceOption{WithConsentScreen(ConsentOnce)}, expectedPrompt: "consent"},
+		{name: "once without refresh token", options: []ServiceOption{WithConsentScreen(ConsentOnce)}, storedToken: &oauth2.Token{AccessToken: "a"}, expectedPrompt: "consent"},
+		{name: "once with refresh token", options: []ServiceOption{WithConsentScreen(ConsentOnce)}, storedToken: &oauth2.Token{AccessToken: "a", RefreshToken: "r"}, expectedPrompt: "select_account"},
+		{name: "never", options: []ServiceOption{WithConsentScreen(ConsentNever)}, expectedPrompt: "none"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			req := httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil)
+			if testCase.storedToken != nil {
+				tokenBytes, _ := json.Marshal(testCase.storedToken)
+				initRR := httptest.NewRecorder()
+				webSession, _ := session.Store().Get(req, session.Name())
+				webSession.Values[constants.SessionKeyOAuthToken] = string(tokenBytes)
+				if err := webSession.Save(req, initRR); err != nil {
+					t.Fatal(err)
+				}
+				req = requestWithCookies(constants.GoogleAuthPath, lastCookies(initRR))
+			}
+			rr := httptest.NewRecorder()
+			h.Login(rr, req)
+			if prompt := promptOf(t, rr); prompt != testCase.expectedPrompt {
+				t.Fatalf("expected prompt %q, got %q", testCase.expectedPrompt, prompt)
+			}
+		})
+	}
+}
+
+func TestConsentNeverFallsBackToConsent(t *testing.T) {
+	h := newTestHandlers(t, WithConsentScreen(ConsentNever))
+
+	authStartRecorder := httptest.NewRecorder()
+	h.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath+"?next=%2Freports", nil))
+	if prompt := promptOf(t, authStartRecorder); prompt != "none" {
+		t.Fatalf("expected prompt none, got %q", prompt)
+	}
+
+	callbackRecorder := httptest.NewRecorder()
+	firstState, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+	h.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?error=interaction_required&state="+url.QueryEscape(firstState.Query().Get("state")), lastCookies(authStartRecorder)))
+	if prompt := promptOf(t, callbackRecorder); callbackRecorder.Code != http.StatusFound || prompt != "consent" {
+		t.Fatalf("expected a retry with prompt consent, got %d %q", callbackRecorder.Code, callbackRecorder.Header().Get("Location"))
+	}
+	if returnTo := sessionFromResponse(t, callbackRecorder)[sessionKeyReturnTo]; returnTo != "/reports" {
+		t.Fatalf("expected the return path to survive the retry, got %v", returnTo)
+	}
+
+	secondState, _ := url.Parse(callbackRecorder.Header().Get("Location"))
+	retryRecorder := httptest.NewRecorder()
+	h.Callback(retryRecorder, requestWithCookies(constants.CallbackPath+"?error=interaction_required&state="+url.QueryEscape(secondState.Query().Get("state")), lastCookies(callbackRecorder)))
+	assertErrorRedirect(t, retryRecorder, ErrCodeAuthorizationFailed)
+	if _, consentRetry := sessionFromResponse(t, retryRecorder)[sessionKeyConsentRetry]; consentRetry {
+		t.Fatal("expected the failed retry to clear the consent retry flag")
+	}
+}
+
+func TestConsentOnceKeepsStoredRefreshToken(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		secondUserID         string
+		expectedRefreshToken string
+	}{
+		{name: "same account", secondUserID: "42", expectedRefreshToken: "rtok"},
+		{name: "different account", secondUserID: "43", expectedRefreshToken: "rtok2"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithConsentScreen(ConsentOnce))
+			refreshToken, userID := "rtok", "42"
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					tokenResponse, _ := json.Marshal(map[string]string{"access_token": "abc", "token_type": "bearer", "refresh_token": refreshToken})
+					w.Write(tokenResponse)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(`{"id":"` + userID + `","email":"e@example.com","verified_email":true}`))
+				},
+			)
+			firstLogin := loginAsUser(t, h)
+
+			refreshToken, userID = "", testCase.secondUserID
+			authStartRecorder := httptest.NewRecorder()
+			h.Login(authStartRecorder, requestWithCookies(constants.GoogleAuthPath, lastCookies(firstLogin)))
+			if prompt := promptOf(t, authStartRecorder); prompt != "select_account" {
+				t.Fatalf("expected prompt select_account, got %q", prompt)
+			}
+			authorizationURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+			callbackRecorder := httptest.NewRecorder()
+			h.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?code=c2&state="+url.QueryEscape(authorizationURL.Query().Get("state")), lastCookies(authStartRecorder)))
+
+			if testCase.secondUserID != "42" {
+				if prompt := promptOf(t, callbackRecorder); callbackRecorder.Code != http.StatusFound || prompt != "consent" {
+					t.Fatalf("expected a retry with prompt consent, got %d %q", callbackRecorder.Code, callbackRecorder.Header().Get("Location"))
+				}
+				refreshToken = "rtok2"
+				retryURL, _ := url.Parse(callbackRecorder.Header().Get("Location"))
+				retryRecorder := httptest.NewRecorder()
+				h.Callback(retryRecorder, requestWithCookies(constants.CallbackPath+"?code=c3&state="+url.QueryEscape(retryURL.Query().Get("state")), lastCookies(callbackRecorder)))
+				callbackRecorder = retryRecorder
+			}
+			if location := callbackRecorder.Header().Get("Location"); location != "/dashboard" {
+				t.Fatalf("expected the login to complete, got %q", location)
+			}
+			completedSession := sessionFromResponse(t, callbackRecorder)
+			if _, consentRetry := completedSession[sessionKeyConsentRetry]; consentRetry {
+				t.Fatal("expected the completed login to clear the consent retry flag")
+			}
+			var storedToken oauth2.Token
+			storedTokenJSON, _ := completedSession[constants.SessionKeyOAuthToken].(string)
+			if err := json.Unmarshal([]byte(storedTokenJSON), &storedToken); err != nil || storedToken.RefreshToken != testCase.expectedRefreshToken {
+				t.Fatalf("expected refresh token %q, got %q (%v)", testCase.expectedRefreshToken, storedToken.RefreshToken, err)
+			}
+		})
+	}
+}
+
+func TestConsentAlwaysDoesNotRetryInteraction(t *testing.T) {
+	h := newTestHandlers(t)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?error=interaction_required&state=s123", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertErrorRedirect(t, rr, ErrCodeAuthorizationFailed)
+}
+
+func TestWithConsentScreenRejectsUnknownModes(t *testing.T) {
+	for _, mode := range []ConsentMode{-1, 3} {
+		if _, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithConsentScreen(mode)); err == nil {
+			t.Errorf("expected mode %d to be rejected", mode)
+		}
+	}
+}
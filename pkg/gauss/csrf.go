@@ -0,0 +1,165 @@
+package gauss
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+const (
+	// HeaderCSRFToken is the request header checked for the CSRF token.
+	HeaderCSRFToken = "X-CSRF-Token"
+	// FormFieldCSRFToken is the form field checked for the CSRF token when
+	// the header is absent.
+	FormFieldCSRFToken = "_csrf"
+
+	csrfTokenLabel = "gauss-csrf:"
+	// sessionKeyCSRFKey stores the random key, generated at login, that CSRF
+	// tokens of the session are derived from.
+	sessionKeyCSRFKey = "csrf_key"
+)
+
+// csrfTokenContextKey stores the CSRF token in a request context.
+type csrfTokenContextKey struct{}
+
+// WithCSRFProtection returns a ServiceOption that makes NewAuthMiddleware
+// guard authenticated requests against cross-site request forgery. The
+// middleware derives a token from the session ID with HMAC-SHA256 keyed by a
+// random key generated for the session at login, exposes it through
+// CSRFTokenFromContext for templates, and rejects POST, PUT, PATCH and DELETE
+// requests with 403 Forbidden unless they carry it in the X-CSRF-Token header
+// or the _csrf form field. The token changes on every login because the
+// session ID does.
+func WithCSRFProtection(enabled bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.csrfProtection = enabled
+	}
+}
+
+// CSRFTokenFromContext returns the CSRF token NewAuthMiddleware, with
+// WithCSRFProtection, or NewCSRFMiddleware stored in ctx, or an empty string.
+func CSRFTokenFromContext(ctx context.Context) string {
+	csrfToken, _ := ctx.Value(csrfTokenContextKey{}).(string)
+	return csrfToken
+}
+
+// NewCSRFMiddleware returns middleware that guards the routes it wraps
+// against cross-site request forgery, like WithCSRFProtection does for
+// NewAuthMiddleware, without requiring a session. Requests with a logged-in
+// session carry the session's token in their context for CSRFToken and
+// CSRFField; their POST, PUT, PATCH and DELETE requests are
+// rejected with 403 Forbidden unless they send the token in the X-CSRF-Token
+// header or the _csrf form field. Requests without a logged-in session pass
+// through unchanged, so it can wrap a whole mux.
+func NewCSRFMiddleware(serviceInstance *Service) func(http.Handler) http.Handler {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if _, authenticated := serviceInstance.sessionUser(request); !authenticated {
+				nextHandler.ServeHTTP(responseWriter, request)
+				return
+			}
+			protectedRequest, verified := serviceInstance.csrfProtected(responseWriter, request)
+			if !verified {
+				return
+			}
+			nextHandler.ServeHTTP(responseWriter, protectedRequest)
+		})
+	}
+}
+
+// CSRFToken returns the CSRF token of the session of request, as attached to
+// its context by NewCSRFMiddleware, or by NewAuthMiddleware with
+// WithCSRFProtection. It returns an empty string for requests that did not
+// pass through either or carry no logged-in session. Handlers holding the
+// Service can use Service.CSRFToken instead.
+func CSRFToken(request *http.Request) string {
+	return CSRFTokenFromContext(request.Context())
+}
+
+// CSRFField returns a hidden form input carrying the CSRF token of request,
+// for embedding in forms posted to protected routes, or an empty string when
+// the request has no token. Pass it to templates as data:
+//
+//	data := map[string]interface{}{"csrfField": gauss.CSRFField(request)}
+//	// <form method="post">{{ .csrfField }}<button>Save</button></form>
+func CSRFField(request *http.Request) template.HTML {
+	return csrfField(CSRFToken(request))
+}
+
+// CSRFToken returns the CSRF token for the session of request, or an empty
+// string when the request carries no logged-in session. Forms posting to
+// Disconnect, and to routes guarded by WithCSRFProtection or
+// NewCSRFMiddleware, submit it in the _csrf field.
+func (serviceInstance *Service) CSRFToken(request *http.Request) string {
+	return csrfToken(serviceInstance.webSession(request))
+}
+
+// CSRFField returns a hidden form input carrying the CSRF token for the
+// session of request, or an empty string when the request carries no
+// logged-in session. Template functions cannot reach the request, so pass the
+// field to the template as data:
+//
+//	data := map[string]interface{}{"csrfField": serviceInstance.CSRFField(request)}
+//	// <form method="post">{{ .csrfField }}<button>Save</button></form>
+func (serviceInstance *Service) CSRFField(request *http.Request) template.HTML {
+	return csrfField(serviceInstance.CSRFToken(request))
+}
+
+// csrfField returns a hidden form input carrying csrfToken, or an empty
+// string when csrfToken is empty.
+func csrfField(csrfToken string) template.HTML {
+	if csrfToken == "" {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + FormFieldCSRFToken + `" value="` + template.HTMLEscapeString(csrfToken) + `">`)
+}
+
+// csrfProtected verifies the CSRF token of a state-changing request and
+// returns request with the token in its context. It answers 403 Forbidden
+// and returns false when the token is missing or wrong.
+func (serviceInstance *Service) csrfProtected(responseWriter http.ResponseWriter, request *http.Request) (*http.Request, bool) {
+	expectedToken := serviceInstance.CSRFToken(request)
+	if !safeMethod(request.Method) {
+		submittedToken := request.Header.Get(HeaderCSRFToken)
+		if submittedToken == "" {
+			submittedToken = request.PostFormValue(FormFieldCSRFToken)
+		}
+		if expectedToken == "" || !hmac.Equal([]byte(submittedToken), []byte(expectedToken)) {
+			logRequestf(request, "Rejecting %s request without a valid CSRF token", request.Method)
+			http.Error(responseWriter, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return request, false
+		}
+	}
+	return request.WithContext(context.WithValue(request.Context(), csrfTokenContextKey{}, expectedToken)), true
+}
+
+// csrfToken returns the hex encoded HMAC-SHA256 of the identifier of
+// webSession keyed by its CSRF key, or an empty string when the session has
+// neither. Keeping the key in the session leaves tokens unaffected by
+// rotation of the OAuth client secret.
+func csrfToken(webSession *sessions.Session) string {
+	sessionIdentifier, _ := webSession.Values[constants.SessionKeySessionID].(string)
+	csrfKey, _ := webSession.Values[sessionKeyCSRFKey].(string)
+	if sessionIdentifier == "" || csrfKey == "" {
+		return ""
+	}
+	tokenMAC := hmac.New(sha256.New, []byte(csrfKey))
+	tokenMAC.Write([]byte(csrfTokenLabel + sessionIdentifier))
+	return hex.EncodeToString(tokenMAC.Sum(nil))
+}
+
+// safeMethod reports whether method is idempotent and therefore exempt from
+// CSRF checks.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	h := newTestHandlers(t, WithCSRFProtection(true))
+	useMockGoogleUser(t, h)
+	loginRecorder := loginAsUser(t, h)
+
+	var contextToken string
+	protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextToken = CSRFTokenFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	pageRecorder := httptest.NewRecorder()
+	pageRequest := requestWithCookies("/account", lastCookies(loginRecorder))
+	protected.ServeHTTP(pageRecorder, pageRequest)
+	if pageRecorder.Code != http.StatusOK || len(contextToken) != 64 {
+		t.Fatalf("expected a CSRF token in the context of a GET request, got %d %q", pageRecorder.Code, contextToken)
+	}
+	if contextToken != h.service.CSRFToken(pageRequest) {
+		t.Fatal("expected the context token to match Service.CSRFToken")
+	}
+	issuedToken := contextToken
+
+	testCases := []struct {
+		name           string
+		method         string
+		headerToken    string
+		formToken      string
+		expectedStatus int
+	}{
+		{name: "post without token", method: http.MethodPost, expectedStatus: http.StatusForbidden},
+		{name: "post with wrong header", method: http.MethodPost, headerToken: "forged", expectedStatus: http.StatusForbidden},
+		{name: "post with header", method: http.MethodPost, headerToken: issuedToken, expectedStatus: http.StatusOK},
+		{name: "post with form field", method: http.MethodPost, formToken: issuedToken, expectedStatus: http.StatusOK},
+		{name: "delete with header", method: http.MethodDelete, headerToken: issuedToken, expectedStatus: http.StatusOK},
+		{name: "put without token", method: http.MethodPut, expectedStatus: http.StatusForbidden},
+		{name: "patch without token", method: http.MethodPatch, expectedStatus: http.StatusForbidden},
+		{name: "options without token", method: http.MethodOptions, expectedStatus: http.StatusOK},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var body *strings.Reader
+			if testCase.formToken != "" {
+				body = strings.NewReader(url.Values{FormFieldCSRFToken: {testCase.formToken}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(testCase.method, "/account", body)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if testCase.headerToken != "" {
+				req.Header.Set(HeaderCSRFToken, testCase.headerToken)
+			}
+			for _, cookie := range lastCookies(loginRecorder) {
+				req.AddCookie(cookie)
+			}
+			rr := httptest.NewRecorder()
+			protected.ServeHTTP(rr, req)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenChangesWithSession(t *testing.T) {
+	h := newTestHandlers(t, WithCSRFProtection(true))
+	useMockGoogleUser(t, h)
+	firstCookies := lastCookies(loginAsUser(t, h))
+	firstToken := h.service.CSRFToken(requestWithCookies("/", firstCookies))
+	secondToken := h.service.CSRFToken(requestWithCookies("/", lastCookies(loginAsUser(t, h))))
+	if firstToken == "" || firstToken == secondToken {
+		t.Fatalf("expected different sessions to get different tokens, got %q and %q", firstToken, secondToken)
+	}
+	h.service.config.ClientSecret = "rotated"
+	if rotatedToken := h.service.CSRFToken(requestWithCookies("/", firstCookies)); rotatedToken != firstToken {
+		t.Fatal("expected rotating the client secret to keep the token")
+	}
+	if h.service.CSRFToken(httptest.NewRequest(http.MethodGet, "/", nil)) != "" {
+		t.Fatal("expected no token without a session")
+	}
+	if CSRFTokenFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()) != "" {
+		t.Fatal("expected no token outside the middleware")
+	}
+	if CSRFToken(requestWithCookies("/", firstCookies)) != "" || CSRFField(requestWithCookies("/", firstCookies)) != "" {
+		t.Fatal("expected no package-level token outside the middleware")
+	}
+}
+
+func TestCSRFProtectionDisabledByDefault(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	loginRecorder := loginAsUser(t, h)
+	protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/account", nil)
+	for _, cookie := range lastCookies(loginRecorder) {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected POST to pass without CSRF protection, got %d", rr.Code)
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	sessionCookies := lastCookies(loginAsUser(t, h))
+	otherSessionCookies := lastCookies(loginAsUser(t, h))
+	issuedToken := h.service.CSRFToken(requestWithCookies("/account", sessionCookies))
+	otherSessionToken := h.service.CSRFToken(requestWithCookies("/account", otherSessionCookies))
+	if issuedToken == "" || issuedToken == otherSessionToken {
+		t.Fatalf("expected distinct tokens per session, got %q and %q", issuedToken, otherSessionToken)
+	}
+
+	testCases := []struct {
+		name           string
+		method         string
+		cookies        []*http.Cookie
+		headerToken    string
+		formToken      string
+		expectedStatus int
+		expectedToken  string
+	}{
+		{name: "valid form token", method: http.MethodPost, cookies: sessionCookies, formToken: issuedToken, expectedStatus: http.StatusOK, expectedToken: issuedToken},
+		{name: "valid header token", method: http.MethodPut, cookies: sessionCookies, headerToken: issuedToken, expectedStatus: http.StatusOK, expectedToken: issuedToken},
+		{name: "missing token", method: http.MethodPost, cookies: sessionCookies, expectedStatus: http.StatusForbidden},
+		{name: "token from another session", method: http.MethodDelete, cookies: sessionCookies, headerToken: otherSessionToken, expectedStatus: http.StatusForbidden},
+		{name: "safe method", method: http.MethodGet, cookies: sessionCookies, expectedStatus: http.StatusOK, expectedToken: issuedToken},
+		{name: "unauthenticated post", method: http.MethodPost, expectedStatus: http.StatusOK},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			seenToken := ""
+			seenField := ""
+			protected := NewCSRFMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenToken = CSRFToken(r)
+				seenField = string(CSRFField(r))
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(testCase.method, "/account", strings.NewReader(url.Values{FormFieldCSRFToken: {testCase.formToken}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if testCase.headerToken != "" {
+				req.Header.Set(HeaderCSRFToken, testCase.headerToken)
+			}
+			for _, cookie := range testCase.cookies {
+				req.AddCookie(cookie)
+			}
+			rr := httptest.NewRecorder()
+			protected.ServeHTTP(rr, req)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if seenToken != testCase.expectedToken {
+				t.Fatalf("expected CSRFToken %q, got %q", testCase.expectedToken, seenToken)
+			}
+			expectedField := ""
+			if testCase.expectedToken != "" {
+				expectedField = `<input type="hidden" name="_csrf" value="` + testCase.expectedToken + `">`
+			}
+			if seenField != expectedField {
+				t.Fatalf("expected CSRFField %q, got %q", expectedField, seenField)
+			}
+		})
+	}
+}
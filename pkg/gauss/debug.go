@@ -0,0 +1,90 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Sources of the base URL reported by DebugHandler.
+const (
+	// baseURLSourceStatic is the public base URL passed to NewService.
+	baseURLSourceStatic = "static"
+	// baseURLSourceDynamic is the scheme and host of the request, including
+	// forwarded headers, applied to the public base URL.
+	baseURLSourceDynamic = "dynamic"
+	// baseURLSourceDetected is the base URL pinned by WithBaseURLDetection.
+	baseURLSourceDetected = "detected"
+
+	headerPrefixXForwarded = "X-Forwarded-"
+)
+
+// redirectDiagnostics is the document served by DebugHandler.
+type redirectDiagnostics struct {
+	ForwardedHeaders  map[string]string `json:"forwarded_headers"`
+	RequestHost       string            `json:"request_host"`
+	RequestTLS        bool              `json:"request_tls"`
+	ResolvedScheme    string            `json:"resolved_scheme"`
+	ResolvedHost      string            `json:"resolved_host"`
+	ResolvedPort      string            `json:"resolved_port"`
+	ConfiguredBaseURL string            `json:"configured_base_url"`
+	BaseURL           string            `json:"base_url"`
+	BaseURLSource     string            `json:"base_url_source"`
+	CallbackURL       string            `json:"callback_url"`
+}
+
+// WithDebugEndpoint returns a ServiceOption that makes RegisterRoutes serve
+// DebugHandler at constants.DebugPath. Enable it only while diagnosing a
+// deployment.
+func WithDebugEndpoint(enabled bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.debugEndpoint = enabled
+	}
+}
+
+// DebugHandler returns a handler that explains how the OAuth2 redirect URI is
+// derived for the request, to diagnose redirect_uri_mismatch errors behind
+// proxies. The JSON response lists the Forwarded and X-Forwarded-* headers
+// received, the scheme, host and port GAuss resolved from them, the base URL
+// and whether it came from the configured base URL ("static"), the request
+// ("dynamic") or WithBaseURLDetection ("detected"), and the callback URL sent
+// to Google. It never includes credentials, tokens or session values.
+func (handlersInstance *Handlers) DebugHandler() http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		setSecurityHeaders(responseWriter)
+		serviceInstance := handlersInstance.service
+		diagnostics := redirectDiagnostics{
+			ForwardedHeaders: forwardedHeaders(request),
+			RequestHost:      request.Host,
+			RequestTLS:       request.TLS != nil,
+			ResolvedScheme:   serviceInstance.resolveScheme(request),
+			ResolvedHost:     serviceInstance.resolveHost(request),
+			ResolvedPort:     serviceInstance.resolvePort(request),
+			CallbackURL:      serviceInstance.redirectURLForRequest(request),
+		}
+		if serviceInstance.publicBaseURL != nil {
+			diagnostics.ConfiguredBaseURL = serviceInstance.publicBaseURL.String()
+		}
+		baseURL, baseURLSource := serviceInstance.resolveBaseURL(request)
+		if baseURL != nil {
+			diagnostics.BaseURL = baseURL.String()
+		}
+		diagnostics.BaseURLSource = baseURLSource
+		responseWriter.Header().Set(headerContentType, "application/json")
+		if encodeError := json.NewEncoder(responseWriter).Encode(diagnostics); encodeError != nil {
+			logRequestf(request, "Failed to write the redirect diagnostics: %v", encodeError)
+		}
+	}
+}
+
+// forwardedHeaders returns the Forwarded and X-Forwarded-* headers of request,
+// joining repeated headers with commas.
+func forwardedHeaders(request *http.Request) map[string]string {
+	receivedHeaders := make(map[string]string)
+	for headerName, headerValues := range request.Header {
+		if headerName == headerForwarded || strings.HasPrefix(headerName, headerPrefixXForwarded) {
+			receivedHeaders[headerName] = strings.Join(headerValues, ", ")
+		}
+	}
+	return receivedHeaders
+}
@@ -0,0 +1,115 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestDebugEndpointIsOptIn(t *testing.T) {
+	h := newTestHandlers(t)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.DebugPath, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without WithDebugEndpoint, got %d", rr.Code)
+	}
+}
+
+func TestDebugEndpointReportsRedirectResolution(t *testing.T) {
+	testCases := []struct {
+		name                string
+		options             []ServiceOption
+		headers             map[string]string
+		expectedScheme      string
+		expectedHost        string
+		expectedSource      string
+		expectedCallbackURL string
+	}{
+		{
+			name:                "direct request",
+			expectedScheme:      "http",
+			expectedHost:        "app.internal:8080",
+			expectedSource:      baseURLSourceDynamic,
+			expectedCallbackURL: "http://app.internal:8080" + constants.CallbackPath,
+		},
+		{
+			name:                "x-forwarded headers",
+			headers:             map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "auth.example.com", "X-Forwarded-Port": "8443"},
+			expectedScheme:      "https",
+			expectedHost:        "auth.example.com",
+			expectedSource:      baseURLSourceDynamic,
+			expectedCallbackURL: "https://auth.example.com:8443" + constants.CallbackPath,
+		},
+		{
+			name:                "forwarded header",
+			headers:             map[string]string{"Forwarded": "for=10.0.0.1;proto=https;host=login.example.com", "X-Forwarded-Host": "ignored.example.com"},
+			expectedScheme:      "https",
+			expectedHost:        "login.example.com",
+			expectedSource:      baseURLSourceDynamic,
+			expectedCallbackURL: "https://login.example.com" + constants.CallbackPath,
+		},
+		{
+			name:                "detected base URL",
+			options:             []ServiceOption{WithBaseURLDetection()},
+			headers:             map[string]string{"X-Forwarded-Proto": "https"},
+			expectedScheme:      "https",
+			expectedHost:        "app.internal:8080",
+			expectedSource:      baseURLSourceDetected,
+			expectedCallbackURL: "https://app.internal:8080" + constants.CallbackPath,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, append(testCase.options, WithDebugEndpoint(true))...)
+			req := httptest.NewRequest(http.MethodGet, constants.DebugPath, nil)
+			req.Host = "app.internal:8080"
+			for headerName, headerValue := range testCase.headers {
+				req.Header.Set(headerName, headerValue)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			var diagnostics redirectDiagnostics
+			if err := json.Unmarshal(rr.Body.Bytes(), &diagnostics); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if diagnostics.ResolvedScheme != testCase.expectedScheme || diagnostics.ResolvedHost != testCase.expectedHost {
+				t.Fatalf("expected %s://%s, got %s://%s", testCase.expectedScheme, testCase.expectedHost, diagnostics.ResolvedScheme, diagnostics.ResolvedHost)
+			}
+			if diagnostics.BaseURLSource != testCase.expectedSource {
+				t.Fatalf("expected source %s, got %s", testCase.expectedSource, diagnostics.BaseURLSource)
+			}
+			if diagnostics.CallbackURL != testCase.expectedCallbackURL {
+				t.Fatalf("expected callback URL %s, got %s", testCase.expectedCallbackURL, diagnostics.CallbackURL)
+			}
+			if expectedCallbackURL := h.service.redirectURLForRequest(req); diagnostics.CallbackURL != expectedCallbackURL {
+				t.Fatalf("expected the callback URL used by Login %s, got %s", expectedCallbackURL, diagnostics.CallbackURL)
+			}
+			for headerName, headerValue := range testCase.headers {
+				if diagnostics.ForwardedHeaders[headerName] != headerValue {
+					t.Fatalf("expected %s: %s to be reported, got %q", headerName, headerValue, diagnostics.ForwardedHeaders[headerName])
+				}
+			}
+		})
+	}
+}
+
+func TestDebugEndpointOmitsSecrets(t *testing.T) {
+	h := newTestHandlers(t, WithDebugEndpoint(true))
+	req := httptest.NewRequest(http.MethodGet, constants.DebugPath, nil)
+	req.Header.Set("Authorization", "Bearer private")
+	req.AddCookie(&http.Cookie{Name: constants.SessionName, Value: "private"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	for _, secret := range []string{"private", h.service.config.ClientSecret} {
+		if strings.Contains(rr.Body.String(), secret) {
+			t.Fatalf("expected %q to be omitted, got %s", secret, rr.Body.String())
+		}
+	}
+}
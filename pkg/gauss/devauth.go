@@ -0,0 +1,63 @@
+package gauss
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DevFakeAuthEnvironmentVariable must be set to "1" for WithDevFakeAuth to
+	// take effect.
+	DevFakeAuthEnvironmentVariable = "GAUSS_INSECURE_DEV"
+	devFakeAuthEnabledValue        = "1"
+	devFakeAccessToken             = "gauss-dev-fake-access-token"
+)
+
+// WithDevFakeAuth returns a ServiceOption that replaces Google with a fixed
+// fake user for local development: the auth start endpoint and the callback
+// immediately sign fakeUser in and redirect to the post-login URL, without
+// contacting Google. The stored token is a placeholder that Google APIs
+// reject. NewService refuses the option with an error unless the
+// GAUSS_INSECURE_DEV environment variable is "1", and logs a warning when it
+// is active. Never enable it in production.
+func WithDevFakeAuth(fakeUser GoogleUser) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.devFakeUser = &fakeUser
+	}
+}
+
+// checkDevFakeAuth rejects WithDevFakeAuth unless the insecure development
+// environment variable is set, and announces the fake mode when it is.
+func (serviceInstance *Service) checkDevFakeAuth() error {
+	if serviceInstance.devFakeUser == nil {
+		return nil
+	}
+	if os.Getenv(DevFakeAuthEnvironmentVariable) != devFakeAuthEnabledValue {
+		return errors.New("WithDevFakeAuth requires the " + DevFakeAuthEnvironmentVariable + "=1 environment variable")
+	}
+	if serviceInstance.devFakeUser.Email == "" {
+		return errors.New("WithDevFakeAuth requires a user with an email address")
+	}
+	log.Printf("WARNING: INSECURE development fake authentication is enabled (%s=1); every sign-in is accepted as the configured user without contacting Google. Never use this in production.",
+		DevFakeAuthEnvironmentVariable)
+	return nil
+}
+
+// devFakeLogin signs the configured fake user in and redirects to the
+// post-login URL, honouring the return-to and remember-me choices of request.
+func (handlersInstance *Handlers) devFakeLogin(responseWriter http.ResponseWriter, request *http.Request) {
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	handlersInstance.service.rememberReturnTo(webSession, request)
+	handlersInstance.service.rememberLoginChoice(webSession, request)
+	fakeUser := *handlersInstance.service.devFakeUser
+	logRequestf(request, "Development fake authentication is active; signing in the configured user")
+	handlersInstance.service.metrics.LoginStarted()
+	fakeToken := &oauth2.Token{AccessToken: devFakeAccessToken, TokenType: "Bearer"}
+	handlersInstance.signIn(responseWriter, request, webSession, &fakeUser, fakeToken, func(authError *AuthError) {
+		handlersInstance.failLogin(responseWriter, request, authError)
+	})
+}
@@ -0,0 +1,94 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+var testDevFakeUser = GoogleUser{ID: "dev-1", Email: "dev@example.com", Name: "Dev User", VerifiedEmail: true}
+
+func TestWithDevFakeAuthRequiresEnvironmentVariable(t *testing.T) {
+	testCases := []struct {
+		name        string
+		environment string
+		fakeUser    GoogleUser
+		expectError bool
+	}{
+		{name: "unset", environment: "", fakeUser: testDevFakeUser, expectError: true},
+		{name: "other value", environment: "true", fakeUser: testDevFakeUser, expectError: true},
+		{name: "missing email", environment: "1", fakeUser: GoogleUser{ID: "dev-1"}, expectError: true},
+		{name: "enabled", environment: "1", fakeUser: testDevFakeUser, expectError: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv(DevFakeAuthEnvironmentVariable, testCase.environment)
+			_, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", ScopeStrings(DefaultScopes), "", WithDevFakeAuth(testCase.fakeUser))
+			if testCase.expectError && err == nil {
+				t.Fatal("expected NewService to refuse fake authentication")
+			}
+			if !testCase.expectError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDevFakeAuthSignsInWithoutGoogle(t *testing.T) {
+	t.Setenv(DevFakeAuthEnvironmentVariable, "1")
+	testCases := []struct {
+		name   string
+		target string
+	}{
+		{name: "auth start", target: constants.GoogleAuthPath},
+		{name: "callback", target: constants.CallbackPath + "?state=s1&code=c1"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithDevFakeAuth(testDevFakeUser))
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.target, nil))
+			if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "/dashboard" {
+				t.Fatalf("expected a redirect to /dashboard, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+			}
+
+			followUp := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+			for _, cookie := range recorder.Result().Cookies() {
+				followUp.AddCookie(cookie)
+			}
+			webSession, _ := session.Store().Get(followUp, session.Name())
+			if email := webSession.Values[constants.SessionKeyUserEmail]; email != testDevFakeUser.Email {
+				t.Fatalf("expected the fake user in the session, got %v", email)
+			}
+			if storedToken, _ := webSession.Values[constants.SessionKeyOAuthToken].(string); !strings.Contains(storedToken, devFakeAccessToken) {
+				t.Fatalf("expected the fake token in the session, got %q", storedToken)
+			}
+		})
+	}
+}
+
+func TestDevFakeAuthLogoutClearsSession(t *testing.T) {
+	t.Setenv(DevFakeAuthEnvironmentVariable, "1")
+	h := newTestHandlers(t, WithDevFakeAuth(testDevFakeUser))
+	loginRecorder := httptest.NewRecorder()
+	h.ServeHTTP(loginRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+
+	logoutRequest := httptest.NewRequest(http.MethodGet, constants.LogoutPath, nil)
+	for _, cookie := range loginRecorder.Result().Cookies() {
+		logoutRequest.AddCookie(cookie)
+	}
+	logoutRecorder := httptest.NewRecorder()
+	h.ServeHTTP(logoutRecorder, logoutRequest)
+	if location := logoutRecorder.Header().Get("Location"); location != constants.LoginPath {
+		t.Fatalf("expected logout to redirect to the login page, got %q", location)
+	}
+	for _, cookie := range logoutRecorder.Result().Cookies() {
+		if cookie.Name == session.Name() && cookie.MaxAge >= 0 {
+			t.Fatalf("expected the session cookie to be deleted, got %+v", cookie)
+		}
+	}
+}
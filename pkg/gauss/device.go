@@ -0,0 +1,194 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	deviceErrorExpiredToken         = "expired_token"
+	deviceErrorAccessDenied         = "access_denied"
+	defaultDevicePollInterval       = 5 * time.Second
+	devicePageRefreshSeconds        = 1
+	deviceTemplateName              = "device.html"
+	sessionKeyDeviceCode            = "oauth_device_code"
+	sessionKeyDeviceUserCode        = "oauth_device_user_code"
+	sessionKeyDeviceVerificationURL = "oauth_device_verification_url"
+	sessionKeyDeviceInterval        = "oauth_device_interval"
+	sessionKeyDeviceExpiry          = "oauth_device_expiry"
+)
+
+var (
+	// ErrDeviceCodeExpired is returned by PollDeviceToken when the user did
+	// not approve the request before the device code expired.
+	ErrDeviceCodeExpired = errors.New("device code expired")
+	// ErrDeviceAccessDenied is returned by PollDeviceToken when the user
+	// declined the request.
+	ErrDeviceAccessDenied = errors.New("device authorization denied")
+	// errDeviceAuthorizationPending is returned by pollDeviceTokenOnce while
+	// the user has not answered yet.
+	errDeviceAuthorizationPending = errors.New("device authorization pending")
+)
+
+// deviceTemplate renders the page that shows the user code on kiosk-style
+// displays.
+var deviceTemplate = template.Must(template.ParseFS(templatesFileSystem, "templates/"+deviceTemplateName))
+
+// StartDeviceFlow begins Google's device authorization flow for devices
+// without a browser, such as TVs and command-line tools, with the oauth2
+// package's Config.DeviceAuth. Show the returned UserCode and VerificationURI
+// to the user and pass the response to PollDeviceToken. The OAuth client must
+// be of the "TVs and Limited Input devices" type.
+func (serviceInstance *Service) StartDeviceFlow(ctx context.Context) (*oauth2.DeviceAuthResponse, error) {
+	deviceAuthorization, deviceAuthError := serviceInstance.config.DeviceAuth(serviceInstance.oauthContext(ctx))
+	if deviceAuthError != nil {
+		return nil, fmt.Errorf("failed to request device code: %w", deviceAuthError)
+	}
+	if deviceAuthorization.DeviceCode == "" {
+		return nil, errors.New("device code response is missing device_code")
+	}
+	return deviceAuthorization, nil
+}
+
+// PollDeviceToken polls the token endpoint with the oauth2 package's
+// Config.DeviceAccessToken until the user approves or denies
+// deviceAuthorization, waiting the interval Google asked for and backing off
+// on slow_down. It returns ErrDeviceCodeExpired or ErrDeviceAccessDenied for
+// the terminal answers and ctx.Err() when ctx is done.
+func (serviceInstance *Service) PollDeviceToken(ctx context.Context, deviceAuthorization *oauth2.DeviceAuthResponse) (*oauth2.Token, error) {
+	oauthToken, tokenError := serviceInstance.config.DeviceAccessToken(serviceInstance.oauthContext(ctx), deviceAuthorization)
+	if tokenError == nil {
+		return oauthToken, nil
+	}
+	var retrieveError *oauth2.RetrieveError
+	if errors.As(tokenError, &retrieveError) {
+		switch retrieveError.ErrorCode {
+		case deviceErrorExpiredToken:
+			return nil, fmt.Errorf("%w: %w", ErrDeviceCodeExpired, tokenError)
+		case deviceErrorAccessDenied:
+			return nil, fmt.Errorf("%w: %w", ErrDeviceAccessDenied, tokenError)
+		}
+		return nil, fmt.Errorf("device token request failed: %w", tokenError)
+	}
+	if ctx.Err() == nil && errors.Is(tokenError, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrDeviceCodeExpired, tokenError)
+	}
+	return nil, tokenError
+}
+
+// pollDeviceTokenOnce makes a single PollDeviceToken poll, which waits one
+// poll interval first, and returns errDeviceAuthorizationPending when the
+// user has not answered by then.
+func (serviceInstance *Service) pollDeviceTokenOnce(ctx context.Context, deviceAuthorization *oauth2.DeviceAuthResponse) (*oauth2.Token, error) {
+	pollInterval := time.Duration(deviceAuthorization.Interval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultDevicePollInterval
+	}
+	pollContext, cancelPoll := context.WithTimeout(ctx, pollInterval*3/2)
+	defer cancelPoll()
+	oauthToken, pollError := serviceInstance.PollDeviceToken(pollContext, deviceAuthorization)
+	if pollError != nil && ctx.Err() == nil && errors.Is(pollError, context.DeadlineExceeded) && !errors.Is(pollError, ErrDeviceCodeExpired) {
+		return nil, errDeviceAuthorizationPending
+	}
+	return oauthToken, pollError
+}
+
+// DeviceLoginHandler returns an optional handler for kiosk-style apps that
+// sign in through the device flow. The first visit starts a device
+// authorization and renders the user code; the page refreshes itself and each
+// refresh polls Google once, after waiting the poll interval Google asked
+// for. When the user approves, the session is established exactly as after a
+// browser login. Mount it at a path of your choice; it is not installed by
+// RegisterRoutes.
+func (handlersInstance *Handlers) DeviceLoginHandler() http.Handler {
+	return withSecurityHeaders(http.HandlerFunc(handlersInstance.deviceLogin))
+}
+
+// deviceLogin serves DeviceLoginHandler.
+func (handlersInstance *Handlers) deviceLogin(responseWriter http.ResponseWriter, request *http.Request) {
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	deviceCode, _ := webSession.Values[sessionKeyDeviceCode].(string)
+	if deviceCode == "" {
+		handlersInstance.startDeviceLogin(responseWriter, request)
+		return
+	}
+
+	clearDeviceLogin := func() {
+		for _, sessionKey := range []string{sessionKeyDeviceCode, sessionKeyDeviceUserCode, sessionKeyDeviceVerificationURL, sessionKeyDeviceInterval, sessionKeyDeviceExpiry} {
+			delete(webSession.Values, sessionKey)
+		}
+	}
+	failDeviceLogin := func(authError *AuthError) {
+		clearDeviceLogin()
+		if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+			logRequestf(request, "Failed to clear device authorization: %v", sessionSaveError)
+		}
+		handlersInstance.failLogin(responseWriter, request, authError)
+	}
+
+	pollSeconds, _ := webSession.Values[sessionKeyDeviceInterval].(int64)
+	expiryUnixTime, _ := webSession.Values[sessionKeyDeviceExpiry].(int64)
+	deviceAuthorization := &oauth2.DeviceAuthResponse{DeviceCode: deviceCode, Interval: pollSeconds}
+	if expiryUnixTime > 0 {
+		deviceAuthorization.Expiry = time.Unix(expiryUnixTime, 0)
+	}
+	oauthToken, pollError := handlersInstance.service.pollDeviceTokenOnce(request.Context(), deviceAuthorization)
+	switch {
+	case pollError == nil:
+		clearDeviceLogin()
+		handlersInstance.completeLogin(responseWriter, request, webSession, oauthToken, false, failDeviceLogin)
+	case errors.Is(pollError, errDeviceAuthorizationPending):
+		userCode, _ := webSession.Values[sessionKeyDeviceUserCode].(string)
+		verificationURL, _ := webSession.Values[sessionKeyDeviceVerificationURL].(string)
+		handlersInstance.service.renderDevicePage(responseWriter, userCode, verificationURL)
+	case request.Context().Err() != nil:
+		logRequestf(request, "Device authorization poll cancelled: %v", pollError)
+	default:
+		failDeviceLogin(newAuthError(ErrCodeDeviceAuthorization, "Device authorization failed", pollError))
+	}
+}
+
+// startDeviceLogin requests a new device code, remembers it in the session and
+// renders the user code page.
+func (handlersInstance *Handlers) startDeviceLogin(responseWriter http.ResponseWriter, request *http.Request) {
+	deviceAuthorization, startError := handlersInstance.service.StartDeviceFlow(request.Context())
+	if startError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeDeviceAuthorization, "Failed to start device authorization", startError))
+		return
+	}
+
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	webSession.Values[sessionKeyDeviceCode] = deviceAuthorization.DeviceCode
+	webSession.Values[sessionKeyDeviceUserCode] = deviceAuthorization.UserCode
+	webSession.Values[sessionKeyDeviceVerificationURL] = deviceAuthorization.VerificationURI
+	webSession.Values[sessionKeyDeviceInterval] = deviceAuthorization.Interval
+	if !deviceAuthorization.Expiry.IsZero() {
+		webSession.Values[sessionKeyDeviceExpiry] = deviceAuthorization.Expiry.Unix()
+	}
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to save device authorization", sessionSaveError))
+		return
+	}
+	handlersInstance.service.renderDevicePage(responseWriter, deviceAuthorization.UserCode, deviceAuthorization.VerificationURI)
+}
+
+// renderDevicePage writes the user code page, which must not be cached.
+func (serviceInstance *Service) renderDevicePage(responseWriter http.ResponseWriter, userCode string, verificationURL string) {
+	serviceInstance.setPageSecurityHeaders(responseWriter)
+	responseWriter.Header().Set(headerContentType, contentTypeHTMLUTF8)
+	responseWriter.Header().Set(headerCacheControl, cacheControlNoStore)
+	templateData := map[string]interface{}{
+		"userCode":        userCode,
+		"verificationURL": verificationURL,
+		"refreshSeconds":  devicePageRefreshSeconds,
+	}
+	if executeError := deviceTemplate.Execute(responseWriter, templateData); executeError != nil {
+		http.Error(responseWriter, executeError.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,211 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+	"golang.org/x/oauth2"
+)
+
+const deviceAuthorizationJSON = `{"device_code":"dev-1","user_code":"ABCD-EFGH","verification_url":"https://www.google.com/device","expires_in":1800,"interval":1}`
+
+// deviceTokenSequence returns a token handler that answers with each response
+// in turn and repeats the last one.
+func deviceTokenSequence(t *testing.T, responses ...string) http.HandlerFunc {
+	t.Helper()
+	requestCount := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse token request: %v", err)
+		}
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" || r.PostForm.Get("device_code") != "dev-1" {
+			t.Errorf("unexpected token request %v", r.PostForm)
+		}
+		response := responses[len(responses)-1]
+		if requestCount < len(responses) {
+			response = responses[requestCount]
+		}
+		requestCount++
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(response, `"error"`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		io.WriteString(w, response)
+	}
+}
+
+// useMockDeviceGoogle points h at a mock Google serving the device code
+// endpoint, tokenHandler and a userinfo endpoint.
+func useMockDeviceGoogle(t *testing.T, h *Handlers, tokenHandler http.HandlerFunc) {
+	t.Helper()
+	useMockGoogleHandlers(t, h, tokenHandler, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"verified_email": true, "email": "e@example.com", "name": "tester"})
+	})
+	deviceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != "id" {
+			t.Errorf("unexpected device code request %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, deviceAuthorizationJSON)
+	}))
+	t.Cleanup(deviceServer.Close)
+	WithGoogleEndpoints(GoogleEndpoints{DeviceAuthURL: deviceServer.URL})(h.service)
+}
+
+func TestDeviceFlowPollsUntilToken(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockDeviceGoogle(t, h, deviceTokenSequence(t,
+		`{"error":"authorization_pending"}`,
+		`{"access_token":"abc","token_type":"Bearer","refresh_token":"rtok","expires_in":3600,"id_token":"idt"}`,
+	))
+
+	deviceAuthorization, err := h.service.StartDeviceFlow(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceFlow error: %v", err)
+	}
+	if deviceAuthorization.UserCode != "ABCD-EFGH" || deviceAuthorization.VerificationURI != "https://www.google.com/device" || deviceAuthorization.Interval != 1 {
+		t.Fatalf("unexpected device authorization %+v", deviceAuthorization)
+	}
+
+	token, err := h.service.PollDeviceToken(context.Background(), deviceAuthorization)
+	if err != nil {
+		t.Fatalf("PollDeviceToken error: %v", err)
+	}
+	if token.AccessToken != "abc" || token.RefreshToken != "rtok" || token.Extra("id_token") != "idt" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+}
+
+func TestDeviceFlowTerminalErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		response  string
+		wantError error
+	}{
+		{name: "expired", response: `{"error":"expired_token"}`, wantError: ErrDeviceCodeExpired},
+		{name: "denied", response: `{"error":"access_denied"}`, wantError: ErrDeviceAccessDenied},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			useMockDeviceGoogle(t, h, deviceTokenSequence(t, testCase.response))
+
+			deviceAuthorization := &oauth2.DeviceAuthResponse{DeviceCode: "dev-1", Interval: 1}
+			if _, err := h.service.PollDeviceToken(context.Background(), deviceAuthorization); !errors.Is(err, testCase.wantError) {
+				t.Fatalf("expected %v, got %v", testCase.wantError, err)
+			}
+		})
+	}
+}
+
+func TestDeviceFlowStopsWhenContextIsCancelled(t *testing.T) {
+	h := newTestHandlers(t)
+	cancelledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.service.PollDeviceToken(cancelledContext, &oauth2.DeviceAuthResponse{DeviceCode: "dev-1"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDeviceFlowReportsExpiredDeviceCode(t *testing.T) {
+	h := newTestHandlers(t)
+	deviceAuthorization := &oauth2.DeviceAuthResponse{DeviceCode: "dev-1", Expiry: time.Now().Add(-time.Second)}
+	if _, err := h.service.PollDeviceToken(context.Background(), deviceAuthorization); !errors.Is(err, ErrDeviceCodeExpired) {
+		t.Fatalf("expected ErrDeviceCodeExpired, got %v", err)
+	}
+}
+
+func TestDeviceLoginHandlerCompletesLogin(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockDeviceGoogle(t, h, deviceTokenSequence(t,
+		`{"error":"authorization_pending"}`,
+		`{"access_token":"abc","token_type":"Bearer","refresh_token":"rtok","expires_in":3600}`,
+	))
+	deviceHandler := h.DeviceLoginHandler()
+
+	startRecorder := httptest.NewRecorder()
+	deviceHandler.ServeHTTP(startRecorder, httptest.NewRequest(http.MethodGet, "/device", nil))
+	if !strings.Contains(startRecorder.Body.String(), "ABCD-EFGH") || !strings.Contains(startRecorder.Body.String(), `content="1"`) {
+		t.Fatalf("expected the user code page, got %s", startRecorder.Body.String())
+	}
+
+	pendingRequest := httptest.NewRequest(http.MethodGet, "/device", nil)
+	for _, cookie := range lastCookies(startRecorder) {
+		pendingRequest.AddCookie(cookie)
+	}
+	pendingRecorder := httptest.NewRecorder()
+	deviceHandler.ServeHTTP(pendingRecorder, pendingRequest)
+	if !strings.Contains(pendingRecorder.Body.String(), "ABCD-EFGH") {
+		t.Fatal("expected the user code page while authorization is pending")
+	}
+
+	approvedRequest := httptest.NewRequest(http.MethodGet, "/device", nil)
+	for _, cookie := range lastCookies(startRecorder) {
+		approvedRequest.AddCookie(cookie)
+	}
+	approvedRecorder := httptest.NewRecorder()
+	deviceHandler.ServeHTTP(approvedRecorder, approvedRequest)
+	if location := approvedRecorder.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %q", location)
+	}
+	sessionValues := sessionFromResponse(t, approvedRecorder)
+	if sessionValues[constants.SessionKeyUserEmail] != "e@example.com" {
+		t.Fatal("expected the user to be stored in the session")
+	}
+	if _, present := sessionValues[sessionKeyDeviceCode]; present {
+		t.Fatal("expected the device code to be cleared")
+	}
+}
+
+func TestDeviceLoginHandlerReportsExpiredCode(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockDeviceGoogle(t, h, deviceTokenSequence(t, `{"error":"expired_token"}`))
+	deviceHandler := h.DeviceLoginHandler()
+
+	startRecorder := httptest.NewRecorder()
+	deviceHandler.ServeHTTP(startRecorder, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	pollRequest := httptest.NewRequest(http.MethodGet, "/device", nil)
+	for _, cookie := range lastCookies(startRecorder) {
+		pollRequest.AddCookie(cookie)
+	}
+	pollRecorder := httptest.NewRecorder()
+	deviceHandler.ServeHTTP(pollRecorder, pollRequest)
+	assertErrorRedirect(t, pollRecorder, ErrCodeDeviceAuthorization)
+}
+
+func TestStartDeviceFlowRequestsConfiguredScopes(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", ScopeStrings([]Scope{ScopeOpenID, ScopeEmail}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestedScopes := ""
+	deviceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedScopes = r.PostFormValue("scope")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, deviceAuthorizationJSON)
+	}))
+	t.Cleanup(deviceServer.Close)
+	WithGoogleEndpoints(GoogleEndpoints{DeviceAuthURL: deviceServer.URL})(svc)
+
+	deviceAuthorization, err := svc.StartDeviceFlow(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceFlow error: %v", err)
+	}
+	if deviceAuthorization.UserCode != "ABCD-EFGH" || deviceAuthorization.VerificationURI != "https://www.google.com/device" || deviceAuthorization.Interval != 1 {
+		t.Fatalf("unexpected device authorization %+v", deviceAuthorization)
+	}
+	if requestedScopes != "openid email" {
+		t.Fatalf("expected the openid and email scopes, got %q", requestedScopes)
+	}
+}
@@ -0,0 +1,130 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+const revocationTokenParameter = "token"
+
+// disconnectedSessionKeys lists the session values Disconnect removes: the
+// OAuth token, the granted scopes and the stored profile.
+var disconnectedSessionKeys = []string{
+	constants.SessionKeyOAuthToken,
+	constants.SessionKeyGrantedScopes,
+	constants.SessionKeyUserID,
+	constants.SessionKeyUserEmail,
+	constants.SessionKeyUserName,
+	constants.SessionKeyUserPicture,
+	constants.SessionKeySessionID,
+	sessionKeyCSRFKey,
+}
+
+// WithDisconnectPath returns a ServiceOption that serves Handlers.Disconnect
+// at disconnectPath instead of constants.DisconnectPath.
+func WithDisconnectPath(disconnectPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.disconnectPath = strings.TrimSpace(disconnectPath)
+	}
+}
+
+// WithDisconnectRedirectURL returns a ServiceOption that sets where
+// Disconnect sends the client once the grant is revoked. It defaults to the
+// logout redirect. Empty values are ignored.
+func WithDisconnectRedirectURL(redirectURL string) ServiceOption {
+	return func(serviceInstance *Service) {
+		if trimmedRedirect := strings.TrimSpace(redirectURL); trimmedRedirect != "" {
+			serviceInstance.disconnectRedirectURL = trimmedRedirect
+		}
+	}
+}
+
+// RevokeToken asks Google to revoke oauthToken, which withdraws every scope
+// the user granted to the application. The refresh token is revoked when
+// present, otherwise the access token. With WithDevFakeAuth the fake token is
+// not sent to Google.
+func (serviceInstance *Service) RevokeToken(ctx context.Context, oauthToken *oauth2.Token) error {
+	if serviceInstance.devFakeUser != nil && oauthToken.AccessToken == devFakeAccessToken {
+		return nil
+	}
+	revokedToken := oauthToken.RefreshToken
+	if revokedToken == "" {
+		revokedToken = oauthToken.AccessToken
+	}
+	if revokedToken == "" {
+		return fmt.Errorf("no token to revoke")
+	}
+	formValues := url.Values{revocationTokenParameter: {revokedToken}}
+	revocationRequest, requestError := http.NewRequestWithContext(ctx, http.MethodPost, serviceInstance.googleEndpoints.RevocationURL, strings.NewReader(formValues.Encode()))
+	if requestError != nil {
+		return requestError
+	}
+	revocationRequest.Header.Set(headerContentType, "application/x-www-form-urlencoded")
+	revocationResponse, responseError := serviceInstance.httpClient(ctx).Do(revocationRequest)
+	if responseError != nil {
+		return responseError
+	}
+	defer revocationResponse.Body.Close()
+	if revocationResponse.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(revocationResponse.Body, 1024))
+		return fmt.Errorf("token revocation failed with status %d: %s", revocationResponse.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
+
+// Disconnect revokes the application's access to the user's Google account
+// and signs the user out. It accepts only POST requests carrying the CSRF
+// token returned by Service.CSRFToken in the X-CSRF-Token header or the _csrf
+// form field. The stored refresh token, or the access token when there is
+// none, is revoked with Google; the token and profile values are then removed
+// from the session even when Google reports an error, the logout hook runs,
+// and the client is redirected to the URL set with WithDisconnectRedirectURL.
+func (handlersInstance *Handlers) Disconnect(responseWriter http.ResponseWriter, request *http.Request) {
+	request = handlersInstance.service.withRequestID(responseWriter, request)
+	if request.Method != http.MethodPost {
+		responseWriter.Header().Set("Allow", http.MethodPost)
+		http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	request, verified := handlersInstance.service.csrfProtected(responseWriter, request)
+	if !verified {
+		return
+	}
+
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	disconnectedEmail, _ := webSession.Values[constants.SessionKeyUserEmail].(string)
+	if tokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string); tokenJSON != "" {
+		var storedToken oauth2.Token
+		if unmarshalError := json.Unmarshal([]byte(tokenJSON), &storedToken); unmarshalError != nil {
+			logRequestf(request, "Failed to read the stored token for revocation: %v", unmarshalError)
+		} else if revokeError := handlersInstance.service.RevokeToken(request.Context(), &storedToken); revokeError != nil {
+			logRequestf(request, "Failed to revoke the Google grant: %v", revokeError)
+		}
+	}
+
+	handlersInstance.service.unregisterSession(request, webSession)
+	for _, sessionKey := range disconnectedSessionKeys {
+		delete(webSession.Values, sessionKey)
+	}
+	if webSessionSaveError := saveSession(responseWriter, request, webSession); webSessionSaveError != nil {
+		handlersInstance.handleAuthError(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to clear session", webSessionSaveError))
+		return
+	}
+	handlersInstance.service.notifyLogout(request, disconnectedEmail)
+	handlersInstance.service.metrics.LoggedOut()
+	http.Redirect(responseWriter, request, handlersInstance.service.mountedURL(request, handlersInstance.service.disconnectRedirectURL), http.StatusFound)
+}
+
+// DisconnectHandler returns the handler that revokes the Google grant, so it
+// can be mounted independently of RegisterRoutes.
+func (handlersInstance *Handlers) DisconnectHandler() http.Handler {
+	return withSecurityHeaders(handlersInstance.service.rateLimited(handlersInstance.Disconnect))
+}
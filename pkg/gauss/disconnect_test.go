@@ -0,0 +1,108 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// useMockRevocation points h at a revocation endpoint answering with status
+// and records the revoked tokens.
+func useMockRevocation(t *testing.T, h *Handlers, status int) *[]string {
+	t.Helper()
+	revokedTokens := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*revokedTokens = append(*revokedTokens, r.PostFormValue("token"))
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"error":"invalid_token"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	WithGoogleEndpoints(GoogleEndpoints{RevocationURL: server.URL})(h.service)
+	return revokedTokens
+}
+
+// disconnectRequest builds a disconnect form post carrying cookies and csrfToken.
+func disconnectRequest(cookies []*http.Cookie, csrfToken string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, constants.DisconnectPath, strings.NewReader(url.Values{FormFieldCSRFToken: {csrfToken}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestDisconnectRevokesAndClearsSession(t *testing.T) {
+	testCases := []struct {
+		name             string
+		revocationStatus int
+	}{
+		{name: "revoked", revocationStatus: http.StatusOK},
+		{name: "google error", revocationStatus: http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			disconnectedEmails := []string{}
+			h := newTestHandlers(t,
+				WithDisconnectRedirectURL("/goodbye"),
+				WithLogoutHook(func(request *http.Request, email string) { disconnectedEmails = append(disconnectedEmails, email) }),
+			)
+			useMockGoogleUser(t, h)
+			revokedTokens := useMockRevocation(t, h, testCase.revocationStatus)
+			loginCookies := lastCookies(loginAsUser(t, h))
+			csrfToken := h.service.CSRFToken(requestWithCookies("/", loginCookies))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, disconnectRequest(loginCookies, csrfToken))
+			if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/goodbye" {
+				t.Fatalf("expected a redirect to /goodbye, got %d %q", rr.Code, rr.Header().Get("Location"))
+			}
+			if len(*revokedTokens) != 1 || (*revokedTokens)[0] != "rtok" {
+				t.Fatalf("expected the refresh token to be revoked, got %v", *revokedTokens)
+			}
+			values := sessionFromResponse(t, rr)
+			for _, sessionKey := range []string{constants.SessionKeyOAuthToken, constants.SessionKeyUserEmail, constants.SessionKeyUserID, constants.SessionKeyGrantedScopes} {
+				if _, found := values[sessionKey]; found {
+					t.Fatalf("expected %s to be removed, got %v", sessionKey, values)
+				}
+			}
+			if len(disconnectedEmails) != 1 || disconnectedEmails[0] != "e@example.com" {
+				t.Fatalf("expected the logout hook to receive the email, got %v", disconnectedEmails)
+			}
+		})
+	}
+}
+
+func TestDisconnectRejectsForgedRequests(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	revokedTokens := useMockRevocation(t, h, http.StatusOK)
+	loginCookies := lastCookies(loginAsUser(t, h))
+	testCases := []struct {
+		name           string
+		request        *http.Request
+		expectedStatus int
+	}{
+		{name: "missing token", request: disconnectRequest(loginCookies, ""), expectedStatus: http.StatusForbidden},
+		{name: "wrong token", request: disconnectRequest(loginCookies, "forged"), expectedStatus: http.StatusForbidden},
+		{name: "no session", request: disconnectRequest(nil, ""), expectedStatus: http.StatusForbidden},
+		{name: "get", request: requestWithCookies(constants.DisconnectPath, loginCookies), expectedStatus: http.StatusMethodNotAllowed},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, testCase.request)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+		})
+	}
+	if len(*revokedTokens) != 0 {
+		t.Fatalf("expected no revocation, got %v", *revokedTokens)
+	}
+}
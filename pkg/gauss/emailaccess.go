@@ -0,0 +1,97 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	accessForbiddenErrorValue   = "forbidden"
+	accessForbiddenMessage      = "Your account is not allowed to access this page."
+	accessUnavailableErrorValue = "access_check_unavailable"
+	accessUnavailableMessage    = "We could not check your access. Please try again later."
+)
+
+// EmailLookupFunc reports whether the user with email may access a route.
+// A non-nil error means the answer is unknown.
+type EmailLookupFunc func(ctx context.Context, email string) (bool, error)
+
+// EmailAccessOption configures RequireEmailFunc and RequireEmails.
+type EmailAccessOption func(*emailAccessConfig)
+
+// emailAccessConfig holds the settings applied by EmailAccessOption values.
+type emailAccessConfig struct {
+	lookupErrorStatus int
+}
+
+// WithLookupErrorStatus returns an EmailAccessOption that answers requests
+// whose lookup failed with statusCode instead of 503 Service Unavailable.
+func WithLookupErrorStatus(statusCode int) EmailAccessOption {
+	return func(accessConfig *emailAccessConfig) {
+		accessConfig.lookupErrorStatus = statusCode
+	}
+}
+
+// RequireEmailFunc returns middleware that lets a request through only when
+// lookup allows the logged-in user's email address. lookup runs on every
+// request, so allowlists stored in a database take effect immediately;
+// callers that need caching add it inside lookup. Requests without a user
+// receive 401 Unauthorized, and API-only sessions, whose email is unknown,
+// receive 403 Forbidden without calling lookup. A denied email receives 403
+// Forbidden. A lookup error is logged and answered with 503 Service
+// Unavailable, or the status set with WithLookupErrorStatus, rather than
+// treated as a denial. Responses are a short HTML page, or JSON for requests
+// that look like API calls.
+//
+// Stack it after NewAuthMiddleware, which attaches the user:
+//
+//	adminOnly := gauss.RequireEmailFunc(adminStore.IsAdmin)
+//	mux.Handle("/admin/", gauss.NewAuthMiddleware(svc)(adminOnly(adminHandler)))
+func RequireEmailFunc(lookup EmailLookupFunc, options ...EmailAccessOption) func(http.Handler) http.Handler {
+	accessConfig := &emailAccessConfig{lookupErrorStatus: http.StatusServiceUnavailable}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		option(accessConfig)
+	}
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			userID, userEmail, found := requestUserIdentity(request)
+			if !found {
+				writeAccessRejection(responseWriter, request, http.StatusUnauthorized, unauthenticatedErrorValue, signInRequiredMessage)
+				return
+			}
+			if userEmail == apiOnlyUserEmail {
+				writeAccessRejection(responseWriter, request, http.StatusForbidden, accessForbiddenErrorValue, accessForbiddenMessage)
+				return
+			}
+			allowed, lookupError := lookup(request.Context(), userEmail)
+			if lookupError != nil {
+				logRequestf(request, "Failed to check access for user %s: %v", userID, lookupError)
+				writeAccessRejection(responseWriter, request, accessConfig.lookupErrorStatus, accessUnavailableErrorValue, accessUnavailableMessage)
+				return
+			}
+			if !allowed {
+				logRequestf(request, "Refused user %s: email not allowed", userID)
+				writeAccessRejection(responseWriter, request, http.StatusForbidden, accessForbiddenErrorValue, accessForbiddenMessage)
+				return
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// RequireEmails returns middleware like RequireEmailFunc that allows the
+// fixed list emails, compared case-insensitively.
+func RequireEmails(emails ...string) func(http.Handler) http.Handler {
+	allowedEmails := make(map[string]struct{}, len(emails))
+	for _, email := range emails {
+		allowedEmails[strings.ToLower(strings.TrimSpace(email))] = struct{}{}
+	}
+	return RequireEmailFunc(func(_ context.Context, email string) (bool, error) {
+		_, allowed := allowedEmails[strings.ToLower(email)]
+		return allowed, nil
+	})
+}
@@ -0,0 +1,92 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireEmailFunc(t *testing.T) {
+	errLookup := errors.New("database unavailable")
+	fakeLookup := func(ctx context.Context, email string) (bool, error) {
+		switch email {
+		case "admin@corp.com":
+			return true, nil
+		case "broken@corp.com":
+			return false, errLookup
+		}
+		return false, nil
+	}
+	testCases := []struct {
+		name           string
+		userEmail      string
+		options        []EmailAccessOption
+		acceptJSON     bool
+		expectedStatus int
+		expectedBody   string
+		expectLookup   bool
+	}{
+		{name: "allowed", userEmail: "admin@corp.com", expectedStatus: http.StatusOK, expectLookup: true},
+		{name: "denied", userEmail: "guest@corp.com", expectedStatus: http.StatusForbidden, expectedBody: accessForbiddenMessage, expectLookup: true},
+		{name: "lookup error", userEmail: "broken@corp.com", expectedStatus: http.StatusServiceUnavailable, expectedBody: accessUnavailableMessage, expectLookup: true},
+		{
+			name:           "lookup error with configured status",
+			userEmail:      "broken@corp.com",
+			options:        []EmailAccessOption{nil, WithLookupErrorStatus(http.StatusInternalServerError)},
+			acceptJSON:     true,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"error":"access_check_unavailable"`,
+			expectLookup:   true,
+		},
+		{name: "missing user", expectedStatus: http.StatusUnauthorized},
+		{name: "API-only placeholder", userEmail: apiOnlyUserEmail, expectedStatus: http.StatusForbidden},
+	}
+	newTestHandlers(t)
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			lookupCalled := false
+			recordingLookup := func(ctx context.Context, email string) (bool, error) {
+				lookupCalled = true
+				return fakeLookup(ctx, email)
+			}
+			req := requestWithSessionEmail(t, testCase.userEmail)
+			if testCase.acceptJSON {
+				req.Header.Set("Accept", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			RequireEmailFunc(recordingLookup, testCase.options...)(next).ServeHTTP(rr, req)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if lookupCalled != testCase.expectLookup {
+				t.Fatalf("expected lookup called %v, got %v", testCase.expectLookup, lookupCalled)
+			}
+			if !strings.Contains(rr.Body.String(), testCase.expectedBody) {
+				t.Fatalf("expected body to contain %q, got %q", testCase.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireEmails(t *testing.T) {
+	newTestHandlers(t)
+	requireAdmins := RequireEmails("Admin@corp.com")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	testCases := []struct {
+		userEmail      string
+		expectedStatus int
+	}{
+		{userEmail: "admin@CORP.com", expectedStatus: http.StatusOK},
+		{userEmail: "guest@corp.com", expectedStatus: http.StatusForbidden},
+	}
+	for _, testCase := range testCases {
+		rr := httptest.NewRecorder()
+		requireAdmins.ServeHTTP(rr, requestWithSessionEmail(t, testCase.userEmail))
+		if rr.Code != testCase.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d", testCase.userEmail, testCase.expectedStatus, rr.Code)
+		}
+	}
+}
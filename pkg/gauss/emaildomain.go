@@ -0,0 +1,104 @@
+package gauss
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// apiOnlyUserEmail is stored as the session email when no profile scope was
+// requested, so the session counts as logged in without a known address.
+const apiOnlyUserEmail = "authenticated_api_user"
+
+const signInRequiredMessage = "Please sign in to continue."
+
+// accessRejection is the JSON body sent to API requests refused by
+// RequireEmailDomain, RequireEmailFunc and RequireEmails.
+type accessRejection struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// RequireEmailDomain returns middleware that lets a request through only when
+// the logged-in user's email address belongs to one of domains, compared
+// case-insensitively and without matching subdomains. A leading "@" on a
+// domain is ignored. The user is taken from the request context, as attached
+// by NewAuthMiddleware, or else from the session. Requests without a user
+// receive 401 Unauthorized; users from other domains, and API-only sessions
+// whose email is unknown, receive 403 Forbidden. Both are answered with a
+// short HTML page, or with JSON for requests that look like API calls. With
+// no domains every request is refused.
+//
+// Stack it after NewAuthMiddleware to restrict individual routes:
+//
+//	adminOnly := gauss.RequireEmailDomain("corp.com")
+//	mux.Handle("/admin/", gauss.NewAuthMiddleware(svc)(adminOnly(adminHandler)))
+func RequireEmailDomain(domains ...string) func(http.Handler) http.Handler {
+	allowedDomains := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		normalizedDomain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if normalizedDomain != "" {
+			allowedDomains[normalizedDomain] = struct{}{}
+		}
+	}
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			userID, userEmail, found := requestUserIdentity(request)
+			if !found {
+				writeAccessRejection(responseWriter, request, http.StatusUnauthorized, unauthenticatedErrorValue, signInRequiredMessage)
+				return
+			}
+			if !emailInDomains(userEmail, allowedDomains) {
+				logRequestf(request, "Refused user %s: email domain not allowed", userID)
+				writeAccessRejection(responseWriter, request, http.StatusForbidden, string(ErrCodeDomainNotAllowed), errorMessages[ErrCodeDomainNotAllowed])
+				return
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// requestUserIdentity returns the Google ID and email of the user attached
+// to the request context, falling back to the session.
+func requestUserIdentity(request *http.Request) (userID string, userEmail string, found bool) {
+	if user, found := UserFromContext(request.Context()); found {
+		return user.ID, user.Email, true
+	}
+	if googleUser, found := SessionUser(request); found {
+		return googleUser.ID, googleUser.Email, true
+	}
+	return "", "", false
+}
+
+// emailInDomains reports whether userEmail is a real address whose domain is
+// one of allowedDomains.
+func emailInDomains(userEmail string, allowedDomains map[string]struct{}) bool {
+	if userEmail == apiOnlyUserEmail {
+		return false
+	}
+	separatorIndex := strings.LastIndex(userEmail, "@")
+	if separatorIndex <= 0 {
+		return false
+	}
+	_, allowed := allowedDomains[strings.ToLower(userEmail[separatorIndex+1:])]
+	return allowed
+}
+
+// writeAccessRejection answers request with statusCode and message,
+// as JSON for API requests and as a minimal HTML page otherwise.
+func writeAccessRejection(responseWriter http.ResponseWriter, request *http.Request, statusCode int, errorCode string, message string) {
+	if isAPIRequest(request) {
+		responseWriter.Header().Set(headerContentType, mediaTypeJSON)
+		responseWriter.WriteHeader(statusCode)
+		if encodeError := json.NewEncoder(responseWriter).Encode(accessRejection{Error: errorCode, Message: message}); encodeError != nil {
+			logRequestf(request, "Failed to write the access rejection: %v", encodeError)
+		}
+		return
+	}
+	responseWriter.Header().Set(headerContentType, mediaTypeHTML+"; charset=utf-8")
+	responseWriter.WriteHeader(statusCode)
+	escapedMessage := template.HTMLEscapeString(message)
+	fmt.Fprintf(responseWriter, "<!DOCTYPE html><title>%s</title><h1>%s</h1><p>%s</p>", http.StatusText(statusCode), http.StatusText(statusCode), escapedMessage)
+}
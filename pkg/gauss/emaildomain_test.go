@@ -0,0 +1,88 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// requestWithSessionEmail returns a request for /admin carrying a session
+// whose email is userEmail, or no session when userEmail is empty.
+func requestWithSessionEmail(t *testing.T, userEmail string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	if userEmail == "" {
+		return req
+	}
+	initRR := httptest.NewRecorder()
+	sess, _ := session.Store().Get(req, constants.SessionName)
+	sess.Values[constants.SessionKeyUserEmail] = userEmail
+	if err := sess.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	loggedIn := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	for _, cookie := range initRR.Result().Cookies() {
+		loggedIn.AddCookie(cookie)
+	}
+	return loggedIn
+}
+
+func TestRequireEmailDomain(t *testing.T) {
+	testCases := []struct {
+		name           string
+		userEmail      string
+		contextEmail   string
+		acceptJSON     bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "allowed", userEmail: "admin@corp.com", expectedStatus: http.StatusOK},
+		{name: "allowed case-insensitively", userEmail: "Admin@CORP.com", expectedStatus: http.StatusOK},
+		{name: "allowed from context", contextEmail: "admin@corp.com", expectedStatus: http.StatusOK},
+		{name: "denied", userEmail: "someone@gmail.com", expectedStatus: http.StatusForbidden, expectedBody: "<h1>Forbidden</h1>"},
+		{name: "subdomain denied", userEmail: "someone@eu.corp.com", expectedStatus: http.StatusForbidden},
+		{name: "lookalike denied", userEmail: "someone@notcorp.com", expectedStatus: http.StatusForbidden},
+		{name: "denied as JSON", userEmail: "someone@gmail.com", acceptJSON: true, expectedStatus: http.StatusForbidden, expectedBody: `"error":"domain_not_allowed"`},
+		{name: "missing user", expectedStatus: http.StatusUnauthorized, expectedBody: signInRequiredMessage},
+		{name: "API-only placeholder", userEmail: apiOnlyUserEmail, expectedStatus: http.StatusForbidden},
+	}
+	newTestHandlers(t)
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			nextCalled := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { nextCalled = true })
+			req := requestWithSessionEmail(t, testCase.userEmail)
+			if testCase.contextEmail != "" {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey{}, &User{Email: testCase.contextEmail}))
+			}
+			if testCase.acceptJSON {
+				req.Header.Set("Accept", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			RequireEmailDomain("@corp.com", "partner.org")(next).ServeHTTP(rr, req)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if nextCalled != (testCase.expectedStatus == http.StatusOK) {
+				t.Fatalf("expected the next handler to run only when allowed, ran: %v", nextCalled)
+			}
+			if !strings.Contains(rr.Body.String(), testCase.expectedBody) {
+				t.Fatalf("expected body to contain %q, got %q", testCase.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireEmailDomainWithoutDomainsRefusesEveryone(t *testing.T) {
+	newTestHandlers(t)
+	rr := httptest.NewRecorder()
+	RequireEmailDomain()(http.NotFoundHandler()).ServeHTTP(rr, requestWithSessionEmail(t, "admin@corp.com"))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
@@ -0,0 +1,155 @@
+package gauss
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Google's URLs for the endpoints outside the OAuth2 flow, used unless
+// WithGoogleEndpoints replaces them.
+const (
+	userInfoEndpoint            = "https://www.googleapis.com/oauth2/v2/userinfo"
+	tokenInfoEndpoint           = "https://oauth2.googleapis.com/tokeninfo"
+	revocationEndpoint          = "https://oauth2.googleapis.com/revoke"
+	peopleEndpoint              = "https://people.googleapis.com/v1/people/me?personFields=names,emailAddresses,photos,locales"
+	adminDirectoryUsersEndpoint = "https://admin.googleapis.com/admin/directory/v1/users/"
+	calendarListEndpoint        = "https://www.googleapis.com/calendar/v3/users/me/calendarList"
+)
+
+// GoogleEndpoints lists the Google URLs a Service calls. Pass it to
+// WithGoogleEndpoints to point a Service at other servers, such as a
+// MockGoogleServer from pkg/gauss/gausstest in tests.
+type GoogleEndpoints struct {
+	// AuthURL is the OAuth2 authorization endpoint users are sent to.
+	AuthURL string
+	// TokenURL is the OAuth2 token endpoint, also polled by the device flow.
+	TokenURL string
+	// DeviceAuthURL is the device authorization endpoint.
+	DeviceAuthURL string
+	// UserInfoURL is the OAuth2 userinfo endpoint read after login.
+	UserInfoURL string
+	// TokenInfoURL is the endpoint VerifyAccessToken checks tokens with.
+	TokenInfoURL string
+	// RevocationURL is the endpoint RevokeToken revokes tokens with.
+	RevocationURL string
+	// PeopleURL is the People API profile URL read by GetUserV3.
+	PeopleURL string
+	// AdminDirectoryUsersURL is the Admin SDK Directory API users URL read
+	// by IsWorkspaceAdmin, ending in a slash that the user's email follows.
+	AdminDirectoryUsersURL string
+	// CalendarListURL is the Calendar API calendar list URL read by
+	// GetCalendarList.
+	CalendarListURL string
+}
+
+// defaultGoogleEndpoints returns Google's URLs.
+func defaultGoogleEndpoints() GoogleEndpoints {
+	return GoogleEndpoints{
+		AuthURL:                google.Endpoint.AuthURL,
+		TokenURL:               google.Endpoint.TokenURL,
+		DeviceAuthURL:          google.Endpoint.DeviceAuthURL,
+		UserInfoURL:            userInfoEndpoint,
+		TokenInfoURL:           tokenInfoEndpoint,
+		RevocationURL:          revocationEndpoint,
+		PeopleURL:              peopleEndpoint,
+		AdminDirectoryUsersURL: adminDirectoryUsersEndpoint,
+		CalendarListURL:        calendarListEndpoint,
+	}
+}
+
+// WithGoogleEndpoints returns a ServiceOption that replaces the Google URLs
+// set in endpoints, for example with MockGoogleServer.Endpoints. Empty fields
+// keep the current URLs. NewService rejects values that are not absolute
+// HTTP or HTTPS URLs.
+func WithGoogleEndpoints(endpoints GoogleEndpoints) ServiceOption {
+	return func(serviceInstance *Service) {
+		configuredEndpoints := &serviceInstance.googleEndpoints
+		replacements := []struct {
+			configuredURL *string
+			replacement   string
+		}{
+			{configuredURL: &configuredEndpoints.AuthURL, replacement: endpoints.AuthURL},
+			{configuredURL: &configuredEndpoints.TokenURL, replacement: endpoints.TokenURL},
+			{configuredURL: &configuredEndpoints.DeviceAuthURL, replacement: endpoints.DeviceAuthURL},
+			{configuredURL: &configuredEndpoints.UserInfoURL, replacement: endpoints.UserInfoURL},
+			{configuredURL: &configuredEndpoints.TokenInfoURL, replacement: endpoints.TokenInfoURL},
+			{configuredURL: &configuredEndpoints.RevocationURL, replacement: endpoints.RevocationURL},
+			{configuredURL: &configuredEndpoints.PeopleURL, replacement: endpoints.PeopleURL},
+			{configuredURL: &configuredEndpoints.AdminDirectoryUsersURL, replacement: endpoints.AdminDirectoryUsersURL},
+			{configuredURL: &configuredEndpoints.CalendarListURL, replacement: endpoints.CalendarListURL},
+		}
+		for _, endpointReplacement := range replacements {
+			if trimmedURL := strings.TrimSpace(endpointReplacement.replacement); trimmedURL != "" {
+				*endpointReplacement.configuredURL = trimmedURL
+			}
+		}
+		serviceInstance.config.Endpoint.AuthURL = configuredEndpoints.AuthURL
+		serviceInstance.config.Endpoint.TokenURL = configuredEndpoints.TokenURL
+		serviceInstance.config.Endpoint.DeviceAuthURL = configuredEndpoints.DeviceAuthURL
+	}
+}
+
+// validateGoogleEndpoints reports an error when an endpoint set with
+// WithGoogleEndpoints is not an absolute HTTP or HTTPS URL.
+func (serviceInstance *Service) validateGoogleEndpoints() error {
+	configuredEndpoints := []struct {
+		endpointName string
+		endpointURL  string
+	}{
+		{endpointName: "authorization", endpointURL: serviceInstance.googleEndpoints.AuthURL},
+		{endpointName: "token", endpointURL: serviceInstance.googleEndpoints.TokenURL},
+		{endpointName: "device authorization", endpointURL: serviceInstance.googleEndpoints.DeviceAuthURL},
+		{endpointName: "userinfo", endpointURL: serviceInstance.googleEndpoints.UserInfoURL},
+		{endpointName: "tokeninfo", endpointURL: serviceInstance.googleEndpoints.TokenInfoURL},
+		{endpointName: "revocation", endpointURL: serviceInstance.googleEndpoints.RevocationURL},
+		{endpointName: "People API", endpointURL: serviceInstance.googleEndpoints.PeopleURL},
+		{endpointName: "Admin SDK Directory users", endpointURL: serviceInstance.googleEndpoints.AdminDirectoryUsersURL},
+		{endpointName: "calendar list", endpointURL: serviceInstance.googleEndpoints.CalendarListURL},
+	}
+	for _, configuredEndpoint := range configuredEndpoints {
+		parsedURL, parseError := url.Parse(configuredEndpoint.endpointURL)
+		if parseError != nil || !parsedURL.IsAbs() || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("invalid Google %s endpoint %q: must be an absolute HTTP or HTTPS URL", configuredEndpoint.endpointName, configuredEndpoint.endpointURL)
+		}
+	}
+	return nil
+}
+
+// WithCustomHTTPClient returns a ServiceOption that makes the Service send
+// its requests to Google through httpClient: token exchanges and refreshes,
+// profile and tokeninfo lookups, revocations and the device flow. A client
+// placed in the context with oauth2.HTTPClient takes precedence. Nil keeps
+// http.DefaultClient.
+func WithCustomHTTPClient(httpClient *http.Client) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.customHTTPClient = httpClient
+	}
+}
+
+// httpClient returns the client for requests to Google made with ctx: the
+// one in ctx under oauth2.HTTPClient, the one set with WithCustomHTTPClient
+// or http.DefaultClient.
+func (serviceInstance *Service) httpClient(ctx context.Context) *http.Client {
+	if contextClient, found := ctx.Value(oauth2.HTTPClient).(*http.Client); found && contextClient != nil {
+		return contextClient
+	}
+	if serviceInstance.customHTTPClient != nil {
+		return serviceInstance.customHTTPClient
+	}
+	return http.DefaultClient
+}
+
+// oauthContext returns ctx carrying the client set with WithCustomHTTPClient
+// for the oauth2 package, unless ctx already carries one.
+func (serviceInstance *Service) oauthContext(ctx context.Context) context.Context {
+	if serviceInstance.customHTTPClient == nil || ctx.Value(oauth2.HTTPClient) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, serviceInstance.customHTTPClient)
+}
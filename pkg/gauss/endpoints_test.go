@@ -0,0 +1,133 @@
+package gauss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestWithGoogleEndpoints(t *testing.T) {
+	mockEndpoints := GoogleEndpoints{
+		AuthURL:                "http://mock/auth",
+		TokenURL:               "http://mock/token",
+		DeviceAuthURL:          "http://mock/device/code",
+		UserInfoURL:            "http://mock/userinfo",
+		TokenInfoURL:           "http://mock/tokeninfo",
+		RevocationURL:          "http://mock/revoke",
+		PeopleURL:              "http://mock/people",
+		AdminDirectoryUsersURL: "http://mock/admin/users/",
+		CalendarListURL:        "http://mock/calendarList",
+	}
+	testCases := []struct {
+		name              string
+		endpoints         GoogleEndpoints
+		expectedEndpoints GoogleEndpoints
+	}{
+		{name: "custom", endpoints: mockEndpoints, expectedEndpoints: mockEndpoints},
+		{name: "empty keeps Google", expectedEndpoints: defaultGoogleEndpoints()},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithGoogleEndpoints(testCase.endpoints))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if svc.googleEndpoints != testCase.expectedEndpoints {
+				t.Fatalf("expected %+v, got %+v", testCase.expectedEndpoints, svc.googleEndpoints)
+			}
+			expected := testCase.expectedEndpoints
+			if svc.config.Endpoint.AuthURL != expected.AuthURL || svc.config.Endpoint.TokenURL != expected.TokenURL || svc.config.Endpoint.DeviceAuthURL != expected.DeviceAuthURL {
+				t.Fatalf("unexpected OAuth2 endpoint %+v", svc.config.Endpoint)
+			}
+		})
+	}
+}
+
+func TestWithGoogleEndpointsRejectsInvalidURLs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		endpoints GoogleEndpoints
+	}{
+		{name: "relative auth URL", endpoints: GoogleEndpoints{AuthURL: "/auth"}},
+		{name: "token URL without host", endpoints: GoogleEndpoints{TokenURL: "http://"}},
+		{name: "unsupported userinfo scheme", endpoints: GoogleEndpoints{UserInfoURL: "ftp://mock/userinfo"}},
+		{name: "relative revocation URL", endpoints: GoogleEndpoints{RevocationURL: "revoke"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithGoogleEndpoints(testCase.endpoints)); err == nil {
+				t.Fatal("expected NewService to reject the endpoint")
+			}
+		})
+	}
+}
+
+// recordingTransport answers every request with an empty JSON object and
+// records the requested URLs.
+type recordingTransport struct {
+	requestedURLs []string
+}
+
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.requestedURLs = append(transport.requestedURLs, request.URL.Scheme+"://"+request.URL.Host+request.URL.Path)
+	recorder := httptest.NewRecorder()
+	io.WriteString(recorder, `{}`)
+	return recorder.Result(), nil
+}
+
+func TestWithCustomHTTPClient(t *testing.T) {
+	testCases := []struct {
+		name        string
+		callGoogle  func(svc *Service, ctx context.Context)
+		expectedURL string
+	}{
+		{
+			name:        "revocation",
+			callGoogle:  func(svc *Service, ctx context.Context) { svc.RevokeToken(ctx, &oauth2.Token{AccessToken: "abc"}) },
+			expectedURL: revocationEndpoint,
+		},
+		{
+			name:        "tokeninfo",
+			callGoogle:  func(svc *Service, ctx context.Context) { svc.VerifyAccessToken(ctx, "abc") },
+			expectedURL: tokenInfoEndpoint,
+		},
+		{
+			name:        "userinfo",
+			callGoogle:  func(svc *Service, ctx context.Context) { svc.fetchGoogleUser(ctx, &oauth2.Token{AccessToken: "abc"}) },
+			expectedURL: userInfoEndpoint,
+		},
+		{
+			name:        "device authorization",
+			callGoogle:  func(svc *Service, ctx context.Context) { svc.StartDeviceFlow(ctx) },
+			expectedURL: defaultGoogleEndpoints().DeviceAuthURL,
+		},
+		{
+			name:        "token exchange",
+			callGoogle:  func(svc *Service, ctx context.Context) { svc.exchangeCode(ctx, svc.config, "c1") },
+			expectedURL: defaultGoogleEndpoints().TokenURL,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			serviceTransport := &recordingTransport{}
+			svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithCustomHTTPClient(&http.Client{Transport: serviceTransport}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testCase.callGoogle(svc, context.Background())
+			if len(serviceTransport.requestedURLs) != 1 || serviceTransport.requestedURLs[0] != testCase.expectedURL {
+				t.Fatalf("expected a request to %s through the custom client, got %v", testCase.expectedURL, serviceTransport.requestedURLs)
+			}
+
+			contextTransport := &recordingTransport{}
+			testCase.callGoogle(svc, context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: contextTransport}))
+			if len(contextTransport.requestedURLs) != 1 || len(serviceTransport.requestedURLs) != 1 {
+				t.Fatalf("expected the context client to take precedence, got %v and %v", contextTransport.requestedURLs, serviceTransport.requestedURLs)
+			}
+		})
+	}
+}
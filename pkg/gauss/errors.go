@@ -0,0 +1,125 @@
+package gauss
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AuthErrorCode is a machine-readable identifier for an authentication
+// failure. The string value is sent to the login page in the error_code query
+// parameter so custom templates can branch on it.
+type AuthErrorCode string
+
+const (
+	// ErrCodeStateGeneration means a random OAuth state could not be generated.
+	ErrCodeStateGeneration AuthErrorCode = "state_generation_failed"
+	// ErrCodeMissingState means the session did not contain an OAuth state.
+	ErrCodeMissingState AuthErrorCode = "missing_state"
+	// ErrCodeStateMismatch means the callback state did not match the session.
+	ErrCodeStateMismatch AuthErrorCode = "invalid_state"
+	// ErrCodeAuthorizationFailed means Google returned an error instead of a code.
+	ErrCodeAuthorizationFailed AuthErrorCode = "authorization_failed"
+	// ErrCodeMissingCode means the callback did not include an authorization code.
+	ErrCodeMissingCode AuthErrorCode = "missing_code"
+	// ErrCodeTokenExchange means exchanging the authorization code failed.
+	ErrCodeTokenExchange AuthErrorCode = "token_exchange_failed"
+	// ErrCodeRefreshTokenUnavailable means Google withheld the refresh token
+	// even after the consent screen was shown again.
+	ErrCodeRefreshTokenUnavailable AuthErrorCode = "refresh_token_unavailable"
+	// ErrCodeUserInfo means the user profile could not be retrieved.
+	ErrCodeUserInfo AuthErrorCode = "user_info_failed"
+	// ErrCodeLoginRejected means the login success hook refused the user.
+	ErrCodeLoginRejected AuthErrorCode = "login_rejected"
+	// ErrCodeSessionSave means the authenticated session could not be saved.
+	ErrCodeSessionSave AuthErrorCode = "session_save_failed"
+	// ErrCodeDomainNotAllowed means the user's email domain is not permitted.
+	ErrCodeDomainNotAllowed AuthErrorCode = "domain_not_allowed"
+	// ErrCodeEmailNotVerified means Google reported the user's email address as
+	// unverified.
+	ErrCodeEmailNotVerified AuthErrorCode = "email_not_verified"
+	// ErrCodeDeviceAuthorization means the device flow failed, expired or was
+	// denied by the user.
+	ErrCodeDeviceAuthorization AuthErrorCode = "device_authorization_failed"
+	// ErrCodeSessionLimit means the user already has the maximum number of
+	// concurrent sessions and WithSessionEvictionPolicy blocks new ones.
+	ErrCodeSessionLimit AuthErrorCode = "session_limit_reached"
+	// ErrCodeSessionExpired means the session was unused for longer than the
+	// idle timeout set with WithIdleTimeout, or outlived the lifetime set with
+	// WithMaxSessionLifetime.
+	ErrCodeSessionExpired AuthErrorCode = "session_expired"
+	// ErrCodeAccountMismatch means additional scopes requested through
+	// Handlers.RequestScopes were granted with a Google account other than the
+	// logged-in one.
+	ErrCodeAccountMismatch AuthErrorCode = "account_mismatch"
+)
+
+// errorMessages holds the human-readable text rendered on the login page for
+// every known error code.
+var errorMessages = map[AuthErrorCode]string{
+	ErrCodeStateGeneration:         "We could not start the sign-in. Please try again.",
+	ErrCodeMissingState:            "Your sign-in attempt expired. Please try again.",
+	ErrCodeStateMismatch:           "Your sign-in request could not be verified. Please try again.",
+	ErrCodeAuthorizationFailed:     "Google did not authorize the sign-in.",
+	ErrCodeMissingCode:             "Google did not complete the sign-in. Please try again.",
+	ErrCodeTokenExchange:           "We could not complete the sign-in with Google. Please try again.",
+	ErrCodeRefreshTokenUnavailable: "Google did not grant offline access for this account.",
+	ErrCodeUserInfo:                "We could not retrieve your Google profile. Please try again.",
+	ErrCodeLoginRejected:           "Your account is not allowed to sign in.",
+	ErrCodeSessionSave:             "We could not save your session. Please try again.",
+	ErrCodeDomainNotAllowed:        "Your account's domain is not allowed to sign in.",
+	ErrCodeEmailNotVerified:        "Please verify your Google email address before signing in.",
+	ErrCodeDeviceAuthorization:     "The device sign-in did not complete. Please try again.",
+	ErrCodeSessionLimit:            "You are signed in on too many devices. Sign out elsewhere and try again.",
+	ErrCodeSessionExpired:          "Your session expired. Please sign in again.",
+	ErrCodeAccountMismatch:         "Please grant access with the Google account you are signed in with.",
+}
+
+// knownErrorCode converts rawCode into an AuthErrorCode when it names a known
+// error.
+func knownErrorCode(rawCode string) (AuthErrorCode, bool) {
+	errorCode := AuthErrorCode(rawCode)
+	_, known := errorMessages[errorCode]
+	return errorCode, known
+}
+
+// AuthError describes a failed authentication attempt. Code identifies the
+// failure for programmatic handling, Message is a human-readable description,
+// Cause, when set, is the underlying error and Request is the request that
+// failed.
+type AuthError struct {
+	Code    AuthErrorCode
+	Message string
+	Cause   error
+	Request *http.Request
+}
+
+// ErrorHandler responds to a failed authentication attempt. It is invoked by
+// the Login, Callback and Logout handlers in place of the default redirect to
+// the login page.
+type ErrorHandler func(responseWriter http.ResponseWriter, request *http.Request, authError *AuthError)
+
+// WithErrorHandler returns a ServiceOption that replaces the default
+// redirect-on-error behavior with errorHandler. A nil handler keeps the default,
+// which redirects to the login page with error and error_code query parameters.
+func WithErrorHandler(errorHandler ErrorHandler) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.errorHandler = errorHandler
+	}
+}
+
+func newAuthError(code AuthErrorCode, message string, cause error) *AuthError {
+	return &AuthError{Code: code, Message: message, Cause: cause}
+}
+
+// Error implements the error interface.
+func (authError *AuthError) Error() string {
+	if authError.Cause == nil {
+		return authError.Message
+	}
+	return fmt.Sprintf("%s: %v", authError.Message, authError.Cause)
+}
+
+// Unwrap returns the underlying cause so errors.Is and errors.As can inspect it.
+func (authError *AuthError) Unwrap() error {
+	return authError.Cause
+}
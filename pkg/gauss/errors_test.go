@@ -0,0 +1,28 @@
+package gauss
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAuthErrorWrapsCause(t *testing.T) {
+	cause := errors.New("boom")
+	var err error = newAuthError(ErrCodeTokenExchange, "Token exchange failed", cause)
+
+	var authError *AuthError
+	if !errors.As(err, &authError) {
+		t.Fatal("expected errors.As to find AuthError")
+	}
+	if authError.Code != ErrCodeTokenExchange {
+		t.Fatalf("expected code %s, got %s", ErrCodeTokenExchange, authError.Code)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("expected errors.Is to match the cause")
+	}
+	if err.Error() != "Token exchange failed: boom" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if message := newAuthError(ErrCodeMissingState, "Missing state in session", nil).Error(); message != "Missing state in session" {
+		t.Fatalf("unexpected message %q", message)
+	}
+}
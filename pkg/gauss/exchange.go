@@ -0,0 +1,108 @@
+package gauss
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenExchangeError describes a failed authorization code exchange. Code and
+// Description are the error and error_description fields of the token
+// endpoint's response, such as "invalid_grant" for a used or expired code or
+// "invalid_client" for wrong credentials. Code is empty when no OAuth2 error
+// was returned, for example after a network failure, which is usually worth
+// retrying. Cause is the underlying error.
+type TokenExchangeError struct {
+	Code        string
+	Description string
+	Cause       error
+}
+
+// Error implements the error interface.
+func (tokenExchangeError *TokenExchangeError) Error() string {
+	switch {
+	case tokenExchangeError.Code == "":
+		return fmt.Sprintf("%v", tokenExchangeError.Cause)
+	case tokenExchangeError.Description == "":
+		return tokenExchangeError.Code
+	default:
+		return tokenExchangeError.Code + ": " + tokenExchangeError.Description
+	}
+}
+
+// Unwrap returns the underlying cause so errors.Is and errors.As can inspect it.
+func (tokenExchangeError *TokenExchangeError) Unwrap() error {
+	return tokenExchangeError.Cause
+}
+
+// newTokenExchangeError wraps exchangeError, copying the OAuth2 error code and
+// description when the token endpoint returned them.
+func newTokenExchangeError(exchangeError error) *TokenExchangeError {
+	tokenExchangeError := &TokenExchangeError{Cause: exchangeError}
+	var retrieveError *oauth2.RetrieveError
+	if errors.As(exchangeError, &retrieveError) {
+		tokenExchangeError.Code = retrieveError.ErrorCode
+		tokenExchangeError.Description = retrieveError.ErrorDescription
+	}
+	return tokenExchangeError
+}
+
+// ExchangeAuthorizationCode completes an authorization outside of Callback,
+// for backends that receive the code and state from a single-page frontend.
+// It compares state with expectedState, the value the backend issued when the
+// authorization started, exchanges code for a token using the Service's
+// callback URL as the redirect URI, and fetches the Google profile when
+// profile or email scopes are configured; otherwise the returned user is nil.
+// Failures are reported as *AuthError with the same codes Callback uses, and
+// a state or code problem wraps ErrMissingState, ErrStateMismatch or
+// ErrMissingCode. A failed exchange wraps a *TokenExchangeError.
+func (serviceInstance *Service) ExchangeAuthorizationCode(ctx context.Context, code string, state string, expectedState string) (*oauth2.Token, *GoogleUser, error) {
+	if state == "" || expectedState == "" {
+		return nil, nil, newAuthError(ErrCodeMissingState, "Missing state", ErrMissingState)
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(expectedState)) != 1 {
+		return nil, nil, newAuthError(ErrCodeStateMismatch, "State mismatch", ErrStateMismatch)
+	}
+	if code == "" {
+		return nil, nil, newAuthError(ErrCodeMissingCode, "Missing authorization code", ErrMissingCode)
+	}
+
+	oauthToken, tokenExchangeError := serviceInstance.exchangeCode(ctx, serviceInstance.config, code)
+	if tokenExchangeError != nil {
+		return nil, nil, newAuthError(ErrCodeTokenExchange, "Token exchange failed", tokenExchangeError)
+	}
+	if !serviceInstance.requestsProfile() {
+		return oauthToken, nil, nil
+	}
+	googleUser, getUserError := serviceInstance.fetchGoogleUser(ctx, oauthToken)
+	if getUserError != nil {
+		return nil, nil, newAuthError(ErrCodeUserInfo, "Failed to get user info", getUserError)
+	}
+	if googleUser.Email != "" && !googleUser.VerifiedEmail && !serviceInstance.allowUnverifiedEmail {
+		return nil, nil, newAuthError(ErrCodeEmailNotVerified, "Email address not verified", fmt.Errorf("email of user %s is unverified", googleUser.ID))
+	}
+	return oauthToken, googleUser, nil
+}
+
+// exchangeCode redeems code with oauthConfig, recording the exchange in the
+// metrics and as a trace span. Failures are returned as *TokenExchangeError.
+func (serviceInstance *Service) exchangeCode(ctx context.Context, oauthConfig *oauth2.Config, code string) (*oauth2.Token, error) {
+	exchangeContext, exchangeSpan := serviceInstance.startSpan(ctx, spanNameTokenExchange)
+	exchangeStartTime := serviceInstance.now()
+	oauthToken, tokenExchangeError := oauthConfig.Exchange(serviceInstance.oauthContext(exchangeContext), code)
+	serviceInstance.metrics.ObserveTokenExchange(serviceInstance.now().Sub(exchangeStartTime), tokenExchangeError)
+	endSpan(exchangeSpan, tokenExchangeError, "token exchange failed")
+	if tokenExchangeError != nil {
+		return nil, newTokenExchangeError(tokenExchangeError)
+	}
+	return oauthToken, nil
+}
+
+// requestsProfile reports whether the configured scopes include profile or
+// email, in which case a login fetches the user's Google profile.
+func (serviceInstance *Service) requestsProfile() bool {
+	return serviceInstance.HasScope(ScopeProfile) || serviceInstance.HasScope(ScopeEmail)
+}
@@ -0,0 +1,181 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestExchangeAuthorizationCodeValidatesInput(t *testing.T) {
+	h := newTestHandlers(t)
+	testCases := []struct {
+		name          string
+		code          string
+		state         string
+		expectedState string
+		expectedCode  AuthErrorCode
+		expectedError error
+	}{
+		{name: "missing state", code: "c1", state: "", expectedState: "s1", expectedCode: ErrCodeMissingState, expectedError: ErrMissingState},
+		{name: "missing expected state", code: "c1", state: "s1", expectedState: "", expectedCode: ErrCodeMissingState, expectedError: ErrMissingState},
+		{name: "state mismatch", code: "c1", state: "s1", expectedState: "s2", expectedCode: ErrCodeStateMismatch, expectedError: ErrStateMismatch},
+		{name: "missing code", code: "", state: "s1", expectedState: "s1", expectedCode: ErrCodeMissingCode, expectedError: ErrMissingCode},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			oauthToken, googleUser, err := h.service.ExchangeAuthorizationCode(context.Background(), testCase.code, testCase.state, testCase.expectedState)
+			if oauthToken != nil || googleUser != nil {
+				t.Fatal("expected no token or user")
+			}
+			var authError *AuthError
+			if !errors.As(err, &authError) || authError.Code != testCase.expectedCode {
+				t.Fatalf("expected %s, got %v", testCase.expectedCode, err)
+			}
+			if !errors.Is(err, testCase.expectedError) {
+				t.Fatalf("expected %v, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCodeReturnsTokenAndUser(t *testing.T) {
+	h := newTestHandlers(t)
+	exchangedRedirectURI := ""
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			exchangedRedirectURI = r.Form.Get("redirect_uri")
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+		},
+	)
+	oauthToken, googleUser, err := h.service.ExchangeAuthorizationCode(context.Background(), "c1", "s1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oauthToken.AccessToken != "abc" || oauthToken.RefreshToken != "rtok" {
+		t.Fatalf("unexpected token %+v", oauthToken)
+	}
+	if googleUser == nil || googleUser.Email != "e@example.com" {
+		t.Fatalf("unexpected user %+v", googleUser)
+	}
+	if exchangedRedirectURI != h.service.config.RedirectURL {
+		t.Fatalf("expected redirect_uri %q, got %q", h.service.config.RedirectURL, exchangedRedirectURI)
+	}
+}
+
+func TestExchangeAuthorizationCodeWithoutProfileScopes(t *testing.T) {
+	h := newTestHandlers(t)
+	userInfoRequests := 0
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			userInfoRequests++
+		},
+	)
+	h.service.config.Scopes = []string{"https://www.googleapis.com/auth/drive.readonly"}
+	oauthToken, googleUser, err := h.service.ExchangeAuthorizationCode(context.Background(), "c1", "s1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oauthToken == nil || googleUser != nil || userInfoRequests != 0 {
+		t.Fatalf("expected a token without a user, got %+v %+v after %d user info requests", oauthToken, googleUser, userInfoRequests)
+	}
+}
+
+func TestExchangeAuthorizationCodeReportsFailures(t *testing.T) {
+	testCases := []struct {
+		name         string
+		tokenStatus  int
+		userInfo     string
+		expectedCode AuthErrorCode
+	}{
+		{name: "token exchange", tokenStatus: http.StatusBadRequest, expectedCode: ErrCodeTokenExchange},
+		{name: "unverified email", tokenStatus: http.StatusOK, userInfo: `{"id":"42","email":"e@example.com","verified_email":false}`, expectedCode: ErrCodeEmailNotVerified},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(testCase.tokenStatus)
+					if testCase.tokenStatus != http.StatusOK {
+						io.WriteString(w, `{"error":"invalid_grant"}`)
+						return
+					}
+					io.WriteString(w, `{"access_token":"abc","token_type":"bearer"}`)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, testCase.userInfo)
+				},
+			)
+			_, _, err := h.service.ExchangeAuthorizationCode(context.Background(), "c1", "s1", "s1")
+			var authError *AuthError
+			if !errors.As(err, &authError) || authError.Code != testCase.expectedCode {
+				t.Fatalf("expected %s, got %v", testCase.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCodeReturnsTokenExchangeError(t *testing.T) {
+	testCases := []struct {
+		name                string
+		tokenResponse       string
+		expectedCode        string
+		expectedDescription string
+	}{
+		{name: "invalid grant", tokenResponse: `{"error":"invalid_grant","error_description":"Bad Request"}`, expectedCode: "invalid_grant", expectedDescription: "Bad Request"},
+		{name: "invalid client", tokenResponse: `{"error":"invalid_client","error_description":"Unauthorized"}`, expectedCode: "invalid_client", expectedDescription: "Unauthorized"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, testCase.tokenResponse)
+				},
+				func(w http.ResponseWriter, r *http.Request) {},
+			)
+			_, _, err := h.service.ExchangeAuthorizationCode(context.Background(), "c1", "s1", "s1")
+			var tokenExchangeError *TokenExchangeError
+			if !errors.As(err, &tokenExchangeError) {
+				t.Fatalf("expected a TokenExchangeError, got %v", err)
+			}
+			if tokenExchangeError.Code != testCase.expectedCode || tokenExchangeError.Description != testCase.expectedDescription {
+				t.Fatalf("expected %s %q, got %s %q", testCase.expectedCode, testCase.expectedDescription, tokenExchangeError.Code, tokenExchangeError.Description)
+			}
+			var retrieveError *oauth2.RetrieveError
+			if !errors.As(err, &retrieveError) {
+				t.Fatal("expected the oauth2 error to remain reachable")
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCodeNetworkFailureHasNoCode(t *testing.T) {
+	h := newTestHandlers(t)
+	tokenServer := useMockGoogleHandlers(t, h, func(w http.ResponseWriter, r *http.Request) {}, func(w http.ResponseWriter, r *http.Request) {})
+	tokenServer.Close()
+	_, _, err := h.service.ExchangeAuthorizationCode(context.Background(), "c1", "s1", "s1")
+	var tokenExchangeError *TokenExchangeError
+	if !errors.As(err, &tokenExchangeError) {
+		t.Fatalf("expected a TokenExchangeError, got %v", err)
+	}
+	if tokenExchangeError.Code != "" || tokenExchangeError.Cause == nil {
+		t.Fatalf("expected a network failure without an OAuth2 code, got %+v", tokenExchangeError)
+	}
+}
@@ -0,0 +1,68 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// HeaderAuthUser carries the user's Google ID, or the email when the ID
+	// is unknown, in ForwardAuthHandler responses.
+	HeaderAuthUser = "X-Auth-User"
+	// HeaderAuthEmail carries the user's email in ForwardAuthHandler
+	// responses.
+	HeaderAuthEmail = "X-Auth-Email"
+	// HeaderAuthToken carries the user's Google access token in
+	// ForwardAuthHandler responses.
+	HeaderAuthToken = "X-Auth-Token"
+)
+
+// ForwardAuthHandler returns a handler for the forward authentication
+// endpoint of a reverse proxy, such as Traefik's forwardAuth middleware or
+// nginx's auth_request, so upstream applications are protected without
+// embedding GAuss. The proxy forwards the client's cookies; a request with a
+// valid session, as checked by Service.AuthenticatedUser, receives 200 OK
+// with the X-Auth-User, X-Auth-Email and X-Auth-Token headers taken from the
+// session, for the proxy to pass upstream. Any other request receives 401
+// Unauthorized without a redirect. The handler is not registered by
+// RegisterRoutes; mount it where the proxy expects it:
+//
+//	mux.Handle("/auth/verify", authHandlers.ForwardAuthHandler())
+//
+// The access token is sent to the proxy, so configure it to strip these
+// headers from client requests and only pass them to trusted upstreams.
+func (handlersInstance *Handlers) ForwardAuthHandler() http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set(headerCacheControl, cacheControlNoStore)
+		googleUser, authenticated := handlersInstance.service.AuthenticatedUser(responseWriter, request)
+		if !authenticated {
+			responseWriter.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		userEmail := googleUser.Email
+		if userEmail == apiOnlyUserEmail {
+			userEmail = ""
+		}
+		authUser := googleUser.ID
+		if authUser == "" {
+			authUser = userEmail
+		}
+		responseHeader := responseWriter.Header()
+		responseHeader.Set(HeaderAuthUser, authUser)
+		responseHeader.Set(HeaderAuthEmail, userEmail)
+		webSession := handlersInstance.service.webSession(request)
+		if tokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string); tokenJSON != "" {
+			var storedToken oauth2.Token
+			if unmarshalError := json.Unmarshal([]byte(tokenJSON), &storedToken); unmarshalError != nil {
+				logRequestf(request, "Failed to read the stored token for forward auth: %v", unmarshalError)
+			} else {
+				responseHeader.Set(HeaderAuthToken, storedToken.AccessToken)
+			}
+		}
+		responseWriter.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,82 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+func TestForwardAuthHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		sessionValues  map[string]string
+		expectedStatus int
+		expectedUser   string
+		expectedEmail  string
+		expectedToken  string
+	}{
+		{name: "no session", expectedStatus: http.StatusUnauthorized},
+		{
+			name: "valid session",
+			sessionValues: map[string]string{
+				constants.SessionKeyUserID:     "42",
+				constants.SessionKeyUserEmail:  "e@example.com",
+				constants.SessionKeyOAuthToken: `{"access_token":"abc","token_type":"bearer"}`,
+			},
+			expectedStatus: http.StatusOK,
+			expectedUser:   "42",
+			expectedEmail:  "e@example.com",
+			expectedToken:  "abc",
+		},
+		{
+			name:           "session without ID or token",
+			sessionValues:  map[string]string{constants.SessionKeyUserEmail: "e@example.com"},
+			expectedStatus: http.StatusOK,
+			expectedUser:   "e@example.com",
+			expectedEmail:  "e@example.com",
+		},
+		{
+			name: "API-only session",
+			sessionValues: map[string]string{
+				constants.SessionKeyUserID:     "42",
+				constants.SessionKeyUserEmail:  apiOnlyUserEmail,
+				constants.SessionKeyOAuthToken: `{"access_token":"abc"}`,
+			},
+			expectedStatus: http.StatusOK,
+			expectedUser:   "42",
+			expectedToken:  "abc",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+			if len(testCase.sessionValues) > 0 {
+				initRR := httptest.NewRecorder()
+				sess, _ := session.Store().Get(req, constants.SessionName)
+				for sessionKey, sessionValue := range testCase.sessionValues {
+					sess.Values[sessionKey] = sessionValue
+				}
+				if err := sess.Save(req, initRR); err != nil {
+					t.Fatal(err)
+				}
+				req = requestWithCookies("/auth/verify", initRR.Result().Cookies())
+			}
+			rr := httptest.NewRecorder()
+			h.ForwardAuthHandler().ServeHTTP(rr, req)
+
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if location := rr.Header().Get("Location"); location != "" {
+				t.Fatalf("expected no redirect, got %q", location)
+			}
+			if user, email, token := rr.Header().Get(HeaderAuthUser), rr.Header().Get(HeaderAuthEmail), rr.Header().Get(HeaderAuthToken); user != testCase.expectedUser || email != testCase.expectedEmail || token != testCase.expectedToken {
+				t.Fatalf("expected headers %q %q %q, got %q %q %q", testCase.expectedUser, testCase.expectedEmail, testCase.expectedToken, user, email, token)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package gauss
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// googleLogoutEndpoint is Google's front-channel logout URL, which signs the
+// browser out of every Google service. It only follows continue targets on
+// Google's own domains, so the browser is led back to the application
+// through googleLogoutReturnEndpoint, App Engine's logout URL, which forwards
+// to any continue URL. Google does not document that behavior; if it stops,
+// the browser is still signed out but stays on a Google page.
+const (
+	googleLogoutEndpoint       = "https://accounts.google.com/Logout"
+	googleLogoutReturnEndpoint = "https://appengine.google.com/_ah/logout"
+)
+
+const googleLogoutContinueParameter = "continue"
+
+// WithGoogleFrontChannelLogout returns a ServiceOption that makes Logout also
+// sign the browser out of Google. After clearing the local session, Logout
+// redirects to Google's logout page, which sends the browser on to the logout
+// redirect URL through its continue parameter and App Engine's logout page.
+// Use it on kiosks and shared devices, where a Google session left open would
+// let the next user act as the previous one in every Google service. It is
+// disabled by default. Google can only return to an absolute URL, so
+// NewService rejects it unless googleOAuthBase is an absolute URL.
+func WithGoogleFrontChannelLogout(enabled bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.googleFrontChannelLogout = enabled
+	}
+}
+
+// validateGoogleFrontChannelLogout reports an error when front-channel logout
+// is enabled without an absolute public base URL to return to.
+func (serviceInstance *Service) validateGoogleFrontChannelLogout() error {
+	if !serviceInstance.googleFrontChannelLogout {
+		return nil
+	}
+	if serviceInstance.publicBaseURL == nil || !serviceInstance.publicBaseURL.IsAbs() || serviceInstance.publicBaseURL.Host == "" {
+		return errors.New("Google front-channel logout requires an absolute public base URL to return to")
+	}
+	return nil
+}
+
+// logoutDestination returns where Logout redirects request: the logout
+// redirect URL, or Google's logout page continuing to it when front-channel
+// logout is enabled.
+func (serviceInstance *Service) logoutDestination(request *http.Request) string {
+	localDestination := serviceInstance.mountedURL(request, serviceInstance.logoutRedirectURL)
+	if !serviceInstance.googleFrontChannelLogout {
+		return localDestination
+	}
+	return googleLogoutURL(serviceInstance.absoluteURL(request, localDestination))
+}
+
+// absoluteURL resolves target against the base URL of request, since Google
+// redirects back to it from another origin. Absolute targets are returned
+// unchanged.
+func (serviceInstance *Service) absoluteURL(request *http.Request, target string) string {
+	targetURL, parseError := url.Parse(target)
+	if parseError != nil || targetURL.IsAbs() {
+		return target
+	}
+	baseURL := serviceInstance.effectiveBaseURL(request)
+	if baseURL == nil {
+		return target
+	}
+	return baseURL.ResolveReference(targetURL).String()
+}
+
+// googleLogoutURL returns the Google logout URL that ends at returnURL.
+func googleLogoutURL(returnURL string) string {
+	returnChain := googleLogoutReturnEndpoint + "?" + url.Values{googleLogoutContinueParameter: {returnURL}}.Encode()
+	return googleLogoutEndpoint + "?" + url.Values{googleLogoutContinueParameter: {returnChain}}.Encode()
+}
@@ -0,0 +1,91 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestLogoutWithGoogleFrontChannelLogout(t *testing.T) {
+	testCases := []struct {
+		name              string
+		options           []ServiceOption
+		expectedReturnURL string
+	}{
+		{name: "login page", options: []ServiceOption{WithGoogleFrontChannelLogout(true)}, expectedReturnURL: "http://example.com/login"},
+		{
+			name:              "relative redirect",
+			options:           []ServiceOption{WithGoogleFrontChannelLogout(true), WithLogoutRedirectURL("/goodbye?kiosk=1")},
+			expectedReturnURL: "http://example.com/goodbye?kiosk=1",
+		},
+		{
+			name:              "absolute redirect",
+			options:           []ServiceOption{WithGoogleFrontChannelLogout(true), WithLogoutRedirectURL("https://kiosk.example.com/")},
+			expectedReturnURL: "https://kiosk.example.com/",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			rr := httptest.NewRecorder()
+			h.Logout(rr, requestWithSessionEmail(t, "e@example.com"))
+			if rr.Code != http.StatusFound {
+				t.Fatalf("expected status %d, got %d", http.StatusFound, rr.Code)
+			}
+			if cookies := lastCookies(rr); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+				t.Fatalf("expected the session cookie to be deleted, got %v", cookies)
+			}
+			googleLogout, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if googleLogout.Scheme+"://"+googleLogout.Host+googleLogout.Path != googleLogoutEndpoint {
+				t.Fatalf("expected a redirect to Google's logout page, got %q", googleLogout)
+			}
+			returnChain, err := url.Parse(googleLogout.Query().Get("continue"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if returnChain.Scheme+"://"+returnChain.Host+returnChain.Path != googleLogoutReturnEndpoint {
+				t.Fatalf("expected Google to continue to the return endpoint, got %q", returnChain)
+			}
+			if returnURL := returnChain.Query().Get("continue"); returnURL != testCase.expectedReturnURL {
+				t.Fatalf("expected the chain to end at %q, got %q", testCase.expectedReturnURL, returnURL)
+			}
+		})
+	}
+}
+
+func TestLogoutWithoutGoogleFrontChannelLogout(t *testing.T) {
+	h := newTestHandlers(t, WithGoogleFrontChannelLogout(false))
+	rr := httptest.NewRecorder()
+	h.Logout(rr, requestWithSessionEmail(t, "e@example.com"))
+	if location := rr.Header().Get("Location"); location != constants.LoginPath {
+		t.Fatalf("expected a local redirect to %s, got %q", constants.LoginPath, location)
+	}
+}
+
+func TestWithGoogleFrontChannelLogoutRequiresAbsoluteBaseURL(t *testing.T) {
+	testCases := []struct {
+		name            string
+		googleOAuthBase string
+		enabled         bool
+		expectError     bool
+	}{
+		{name: "absolute base URL", googleOAuthBase: "https://example.com", enabled: true},
+		{name: "empty base URL", googleOAuthBase: "", enabled: true, expectError: true},
+		{name: "relative base URL", googleOAuthBase: "/app", enabled: true, expectError: true},
+		{name: "disabled without base URL", googleOAuthBase: "", enabled: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NewService("id", "secret", testCase.googleOAuthBase, "/dashboard", nil, "", WithGoogleFrontChannelLogout(testCase.enabled))
+			if (err != nil) != testCase.expectError {
+				t.Fatalf("expected error %t, got %v", testCase.expectError, err)
+			}
+		})
+	}
+}
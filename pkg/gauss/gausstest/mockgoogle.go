@@ -0,0 +1,188 @@
+// Package gausstest provides test helpers for applications that use GAuss.
+package gausstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+const (
+	// MockAuthorizationCode is the code the mock authorization endpoint sends
+	// to the callback.
+	MockAuthorizationCode = "mock-authorization-code"
+	// MockAccessToken is the access token issued by default.
+	MockAccessToken = "mock-access-token"
+	// MockRefreshToken is the refresh token issued with every access token.
+	MockRefreshToken = "mock-refresh-token"
+	// MockUserID is the Google account ID of the mock user.
+	MockUserID = "mock-user-id"
+
+	authPath     = "/auth"
+	tokenPath    = "/token"
+	userInfoPath = "/userinfo"
+
+	headerAuthorization = "Authorization"
+	headerContentType   = "Content-Type"
+	contentTypeJSON     = "application/json"
+	tokenLifetimeSecond = 3600
+)
+
+// mockUserInfo is the userinfo document returned by the mock server.
+type mockUserInfo struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// MockGoogleServer simulates Google's OAuth2 authorization, token and userinfo
+// endpoints. The authorization endpoint approves every request at once by
+// redirecting to redirect_uri with MockAuthorizationCode and the received
+// state, so a client that follows redirects completes the whole login. Pass
+// Option to gauss.NewService to direct a Service at the server.
+type MockGoogleServer struct {
+	server *httptest.Server
+
+	mutex               sync.Mutex
+	userInfo            mockUserInfo
+	accessToken         string
+	exchangeErrorCode   string
+	exchangeDescription string
+}
+
+// NewMockGoogleServer starts a MockGoogleServer that is closed when the test
+// ends. It issues MockAccessToken and MockRefreshToken and reports a verified
+// user@example.com called "Mock User" until configured otherwise.
+func NewMockGoogleServer(t testing.TB) *MockGoogleServer {
+	t.Helper()
+	mockServer := &MockGoogleServer{
+		userInfo:    mockUserInfo{ID: MockUserID, Email: "user@example.com", VerifiedEmail: true, Name: "Mock User"},
+		accessToken: MockAccessToken,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(authPath, mockServer.authorize)
+	mux.HandleFunc(tokenPath, mockServer.exchangeToken)
+	mux.HandleFunc(userInfoPath, mockServer.serveUserInfo)
+	mockServer.server = httptest.NewServer(mux)
+	t.Cleanup(mockServer.server.Close)
+	return mockServer
+}
+
+// URL returns the base URL of the server.
+func (mockServer *MockGoogleServer) URL() string {
+	return mockServer.server.URL
+}
+
+// AuthURL returns the URL of the authorization endpoint.
+func (mockServer *MockGoogleServer) AuthURL() string {
+	return mockServer.server.URL + authPath
+}
+
+// TokenURL returns the URL of the token endpoint.
+func (mockServer *MockGoogleServer) TokenURL() string {
+	return mockServer.server.URL + tokenPath
+}
+
+// UserInfoURL returns the URL of the userinfo endpoint.
+func (mockServer *MockGoogleServer) UserInfoURL() string {
+	return mockServer.server.URL + userInfoPath
+}
+
+// Endpoints returns the authorization, token and userinfo URLs of the
+// server, leaving Google's URLs for the endpoints it does not simulate.
+func (mockServer *MockGoogleServer) Endpoints() gauss.GoogleEndpoints {
+	return gauss.GoogleEndpoints{AuthURL: mockServer.AuthURL(), TokenURL: mockServer.TokenURL(), UserInfoURL: mockServer.UserInfoURL()}
+}
+
+// Option returns the gauss.WithGoogleEndpoints option that points a Service at
+// the server.
+func (mockServer *MockGoogleServer) Option() gauss.ServiceOption {
+	return gauss.WithGoogleEndpoints(mockServer.Endpoints())
+}
+
+// SetUserInfo sets the profile returned by the userinfo endpoint. The email
+// address is reported as verified.
+func (mockServer *MockGoogleServer) SetUserInfo(email string, name string, picture string) {
+	mockServer.mutex.Lock()
+	defer mockServer.mutex.Unlock()
+	mockServer.userInfo = mockUserInfo{ID: MockUserID, Email: email, VerifiedEmail: true, Name: name, Picture: picture}
+}
+
+// SetTokenResponse makes the token endpoint issue accessToken and clears an
+// error set with SimulateTokenExchangeError.
+func (mockServer *MockGoogleServer) SetTokenResponse(accessToken string) {
+	mockServer.mutex.Lock()
+	defer mockServer.mutex.Unlock()
+	mockServer.accessToken = accessToken
+	mockServer.exchangeErrorCode = ""
+	mockServer.exchangeDescription = ""
+}
+
+// SimulateTokenExchangeError makes the token endpoint answer 400 Bad Request
+// with the OAuth2 error code and description, such as "invalid_grant".
+func (mockServer *MockGoogleServer) SimulateTokenExchangeError(code string, description string) {
+	mockServer.mutex.Lock()
+	defer mockServer.mutex.Unlock()
+	mockServer.exchangeErrorCode = code
+	mockServer.exchangeDescription = description
+}
+
+// authorize approves the authorization request by redirecting to its
+// redirect_uri with a code and the received state.
+func (mockServer *MockGoogleServer) authorize(responseWriter http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	redirectURL, parseError := url.Parse(query.Get("redirect_uri"))
+	if parseError != nil || !redirectURL.IsAbs() {
+		http.Error(responseWriter, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	callbackQuery := redirectURL.Query()
+	callbackQuery.Set("code", MockAuthorizationCode)
+	callbackQuery.Set("state", query.Get("state"))
+	redirectURL.RawQuery = callbackQuery.Encode()
+	http.Redirect(responseWriter, request, redirectURL.String(), http.StatusFound)
+}
+
+// exchangeToken issues the configured token or the simulated error.
+func (mockServer *MockGoogleServer) exchangeToken(responseWriter http.ResponseWriter, request *http.Request) {
+	mockServer.mutex.Lock()
+	accessToken, errorCode, errorDescription := mockServer.accessToken, mockServer.exchangeErrorCode, mockServer.exchangeDescription
+	mockServer.mutex.Unlock()
+
+	if errorCode != "" {
+		writeJSON(responseWriter, http.StatusBadRequest, map[string]string{"error": errorCode, "error_description": errorDescription})
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"refresh_token": MockRefreshToken,
+		"expires_in":    tokenLifetimeSecond,
+	})
+}
+
+// serveUserInfo returns the configured profile to requests authorized with
+// the issued access token.
+func (mockServer *MockGoogleServer) serveUserInfo(responseWriter http.ResponseWriter, request *http.Request) {
+	mockServer.mutex.Lock()
+	userInfo, accessToken := mockServer.userInfo, mockServer.accessToken
+	mockServer.mutex.Unlock()
+	if request.Header.Get(headerAuthorization) != "Bearer "+accessToken {
+		writeJSON(responseWriter, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, userInfo)
+}
+
+func writeJSON(responseWriter http.ResponseWriter, statusCode int, document interface{}) {
+	responseWriter.Header().Set(headerContentType, contentTypeJSON)
+	responseWriter.WriteHeader(statusCode)
+	_ = json.NewEncoder(responseWriter).Encode(document)
+}
@@ -0,0 +1,110 @@
+package gausstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// newTestApplication serves the GAuss routes and a /dashboard page that
+// prints the email of the logged-in user.
+func newTestApplication(t *testing.T, mockServer *MockGoogleServer) *httptest.Server {
+	t.Helper()
+	session.NewSession([]byte("secret"))
+	mux := http.NewServeMux()
+	application := httptest.NewServer(mux)
+	t.Cleanup(application.Close)
+	svc, err := gauss.NewService("id", "secret", application.URL, "/dashboard", nil, "", mockServer.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers, err := gauss.NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/", handlers.Handler())
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		user, found := gauss.SessionUser(r)
+		if !found {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, user.Email)
+	})
+	return application
+}
+
+func newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+	cookieJar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: cookieJar}
+}
+
+func TestMockGoogleServerCompletesLogin(t *testing.T) {
+	mockServer := NewMockGoogleServer(t)
+	mockServer.SetUserInfo("alice@example.com", "Alice", "https://example.com/alice.png")
+	application := newTestApplication(t, mockServer)
+
+	response, err := newBrowser(t).Get(application.URL + constants.GoogleAuthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.Request.URL.Path != "/dashboard" || string(body) != "alice@example.com" {
+		t.Fatalf("expected to land on /dashboard as alice@example.com, got %s %q", response.Request.URL, body)
+	}
+}
+
+func TestMockGoogleServerSimulatesTokenExchangeError(t *testing.T) {
+	mockServer := NewMockGoogleServer(t)
+	mockServer.SimulateTokenExchangeError("invalid_client", "Unauthorized")
+	application := newTestApplication(t, mockServer)
+
+	response, err := newBrowser(t).Get(application.URL + constants.GoogleAuthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.Request.URL.Path != constants.LoginPath || response.Request.URL.Query().Get("error_code") != string(gauss.ErrCodeTokenExchange) {
+		t.Fatalf("expected the login page with the token exchange error, got %s", response.Request.URL)
+	}
+}
+
+func TestMockGoogleServerTokenResponse(t *testing.T) {
+	mockServer := NewMockGoogleServer(t)
+	mockServer.SimulateTokenExchangeError("invalid_grant", "Bad Request")
+	svc, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", mockServer.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = svc.ExchangeAuthorizationCode(context.Background(), MockAuthorizationCode, "s1", "s1")
+	var tokenExchangeError *gauss.TokenExchangeError
+	if !errors.As(err, &tokenExchangeError) || tokenExchangeError.Code != "invalid_grant" {
+		t.Fatalf("expected invalid_grant, got %v", err)
+	}
+
+	mockServer.SetTokenResponse("custom-access-token")
+	oauthToken, user, err := svc.ExchangeAuthorizationCode(context.Background(), MockAuthorizationCode, "s1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oauthToken.AccessToken != "custom-access-token" || oauthToken.RefreshToken != MockRefreshToken {
+		t.Fatalf("unexpected token %+v", oauthToken)
+	}
+	if user.ID != MockUserID || user.Email != "user@example.com" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
@@ -3,82 +3,274 @@ package gauss
 import (
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
-	"log"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/session"
+	"github.com/temirov/GAuss/pkg/session/cookie"
 	"golang.org/x/oauth2"
 )
 
 //go:embed templates/*.html
 var templatesFileSystem embed.FS
 
+// themeScript toggles the embedded login page between light and dark mode.
+// It is served from ThemeScriptPath rather than inlined, so the page's
+// Content-Security-Policy can forbid inline scripts.
+//
+//go:embed static/theme.js
+var themeScript []byte
+
+const (
+	sessionKeyOAuthState        = "oauth_state"
+	sessionKeyConsentRetry      = "oauth_consent_retry"
+	queryParameterError         = "error"
+	queryParameterErrorCode     = "error_code"
+	sessionIdentifierByteLength = 32
+	flashKeyErrors              = "gauss_flash"
+)
+
+// builtInTemplateKeys lists the login template data keys set by loginHandler.
+// Application supplied data may not override them.
+var builtInTemplateKeys = map[string]struct{}{
+	"error":            {},
+	"errorCode":        {},
+	"ErrorMessage":     {},
+	"ErrorCode":        {},
+	"flashes":          {},
+	"googleAuthPath":   {},
+	"googleAuthAction": {},
+	"returnTo":         {},
+	"rememberMe":       {},
+}
+
 // Handlers bundles the GAuss service, session store, and HTML templates used
 // for authentication. Instances of Handlers register HTTP endpoints that
-// implement the login and callback workflow.
+// implement the login and callback workflow and also implement http.Handler so
+// the whole subsystem can be mounted at once.
 type Handlers struct {
-	service   *Service
-	store     *sessions.CookieStore
-	templates *template.Template
+	service           *Service
+	store             sessions.Store
+	templates         *template.Template
+	loginTemplateName string
+	routeMux          *http.ServeMux
+	sessionName       string
 }
 
-// NewHandlers constructs a Handlers value from a Service. It loads the login
-// templates either from the custom path specified on the Service or from the
-// embedded templates bundled with GAuss.
+// NewHandlers constructs a Handlers value from a Service. It uses the login
+// templates injected with WithTemplates or loads them from the file system
+// configured with WithTemplateFS, from the custom path specified on the
+// Service or from the embedded templates bundled with GAuss, making any
+// functions registered with WithTemplateFuncMap available to them.
 func NewHandlers(serviceInstance *Service) (*Handlers, error) {
-	var (
-		parsedTemplates *template.Template
-		err             error
-	)
-	if serviceInstance.LoginTemplate != "" {
-		parsedTemplates, err = template.ParseFiles(serviceInstance.LoginTemplate)
-	} else {
-		parsedTemplates, err = template.ParseFS(templatesFileSystem, constants.TemplatesPath)
-	}
+	parsedTemplates, loginTemplateName, err := serviceInstance.parseLoginTemplates()
 	if err != nil {
 		return nil, err
 	}
 
-	cookieStore := session.Store()
+	serviceInstance.bindSessionStore()
+
+	handlersInstance := &Handlers{
+		service:           serviceInstance,
+		store:             serviceInstance.sessionStore,
+		templates:         parsedTemplates,
+		loginTemplateName: loginTemplateName,
+		routeMux:          http.NewServeMux(),
+		sessionName:       serviceInstance.sessionName,
+	}
+	for _, authRoute := range handlersInstance.routes() {
+		for _, pattern := range handlersInstance.muxPatterns(authRoute) {
+			handlersInstance.routeMux.HandleFunc(pattern, authRoute.handler)
+		}
+	}
+
+	return handlersInstance, nil
+}
+
+// route pairs a URL path and the methods it accepts with the handler that
+// serves it.
+type route struct {
+	pattern string
+	methods []string
+	handler http.HandlerFunc
+}
+
+// routes lists every endpoint served by Handlers. The callback also accepts
+// POST when Google delivers the response as a form post, and logout accepts
+// POST so applications can sign out from a form. Disconnect accepts only POST.
+// The scopes path is served only when WithIncrementalScopes is configured, the
+// discovery document only with WithWellKnown and the diagnostics only with
+// WithDebugEndpoint.
+func (handlersInstance *Handlers) routes() []route {
+	callbackMethods := []string{http.MethodGet}
+	if handlersInstance.service.responseMode == responseModeFormPost {
+		callbackMethods = append(callbackMethods, http.MethodPost)
+	}
+	authRoutes := []route{
+		{pattern: handlersInstance.service.loginPath, methods: []string{http.MethodGet}, handler: handlersInstance.loginHandler},
+		{pattern: handlersInstance.service.googleAuthPath, methods: []string{http.MethodGet}, handler: handlersInstance.service.rateLimited(handlersInstance.Login)},
+		{pattern: handlersInstance.service.callbackPath.Path, methods: callbackMethods, handler: handlersInstance.service.rateLimited(handlersInstance.Callback)},
+		{pattern: handlersInstance.service.logoutPath, methods: []string{http.MethodGet, http.MethodPost}, handler: handlersInstance.service.rateLimited(handlersInstance.Logout)},
+		{pattern: handlersInstance.service.disconnectPath, methods: []string{http.MethodPost}, handler: handlersInstance.service.rateLimited(handlersInstance.Disconnect)},
+		{pattern: constants.ThemeScriptPath, methods: []string{http.MethodGet}, handler: serveThemeScript},
+	}
+	if handlersInstance.service.wellKnown {
+		authRoutes = append(authRoutes, route{pattern: constants.WellKnownPath, methods: []string{http.MethodGet}, handler: handlersInstance.WellKnownHandler()})
+	}
+	if handlersInstance.service.debugEndpoint {
+		authRoutes = append(authRoutes, route{pattern: constants.DebugPath, methods: []string{http.MethodGet}, handler: handlersInstance.DebugHandler()})
+	}
+	if len(handlersInstance.service.incrementalScopes) > 0 {
+		authRoutes = append(authRoutes, route{pattern: handlersInstance.service.scopesPath, methods: []string{http.MethodGet}, handler: handlersInstance.service.rateLimited(handlersInstance.RequestScopes)})
+	}
+	return authRoutes
+}
+
+// muxPatterns returns the ServeMux patterns that register authRoute. Each
+// method gets its own "METHOD /path" pattern, so the mux answers other
+// methods with 405 Method Not Allowed and an Allow header. With
+// WithPlainRoutePatterns the bare path is returned instead.
+func (handlersInstance *Handlers) muxPatterns(authRoute route) []string {
+	if handlersInstance.service.plainRoutePatterns {
+		return []string{authRoute.pattern}
+	}
+	patterns := make([]string, 0, len(authRoute.methods))
+	for _, method := range authRoute.methods {
+		patterns = append(patterns, method+" "+authRoute.pattern)
+	}
+	return patterns
+}
+
+// LoginPageHandler returns the handler that renders the login page, so it can
+// be mounted independently of RegisterRoutes.
+func (handlersInstance *Handlers) LoginPageHandler() http.Handler {
+	return withSecurityHeaders(http.HandlerFunc(handlersInstance.loginHandler))
+}
+
+// ThemeScriptHandler returns the handler that serves the script behind the
+// theme switch of the embedded login page. Mount it at
+// constants.ThemeScriptPath next to LoginPageHandler.
+func (handlersInstance *Handlers) ThemeScriptHandler() http.Handler {
+	return withSecurityHeaders(http.HandlerFunc(serveThemeScript))
+}
+
+// serveThemeScript writes the theme switch script.
+func serveThemeScript(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set(headerContentType, mediaTypeJavaScript)
+	if _, writeError := responseWriter.Write(themeScript); writeError != nil {
+		logRequestf(request, "Failed to write the theme script: %v", writeError)
+	}
+}
+
+// LoginHandler returns the handler that starts the OAuth2 flow with Google.
+// Like CallbackHandler and LogoutHandler it applies WithRateLimit.
+func (handlersInstance *Handlers) LoginHandler() http.Handler {
+	return withSecurityHeaders(handlersInstance.service.rateLimited(handlersInstance.Login))
+}
 
-	return &Handlers{
-		service:   serviceInstance,
-		store:     cookieStore,
-		templates: parsedTemplates,
-	}, nil
+// CallbackHandler returns the handler that completes the OAuth2 flow. It must
+// be mounted at the Service's callback path, which Google redirects to.
+func (handlersInstance *Handlers) CallbackHandler() http.Handler {
+	return withSecurityHeaders(handlersInstance.service.rateLimited(handlersInstance.Callback))
+}
+
+// LogoutHandler returns the handler that clears the user session.
+func (handlersInstance *Handlers) LogoutHandler() http.Handler {
+	return withSecurityHeaders(handlersInstance.service.rateLimited(handlersInstance.Logout))
+}
+
+// ServeHTTP dispatches requests for the GAuss routes, allowing Handlers to be
+// mounted as a single http.Handler in any router. Requests for other paths
+// receive a 404 response. Every response is marked uncacheable and sent with
+// Referrer-Policy: no-referrer.
+func (handlersInstance *Handlers) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	setSecurityHeaders(responseWriter)
+	handlersInstance.routeMux.ServeHTTP(responseWriter, withMountPrefix(request))
 }
 
 // RegisterRoutes installs the GAuss authentication handlers onto the provided
-// ServeMux. It returns the mux for convenience so it can be used inline.
+// ServeMux using method-restricted patterns such as "GET /login". Every route
+// is delegated to the Handlers' own mux through ServeHTTP, which adds the
+// no-store and no-referrer headers. It returns the mux for convenience so it
+// can be used inline.
 func (handlersInstance *Handlers) RegisterRoutes(httpMux *http.ServeMux) *http.ServeMux {
-	httpMux.HandleFunc(constants.LoginPath, handlersInstance.loginHandler)
-	httpMux.HandleFunc(constants.GoogleAuthPath, handlersInstance.Login)
-	httpMux.HandleFunc(constants.CallbackPath, handlersInstance.Callback)
-	httpMux.HandleFunc(constants.LogoutPath, handlersInstance.Logout)
+	for _, authRoute := range handlersInstance.routes() {
+		for _, pattern := range handlersInstance.muxPatterns(authRoute) {
+			httpMux.Handle(pattern, handlersInstance)
+		}
+	}
 
 	return httpMux
 }
 
 // loginHandler renders the login page. If a custom template was supplied when
 // creating the Service it is used; otherwise the embedded template named by
-// constants.DefaultTemplateName is executed.
+// constants.DefaultTemplateName is executed. Error codes flashed by the
+// callback, or failing that a known code from the error query parameter, are
+// translated into a human-readable "error" value; unknown codes are ignored.
+// With WithAutoLogin the page is only rendered when there is an error to show
+// or the automatic redirects have been exhausted. The page is sent with the
+// policy set by WithContentSecurityPolicy.
 func (handlersInstance *Handlers) loginHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	handlersInstance.service.setPageSecurityHeaders(responseWriter)
+	flashedCodes := handlersInstance.consumeFlashes(responseWriter, request)
+	rawQueryCode := request.URL.Query().Get(queryParameterError)
+	returnTo := handlersInstance.service.requestedReturnTo(request)
+	if len(flashedCodes) == 0 && !request.URL.Query().Has(queryParameterError) && handlersInstance.service.autoLoginRedirect(responseWriter, request, returnTo) {
+		return
+	}
+
+	var displayedCode AuthErrorCode
+	displayedMessage := ""
+	switch {
+	case len(flashedCodes) > 0:
+		displayedCode = flashedCodes[0]
+		displayedMessage = handlersInstance.service.errorMessage(request, displayedCode)
+	case rawQueryCode != "":
+		if queryCode, known := handlersInstance.service.knownErrorCode(rawQueryCode); known {
+			displayedCode = queryCode
+		}
+		displayedMessage = handlersInstance.service.errorMessage(request, displayedCode)
+	}
+	flashMessages := make([]string, 0, len(flashedCodes))
+	for _, flashedCode := range flashedCodes {
+		flashMessages = append(flashMessages, handlersInstance.service.errorMessage(request, flashedCode))
+	}
+
 	dataMap := map[string]interface{}{
-		"error": request.URL.Query().Get("error"),
+		"error":            displayedMessage,
+		"errorCode":        string(displayedCode),
+		"ErrorMessage":     displayedMessage,
+		"ErrorCode":        string(displayedCode),
+		"flashes":          flashMessages,
+		"googleAuthPath":   withReturnTo(handlersInstance.service.mountedURL(request, handlersInstance.service.googleAuthPath), returnTo),
+		"googleAuthAction": handlersInstance.service.mountedURL(request, handlersInstance.service.googleAuthPath),
+		"returnTo":         returnTo,
+		"rememberMe":       handlersInstance.service.rememberMeEnabled,
+		"themeScriptPath":  handlersInstance.service.mountedURL(request, constants.ThemeScriptPath),
+	}
+	handlersInstance.mergeTemplateData(request, dataMap, handlersInstance.service.customTemplateData)
+	if loginTemplateData := handlersInstance.service.loginTemplateData; loginTemplateData != nil {
+		handlersInstance.mergeTemplateData(request, dataMap, loginTemplateData(request))
 	}
 
-	var templateName string
-	if handlersInstance.service.LoginTemplate != "" {
-		templateName = filepath.Base(handlersInstance.service.LoginTemplate)
-	} else {
-		templateName = constants.DefaultTemplateName
+	parsedTemplates, loginTemplateName := handlersInstance.templates, handlersInstance.loginTemplateName
+	if handlersInstance.service.templateReload {
+		var parseError error
+		parsedTemplates, loginTemplateName, parseError = handlersInstance.service.parseLoginTemplates()
+		if parseError != nil {
+			writeTemplateReloadError(responseWriter, request, parseError)
+			return
+		}
 	}
 
-	tmpl := handlersInstance.templates.Lookup(templateName)
+	tmpl := parsedTemplates.Lookup(loginTemplateName)
 	if tmpl == nil {
 		http.Error(responseWriter, "Login template not found", http.StatusInternalServerError)
 		return
@@ -90,92 +282,268 @@ func (handlersInstance *Handlers) loginHandler(responseWriter http.ResponseWrite
 	}
 }
 
+// mergeTemplateData copies applicationData into dataMap, skipping the keys in
+// builtInTemplateKeys so that an application value cannot replace the error
+// shown to the user.
+func (handlersInstance *Handlers) mergeTemplateData(request *http.Request, dataMap map[string]interface{}, applicationData map[string]interface{}) {
+	for dataKey, dataValue := range applicationData {
+		if _, reserved := builtInTemplateKeys[dataKey]; reserved {
+			logRequestf(request, "Ignoring login template data for reserved key %q", dataKey)
+			continue
+		}
+		dataMap[dataKey] = dataValue
+	}
+}
+
+// consumeFlashes returns and clears the error codes flashed by
+// redirectWithError, dropping any value that is not a known error code.
+func (handlersInstance *Handlers) consumeFlashes(responseWriter http.ResponseWriter, request *http.Request) []AuthErrorCode {
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	flashes := cookie.TakeFlashMessages(webSession, flashKeyErrors)
+	if len(flashes) == 0 {
+		return nil
+	}
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to clear flash messages: %v", sessionSaveError)
+	}
+	flashedCodes := make([]AuthErrorCode, 0, len(flashes))
+	for _, flashedValue := range flashes {
+		if flashedCode, known := handlersInstance.service.knownErrorCode(flashedValue); known {
+			flashedCodes = append(flashedCodes, flashedCode)
+		}
+	}
+	return flashedCodes
+}
+
 // Login initiates the OAuth2 flow with Google by generating a state value,
 // storing it in the session and redirecting the user to Google's authorization
-// endpoint.
+// endpoint. A return-to URL in the next query parameter that passes the
+// open-redirect checks is stored alongside the state and becomes the redirect
+// target after a successful Callback. With WithDevFakeAuth the fake user is
+// signed in directly instead.
 func (handlersInstance *Handlers) Login(responseWriter http.ResponseWriter, request *http.Request) {
+	request = handlersInstance.service.withRequestID(responseWriter, request)
+	spanContext, span := handlersInstance.service.startSpan(request.Context(), spanNameLogin)
+	defer span.End()
+	request = request.WithContext(spanContext)
+	if handlersInstance.service.devFakeUser != nil {
+		handlersInstance.devFakeLogin(responseWriter, request)
+		return
+	}
 	stateValue, stateError := handlersInstance.service.GenerateState()
 	if stateError != nil {
-		log.Printf("Failed to generate state: %v", stateError)
-		http.Error(responseWriter, "Internal Server Error", http.StatusInternalServerError)
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeStateGeneration, "Failed to generate state", stateError))
 		return
 	}
 
-	webSession, _ := handlersInstance.store.Get(request, constants.SessionName)
-	webSession.Values["oauth_state"] = stateValue
-	if sessionSaveError := webSession.Save(request, responseWriter); sessionSaveError != nil {
-		log.Printf("Failed to save session: %v", sessionSaveError)
-		http.Error(responseWriter, "Internal Server Error", http.StatusInternalServerError)
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	webSession.Values[sessionKeyOAuthState] = stateValue
+	delete(webSession.Values, sessionKeyIncrementalScopes)
+	handlersInstance.service.rememberReturnTo(webSession, request)
+	handlersInstance.service.rememberLoginChoice(webSession, request)
+	handlersInstance.service.allowCrossSiteCallback(webSession)
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to save session", sessionSaveError))
 		return
 	}
 
 	oauthConfig := handlersInstance.service.authorizationConfigForRequest(request)
 
-	authorizationURL := oauthConfig.AuthCodeURL(
-		stateValue,
-		oauth2.AccessTypeOffline,
-		oauth2.SetAuthURLParam("prompt", "consent"),
-	)
+	authorizationURL := handlersInstance.service.buildAuthorizationURL(oauthConfig, stateValue, handlersInstance.service.loginPrompt(webSession))
+	handlersInstance.service.metrics.LoginStarted()
 	http.Redirect(responseWriter, request, authorizationURL, http.StatusFound)
 }
 
 // Callback completes the OAuth2 flow. It validates the state value, exchanges
 // the code for a token and stores the retrieved user information in the
-// session before redirecting to the configured post-login URL.
+// session before redirecting to the configured post-login URL. Both the default
+// query-string response and form_post responses delivered via POST are
+// accepted. The stored state is removed from the session as soon as it has been
+// compared so that a replayed callback URL cannot validate a second time, and
+// the session is regenerated before the user is stored to prevent session
+// fixation. A repeated callback, from a double click or a prefetch, that
+// carries the state of the login already completed in the session, or whose
+// matching state comes with a code Google reports as already redeemed, is
+// redirected to the post-login URL without saving the session. Clients
+// blocked by WithBruteForceProtection receive 429 Too Many Requests. With
+// WithDevFakeAuth the callback signs the fake user in like Login.
 func (handlersInstance *Handlers) Callback(responseWriter http.ResponseWriter, request *http.Request) {
-	webSession, _ := handlersInstance.store.Get(request, constants.SessionName)
-	storedStateValue, stateOk := webSession.Values["oauth_state"].(string)
+	request = handlersInstance.service.withRequestID(responseWriter, request)
+	spanContext, span := handlersInstance.service.startSpan(request.Context(), spanNameCallback)
+	defer span.End()
+	request = request.WithContext(spanContext)
+	if handlersInstance.service.devFakeUser != nil {
+		handlersInstance.devFakeLogin(responseWriter, request)
+		return
+	}
+	if handlersInstance.service.rejectBlockedClient(responseWriter, request) {
+		return
+	}
+	if request.Method == http.MethodPost {
+		request.Body = http.MaxBytesReader(responseWriter, request.Body, callbackFormMaxBytes)
+	}
+	authorizationCode, receivedStateValue, callbackError := ParseCallbackRequest(request)
+	if callbackError != nil && !isCallbackValidationError(callbackError) {
+		logRequestf(request, "Failed to parse callback form: %v", callbackError)
+		http.Error(responseWriter, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	if replayedCallback(webSession, receivedStateValue) {
+		handlersInstance.redirectAfterReplay(responseWriter, request, "the session already completed this login")
+		return
+	}
+	storedStateValue, stateOk := webSession.Values[sessionKeyOAuthState].(string)
 	if !stateOk {
-		log.Println("Missing state in session")
-		http.Redirect(responseWriter, request, constants.LoginPath+"?error=missing_state", http.StatusFound)
+		handlersInstance.service.recordCallbackFailure(request)
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeMissingState, "Missing state in session", nil))
 		return
 	}
 
-	receivedStateValue := request.URL.Query().Get("state")
-	if storedStateValue != receivedStateValue {
-		log.Printf("State mismatch: stored %s vs received %s", storedStateValue, receivedStateValue)
-		http.Redirect(responseWriter, request, constants.LoginPath+"?error=invalid_state", http.StatusFound)
+	delete(webSession.Values, sessionKeyOAuthState)
+	failCallback := func(authError *AuthError) {
+		delete(webSession.Values, sessionKeyConsentRetry)
+		if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+			logRequestf(request, "Failed to save session: %v", sessionSaveError)
+		}
+		handlersInstance.failLogin(responseWriter, request, authError)
+	}
+
+	if errors.Is(callbackError, ErrMissingState) || storedStateValue != receivedStateValue {
+		stateMismatchMessage := fmt.Sprintf("State mismatch: stored %s vs received %s", storedStateValue, receivedStateValue)
+		handlersInstance.service.recordCallbackFailure(request)
+		failCallback(newAuthError(ErrCodeStateMismatch, stateMismatchMessage, nil))
 		return
 	}
 
-	authorizationCode := request.URL.Query().Get("code")
-	if authorizationCode == "" {
-		log.Println("Missing authorization code")
-		http.Redirect(responseWriter, request, constants.LoginPath+"?error=missing_code", http.StatusFound)
+	if errors.Is(callbackError, ErrAuthorizationFailed) {
+		if handlersInstance.service.retriesInteraction(request.FormValue(callbackParameterError)) && handlersInstance.retryWithConsent(responseWriter, request, webSession, "Google requires interaction") {
+			return
+		}
+		failCallback(newAuthError(ErrCodeAuthorizationFailed, "Authorization failed", callbackError))
+		return
+	}
+
+	if errors.Is(callbackError, ErrMissingCode) {
+		failCallback(newAuthError(ErrCodeMissingCode, "Missing authorization code", nil))
 		return
 	}
 
 	oauthConfig := handlersInstance.service.authorizationConfigForRequest(request)
 
-	oauthToken, tokenExchangeError := oauthConfig.Exchange(request.Context(), authorizationCode)
+	oauthToken, tokenExchangeError := handlersInstance.service.exchangeCode(request.Context(), oauthConfig, authorizationCode)
 	if tokenExchangeError != nil {
-		log.Printf("Token exchange failed: %v", tokenExchangeError)
-		http.Redirect(responseWriter, request, constants.LoginPath+"?error=token_exchange_failed", http.StatusFound)
+		if invalidAuthorizationCode(tokenExchangeError) {
+			handlersInstance.service.recordCallbackFailure(request)
+			handlersInstance.redirectAfterReplay(responseWriter, request, "the authorization code was already redeemed")
+			return
+		}
+		failCallback(newAuthError(ErrCodeTokenExchange, "Token exchange failed", tokenExchangeError))
 		return
 	}
+	webSession.Values[sessionKeyCompletedState] = stateDigest(receivedStateValue)
 
-	if oauthToken.RefreshToken == "" {
-		log.Printf("Missing refresh token; re-requesting consent")
-		handlersInstance.Login(responseWriter, request)
+	if _, incremental := webSession.Values[sessionKeyIncrementalScopes]; incremental {
+		handlersInstance.completeScopeGrant(responseWriter, request, webSession, oauthToken)
 		return
 	}
 
-	hasProfileScope := false
-	for _, scope := range oauthConfig.Scopes {
-		if scope == string(ScopeProfile) || scope == string(ScopeEmail) {
-			hasProfileScope = true
-			break
+	if oauthToken.RefreshToken == "" && !handlersInstance.service.onlineAccess && !handlersInstance.service.keepsStoredRefreshToken(webSession) {
+		switch {
+		case handlersInstance.retryWithConsent(responseWriter, request, webSession, "Missing refresh token"):
+			return
+		case !handlersInstance.service.allowMissingRefreshToken:
+			failCallback(newAuthError(ErrCodeRefreshTokenUnavailable, "Missing refresh token after re-requesting consent", nil))
+			return
+		default:
+			logRequestf(request, "Missing refresh token after re-requesting consent; continuing without it")
 		}
 	}
 
-	if hasProfileScope {
+	handlersInstance.completeLogin(responseWriter, request, webSession, oauthToken, true, failCallback)
+}
+
+// retryWithConsent restarts the login with the consent screen, logging
+// reason, unless webSession already retried it. It reports whether the login
+// was restarted.
+func (handlersInstance *Handlers) retryWithConsent(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session, reason string) bool {
+	if _, consentRetried := webSession.Values[sessionKeyConsentRetry].(bool); consentRetried {
+		return false
+	}
+	logRequestf(request, "%s; re-requesting consent", reason)
+	webSession.Values[sessionKeyConsentRetry] = true
+	handlersInstance.Login(responseWriter, request)
+	return true
+}
+
+// completeLogin finishes an authorization that produced oauthToken: it fetches
+// the profile when profile scopes were requested and signs the user in with
+// signIn. When Google withholds the refresh token because the user picked
+// an account other than the one whose token the session keeps, the login is
+// restarted with the consent screen if consentRetryAllowed. Failures before
+// the session is saved are reported through failCallback.
+func (handlersInstance *Handlers) completeLogin(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session, oauthToken *oauth2.Token, consentRetryAllowed bool, failCallback func(*AuthError)) {
+	var googleUser *GoogleUser
+	if handlersInstance.service.requestsProfile() {
 		// If profile scopes were requested, fetch user info as before.
-		googleUser, getUserError := handlersInstance.service.GetUser(oauthToken)
+		fetchedUser, getUserError := handlersInstance.service.fetchGoogleUser(request.Context(), oauthToken)
 		if getUserError != nil {
-			log.Printf("Failed to get user info: %v", getUserError)
-			http.Redirect(responseWriter, request, constants.LoginPath+"?error=user_info_failed", http.StatusFound)
+			failCallback(newAuthError(ErrCodeUserInfo, "Failed to get user info", getUserError))
 			return
 		}
+		if fetchedUser.Email != "" && !fetchedUser.VerifiedEmail && !handlersInstance.service.allowUnverifiedEmail {
+			failCallback(newAuthError(ErrCodeEmailNotVerified, "Email address not verified", fmt.Errorf("email of user %s is unverified", fetchedUser.ID)))
+			return
+		}
+		googleUser = fetchedUser
+	}
+	if oauthToken.RefreshToken == "" && handlersInstance.service.keepsStoredRefreshToken(webSession) {
+		storedRefreshToken := storedRefreshTokenFor(webSession, googleUser)
+		if storedRefreshToken == "" && !handlersInstance.service.allowMissingRefreshToken {
+			if consentRetryAllowed && handlersInstance.retryWithConsent(responseWriter, request, webSession, "Missing refresh token for a different account") {
+				return
+			}
+			failCallback(newAuthError(ErrCodeRefreshTokenUnavailable, "Missing refresh token for a different account", nil))
+			return
+		}
+		oauthToken.RefreshToken = storedRefreshToken
+	}
+	delete(webSession.Values, sessionKeyConsentRetry)
+	handlersInstance.signIn(responseWriter, request, webSession, googleUser, oauthToken, failCallback)
+}
+
+// signIn runs the login success hook, regenerates webSession, stores
+// googleUser and oauthToken in it and sends the client on. A nil googleUser
+// marks the session as authenticated for API access only. Failures before the
+// session is saved are reported through failCallback.
+func (handlersInstance *Handlers) signIn(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session, googleUser *GoogleUser, oauthToken *oauth2.Token, failCallback func(*AuthError)) {
+	if loginSuccessHook := handlersInstance.service.loginSuccessHook; loginSuccessHook != nil {
+		if hookError := loginSuccessHook(request.Context(), googleUser, oauthToken); hookError != nil {
+			failCallback(newAuthError(ErrCodeLoginRejected, "Login rejected", hookError))
+			return
+		}
+	}
+
+	redirectTarget := handlersInstance.service.loginRedirectTarget(request, webSession)
+	rememberMe, _ := webSession.Values[sessionKeyRememberMe].(bool)
+	completedState, _ := webSession.Values[sessionKeyCompletedState].(string)
+	if regenerateError := handlersInstance.service.regenerateSession(webSession); regenerateError != nil {
+		failCallback(newAuthError(ErrCodeSessionSave, "Failed to regenerate session", regenerateError))
+		return
+	}
+	if completedState != "" {
+		webSession.Values[sessionKeyCompletedState] = completedState
+	}
+	handlersInstance.service.applySessionLifetime(webSession, rememberMe)
+	webSession.Values[constants.SessionKeyAuthenticatedAt] = handlersInstance.service.now().Unix()
+	webSession.Values[constants.SessionKeyLastSeen] = handlersInstance.service.now().Unix()
+	webSession.Values[constants.SessionKeyTokenValidatedAt] = handlersInstance.service.now().Unix()
+
+	if googleUser != nil {
+		if googleUser.ID != "" {
+			webSession.Values[constants.SessionKeyUserID] = googleUser.ID
+		}
 		webSession.Values[constants.SessionKeyUserEmail] = googleUser.Email
 		webSession.Values[constants.SessionKeyUserName] = googleUser.Name
 		webSession.Values[constants.SessionKeyUserPicture] = googleUser.Picture
@@ -183,36 +551,124 @@ func (handlersInstance *Handlers) Callback(responseWriter http.ResponseWriter, r
 		// If no profile scopes were requested, the user is still authenticated for API access.
 		// We set a generic, non-nil value in the session key that the AuthMiddleware checks.
 		// This confirms a valid session exists without needing the user's actual email.
-		webSession.Values[constants.SessionKeyUserEmail] = "authenticated_api_user"
+		webSession.Values[constants.SessionKeyUserEmail] = apiOnlyUserEmail
+	}
+
+	handlersInstance.service.bindSession(webSession, request)
+	if googleUser != nil {
+		if registryError := handlersInstance.service.registerSession(request.Context(), googleUser.ID, webSession); registryError != nil {
+			failCallback(registryError)
+			return
+		}
 	}
 
 	// ALWAYS store the OAuth token, as this is the primary artifact for API-driven apps.
 	if tokenBytes, err := json.Marshal(oauthToken); err == nil {
 		webSession.Values[constants.SessionKeyOAuthToken] = string(tokenBytes)
 	} else {
-		log.Printf("Failed to marshal token: %v", err)
+		logRequestf(request, "Failed to marshal token: %v", err)
 	}
-	if sessionSaveError := webSession.Save(request, responseWriter); sessionSaveError != nil {
-		log.Printf("Failed to save user session: %v", sessionSaveError)
-		http.Redirect(responseWriter, request, constants.LoginPath+"?error=session_save_failed", http.StatusFound)
+	webSession.Values[constants.SessionKeyGrantedScopes] = strings.Join(grantedScopes(oauthToken, handlersInstance.service.config.Scopes), " ")
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to save user session", sessionSaveError))
 		return
 	}
+	handlersInstance.service.metrics.LoginSucceeded()
+	handlersInstance.service.clearAutoLoginAttempts(responseWriter, request)
 
-	http.Redirect(responseWriter, request, handlersInstance.service.localRedirectURL, http.StatusFound)
+	if handlersInstance.service.popupCallback {
+		handlersInstance.service.renderPopupResult(responseWriter, request, popupMessage{OK: true})
+		return
+	}
+	http.Redirect(responseWriter, request, redirectTarget, http.StatusFound)
 }
 
-// Logout removes all authentication information from the session and redirects
-// the client to the configured logout destination.
-func (handlersInstance *Handlers) Logout(responseWriter http.ResponseWriter, request *http.Request) {
-	webSession, _ := handlersInstance.store.Get(request, constants.SessionName)
-	webSession.Options.MaxAge = -1
-	if webSessionSaveError := webSession.Save(request, responseWriter); webSessionSaveError != nil {
-		http.Error(responseWriter, webSessionSaveError.Error(), http.StatusInternalServerError)
+// failLogin reports a failed login attempt to the login failure hook and then
+// responds through handleAuthError, or reports the error code to the opener
+// window in popup mode when no error handler is configured.
+func (handlersInstance *Handlers) failLogin(responseWriter http.ResponseWriter, request *http.Request, authError *AuthError) {
+	handlersInstance.service.notifyLoginFailure(request, authError)
+	handlersInstance.service.metrics.LoginFailed(authError.Code)
+	recordSpanFailure(request.Context(), authError.Code)
+	if handlersInstance.service.popupCallback && handlersInstance.service.errorHandler == nil {
+		logRequestf(request, "%s", authError.Error())
+		handlersInstance.service.renderPopupResult(responseWriter, request, popupMessage{Error: string(authError.Code)})
 		return
 	}
-	redirectTarget := handlersInstance.service.logoutRedirectURL
-	if redirectTarget == "" {
-		redirectTarget = constants.LoginPath
+	handlersInstance.handleAuthError(responseWriter, request, authError)
+}
+
+// handleAuthError logs the failure and delegates the response to the error
+// handler configured with WithErrorHandler, falling back to redirectWithError.
+func (handlersInstance *Handlers) handleAuthError(responseWriter http.ResponseWriter, request *http.Request, authError *AuthError) {
+	logRequestf(request, "%s", authError.Error())
+	if authError.Request == nil {
+		authError.Request = request
 	}
-	http.Redirect(responseWriter, request, redirectTarget, http.StatusFound)
+	if handlersInstance.service.errorHandler != nil {
+		handlersInstance.service.errorHandler(responseWriter, request, authError)
+		return
+	}
+	handlersInstance.redirectWithError(responseWriter, request, authError)
+}
+
+// redirectWithError is the default error handler. It records the error code as
+// a signed one-time flash in the session and sends the client back to the login
+// page, adding the code to the error and error_code query parameters unless
+// WithFlashMessages is enabled.
+func (handlersInstance *Handlers) redirectWithError(responseWriter http.ResponseWriter, request *http.Request, authError *AuthError) {
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	webSession.AddFlash(string(authError.Code), flashKeyErrors)
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to save flash message: %v", sessionSaveError)
+	}
+	if handlersInstance.service.flashMessages {
+		http.Redirect(responseWriter, request, handlersInstance.service.mountedURL(request, handlersInstance.service.loginPath), http.StatusFound)
+		return
+	}
+	errorQuery := url.Values{
+		queryParameterError:     {string(authError.Code)},
+		queryParameterErrorCode: {string(authError.Code)},
+	}
+	http.Redirect(responseWriter, request, handlersInstance.service.mountedURL(request, handlersInstance.service.loginPath+"?"+errorQuery.Encode()), http.StatusFound)
+}
+
+// regenerateSession discards every value carried over from the pre-login
+// session and assigns a fresh session identifier and CSRF key so that a
+// session planted by an attacker before login never becomes an authenticated
+// one. Clearing the ID makes server-side stores allocate a new record on the
+// next save.
+func (serviceInstance *Service) regenerateSession(webSession *sessions.Session) error {
+	sessionIdentifier, identifierError := randomToken(sessionIdentifierByteLength)
+	if identifierError != nil {
+		return identifierError
+	}
+	csrfKey, keyError := randomToken(sessionIdentifierByteLength)
+	if keyError != nil {
+		return keyError
+	}
+	for sessionKey := range webSession.Values {
+		delete(webSession.Values, sessionKey)
+	}
+	webSession.ID = ""
+	webSession.Values[constants.SessionKeySessionID] = sessionIdentifier
+	webSession.Values[sessionKeyCSRFKey] = csrfKey
+	return nil
+}
+
+// Logout removes all authentication information from the session, notifies the
+// logout hook and redirects the client to the configured logout destination,
+// by way of Google's logout page when WithGoogleFrontChannelLogout is set.
+func (handlersInstance *Handlers) Logout(responseWriter http.ResponseWriter, request *http.Request) {
+	request = handlersInstance.service.withRequestID(responseWriter, request)
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	loggedOutEmail, _ := webSession.Values[constants.SessionKeyUserEmail].(string)
+	handlersInstance.service.unregisterSession(request, webSession)
+	if deleteError := session.DeleteWebSession(responseWriter, request, webSession); deleteError != nil {
+		handlersInstance.handleAuthError(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to clear session", deleteError))
+		return
+	}
+	handlersInstance.service.notifyLogout(request, loggedOutEmail)
+	handlersInstance.service.metrics.LoggedOut()
+	http.Redirect(responseWriter, request, handlersInstance.service.logoutDestination(request), http.StatusFound)
 }
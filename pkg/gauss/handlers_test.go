@@ -2,15 +2,19 @@ package gauss
 
 import (
 	"encoding/json"
+	"html/template"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/session"
-	"golang.org/x/oauth2"
 )
 
 // helper to create service and handlers for tests
@@ -114,27 +118,17 @@ func TestCallbackSuccess(t *testing.T) {
 		io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
 	})
 	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]string{
-			"email":   "e@example.com",
-			"name":    "tester",
-			"picture": "pic",
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"email":          "e@example.com",
+			"verified_email": true,
+			"name":           "tester",
+			"picture":        "pic",
 		})
 	})
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	h := newTestHandlers(t)
-
-	// override endpoints
-	h.service.config.Endpoint = oauth2.Endpoint{
-		AuthURL:   server.URL + "/auth",
-		TokenURL:  server.URL + "/token",
-		AuthStyle: oauth2.AuthStyleInParams,
-	}
-
-	orig := userInfoEndpoint
-	userInfoEndpoint = server.URL + "/userinfo"
-	defer func() { userInfoEndpoint = orig }()
+	h := newTestHandlers(t, WithGoogleEndpoints(GoogleEndpoints{AuthURL: server.URL + "/auth", TokenURL: server.URL + "/token", UserInfoURL: server.URL + "/userinfo"}))
 
 	// prepare request with session containing state
 	req := httptest.NewRequest("GET", constants.CallbackPath+"?state=s123&code=c1", nil)
@@ -187,15 +181,11 @@ func TestCallbackSuccess_APIOnlyScopes(t *testing.T) {
 	session.NewSession([]byte("secret"))
 	// Use a dummy API scope for this test
 	apiScopes := []string{"https://www.googleapis.com/auth/drive.readonly"}
-	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", apiScopes, "")
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", apiScopes, "",
+		WithGoogleEndpoints(GoogleEndpoints{AuthURL: server.URL + "/auth", TokenURL: server.URL + "/token"}))
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Override endpoints to point to the mock server
-	svc.config.Endpoint = oauth2.Endpoint{
-		AuthURL:  server.URL + "/auth",
-		TokenURL: server.URL + "/token",
-	}
 	handlers, err := NewHandlers(svc)
 	if err != nil {
 		t.Fatal(err)
@@ -295,3 +285,871 @@ func TestLogoutRedirectUsesConfiguredTarget(t *testing.T) {
 		t.Fatalf("expected redirect to %s, got %s", desiredRedirect, location)
 	}
 }
+
+// useMockGoogle starts a mock token and userinfo server, points the handlers at
+// it and restores the userinfo endpoint when the test completes.
+func useMockGoogle(t *testing.T, h *Handlers, tokenResponse string) *httptest.Server {
+	t.Helper()
+	return useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, tokenResponse)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"email":          "e@example.com",
+				"verified_email": true,
+				"name":           "tester",
+				"picture":        "pic",
+			})
+		},
+	)
+}
+
+// useMockGoogleHandlers is like useMockGoogle but lets the test control the
+// token and userinfo responses completely.
+func useMockGoogleHandlers(t *testing.T, h *Handlers, tokenHandler http.HandlerFunc, userInfoHandler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", tokenHandler)
+	mux.HandleFunc("/userinfo", userInfoHandler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	WithGoogleEndpoints(GoogleEndpoints{AuthURL: server.URL + "/auth", TokenURL: server.URL + "/token", UserInfoURL: server.URL + "/userinfo"})(h.service)
+	return server
+}
+
+// seedState stores the OAuth state in a fresh session and attaches the
+// resulting cookie to the request.
+func seedState(t *testing.T, req *http.Request, state string) {
+	t.Helper()
+	initRR := httptest.NewRecorder()
+	sess, _ := session.Store().Get(req, constants.SessionName)
+	sess.Values["oauth_state"] = state
+	if err := sess.Save(req, initRR); err != nil {
+		t.Fatalf("failed to seed session: %v", err)
+	}
+	for _, cookie := range initRR.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+}
+
+func TestLoginRequestsFormPostResponseMode(t *testing.T) {
+	h := newTestHandlers(t, WithFormPostResponseMode())
+	req := httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil)
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	if mode := location.Query().Get("response_mode"); mode != "form_post" {
+		t.Fatalf("expected response_mode form_post, got %q", mode)
+	}
+}
+
+func TestCallbackAcceptsFormPost(t *testing.T) {
+	h := newTestHandlers(t, WithFormPostResponseMode())
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+
+	form := url.Values{"state": {"s123"}, "code": {"c1"}}
+	req := httptest.NewRequest(http.MethodPost, constants.CallbackPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	seedState(t, req, "s123")
+
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+}
+
+func TestCallbackFormPostWithCookieJar(t *testing.T) {
+	h := newTestHandlers(t, WithFormPostResponseMode())
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+	server := httptest.NewTLSServer(h)
+	t.Cleanup(server.Close)
+	cookieJar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := server.Client()
+	client.Jar = cookieJar
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	loginResponse, err := client.Get(server.URL + constants.GoogleAuthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginResponse.Body.Close()
+	if len(loginResponse.Cookies()) == 0 {
+		t.Fatal("expected Login to set the session cookie")
+	}
+	for _, sessionCookie := range loginResponse.Cookies() {
+		if sessionCookie.SameSite != http.SameSiteNoneMode || !sessionCookie.Secure {
+			t.Fatalf("expected a SameSite=None Secure session cookie, got %q", loginResponse.Header.Get("Set-Cookie"))
+		}
+	}
+	authorizationURL, err := url.Parse(loginResponse.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"state": {authorizationURL.Query().Get("state")}, "code": {"c1"}}
+	callbackResponse, err := client.PostForm(server.URL+constants.CallbackPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackResponse.Body.Close()
+	if location := callbackResponse.Header.Get("Location"); callbackResponse.StatusCode != http.StatusFound || location != "/dashboard" {
+		t.Fatalf("expected the jar's cookie to complete the login, got %d %q", callbackResponse.StatusCode, location)
+	}
+}
+
+func TestCallbackFormPostProviderError(t *testing.T) {
+	h := newTestHandlers(t, WithFormPostResponseMode())
+	form := url.Values{"state": {"s123"}, "error": {"access_denied"}}
+	req := httptest.NewRequest(http.MethodPost, constants.CallbackPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	seedState(t, req, "s123")
+
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertErrorRedirect(t, rr, ErrCodeAuthorizationFailed)
+}
+
+func TestCallbackRejectsOversizedForm(t *testing.T) {
+	h := newTestHandlers(t)
+	body := "state=" + strings.Repeat("a", callbackFormMaxBytes+1)
+	req := httptest.NewRequest(http.MethodPost, constants.CallbackPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+// lastCookies returns the final value of every cookie set on the response,
+// mirroring how a browser applies multiple Set-Cookie headers.
+func lastCookies(rr *httptest.ResponseRecorder) []*http.Cookie {
+	latest := map[string]*http.Cookie{}
+	var order []string
+	for _, cookie := range rr.Result().Cookies() {
+		if _, seen := latest[cookie.Name]; !seen {
+			order = append(order, cookie.Name)
+		}
+		latest[cookie.Name] = cookie
+	}
+	cookies := make([]*http.Cookie, 0, len(order))
+	for _, name := range order {
+		cookies = append(cookies, latest[name])
+	}
+	return cookies
+}
+
+func TestCallbackMissingRefreshTokenRetriesConsentOnce(t *testing.T) {
+	testCases := []struct {
+		name         string
+		options      []ServiceOption
+		wantLocation string
+	}{
+		{
+			name:         "fails after one retry",
+			wantLocation: constants.LoginPath + "?error=refresh_token_unavailable&error_code=refresh_token_unavailable",
+		},
+		{
+			name:         "relaxed option continues without refresh token",
+			options:      []ServiceOption{WithAllowMissingRefreshToken()},
+			wantLocation: "/dashboard",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer"}`)
+
+			firstRequest := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+			seedState(t, firstRequest, "s123")
+			firstRecorder := httptest.NewRecorder()
+			h.Callback(firstRecorder, firstRequest)
+
+			consentURL, err := url.Parse(firstRecorder.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("failed to parse consent redirect: %v", err)
+			}
+			retryState := consentURL.Query().Get("state")
+			if retryState == "" || retryState == "s123" {
+				t.Fatalf("expected a fresh consent redirect, got %s", consentURL)
+			}
+
+			secondRequest := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state="+url.QueryEscape(retryState)+"&code=c2", nil)
+			for _, cookie := range lastCookies(firstRecorder) {
+				secondRequest.AddCookie(cookie)
+			}
+			secondRecorder := httptest.NewRecorder()
+			h.Callback(secondRecorder, secondRequest)
+
+			if location := secondRecorder.Header().Get("Location"); location != testCase.wantLocation {
+				t.Fatalf("expected redirect to %s, got %s", testCase.wantLocation, location)
+			}
+
+			checkRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range lastCookies(secondRecorder) {
+				checkRequest.AddCookie(cookie)
+			}
+			checkSession, _ := session.Store().Get(checkRequest, constants.SessionName)
+			if _, retried := checkSession.Values[sessionKeyConsentRetry]; retried {
+				t.Fatal("expected consent retry flag to be cleared")
+			}
+		})
+	}
+}
+
+// assertErrorRedirect verifies that the response redirects to the login page
+// carrying the expected error code.
+func assertErrorRedirect(t *testing.T, rr *httptest.ResponseRecorder, wantCode AuthErrorCode) {
+	t.Helper()
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rr.Code)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	if location.Path != constants.LoginPath {
+		t.Fatalf("expected redirect to %s, got %s", constants.LoginPath, location.Path)
+	}
+	if got := location.Query().Get("error"); got != string(wantCode) {
+		t.Fatalf("expected error %s, got %s", wantCode, got)
+	}
+	if got := location.Query().Get("error_code"); got != string(wantCode) {
+		t.Fatalf("expected error_code %s, got %s", wantCode, got)
+	}
+}
+
+func TestCallbackErrorRedirectsIncludeErrorCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		state    string
+		wantCode AuthErrorCode
+	}{
+		{name: "missing session state", target: constants.CallbackPath + "?state=s123&code=c1", wantCode: ErrCodeMissingState},
+		{name: "state mismatch", target: constants.CallbackPath + "?state=other&code=c1", state: "s123", wantCode: ErrCodeStateMismatch},
+		{name: "missing code", target: constants.CallbackPath + "?state=s123", state: "s123", wantCode: ErrCodeMissingCode},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			req := httptest.NewRequest(http.MethodGet, testCase.target, nil)
+			if testCase.state != "" {
+				seedState(t, req, testCase.state)
+			}
+			rr := httptest.NewRecorder()
+			h.Callback(rr, req)
+			assertErrorRedirect(t, rr, testCase.wantCode)
+		})
+	}
+}
+
+func TestCallbackStateIsSingleUse(t *testing.T) {
+	h := newTestHandlers(t)
+	tokenExchanges := 0
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			tokenExchanges++
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"email":"e@example.com","verified_email":true}`)
+		},
+	)
+
+	callbackTarget := constants.CallbackPath + "?state=s123&code=c1"
+	firstRequest := httptest.NewRequest(http.MethodGet, callbackTarget, nil)
+	seedState(t, firstRequest, "s123")
+	firstRecorder := httptest.NewRecorder()
+	h.Callback(firstRecorder, firstRequest)
+	if location := firstRecorder.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected first callback to succeed, got %s", location)
+	}
+
+	replayRequest := httptest.NewRequest(http.MethodGet, callbackTarget, nil)
+	for _, cookie := range lastCookies(firstRecorder) {
+		replayRequest.AddCookie(cookie)
+	}
+	replayRecorder := httptest.NewRecorder()
+	h.Callback(replayRecorder, replayRequest)
+	if location := replayRecorder.Header().Get("Location"); location != "/dashboard" || tokenExchanges != 1 || len(replayRecorder.Result().Cookies()) != 0 {
+		t.Fatalf("expected the replay to reach /dashboard without a second exchange or a new cookie, got %q after %d exchanges", location, tokenExchanges)
+	}
+
+	loggedOutReplay := httptest.NewRecorder()
+	h.Callback(loggedOutReplay, httptest.NewRequest(http.MethodGet, callbackTarget, nil))
+	assertErrorRedirect(t, loggedOutReplay, ErrCodeMissingState)
+}
+
+func TestCallbackStateIsRemovedOnFailure(t *testing.T) {
+	h := newTestHandlers(t)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertErrorRedirect(t, rr, ErrCodeStateMismatch)
+
+	checkRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range lastCookies(rr) {
+		checkRequest.AddCookie(cookie)
+	}
+	checkSession, _ := session.Store().Get(checkRequest, constants.SessionName)
+	if _, present := checkSession.Values[sessionKeyOAuthState]; present {
+		t.Fatal("expected state to be removed from the session")
+	}
+}
+
+func TestErrorHandlerReplacesRedirect(t *testing.T) {
+	var receivedCodes []AuthErrorCode
+	customHandler := func(w http.ResponseWriter, r *http.Request, authError *AuthError) {
+		receivedCodes = append(receivedCodes, authError.Code)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	h := newTestHandlers(t, WithErrorHandler(customHandler))
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected custom status 401, got %d", rr.Code)
+	}
+	if rr.Header().Get("Location") != "" {
+		t.Fatal("expected no redirect when a custom error handler is set")
+	}
+	if len(receivedCodes) != 1 || receivedCodes[0] != ErrCodeStateMismatch {
+		t.Fatalf("expected a single %s error, got %v", ErrCodeStateMismatch, receivedCodes)
+	}
+}
+
+func TestCallbackRegeneratesSession(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	initRR := httptest.NewRecorder()
+	plantedSession, _ := session.Store().Get(req, constants.SessionName)
+	plantedSession.Values[sessionKeyOAuthState] = "s123"
+	plantedSession.Values[constants.SessionKeySessionID] = "attacker-chosen"
+	plantedSession.Values["attacker_value"] = "planted"
+	plantedSession.Save(req, initRR)
+	for _, cookie := range initRR.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+
+	checkRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range lastCookies(rr) {
+		checkRequest.AddCookie(cookie)
+	}
+	loggedInSession, _ := session.Store().Get(checkRequest, constants.SessionName)
+	sessionIdentifier, _ := loggedInSession.Values[constants.SessionKeySessionID].(string)
+	if sessionIdentifier == "" || sessionIdentifier == "attacker-chosen" {
+		t.Fatalf("expected a fresh session identifier, got %q", sessionIdentifier)
+	}
+	if _, present := loggedInSession.Values["attacker_value"]; present {
+		t.Fatal("expected pre-login values to be discarded")
+	}
+	if loggedInSession.Values[constants.SessionKeyUserEmail] != "e@example.com" {
+		t.Fatal("expected user to be stored in the regenerated session")
+	}
+}
+
+func TestFlashMessagesKeepErrorsOutOfTheURL(t *testing.T) {
+	h := newTestHandlers(t, WithFlashMessages(true))
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	location := rr.Header().Get("Location")
+	if location != constants.LoginPath {
+		t.Fatalf("expected redirect to %s without query parameters, got %s", constants.LoginPath, location)
+	}
+	if strings.Contains(location, "error=") {
+		t.Fatalf("expected no error query parameter, got %s", location)
+	}
+
+	loginRequest := httptest.NewRequest(http.MethodGet, constants.LoginPath, nil)
+	for _, cookie := range lastCookies(rr) {
+		loginRequest.AddCookie(cookie)
+	}
+	loginRecorder := httptest.NewRecorder()
+	h.loginHandler(loginRecorder, loginRequest)
+	if !strings.Contains(loginRecorder.Body.String(), errorMessages[ErrCodeStateMismatch]) {
+		t.Fatal("expected the flash message to be rendered on the login page")
+	}
+
+	replayRequest := httptest.NewRequest(http.MethodGet, constants.LoginPath, nil)
+	for _, cookie := range lastCookies(loginRecorder) {
+		replayRequest.AddCookie(cookie)
+	}
+	replayRecorder := httptest.NewRecorder()
+	h.loginHandler(replayRecorder, replayRequest)
+	if strings.Contains(replayRecorder.Body.String(), errorMessages[ErrCodeStateMismatch]) {
+		t.Fatal("expected the flash message to be shown only once")
+	}
+}
+
+func TestErrorHandlerReceivesCodeForEachCallbackFailure(t *testing.T) {
+	validToken := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+	}
+	validUser := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"verified_email": true, "email": "e@example.com"})
+	}
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+	}
+
+	testCases := []struct {
+		name            string
+		target          string
+		state           string
+		tokenHandler    http.HandlerFunc
+		userInfoHandler http.HandlerFunc
+		wantCode        AuthErrorCode
+	}{
+		{name: "missing state", target: "?state=s123&code=c1", wantCode: ErrCodeMissingState},
+		{name: "invalid state", target: "?state=other&code=c1", state: "s123", wantCode: ErrCodeStateMismatch},
+		{name: "authorization failed", target: "?state=s123&error=access_denied", state: "s123", wantCode: ErrCodeAuthorizationFailed},
+		{name: "missing code", target: "?state=s123", state: "s123", wantCode: ErrCodeMissingCode},
+		{name: "token exchange failed", target: "?state=s123&code=c1", state: "s123", tokenHandler: failing, wantCode: ErrCodeTokenExchange},
+		{name: "user info failed", target: "?state=s123&code=c1", state: "s123", userInfoHandler: failing, wantCode: ErrCodeUserInfo},
+		{
+			name:   "session save failed",
+			target: "?state=s123&code=c1",
+			state:  "s123",
+			userInfoHandler: func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"verified_email": true, "email": "e@example.com", "name": strings.Repeat("n", 8192)})
+			},
+			wantCode: ErrCodeSessionSave,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var received *AuthError
+			h := newTestHandlers(t, WithErrorHandler(func(w http.ResponseWriter, r *http.Request, authError *AuthError) {
+				received = authError
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			tokenHandler, userInfoHandler := testCase.tokenHandler, testCase.userInfoHandler
+			if tokenHandler == nil {
+				tokenHandler = validToken
+			}
+			if userInfoHandler == nil {
+				userInfoHandler = validUser
+			}
+			useMockGoogleHandlers(t, h, tokenHandler, userInfoHandler)
+
+			req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+testCase.target, nil)
+			if testCase.state != "" {
+				seedState(t, req, testCase.state)
+			}
+			rr := httptest.NewRecorder()
+			h.Callback(rr, req)
+
+			if received == nil {
+				t.Fatalf("expected error handler to be called, got status %d", rr.Code)
+			}
+			if received.Code != testCase.wantCode {
+				t.Fatalf("expected code %s, got %s (%v)", testCase.wantCode, received.Code, received)
+			}
+			if received.Request == nil || received.Request.URL != req.URL {
+				t.Fatal("expected the failing request to be attached to the error")
+			}
+		})
+	}
+}
+
+func TestLoginPageRendersCustomTemplateData(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "branded_login.html")
+	if err := os.WriteFile(templatePath, []byte(`<h1>{{ .appName }}</h1>{{ .error }}`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath,
+		WithCustomTemplateData(map[string]interface{}{"appName": "Acme Portal"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "<h1>Acme Portal</h1>") {
+		t.Fatalf("expected injected value in output, got %s", rr.Body.String())
+	}
+}
+
+func TestLoginPageRendersRequestTemplateData(t *testing.T) {
+	h := newTestHandlers(t,
+		WithCustomTemplateData(map[string]interface{}{"appName": "Acme Portal", "tagline": "static tagline"}),
+		WithLoginTemplateData(func(request *http.Request) map[string]interface{} {
+			return map[string]interface{}{
+				"tagline":      "Welcome from " + request.Host,
+				"logoURL":      "https://cdn.example.com/logo.png",
+				"supportEmail": "help@example.com",
+			}
+		}))
+
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{
+		"Sign in to Acme Portal",
+		"Welcome from example.com",
+		`src="https://cdn.example.com/logo.png"`,
+		"mailto:help@example.com",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in rendered page", expected)
+		}
+	}
+	if strings.Contains(body, "static tagline") {
+		t.Error("expected per-request data to take precedence over static data")
+	}
+}
+
+func TestLoginTemplateDataCannotOverrideBuiltInKeys(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "reserved_login.html")
+	if err := os.WriteFile(templatePath, []byte(`[{{ .error }}][{{ .googleAuthPath }}]`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath,
+		WithCustomTemplateData(map[string]interface{}{"error": "static override"}),
+		WithLoginTemplateData(func(request *http.Request) map[string]interface{} {
+			return map[string]interface{}{"error": "dynamic override", "googleAuthPath": "/elsewhere"}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath+"?error="+string(ErrCodeStateMismatch), nil))
+	expected := "[" + errorMessages[ErrCodeStateMismatch] + "][" + constants.GoogleAuthPath + "]"
+	if rr.Body.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, rr.Body.String())
+	}
+}
+
+func TestLoginTemplateUsesFuncMap(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "func_login.html")
+	if err := os.WriteFile(templatePath, []byte(`<h1>{{ t "sign_in" }}</h1><img src="{{ asset "logo.png" }}">`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath,
+		WithTemplateFuncMap(template.FuncMap{"t": func(key string) string { return strings.ToUpper(strings.ReplaceAll(key, "_", " ")) }}),
+		WithTemplateFuncMap(template.FuncMap{"asset": func(name string) string { return "/static/" + name + "?v=1" }}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatalf("NewHandlers error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if !strings.Contains(rr.Body.String(), "<h1>SIGN IN</h1>") || !strings.Contains(rr.Body.String(), `src="/static/logo.png?v=1"`) {
+		t.Fatalf("expected custom function output, got %s", rr.Body.String())
+	}
+}
+
+func TestWithTemplateFuncMapRejectsInvalidFunctions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		funcs template.FuncMap
+	}{
+		{name: "built-in name", funcs: template.FuncMap{"printf": strings.ToUpper}},
+		{name: "not a function", funcs: template.FuncMap{"version": "1.0"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplateFuncMap(testCase.funcs))
+			if err == nil {
+				t.Fatal("expected NewService to reject the function map")
+			}
+		})
+	}
+}
+
+func TestEmbeddedTemplateParsesWithFuncMap(t *testing.T) {
+	h := newTestHandlers(t, WithTemplateFuncMap(template.FuncMap{"upper": strings.ToUpper}))
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestHandlersServeHTTPDispatchesRoutes(t *testing.T) {
+	h := newTestHandlers(t)
+	testCases := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{name: "login page", target: constants.LoginPath, wantStatus: http.StatusOK},
+		{name: "start login", target: constants.GoogleAuthPath, wantStatus: http.StatusFound},
+		{name: "logout", target: constants.LogoutPath, wantStatus: http.StatusFound},
+		{name: "unknown path", target: "/elsewhere", wantStatus: http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, testCase.target, nil))
+			if rr.Code != testCase.wantStatus {
+				t.Fatalf("expected %d, got %d", testCase.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRegisterRoutesDelegatesToHandlers(t *testing.T) {
+	h := newTestHandlers(t)
+	mux := h.RegisterRoutes(http.NewServeMux())
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d", rr.Code)
+	}
+}
+
+func TestLoginPageTranslatesErrorCodes(t *testing.T) {
+	testCases := []struct {
+		name            string
+		query           string
+		expectedMessage string
+		forbiddenText   string
+	}{
+		{name: "known code", query: "?error=" + string(ErrCodeTokenExchange), expectedMessage: errorMessages[ErrCodeTokenExchange], forbiddenText: string(ErrCodeTokenExchange)},
+		{name: "unknown code", query: "?error=%3Cb%3Einjected%3C%2Fb%3E", forbiddenText: "injected"},
+		{name: "no code", query: "", forbiddenText: "class=\"card error"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			req := httptest.NewRequest(http.MethodGet, constants.LoginPath+testCase.query, nil)
+			rr := httptest.NewRecorder()
+			h.loginHandler(rr, req)
+			body := rr.Body.String()
+			if testCase.expectedMessage != "" && !strings.Contains(body, testCase.expectedMessage) {
+				t.Fatalf("expected message %q in login page", testCase.expectedMessage)
+			}
+			if strings.Contains(body, testCase.forbiddenText) {
+				t.Fatalf("did not expect %q in login page", testCase.forbiddenText)
+			}
+		})
+	}
+}
+
+func TestDefaultErrorRedirectFlashesTheCode(t *testing.T) {
+	h := newTestHandlers(t)
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertErrorRedirect(t, rr, ErrCodeStateMismatch)
+
+	loginRequest := httptest.NewRequest(http.MethodGet, constants.LoginPath, nil)
+	for _, cookie := range lastCookies(rr) {
+		loginRequest.AddCookie(cookie)
+	}
+	loginRecorder := httptest.NewRecorder()
+	h.loginHandler(loginRecorder, loginRequest)
+	if !strings.Contains(loginRecorder.Body.String(), errorMessages[ErrCodeStateMismatch]) {
+		t.Fatal("expected the flashed error message on the login page")
+	}
+}
+
+func TestRoutesRejectWrongMethods(t *testing.T) {
+	testCases := []struct {
+		path      string
+		method    string
+		wantAllow string
+	}{
+		{path: constants.LoginPath, method: http.MethodDelete, wantAllow: "GET, HEAD"},
+		{path: constants.GoogleAuthPath, method: http.MethodPost, wantAllow: "GET, HEAD"},
+		{path: constants.CallbackPath, method: http.MethodPost, wantAllow: "GET, HEAD"},
+		{path: constants.LogoutPath, method: http.MethodPut, wantAllow: "GET, HEAD, POST"},
+	}
+	h := newTestHandlers(t)
+	servers := map[string]http.Handler{
+		"RegisterRoutes": h.RegisterRoutes(http.NewServeMux()),
+		"ServeHTTP":      h,
+	}
+	for serverName, server := range servers {
+		for _, testCase := range testCases {
+			t.Run(serverName+" "+testCase.method+" "+testCase.path, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				server.ServeHTTP(rr, httptest.NewRequest(testCase.method, testCase.path, nil))
+				if rr.Code != http.StatusMethodNotAllowed {
+					t.Fatalf("expected 405, got %d", rr.Code)
+				}
+				if allow := rr.Header().Get("Allow"); allow != testCase.wantAllow {
+					t.Fatalf("expected Allow %q, got %q", testCase.wantAllow, allow)
+				}
+			})
+		}
+	}
+}
+
+func TestRoutesAcceptCallbackPostInFormPostMode(t *testing.T) {
+	h := newTestHandlers(t, WithFormPostResponseMode())
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, constants.CallbackPath, strings.NewReader("state=s&code=c"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(rr, req)
+	if rr.Code == http.StatusMethodNotAllowed {
+		t.Fatal("expected POST to reach the callback in form_post mode")
+	}
+}
+
+func TestPlainRoutePatternsAcceptAnyMethod(t *testing.T) {
+	h := newTestHandlers(t, WithPlainRoutePatterns())
+	rr := httptest.NewRecorder()
+	h.RegisterRoutes(http.NewServeMux()).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, constants.LoginPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the login page, got %d", rr.Code)
+	}
+}
+
+func TestCallbackStoresUserID(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"verified_email": true, "id": "1234567", "email": "e@example.com"})
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	checkRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range lastCookies(rr) {
+		checkRequest.AddCookie(cookie)
+	}
+	user, found := SessionUser(checkRequest)
+	if !found || user.ID != "1234567" {
+		t.Fatalf("expected the user ID in the session, got %+v", user)
+	}
+}
+
+func TestCallbackRejectsUnverifiedEmail(t *testing.T) {
+	testCases := []struct {
+		name         string
+		options      []ServiceOption
+		wantRejected bool
+	}{
+		{name: "rejected by default", wantRejected: true},
+		{name: "allowed by option", options: []ServiceOption{WithAllowUnverifiedEmail(true)}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					json.NewEncoder(w).Encode(map[string]interface{}{"email": "e@example.com", "verified_email": false, "locale": "de"})
+				},
+			)
+			req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+			seedState(t, req, "s123")
+			rr := httptest.NewRecorder()
+			h.Callback(rr, req)
+
+			if testCase.wantRejected {
+				assertErrorRedirect(t, rr, ErrCodeEmailNotVerified)
+				return
+			}
+			if location := rr.Header().Get("Location"); location != "/dashboard" {
+				t.Fatalf("expected redirect to /dashboard, got %q", location)
+			}
+		})
+	}
+}
+
+func TestCallbackStoresGrantedScopes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		tokenResponse  string
+		expectedScopes string
+	}{
+		{name: "scopes from token response", tokenResponse: `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok","scope":"openid https://www.googleapis.com/auth/userinfo.email"}`, expectedScopes: "openid https://www.googleapis.com/auth/userinfo.email"},
+		{name: "requested scopes as fallback", tokenResponse: `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`, expectedScopes: "profile email"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					io.WriteString(w, testCase.tokenResponse)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+				},
+			)
+			req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+			seedState(t, req, "s123")
+			rr := httptest.NewRecorder()
+			h.Callback(rr, req)
+			if grantedScopeList := sessionFromResponse(t, rr)[constants.SessionKeyGrantedScopes]; grantedScopeList != testCase.expectedScopes {
+				t.Fatalf("expected granted scopes %q, got %v", testCase.expectedScopes, grantedScopeList)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package gauss
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	headerReferrerPolicy        = "Referrer-Policy"
+	referrerPolicyNone          = "no-referrer"
+	headerContentSecurityPolicy = "Content-Security-Policy"
+	headerXFrameOptions         = "X-Frame-Options"
+	frameOptionsDeny            = "DENY"
+	frameAncestorsDirective     = "frame-ancestors"
+	frameAncestorsNone          = "frame-ancestors 'none'"
+
+	// defaultContentSecurityPolicy allows the pages rendered by GAuss to load
+	// scripts, styles and fonts only from the application and the CDN used by
+	// the embedded templates, forbids inline scripts and forbids framing.
+	defaultContentSecurityPolicy = "default-src 'self'; " +
+		"script-src 'self' https://cdn.jsdelivr.net; " +
+		"style-src 'self' https://cdn.jsdelivr.net; " +
+		"font-src 'self' https://cdn.jsdelivr.net; " +
+		"img-src 'self' https: data:; " +
+		"object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+)
+
+// WithContentSecurityPolicy returns a ServiceOption that replaces the
+// Content-Security-Policy sent with the login and device pages, for example
+// to add asset hosts used by a custom template or to allow framing with a
+// frame-ancestors directive. X-Frame-Options: DENY is sent unless policy
+// allows framing with frame-ancestors. An empty policy omits the header.
+func WithContentSecurityPolicy(policy string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.contentSecurityPolicy = strings.TrimSpace(policy)
+	}
+}
+
+// setPageSecurityHeaders protects an HTML page rendered from the login
+// templates against framing and injected scripts.
+func (serviceInstance *Service) setPageSecurityHeaders(responseWriter http.ResponseWriter) {
+	setFramingHeaders(responseWriter, serviceInstance.contentSecurityPolicy)
+}
+
+// setFramingHeaders sends policy, when it is not empty, and X-Frame-Options:
+// DENY unless policy allows some frame ancestors.
+func setFramingHeaders(responseWriter http.ResponseWriter, policy string) {
+	if policy != "" {
+		responseWriter.Header().Set(headerContentSecurityPolicy, policy)
+	}
+	if !strings.Contains(policy, frameAncestorsDirective) || strings.Contains(policy, frameAncestorsNone) {
+		responseWriter.Header().Set(headerXFrameOptions, frameOptionsDeny)
+	}
+}
+
+// setSecurityHeaders marks a GAuss response as uncacheable and keeps its URL,
+// which may carry an authorization code, state or error details, out of the
+// Referer header of follow-up requests.
+func setSecurityHeaders(responseWriter http.ResponseWriter) {
+	responseHeader := responseWriter.Header()
+	responseHeader.Set(headerCacheControl, cacheControlNoStore)
+	responseHeader.Set(headerPragma, pragmaNoCache)
+	responseHeader.Set(headerReferrerPolicy, referrerPolicyNone)
+}
+
+// withSecurityHeaders returns handler with setSecurityHeaders applied to every
+// response, including redirects and errors.
+func withSecurityHeaders(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		setSecurityHeaders(responseWriter)
+		handler.ServeHTTP(responseWriter, request)
+	})
+}
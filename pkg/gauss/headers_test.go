@@ -0,0 +1,129 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestResponsesCarrySecurityHeaders(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	callbackRequest := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, callbackRequest, "s123")
+	testCases := []struct {
+		name           string
+		handler        http.Handler
+		request        *http.Request
+		expectedStatus int
+	}{
+		{name: "login page", handler: h, request: httptest.NewRequest(http.MethodGet, constants.LoginPath+"?error=invalid_state", nil), expectedStatus: http.StatusOK},
+		{name: "callback redirect", handler: h, request: callbackRequest, expectedStatus: http.StatusFound},
+		{name: "logout redirect", handler: h, request: httptest.NewRequest(http.MethodPost, constants.LogoutPath, nil), expectedStatus: http.StatusFound},
+		{name: "method not allowed", handler: h, request: httptest.NewRequest(http.MethodDelete, constants.LoginPath, nil), expectedStatus: http.StatusMethodNotAllowed},
+		{name: "mounted login page", handler: h.LoginPageHandler(), request: httptest.NewRequest(http.MethodGet, "/signin", nil), expectedStatus: http.StatusOK},
+		{name: "mounted logout", handler: h.LogoutHandler(), request: httptest.NewRequest(http.MethodGet, "/signout", nil), expectedStatus: http.StatusFound},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			testCase.handler.ServeHTTP(rr, testCase.request)
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			expectedHeaders := map[string]string{"Cache-Control": "no-store", "Pragma": "no-cache", "Referrer-Policy": "no-referrer"}
+			for headerName, expectedValue := range expectedHeaders {
+				if headerValue := rr.Header().Get(headerName); headerValue != expectedValue {
+					t.Fatalf("expected %s %q, got %q", headerName, expectedValue, headerValue)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginPageContentSecurityPolicy(t *testing.T) {
+	const framingPolicy = "default-src 'self'; frame-ancestors https://portal.example.com"
+	testCases := []struct {
+		name                 string
+		options              []ServiceOption
+		expectedPolicy       string
+		expectedFrameOptions string
+	}{
+		{name: "default", expectedPolicy: defaultContentSecurityPolicy, expectedFrameOptions: "DENY"},
+		{name: "custom framing policy", options: []ServiceOption{WithContentSecurityPolicy(framingPolicy)}, expectedPolicy: framingPolicy},
+		{name: "disabled", options: []ServiceOption{WithContentSecurityPolicy("")}, expectedFrameOptions: "DENY"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected the login page, got %d", rr.Code)
+			}
+			if policy := rr.Header().Get("Content-Security-Policy"); policy != testCase.expectedPolicy {
+				t.Fatalf("expected policy %q, got %q", testCase.expectedPolicy, policy)
+			}
+			if frameOptions := rr.Header().Get("X-Frame-Options"); frameOptions != testCase.expectedFrameOptions {
+				t.Fatalf("expected X-Frame-Options %q, got %q", testCase.expectedFrameOptions, frameOptions)
+			}
+		})
+	}
+}
+
+// TestEmbeddedTemplatesFitDefaultPolicy checks that the embedded pages need
+// neither inline scripts nor assets outside the default policy.
+func TestEmbeddedTemplatesFitDefaultPolicy(t *testing.T) {
+	h := newTestHandlers(t, WithRememberMeDuration(time.Hour), WithCustomTemplateData(map[string]interface{}{"logoURL": "https://cdn.example.com/logo.png"}))
+	loginRecorder := httptest.NewRecorder()
+	h.ServeHTTP(loginRecorder, httptest.NewRequest(http.MethodGet, constants.LoginPath+"?error=invalid_state", nil))
+	deviceRecorder := httptest.NewRecorder()
+	h.service.renderDevicePage(deviceRecorder, "ABCD-EFGH", "https://www.google.com/device")
+
+	inlineScript := regexp.MustCompile(`<script(\s[^>]*)?>\s*[^<\s]`)
+	eventHandler := regexp.MustCompile(`\son[a-z]+\s*=`)
+	scriptOrStyleURL := regexp.MustCompile(`(?:<script|<link)[^>]*(?:src|href)="(https?://[^/"]+)`)
+	for pageName, rr := range map[string]*httptest.ResponseRecorder{"login": loginRecorder, "device": deviceRecorder} {
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, "</html>") {
+			t.Fatalf("expected the %s page to render, got %d", pageName, rr.Code)
+		}
+		if inlineScript.MatchString(body) || eventHandler.MatchString(body) || strings.Contains(body, "<style") {
+			t.Fatalf("expected no inline scripts or styles on the %s page", pageName)
+		}
+		for _, assetMatch := range scriptOrStyleURL.FindAllStringSubmatch(body, -1) {
+			if !strings.Contains(defaultContentSecurityPolicy, assetMatch[1]) {
+				t.Fatalf("expected the default policy to allow %s used by the %s page", assetMatch[1], pageName)
+			}
+		}
+		if rr.Header().Get("Content-Security-Policy") != defaultContentSecurityPolicy {
+			t.Fatalf("expected the default policy on the %s page", pageName)
+		}
+	}
+}
+
+func TestLoginPageThemeSwitch(t *testing.T) {
+	h := newTestHandlers(t)
+	loginRecorder := httptest.NewRecorder()
+	h.ServeHTTP(loginRecorder, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	loginBody := loginRecorder.Body.String()
+	if !strings.Contains(loginBody, `id="theme-switch"`) || !strings.Contains(loginBody, `<script src="`+constants.ThemeScriptPath+`"`) {
+		t.Fatalf("expected the login page to render the theme switch and load its script, got %s", loginBody)
+	}
+
+	for handlerName, handler := range map[string]http.Handler{"routes": h, "mounted": h.ThemeScriptHandler()} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.ThemeScriptPath, nil))
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") {
+			t.Fatalf("%s: expected the theme script, got %d with %q", handlerName, rr.Code, rr.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rr.Body.String(), "theme-switch") {
+			t.Fatalf("%s: expected the script to drive the theme switch", handlerName)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// LoginSuccessHook is invoked by Callback once Google has authenticated the
+// user and before any session values are written. user is nil when only
+// API scopes were requested. Returning an error rejects the login.
+type LoginSuccessHook func(ctx context.Context, user *GoogleUser, token *oauth2.Token) error
+
+// WithLoginSuccessHook returns a ServiceOption that registers hook to run on
+// every successful callback. Applications use it to provision users, assign
+// roles or veto the login; a non-nil error aborts the login and redirects with
+// error=login_rejected.
+func WithLoginSuccessHook(hook LoginSuccessHook) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.loginSuccessHook = hook
+	}
+}
+
+// LoginFailureHook is invoked whenever a login attempt fails, before the error
+// response is written. code is the machine-readable AuthErrorCode and err
+// describes the failure.
+type LoginFailureHook func(request *http.Request, code string, err error)
+
+// WithLoginFailureHook returns a ServiceOption that registers hook to observe
+// failed logins, for example to alert on failing token exchanges or to audit
+// state mismatches. Panics raised by the hook are recovered and logged so they
+// never break the handler.
+func WithLoginFailureHook(hook LoginFailureHook) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.loginFailureHook = hook
+	}
+}
+
+// LogoutHook is invoked by Logout with the email that was stored in the session
+// before it was cleared, or an empty string when no user was logged in.
+type LogoutHook func(request *http.Request, email string)
+
+// WithLogoutHook returns a ServiceOption that registers hook to run on every
+// logout, for example to clear caches keyed by the user or to write an audit
+// record. Panics raised by the hook are recovered and logged so they never
+// break the logout.
+func WithLogoutHook(hook LogoutHook) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.logoutHook = hook
+	}
+}
+
+func (serviceInstance *Service) notifyLoginFailure(request *http.Request, authError *AuthError) {
+	if serviceInstance.loginFailureHook == nil {
+		return
+	}
+	runHookSafely(request, "Login failure", func() {
+		serviceInstance.loginFailureHook(request, string(authError.Code), authError)
+	})
+}
+
+func (serviceInstance *Service) notifyLogout(request *http.Request, email string) {
+	if serviceInstance.logoutHook == nil {
+		return
+	}
+	runHookSafely(request, "Logout", func() {
+		serviceInstance.logoutHook(request, email)
+	})
+}
+
+// runHookSafely invokes hook and recovers from any panic it raises.
+func runHookSafely(request *http.Request, hookName string, hook func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logRequestf(request, "%s hook panicked: %v", hookName, recovered)
+		}
+	}()
+	hook()
+}
@@ -0,0 +1,220 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+	"golang.org/x/oauth2"
+)
+
+// runSuccessfulCallback drives a callback with a valid state against the mock
+// Google server and returns the recorder.
+func runSuccessfulCallback(t *testing.T, h *Handlers) *httptest.ResponseRecorder {
+	t.Helper()
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	return rr
+}
+
+// sessionFromResponse decodes the session carried by the response cookies.
+func sessionFromResponse(t *testing.T, rr *httptest.ResponseRecorder) map[interface{}]interface{} {
+	t.Helper()
+	checkRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range lastCookies(rr) {
+		checkRequest.AddCookie(cookie)
+	}
+	checkSession, _ := session.Store().Get(checkRequest, constants.SessionName)
+	return checkSession.Values
+}
+
+func TestLoginSuccessHookAccepts(t *testing.T) {
+	var hookUser *GoogleUser
+	var hookToken *oauth2.Token
+	h := newTestHandlers(t, WithLoginSuccessHook(func(ctx context.Context, user *GoogleUser, token *oauth2.Token) error {
+		hookUser, hookToken = user, token
+		return nil
+	}))
+
+	rr := runSuccessfulCallback(t, h)
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+	if hookUser == nil || hookUser.Email != "e@example.com" {
+		t.Fatalf("expected hook to receive the user, got %+v", hookUser)
+	}
+	if hookToken == nil || hookToken.AccessToken != "abc" {
+		t.Fatalf("expected hook to receive the token, got %+v", hookToken)
+	}
+}
+
+func TestLoginSuccessHookRejects(t *testing.T) {
+	h := newTestHandlers(t, WithLoginSuccessHook(func(ctx context.Context, user *GoogleUser, token *oauth2.Token) error {
+		return errors.New("account deactivated")
+	}))
+
+	rr := runSuccessfulCallback(t, h)
+	assertErrorRedirect(t, rr, ErrCodeLoginRejected)
+	values := sessionFromResponse(t, rr)
+	if values[constants.SessionKeyUserEmail] != nil || values[constants.SessionKeyOAuthToken] != nil {
+		t.Fatalf("expected no authentication values in the session, got %v", values)
+	}
+}
+
+func TestLoginSuccessHookRunsForAPIOnlyScopes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"api_token","token_type":"bearer","refresh_token":"rtok"}`)
+	}))
+	defer server.Close()
+
+	hookCalled := false
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", []string{"https://www.googleapis.com/auth/drive.readonly"}, "",
+		WithLoginSuccessHook(func(ctx context.Context, user *GoogleUser, token *oauth2.Token) error {
+			hookCalled = true
+			if user != nil {
+				t.Errorf("expected nil user for API-only scopes, got %+v", user)
+			}
+			return nil
+		}),
+		WithGoogleEndpoints(GoogleEndpoints{AuthURL: server.URL + "/auth", TokenURL: server.URL + "/token"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if !hookCalled {
+		t.Fatal("expected hook to run in API-only mode")
+	}
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+}
+
+func TestLoginFailureHookReceivesCodes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		state    string
+		wantCode AuthErrorCode
+	}{
+		{name: "missing state", target: "?state=s123&code=c1", wantCode: ErrCodeMissingState},
+		{name: "invalid state", target: "?state=other&code=c1", state: "s123", wantCode: ErrCodeStateMismatch},
+		{name: "missing code", target: "?state=s123", state: "s123", wantCode: ErrCodeMissingCode},
+		{name: "token exchange failed", target: "?state=s123&code=c1", state: "s123", wantCode: ErrCodeTokenExchange},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var receivedCodes []string
+			h := newTestHandlers(t, WithLoginFailureHook(func(r *http.Request, code string, err error) {
+				receivedCodes = append(receivedCodes, code)
+				if err == nil {
+					t.Error("expected a non-nil error")
+				}
+			}))
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) { http.Error(w, "nope", http.StatusBadRequest) },
+				func(w http.ResponseWriter, r *http.Request) {},
+			)
+
+			req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+testCase.target, nil)
+			if testCase.state != "" {
+				seedState(t, req, testCase.state)
+			}
+			rr := httptest.NewRecorder()
+			h.Callback(rr, req)
+
+			assertErrorRedirect(t, rr, testCase.wantCode)
+			if len(receivedCodes) != 1 || receivedCodes[0] != string(testCase.wantCode) {
+				t.Fatalf("expected hook code %s, got %v", testCase.wantCode, receivedCodes)
+			}
+		})
+	}
+}
+
+func TestLoginFailureHookPanicIsRecovered(t *testing.T) {
+	h := newTestHandlers(t, WithLoginFailureHook(func(r *http.Request, code string, err error) {
+		panic("hook exploded")
+	}))
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertErrorRedirect(t, rr, ErrCodeMissingState)
+}
+
+// logoutRequest builds a logout request whose session holds the given values.
+func logoutRequest(t *testing.T, values map[interface{}]interface{}) *http.Request {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, constants.LogoutPath, nil)
+	initialRecorder := httptest.NewRecorder()
+	activeSession, _ := session.Store().Get(request, constants.SessionName)
+	for valueKey, value := range values {
+		activeSession.Values[valueKey] = value
+	}
+	activeSession.Save(request, initialRecorder)
+	for _, cookie := range initialRecorder.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestLogoutHookReceivesEmail(t *testing.T) {
+	testCases := []struct {
+		name      string
+		values    map[interface{}]interface{}
+		wantEmail string
+	}{
+		{name: "logged in user", values: map[interface{}]interface{}{constants.SessionKeyUserEmail: "e@example.com"}, wantEmail: "e@example.com"},
+		{name: "anonymous session", values: map[interface{}]interface{}{"test": "value"}, wantEmail: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hookCalls := 0
+			var hookEmail string
+			h := newTestHandlers(t, WithLogoutHook(func(r *http.Request, email string) {
+				hookCalls++
+				hookEmail = email
+			}))
+			rr := httptest.NewRecorder()
+			h.Logout(rr, logoutRequest(t, testCase.values))
+
+			if hookCalls != 1 || hookEmail != testCase.wantEmail {
+				t.Fatalf("expected one hook call with %q, got %d calls with %q", testCase.wantEmail, hookCalls, hookEmail)
+			}
+			for _, cookie := range lastCookies(rr) {
+				if cookie.Name == constants.SessionName && cookie.MaxAge >= 0 {
+					t.Fatalf("expected session cookie to be deleted, got MaxAge %d", cookie.MaxAge)
+				}
+			}
+		})
+	}
+}
+
+func TestLogoutHookPanicIsRecovered(t *testing.T) {
+	h := newTestHandlers(t, WithLogoutHook(func(r *http.Request, email string) {
+		panic("hook exploded")
+	}))
+	rr := httptest.NewRecorder()
+	h.Logout(rr, logoutRequest(t, map[interface{}]interface{}{constants.SessionKeyUserEmail: "e@example.com"}))
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != constants.LoginPath {
+		t.Fatalf("expected logout redirect despite the panic, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+}
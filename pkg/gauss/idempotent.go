@@ -0,0 +1,56 @@
+package gauss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// sessionKeyCompletedState stores a digest of the state whose callback
+	// logged the session in, so a replay of that callback is recognized.
+	sessionKeyCompletedState = "oauth_completed_state"
+	tokenErrorInvalidGrant   = "invalid_grant"
+)
+
+// stateDigest returns the hex encoded SHA-256 of state.
+func stateDigest(state string) string {
+	digest := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(digest[:])
+}
+
+// replayedCallback reports whether request repeats the callback that already
+// logged webSession in, as happens when a user double-clicks or a browser
+// prefetches the callback URL.
+func replayedCallback(webSession *sessions.Session, receivedState string) bool {
+	if receivedState == "" {
+		return false
+	}
+	if userEmail, _ := webSession.Values[constants.SessionKeyUserEmail].(string); userEmail == "" {
+		return false
+	}
+	completedState, _ := webSession.Values[sessionKeyCompletedState].(string)
+	return completedState == stateDigest(receivedState)
+}
+
+// invalidAuthorizationCode reports whether exchangeError is Google rejecting
+// the authorization code as expired, forged or already exchanged. Google does
+// not tell these apart, but Callback only exchanges codes whose state matched
+// the session, so the concurrent duplicate of a login is the common cause.
+func invalidAuthorizationCode(exchangeError error) bool {
+	var retrieveError *oauth2.RetrieveError
+	return errors.As(exchangeError, &retrieveError) && retrieveError.ErrorCode == tokenErrorInvalidGrant
+}
+
+// redirectAfterReplay sends a repeated callback to the post-login URL without
+// touching the session, whose cookie may already have been replaced by the
+// callback that succeeded.
+func (handlersInstance *Handlers) redirectAfterReplay(responseWriter http.ResponseWriter, request *http.Request, reason string) {
+	logRequestf(request, "Ignoring repeated callback: %s", reason)
+	http.Redirect(responseWriter, request, handlersInstance.service.localRedirectURL, http.StatusFound)
+}
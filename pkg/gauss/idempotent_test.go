@@ -0,0 +1,75 @@
+package gauss
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestCallbackReplayWithSameCookieJar(t *testing.T) {
+	h := newTestHandlers(t)
+	tokenExchanges := 0
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			tokenExchanges++
+			w.Header().Set("Content-Type", "application/json")
+			if tokenExchanges > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid_grant","error_description":"Bad Request"}`)
+				return
+			}
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+		},
+	)
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	cookieJar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: cookieJar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	loginResponse, err := client.Get(server.URL + constants.GoogleAuthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginResponse.Body.Close()
+	authorizationURL, _ := url.Parse(loginResponse.Header.Get("Location"))
+	callbackURL := server.URL + constants.CallbackPath + "?" + url.Values{"state": {authorizationURL.Query().Get("state")}, "code": {"c1"}}.Encode()
+	serverURL, _ := url.Parse(server.URL)
+	cookiesBeforeCallback := cookieJar.Cookies(serverURL)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		callbackResponse, err := client.Get(callbackURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		callbackResponse.Body.Close()
+		if location := callbackResponse.Header.Get("Location"); location != "/dashboard" {
+			t.Fatalf("expected callback %d to land on /dashboard, got %d %q", attempt, callbackResponse.StatusCode, location)
+		}
+	}
+	if tokenExchanges != 1 {
+		t.Fatalf("expected a single token exchange, got %d", tokenExchanges)
+	}
+
+	// A concurrent duplicate still carries the cookie from before the first
+	// callback; Google rejects its code as already redeemed.
+	staleRequest := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	for _, cookie := range cookiesBeforeCallback {
+		staleRequest.AddCookie(cookie)
+	}
+	staleRecorder := httptest.NewRecorder()
+	h.ServeHTTP(staleRecorder, staleRequest)
+	if location := staleRecorder.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected the duplicate to land on /dashboard, got %d %q", staleRecorder.Code, location)
+	}
+	if len(staleRecorder.Result().Cookies()) != 0 {
+		t.Fatal("expected the duplicate not to overwrite the session cookie")
+	}
+}
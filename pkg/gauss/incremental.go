@@ -0,0 +1,202 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// queryParameterScopes lists the scopes requested from the scopes path,
+	// separated by spaces or commas.
+	queryParameterScopes          = "scopes"
+	includeGrantedScopesParameter = "include_granted_scopes"
+	loginHintParameter            = "login_hint"
+	googleScopePrefix             = "https://www.googleapis.com/auth/"
+	sessionKeyIncrementalScopes   = "oauth_incremental_scopes"
+)
+
+// WithIncrementalScopes returns a ServiceOption that lets logged-in users
+// grant the additional scopes later through Handlers.RequestScopes, for
+// example when they click "Connect YouTube". Only scopes listed here may be
+// requested. The scopes path is registered only when this option is used.
+func WithIncrementalScopes(scopes ...Scope) ServiceOption {
+	return func(serviceInstance *Service) {
+		if serviceInstance.incrementalScopes == nil {
+			serviceInstance.incrementalScopes = make(map[string]struct{}, len(scopes))
+		}
+		for _, scope := range scopes {
+			serviceInstance.incrementalScopes[string(scope)] = struct{}{}
+		}
+	}
+}
+
+// RequestScopes starts an authorization round that asks a logged-in user for
+// the scopes in the scopes query parameter, such as
+// "/auth/google/scopes?scopes=youtube.upload&next=/settings". Scopes may be
+// given in full or relative to https://www.googleapis.com/auth/ and must be
+// allowed by WithIncrementalScopes; other values receive 400 Bad Request.
+// Previously granted scopes are kept with include_granted_scopes, and Google
+// is asked to preselect the logged-in account with login_hint. Callback
+// merges the new token and granted scopes into the session and redirects to
+// next, unless the user granted them with another Google account, which fails
+// with ErrCodeAccountMismatch. Unauthenticated requests are sent to the login
+// page.
+func (handlersInstance *Handlers) RequestScopes(responseWriter http.ResponseWriter, request *http.Request) {
+	request = handlersInstance.service.withRequestID(responseWriter, request)
+	sessionUser, authenticated := handlersInstance.service.AuthenticatedUser(responseWriter, request)
+	if !authenticated {
+		http.Redirect(responseWriter, request, handlersInstance.service.LoginURL(request), http.StatusFound)
+		return
+	}
+
+	requestedScopes, scopesValid := handlersInstance.service.resolveIncrementalScopes(request.URL.Query().Get(queryParameterScopes))
+	if !scopesValid {
+		logRequestf(request, "Rejecting incremental scope request %q", request.URL.Query().Get(queryParameterScopes))
+		http.Error(responseWriter, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	stateValue, stateError := handlersInstance.service.GenerateState()
+	if stateError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeStateGeneration, "Failed to generate state", stateError))
+		return
+	}
+	webSession, _ := handlersInstance.store.Get(request, handlersInstance.sessionName)
+	webSession.Values[sessionKeyOAuthState] = stateValue
+	webSession.Values[sessionKeyIncrementalScopes] = strings.Join(requestedScopes, " ")
+	handlersInstance.service.rememberReturnTo(webSession, request)
+	handlersInstance.service.allowCrossSiteCallback(webSession)
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to save session", sessionSaveError))
+		return
+	}
+
+	oauthConfig := handlersInstance.service.authorizationConfigForRequest(request)
+	oauthConfig.Scopes = append(append([]string(nil), oauthConfig.Scopes...), requestedScopes...)
+	loginHint := sessionUser.Email
+	if loginHint == "" {
+		loginHint = sessionUser.ID
+	}
+	authorizationURL := handlersInstance.service.buildAuthorizationURL(oauthConfig, stateValue, promptConsent, oauth2.SetAuthURLParam(includeGrantedScopesParameter, "true"), oauth2.SetAuthURLParam(loginHintParameter, loginHint))
+	http.Redirect(responseWriter, request, authorizationURL, http.StatusFound)
+}
+
+// ScopesHandler returns the handler that requests additional scopes, so it
+// can be mounted independently of RegisterRoutes. Like LoginHandler it
+// applies WithRateLimit.
+func (handlersInstance *Handlers) ScopesHandler() http.Handler {
+	return withSecurityHeaders(handlersInstance.service.rateLimited(handlersInstance.RequestScopes))
+}
+
+// resolveIncrementalScopes returns the allowed scopes named in
+// rawScopeList. The boolean result is false when the list is empty or names
+// a scope that WithIncrementalScopes does not allow.
+func (serviceInstance *Service) resolveIncrementalScopes(rawScopeList string) ([]string, bool) {
+	scopeNames := strings.FieldsFunc(rawScopeList, func(separator rune) bool {
+		return separator == ' ' || separator == ','
+	})
+	if len(scopeNames) == 0 {
+		return nil, false
+	}
+	resolvedScopes := make([]string, 0, len(scopeNames))
+	for _, scopeName := range scopeNames {
+		if _, allowed := serviceInstance.incrementalScopes[scopeName]; allowed {
+			resolvedScopes = append(resolvedScopes, scopeName)
+			continue
+		}
+		if _, allowed := serviceInstance.incrementalScopes[googleScopePrefix+scopeName]; allowed {
+			resolvedScopes = append(resolvedScopes, googleScopePrefix+scopeName)
+			continue
+		}
+		return nil, false
+	}
+	return resolvedScopes, true
+}
+
+// completeScopeGrant finishes an authorization started by RequestScopes: it
+// checks that oauthToken was granted by the logged-in account, stores it,
+// keeping the previous refresh token when Google sends none, adds the newly
+// granted scopes to the session and redirects to the return-to URL.
+func (handlersInstance *Handlers) completeScopeGrant(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session, oauthToken *oauth2.Token) {
+	requestedScopeList, _ := webSession.Values[sessionKeyIncrementalScopes].(string)
+	delete(webSession.Values, sessionKeyIncrementalScopes)
+	delete(webSession.Values, sessionKeyConsentRetry)
+
+	if accountError := handlersInstance.service.verifyGrantingAccount(request.Context(), webSession, oauthToken); accountError != nil {
+		if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+			logRequestf(request, "Failed to save session: %v", sessionSaveError)
+		}
+		handlersInstance.failLogin(responseWriter, request, accountError)
+		return
+	}
+
+	if oauthToken.RefreshToken == "" {
+		var previousToken oauth2.Token
+		if previousTokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string); json.Unmarshal([]byte(previousTokenJSON), &previousToken) == nil {
+			oauthToken.RefreshToken = previousToken.RefreshToken
+		}
+	}
+	if tokenBytes, marshalError := json.Marshal(oauthToken); marshalError == nil {
+		webSession.Values[constants.SessionKeyOAuthToken] = string(tokenBytes)
+	} else {
+		logRequestf(request, "Failed to marshal token: %v", marshalError)
+	}
+
+	previousScopeList, _ := webSession.Values[constants.SessionKeyGrantedScopes].(string)
+	mergedScopes := strings.Fields(previousScopeList)
+	knownScopes := make(map[string]struct{}, len(mergedScopes))
+	for _, scope := range mergedScopes {
+		knownScopes[scope] = struct{}{}
+	}
+	for _, scope := range grantedScopes(oauthToken, strings.Fields(requestedScopeList)) {
+		if _, known := knownScopes[scope]; !known {
+			knownScopes[scope] = struct{}{}
+			mergedScopes = append(mergedScopes, scope)
+		}
+	}
+	webSession.Values[constants.SessionKeyGrantedScopes] = strings.Join(mergedScopes, " ")
+
+	redirectTarget := handlersInstance.service.loginRedirectTarget(request, webSession)
+	delete(webSession.Values, sessionKeyReturnTo)
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		handlersInstance.failLogin(responseWriter, request, newAuthError(ErrCodeSessionSave, "Failed to save granted scopes", sessionSaveError))
+		return
+	}
+	http.Redirect(responseWriter, request, redirectTarget, http.StatusFound)
+}
+
+// verifyGrantingAccount returns an AuthError unless oauthToken was granted by
+// the account logged in to webSession. Accounts are compared by Google ID,
+// taken from the ID token when Google sent one and from the profile
+// otherwise, or by email for sessions that carry no ID.
+func (serviceInstance *Service) verifyGrantingAccount(ctx context.Context, webSession *sessions.Session, oauthToken *oauth2.Token) *AuthError {
+	sessionUserID, _ := webSession.Values[constants.SessionKeyUserID].(string)
+	grantingUserID := subjectFromIDToken(oauthToken)
+	grantingEmail := ""
+	if grantingUserID == "" || sessionUserID == "" {
+		grantingUser, fetchError := serviceInstance.fetchGoogleUser(ctx, oauthToken)
+		if fetchError != nil {
+			return newAuthError(ErrCodeUserInfo, "Failed to identify the account that granted the scopes", fetchError)
+		}
+		grantingUserID, grantingEmail = grantingUser.ID, grantingUser.Email
+	}
+
+	if sessionUserID != "" {
+		if grantingUserID != sessionUserID {
+			return newAuthError(ErrCodeAccountMismatch, fmt.Sprintf("Scopes granted by account %s instead of the logged-in account %s", grantingUserID, sessionUserID), nil)
+		}
+		return nil
+	}
+	sessionEmail, _ := webSession.Values[constants.SessionKeyUserEmail].(string)
+	if sessionEmail == "" || !strings.EqualFold(grantingEmail, sessionEmail) {
+		return newAuthError(ErrCodeAccountMismatch, fmt.Sprintf("Scopes granted by account %s instead of the logged-in account", grantingUserID), nil)
+	}
+	return nil
+}
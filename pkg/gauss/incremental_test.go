@@ -0,0 +1,181 @@
+package gauss
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+func TestRequestScopesMergesGrantedScopes(t *testing.T) {
+	h := newTestHandlers(t, WithIncrementalScopes(ScopeYouTubeUpload))
+	tokenRequests := 0
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			tokenRequests++
+			w.Header().Set("Content-Type", "application/json")
+			if tokenRequests == 1 {
+				io.WriteString(w, `{"access_token":"first","token_type":"bearer","refresh_token":"rtok","scope":"email profile"}`)
+				return
+			}
+			io.WriteString(w, `{"access_token":"second","token_type":"bearer","scope":"email profile https://www.googleapis.com/auth/youtube.upload"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+		},
+	)
+	loginRecorder := loginAsUser(t, h)
+
+	scopesRecorder := httptest.NewRecorder()
+	h.ServeHTTP(scopesRecorder, requestWithCookies(constants.ScopesPath+"?scopes=youtube.upload&next=%2Fsettings", lastCookies(loginRecorder)))
+	googleURL, err := url.Parse(scopesRecorder.Header().Get("Location"))
+	if err != nil || scopesRecorder.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to Google, got %d %q", scopesRecorder.Code, scopesRecorder.Header().Get("Location"))
+	}
+	expectedScopes := "profile email " + string(ScopeYouTubeUpload)
+	if scopeParameter := googleURL.Query().Get("scope"); scopeParameter != expectedScopes {
+		t.Fatalf("expected scopes %q, got %q", expectedScopes, scopeParameter)
+	}
+	if googleURL.Query().Get("include_granted_scopes") != "true" || googleURL.Query().Get("prompt") != "consent" || googleURL.Query().Get("login_hint") != "e@example.com" {
+		t.Fatalf("expected include_granted_scopes, the consent prompt and the login hint, got %s", googleURL.RawQuery)
+	}
+
+	callbackRecorder := httptest.NewRecorder()
+	h.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?code=c2&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(scopesRecorder)))
+	if location := callbackRecorder.Header().Get("Location"); location != "/settings" {
+		t.Fatalf("expected a redirect to /settings, got %d %q", callbackRecorder.Code, location)
+	}
+
+	values := sessionFromResponse(t, callbackRecorder)
+	if grantedScopeList := values[constants.SessionKeyGrantedScopes]; grantedScopeList != "email profile "+string(ScopeYouTubeUpload) {
+		t.Fatalf("expected the merged scope list, got %v", grantedScopeList)
+	}
+	var storedToken oauth2.Token
+	json.Unmarshal([]byte(values[constants.SessionKeyOAuthToken].(string)), &storedToken)
+	if storedToken.AccessToken != "second" || storedToken.RefreshToken != "rtok" {
+		t.Fatalf("expected the new access token with the previous refresh token, got %+v", storedToken)
+	}
+	if values[constants.SessionKeyUserEmail] != "e@example.com" {
+		t.Fatalf("expected the user to stay logged in, got %v", values)
+	}
+	if requireYouTube := NewRequireScopesMiddleware(h.service, ScopeYouTubeUpload); !passes(requireYouTube, callbackRecorder) {
+		t.Fatal("expected the new scope to satisfy NewRequireScopesMiddleware")
+	}
+}
+
+// passes reports whether middleware lets a request carrying the cookies set
+// by rr through.
+func passes(middleware func(http.Handler) http.Handler, rr *httptest.ResponseRecorder) bool {
+	checkRecorder := httptest.NewRecorder()
+	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(checkRecorder, requestWithCookies("/videos", lastCookies(rr)))
+	return checkRecorder.Code == http.StatusOK
+}
+
+func TestRequestScopesRequiresTheLoggedInAccount(t *testing.T) {
+	idToken := func(subject string) string {
+		return "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"`+subject+`"}`)) + ".signature"
+	}
+	testCases := []struct {
+		name             string
+		grantTokenExtra  string
+		grantingUserID   string
+		expectedMismatch bool
+	}{
+		{name: "same account in profile", grantingUserID: "42"},
+		{name: "other account in profile", grantingUserID: "43", expectedMismatch: true},
+		{name: "same account in ID token", grantTokenExtra: `,"id_token":"` + idToken("42") + `"`, grantingUserID: "43"},
+		{name: "other account in ID token", grantTokenExtra: `,"id_token":"` + idToken("43") + `"`, grantingUserID: "42", expectedMismatch: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithIncrementalScopes(ScopeYouTubeUpload))
+			tokenRequests := 0
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					tokenRequests++
+					w.Header().Set("Content-Type", "application/json")
+					if tokenRequests == 1 {
+						io.WriteString(w, `{"access_token":"first","token_type":"bearer","refresh_token":"rtok","scope":"email profile"}`)
+						return
+					}
+					io.WriteString(w, `{"access_token":"second","token_type":"bearer","scope":"email profile https://www.googleapis.com/auth/youtube.upload"`+testCase.grantTokenExtra+`}`)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					userID := "42"
+					if r.Header.Get("Authorization") == "Bearer second" {
+						userID = testCase.grantingUserID
+					}
+					io.WriteString(w, `{"id":"`+userID+`","email":"user`+userID+`@example.com","verified_email":true}`)
+				},
+			)
+			loginRecorder := loginAsUser(t, h)
+			scopesRecorder := httptest.NewRecorder()
+			h.ServeHTTP(scopesRecorder, requestWithCookies(constants.ScopesPath+"?scopes=youtube.upload&next=%2Fsettings", lastCookies(loginRecorder)))
+			googleURL, err := url.Parse(scopesRecorder.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			callbackRecorder := httptest.NewRecorder()
+			h.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?code=c2&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(scopesRecorder)))
+			if !testCase.expectedMismatch {
+				if location := callbackRecorder.Header().Get("Location"); location != "/settings" {
+					t.Fatalf("expected a redirect to /settings, got %d %q", callbackRecorder.Code, location)
+				}
+				return
+			}
+			assertErrorRedirect(t, callbackRecorder, ErrCodeAccountMismatch)
+			values := sessionFromResponse(t, callbackRecorder)
+			var storedToken oauth2.Token
+			json.Unmarshal([]byte(values[constants.SessionKeyOAuthToken].(string)), &storedToken)
+			if storedToken.AccessToken != "first" || values[constants.SessionKeyGrantedScopes] != "email profile" {
+				t.Fatalf("expected the session to keep the logged-in account's token and scopes, got %+v and %v", storedToken, values[constants.SessionKeyGrantedScopes])
+			}
+		})
+	}
+}
+
+func TestRequestScopesRejectsRequests(t *testing.T) {
+	h := newTestHandlers(t, WithIncrementalScopes(ScopeYouTubeUpload))
+	useMockGoogleUser(t, h)
+	loginRecorder := loginAsUser(t, h)
+	testCases := []struct {
+		name             string
+		target           string
+		cookies          []*http.Cookie
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "scope not allowed", target: constants.ScopesPath + "?scopes=youtube", cookies: lastCookies(loginRecorder), expectedStatus: http.StatusBadRequest},
+		{name: "one of several not allowed", target: constants.ScopesPath + "?scopes=youtube.upload,drive", cookies: lastCookies(loginRecorder), expectedStatus: http.StatusBadRequest},
+		{name: "no scopes", target: constants.ScopesPath, cookies: lastCookies(loginRecorder), expectedStatus: http.StatusBadRequest},
+		{name: "not logged in", target: constants.ScopesPath + "?scopes=youtube.upload", expectedStatus: http.StatusFound, expectedLocation: constants.LoginPath + "?next="},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, requestWithCookies(testCase.target, testCase.cookies))
+			if rr.Code != testCase.expectedStatus || !strings.HasPrefix(rr.Header().Get("Location"), testCase.expectedLocation) {
+				t.Fatalf("expected %d %q, got %d %q", testCase.expectedStatus, testCase.expectedLocation, rr.Code, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestScopesPathRequiresIncrementalScopes(t *testing.T) {
+	h := newTestHandlers(t)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.ScopesPath+"?scopes=youtube.upload", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the scopes path to be unregistered, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package gauss
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// genericErrorMessage is shown for error codes without a message.
+	genericErrorMessage = "Sign-in failed. Please try again."
+
+	// defaultErrorMessageLanguage is the language of errorMessages and of
+	// messages set with WithErrorMessages.
+	defaultErrorMessageLanguage = "en"
+
+	headerAcceptLanguage  = "Accept-Language"
+	languageQualityPrefix = "q="
+)
+
+// WithErrorMessages returns a ServiceOption that replaces the default English
+// messages shown on the login page, keyed by error code such as
+// "invalid_state". Codes that are not built in become known codes, so an
+// application can flash its own. The map is copied.
+func WithErrorMessages(messages map[string]string) ServiceOption {
+	return func(serviceInstance *Service) {
+		if serviceInstance.errorMessageOverrides == nil {
+			serviceInstance.errorMessageOverrides = make(map[AuthErrorCode]string, len(messages))
+		}
+		for code, message := range messages {
+			serviceInstance.errorMessageOverrides[AuthErrorCode(code)] = message
+		}
+	}
+}
+
+// WithLocalizedErrorMessages returns a ServiceOption that adds a message
+// catalog for languageTag, such as "de" or "pt-BR". The login page picks the
+// catalog that best matches the request's Accept-Language header, matching a
+// regional tag against its base language, and falls back to WithErrorMessages
+// and then the defaults for codes the catalog lacks. The map is copied.
+func WithLocalizedErrorMessages(languageTag string, messages map[string]string) ServiceOption {
+	return func(serviceInstance *Service) {
+		if serviceInstance.localizedErrorMessages == nil {
+			serviceInstance.localizedErrorMessages = make(map[string]map[AuthErrorCode]string)
+		}
+		normalizedTag := strings.ToLower(strings.TrimSpace(languageTag))
+		catalog := serviceInstance.localizedErrorMessages[normalizedTag]
+		if catalog == nil {
+			catalog = make(map[AuthErrorCode]string, len(messages))
+			serviceInstance.localizedErrorMessages[normalizedTag] = catalog
+		}
+		for code, message := range messages {
+			catalog[AuthErrorCode(code)] = message
+		}
+	}
+}
+
+// knownErrorCode converts rawCode into an AuthErrorCode when it is built in or
+// has a message set with WithErrorMessages.
+func (serviceInstance *Service) knownErrorCode(rawCode string) (AuthErrorCode, bool) {
+	errorCode, known := knownErrorCode(rawCode)
+	if !known {
+		_, known = serviceInstance.errorMessageOverrides[errorCode]
+	}
+	return errorCode, known
+}
+
+// errorMessage returns the message for code in the language preferred by
+// request, or genericErrorMessage when there is none. Preferring English ends
+// the catalog search, since the default messages are English.
+func (serviceInstance *Service) errorMessage(request *http.Request, code AuthErrorCode) string {
+	for _, languageTag := range preferredLanguages(request) {
+		if message, found := serviceInstance.localizedErrorMessages[languageTag][code]; found {
+			return message
+		}
+		if languageTag == defaultErrorMessageLanguage {
+			break
+		}
+	}
+	if message, found := serviceInstance.errorMessageOverrides[code]; found {
+		return message
+	}
+	if message, found := errorMessages[code]; found {
+		return message
+	}
+	return genericErrorMessage
+}
+
+// preferredLanguages returns the lower-cased language tags accepted by
+// request, best first. Each regional tag is followed by its base language.
+func preferredLanguages(request *http.Request) []string {
+	type weightedLanguage struct {
+		tag     string
+		quality float64
+	}
+	var acceptedLanguages []weightedLanguage
+	for _, languageRange := range strings.Split(request.Header.Get(headerAcceptLanguage), headerValueSeparator) {
+		rangeParts := strings.Split(languageRange, forwardedPairSeparator)
+		languageTag := strings.ToLower(strings.TrimSpace(rangeParts[0]))
+		if languageTag == "" || languageTag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, parameter := range rangeParts[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, languageQualityPrefix) {
+				if parsedQuality, parseError := strconv.ParseFloat(strings.TrimPrefix(parameter, languageQualityPrefix), 64); parseError == nil {
+					quality = parsedQuality
+				}
+			}
+		}
+		if quality > 0 {
+			acceptedLanguages = append(acceptedLanguages, weightedLanguage{tag: languageTag, quality: quality})
+		}
+	}
+	sort.SliceStable(acceptedLanguages, func(first, second int) bool {
+		return acceptedLanguages[first].quality > acceptedLanguages[second].quality
+	})
+
+	languageTags := make([]string, 0, len(acceptedLanguages)*2)
+	for _, acceptedLanguage := range acceptedLanguages {
+		languageTags = append(languageTags, acceptedLanguage.tag)
+		if baseLanguage, _, regional := strings.Cut(acceptedLanguage.tag, "-"); regional {
+			languageTags = append(languageTags, baseLanguage)
+		}
+	}
+	return languageTags
+}
@@ -0,0 +1,84 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestLoginPageErrorMessages(t *testing.T) {
+	germanMessages := map[string]string{string(ErrCodeTokenExchange): "Anmeldung fehlgeschlagen."}
+	testCases := []struct {
+		name            string
+		options         []ServiceOption
+		query           string
+		acceptLanguage  string
+		expectedMessage string
+	}{
+		{name: "default message", query: "?error=" + string(ErrCodeTokenExchange), expectedMessage: errorMessages[ErrCodeTokenExchange]},
+		{name: "unknown code", query: "?error=unheard_of", expectedMessage: genericErrorMessage},
+		{
+			name:            "app override",
+			options:         []ServiceOption{WithErrorMessages(map[string]string{string(ErrCodeTokenExchange): "Google said no."})},
+			query:           "?error=" + string(ErrCodeTokenExchange),
+			expectedMessage: "Google said no.",
+		},
+		{
+			name:            "custom code",
+			options:         []ServiceOption{WithErrorMessages(map[string]string{"account_suspended": "Your account is suspended."})},
+			query:           "?error=account_suspended",
+			expectedMessage: "Your account is suspended.",
+		},
+		{
+			name: "language catalog wins over override",
+			options: []ServiceOption{
+				WithErrorMessages(map[string]string{string(ErrCodeTokenExchange): "Google said no."}),
+				WithLocalizedErrorMessages("de", germanMessages),
+			},
+			query:           "?error=" + string(ErrCodeTokenExchange),
+			acceptLanguage:  "de-CH, en;q=0.5",
+			expectedMessage: "Anmeldung fehlgeschlagen.",
+		},
+		{
+			name:            "preferred language by quality",
+			options:         []ServiceOption{WithLocalizedErrorMessages("de", germanMessages)},
+			query:           "?error=" + string(ErrCodeTokenExchange),
+			acceptLanguage:  "de;q=0.2, en",
+			expectedMessage: errorMessages[ErrCodeTokenExchange],
+		},
+		{
+			name:            "catalog without the code falls back",
+			options:         []ServiceOption{WithLocalizedErrorMessages("de", germanMessages)},
+			query:           "?error=" + string(ErrCodeStateMismatch),
+			acceptLanguage:  "de",
+			expectedMessage: errorMessages[ErrCodeStateMismatch],
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			req := httptest.NewRequest(http.MethodGet, constants.LoginPath+testCase.query, nil)
+			if testCase.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", testCase.acceptLanguage)
+			}
+			rr := httptest.NewRecorder()
+			h.loginHandler(rr, req)
+			if body := rr.Body.String(); !strings.Contains(body, testCase.expectedMessage) {
+				t.Fatalf("expected message %q in login page, got %s", testCase.expectedMessage, body)
+			}
+		})
+	}
+}
+
+func TestPreferredLanguages(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "fr;q=0.8, pt-BR, *;q=0.1, es;q=0")
+	expectedLanguages := []string{"pt-br", "pt", "fr"}
+	if languages := preferredLanguages(req); !reflect.DeepEqual(languages, expectedLanguages) {
+		t.Fatalf("expected %v, got %v", expectedLanguages, languages)
+	}
+}
@@ -0,0 +1,43 @@
+package gauss
+
+import "time"
+
+// Metrics receives measurements of the authentication flow. Implementations
+// must be safe for concurrent use. The pkg/metrics/prometheus package provides
+// a Prometheus implementation.
+type Metrics interface {
+	// LoginStarted counts redirects to Google's consent screen.
+	LoginStarted()
+	// LoginSucceeded counts callbacks that established a session.
+	LoginSucceeded()
+	// LoginFailed counts failed login attempts by error code.
+	LoginFailed(code AuthErrorCode)
+	// LoggedOut counts completed logouts.
+	LoggedOut()
+	// ObserveTokenExchange records how long exchanging an authorization code
+	// took and whether it failed.
+	ObserveTokenExchange(duration time.Duration, err error)
+	// ObserveUserInfo records how long fetching the user profile took and
+	// whether it failed.
+	ObserveUserInfo(duration time.Duration, err error)
+}
+
+// WithMetrics returns a ServiceOption that reports the authentication flow to
+// metrics. Without it measurements are discarded at no cost.
+func WithMetrics(metrics Metrics) ServiceOption {
+	return func(serviceInstance *Service) {
+		if metrics != nil {
+			serviceInstance.metrics = metrics
+		}
+	}
+}
+
+// noopMetrics is the default Metrics implementation and discards everything.
+type noopMetrics struct{}
+
+func (noopMetrics) LoginStarted()                             {}
+func (noopMetrics) LoginSucceeded()                           {}
+func (noopMetrics) LoginFailed(AuthErrorCode)                 {}
+func (noopMetrics) LoggedOut()                                {}
+func (noopMetrics) ObserveTokenExchange(time.Duration, error) {}
+func (noopMetrics) ObserveUserInfo(time.Duration, error)      {}
@@ -0,0 +1,106 @@
+package gauss_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	gaussprometheus "github.com/temirov/GAuss/pkg/metrics/prometheus"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// newInstrumentedHandlers returns handlers reporting to a fresh registry and
+// backed by a mock Google.
+func newInstrumentedHandlers(t *testing.T) (*gauss.Handlers, *prometheusclient.Registry) {
+	t.Helper()
+	session.NewSession([]byte("secret"))
+	registry := prometheusclient.NewRegistry()
+	metrics, err := gaussprometheus.NewMetrics(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"verified_email": true, "email": "e@example.com"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	svc, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "",
+		gauss.WithMetrics(metrics),
+		gauss.WithGoogleEndpoints(gauss.GoogleEndpoints{AuthURL: server.URL + "/auth", TokenURL: server.URL + "/token", UserInfoURL: server.URL + "/userinfo"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handlers, err := gauss.NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers, registry
+}
+
+// startLogin runs the Login handler and returns the state and cookies it set.
+func startLogin(t *testing.T, handlers *gauss.Handlers) (string, []*http.Cookie) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.Login(rr, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	location, err := http.NewRequest(http.MethodGet, rr.Header().Get("Location"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.URL.Query().Get("state"), rr.Result().Cookies()
+}
+
+func runCallback(handlers *gauss.Handlers, state string, cookies []*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?code=c1&state="+state, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	handlers.Callback(httptest.NewRecorder(), req)
+}
+
+func TestPrometheusMetricsCountCallbacks(t *testing.T) {
+	handlers, registry := newInstrumentedHandlers(t)
+
+	state, cookies := startLogin(t, handlers)
+	runCallback(handlers, "forged", cookies)
+	runCallback(handlers, state, cookies)
+
+	expectedCounters := map[string]float64{
+		"gauss_login_started_total":   1,
+		"gauss_login_succeeded_total": 1,
+		"gauss_login_failed_total":    1,
+	}
+	for metricName, wantValue := range expectedCounters {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotValue float64
+		for _, family := range families {
+			if family.GetName() == metricName {
+				for _, metric := range family.GetMetric() {
+					gotValue += metric.GetCounter().GetValue()
+				}
+			}
+		}
+		if gotValue != wantValue {
+			t.Fatalf("expected %s to be %v, got %v", metricName, wantValue, gotValue)
+		}
+	}
+	if count := testutil.CollectAndCount(registry, "gauss_token_exchange_duration_seconds"); count != 1 {
+		t.Fatalf("expected one token exchange series, got %d", count)
+	}
+}
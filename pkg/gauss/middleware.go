@@ -1,21 +1,208 @@
 package gauss
 
 import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/sessions"
 	"github.com/temirov/GAuss/pkg/constants"
 	"github.com/temirov/GAuss/pkg/session"
-	"net/http"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 )
 
+// MiddlewareConfig holds the settings applied by NewAuthMiddleware. It is
+// populated through MiddlewareOption values.
+type MiddlewareConfig struct {
+	unauthenticatedHandler http.Handler
+	redirectTo             string
+	apiMode                bool
+	apiDetection           bool
+	skipPathPrefixes       []string
+	skipFuncs              []func(*http.Request) bool
+}
+
+// MiddlewareOption configures the middleware returned by NewAuthMiddleware.
+type MiddlewareOption func(*MiddlewareConfig)
+
+// WithUnauthenticatedHandler returns a MiddlewareOption that serves
+// unauthenticated requests with handler instead of redirecting them to the
+// login page, for example to answer API clients with 401 Unauthorized.
+func WithUnauthenticatedHandler(handler http.Handler) MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		middlewareConfig.unauthenticatedHandler = handler
+	}
+}
+
+// WithRedirectTo returns a MiddlewareOption that redirects unauthenticated
+// requests to redirectPath instead of the Service's login page or, with
+// WithAutoLogin, Google. The URL of a redirected GET or HEAD request is still
+// passed along in the next query parameter.
+func WithRedirectTo(redirectPath string) MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		middlewareConfig.redirectTo = strings.TrimSpace(redirectPath)
+	}
+}
+
+// WithSkipPaths returns a MiddlewareOption that lets requests whose path
+// starts with one of pathPrefixes, such as "/healthz" or "/static/", through
+// without a session. Calls accumulate.
+func WithSkipPaths(pathPrefixes ...string) MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		for _, pathPrefix := range pathPrefixes {
+			if pathPrefix != "" {
+				middlewareConfig.skipPathPrefixes = append(middlewareConfig.skipPathPrefixes, pathPrefix)
+			}
+		}
+	}
+}
+
+// WithSkipFunc returns a MiddlewareOption that lets requests for which
+// skipFunc returns true through without a session. Calls accumulate.
+func WithSkipFunc(skipFunc func(*http.Request) bool) MiddlewareOption {
+	return func(middlewareConfig *MiddlewareConfig) {
+		if skipFunc != nil {
+			middlewareConfig.skipFuncs = append(middlewareConfig.skipFuncs, skipFunc)
+		}
+	}
+}
+
+// skips reports whether request is exempted by WithSkipPaths or WithSkipFunc.
+func (middlewareConfig *MiddlewareConfig) skips(request *http.Request) bool {
+	for _, pathPrefix := range middlewareConfig.skipPathPrefixes {
+		if strings.HasPrefix(request.URL.Path, pathPrefix) {
+			return true
+		}
+	}
+	for _, skipFunc := range middlewareConfig.skipFuncs {
+		if skipFunc(request) {
+			return true
+		}
+	}
+	return false
+}
+
 // AuthMiddleware ensures that a valid GAuss session exists before allowing the
-// request to proceed. Unauthenticated requests are redirected to the login
-// page.
+// request to proceed. Unauthenticated requests are redirected to the default
+// login path. It is middleware.Auth applied to a Service with the defaults of
+// NewService, reading sessions from the package-level store of pkg/session;
+// both are built by NewAuthMiddleware, as pkg/gauss/middleware imports this
+// package and cannot be called from it.
+//
+// Deprecated: Use Handlers.Middleware or middleware.Auth from
+// pkg/gauss/middleware, which honor the Service's session store, login path,
+// session binding and session limits.
 func AuthMiddleware(nextHandler http.Handler) http.Handler {
-	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
-		webSession, _ := session.Store().Get(request, constants.SessionName)
-		if webSession.Values[constants.SessionKeyUserEmail] == nil {
-			http.Redirect(responseWriter, request, constants.LoginPath, http.StatusFound)
-			return
-		}
-		nextHandler.ServeHTTP(responseWriter, request)
-	})
+	defaultService := newDefaultService(&oauth2.Config{Scopes: ScopeStrings(DefaultScopes), Endpoint: google.Endpoint}, &url.URL{}, "", "")
+	return NewAuthMiddleware(defaultService)(nextHandler)
+}
+
+// NewAuthMiddleware returns middleware that requires a logged-in session. It
+// redirects unauthenticated requests to the login path configured on
+// serviceInstance, or straight to Google with WithAutoLogin, and enforces
+// WithSessionBinding and WithMaxConcurrentSessions. The URL of a redirected
+// GET or HEAD request is passed along in the next query parameter and kept in
+// the session, so the user lands back on it after logging in. Authenticated
+// requests carry the user in their context for UserFromContext. With
+// WithCSRFProtection it also checks and provides CSRF tokens, and with
+// WithIdleTimeout and WithMaxSessionLifetime it signs out expired sessions,
+// skipping WithAutoLogin so the login page can explain why. middlewareOptions
+// adjust this behavior: WithRedirectTo changes where unauthenticated requests
+// are sent, WithUnauthenticatedHandler answers them directly, WithAPIMode and
+// WithAPIDetection answer them with 401 JSON, and WithSkipPaths and
+// WithSkipFunc exempt requests from the middleware entirely.
+func NewAuthMiddleware(serviceInstance *Service, middlewareOptions ...MiddlewareOption) func(http.Handler) http.Handler {
+	middlewareConfig := &MiddlewareConfig{}
+	for _, middlewareOption := range middlewareOptions {
+		middlewareOption(middlewareConfig)
+	}
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if middlewareConfig.skips(request) {
+				nextHandler.ServeHTTP(responseWriter, request)
+				return
+			}
+			googleUser, sessionExpired := serviceInstance.authenticatedUser(responseWriter, request)
+			if googleUser == nil {
+				if middlewareConfig.unauthenticatedHandler != nil {
+					middlewareConfig.unauthenticatedHandler.ServeHTTP(responseWriter, request)
+					return
+				}
+				if middlewareConfig.answersWithJSON(request) {
+					writeUnauthenticatedJSON(responseWriter, request, middlewareConfig.apiLoginURL(serviceInstance))
+					return
+				}
+				returnTo, _ := serviceInstance.validReturnTo(originalRequestURI(request))
+				if middlewareConfig.redirectTo != "" {
+					if request.Method != http.MethodGet && request.Method != http.MethodHead {
+						returnTo = ""
+					}
+					http.Redirect(responseWriter, request, withReturnTo(middlewareConfig.redirectTo, returnTo), http.StatusFound)
+					return
+				}
+				if sessionExpired {
+					http.Redirect(responseWriter, request, serviceInstance.sessionExpiredLoginURL(request), http.StatusFound)
+					return
+				}
+				serviceInstance.rememberRequestedURL(responseWriter, request, returnTo)
+				if serviceInstance.autoLoginRedirect(responseWriter, request, returnTo) {
+					return
+				}
+				http.Redirect(responseWriter, request, serviceInstance.LoginURL(request), http.StatusFound)
+				return
+			}
+			request = serviceInstance.withSessionUser(request, googleUser)
+			if serviceInstance.csrfProtection {
+				protectedRequest, verified := serviceInstance.csrfProtected(responseWriter, request)
+				if !verified {
+					return
+				}
+				request = protectedRequest
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// NewRequireScopesMiddleware returns middleware that lets a request through
+// only when its session was granted every scope in requiredScopes at login.
+// Unauthenticated requests are handled like NewAuthMiddleware handles them;
+// authenticated requests missing a scope receive 403 Forbidden. Stack it
+// after NewAuthMiddleware to restrict individual routes.
+func NewRequireScopesMiddleware(serviceInstance *Service, requiredScopes ...Scope) func(http.Handler) http.Handler {
+	requireSession := NewAuthMiddleware(serviceInstance)
+	return func(nextHandler http.Handler) http.Handler {
+		return requireSession(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			webSession := serviceInstance.webSession(request)
+			grantedScopeList, _ := webSession.Values[constants.SessionKeyGrantedScopes].(string)
+			if !hasScopes(grantedScopeList, requiredScopes) {
+				http.Error(responseWriter, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		}))
+	}
+}
+
+// SessionUser returns the profile stored in the GAuss session for request.
+// The boolean result is false when the request carries no logged-in session.
+// It reads the session cookie named in pkg/session; for a Service with
+// WithSessionNamespace use UserFromContext behind its middleware instead.
+func SessionUser(request *http.Request) (*GoogleUser, bool) {
+	webSession, _ := session.Store().Get(request, session.Name())
+	return userFromSession(webSession)
+}
+
+// userFromSession returns the profile stored in webSession. The boolean
+// result is false when webSession is not logged in.
+func userFromSession(webSession *sessions.Session) (*GoogleUser, bool) {
+	userEmail, _ := webSession.Values[constants.SessionKeyUserEmail].(string)
+	if userEmail == "" {
+		return nil, false
+	}
+	userID, _ := webSession.Values[constants.SessionKeyUserID].(string)
+	userName, _ := webSession.Values[constants.SessionKeyUserName].(string)
+	userPicture, _ := webSession.Values[constants.SessionKeyUserPicture].(string)
+	return &GoogleUser{ID: userID, Email: userEmail, Name: userName, Picture: userPicture}, true
 }
@@ -0,0 +1,90 @@
+// Package middleware groups the net/http middleware constructors provided by
+// GAuss. Each returns a standard func(http.Handler) http.Handler, so they
+// compose with any router and with each other:
+//
+//	protected := middleware.Auth(svc)(middleware.TokenRefresh(svc)(appHandler))
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+// Auth returns middleware that requires a logged-in session. It redirects
+// unauthenticated requests to the service's login page and enforces session
+// binding and concurrent session limits. options adjust this behavior; see
+// gauss.NewAuthMiddleware.
+func Auth(service *gauss.Service, options ...gauss.MiddlewareOption) func(http.Handler) http.Handler {
+	return gauss.NewAuthMiddleware(service, options...)
+}
+
+// APIAuth returns middleware like Auth for API routes: unauthenticated
+// requests receive 401 Unauthorized with a JSON body instead of a redirect.
+// See gauss.WithAPIMode.
+func APIAuth(service *gauss.Service, options ...gauss.MiddlewareOption) func(http.Handler) http.Handler {
+	return gauss.NewAuthMiddleware(service, append([]gauss.MiddlewareOption{gauss.WithAPIMode()}, options...)...)
+}
+
+// BearerToken returns middleware that authenticates API requests by a Google
+// access token in an "Authorization: Bearer" header and answers 401
+// Unauthorized when the token is missing or invalid. See
+// gauss.NewBearerTokenMiddleware.
+func BearerToken(service *gauss.Service) func(http.Handler) http.Handler {
+	return gauss.NewBearerTokenMiddleware(service)
+}
+
+// User returns middleware that puts the logged-in user, if any, into the
+// request context for gauss.UserFromContext without requiring a session. See
+// gauss.NewUserContextMiddleware.
+func User(service *gauss.Service) func(http.Handler) http.Handler {
+	return gauss.NewUserContextMiddleware(service)
+}
+
+// TokenRefresh returns middleware that refreshes an expired OAuth token
+// stored in the session before calling the next handler. See
+// gauss.NewTokenRefreshMiddleware.
+func TokenRefresh(service *gauss.Service) func(http.Handler) http.Handler {
+	return gauss.NewTokenRefreshMiddleware(service)
+}
+
+// CSRF returns middleware that rejects state-changing requests of logged-in
+// sessions with 403 Forbidden unless they carry the session's CSRF token. See
+// gauss.NewCSRFMiddleware.
+func CSRF(service *gauss.Service) func(http.Handler) http.Handler {
+	return gauss.NewCSRFMiddleware(service)
+}
+
+// RequireScopes returns middleware that answers 403 Forbidden unless the
+// session was granted every scope in scopes. See
+// gauss.NewRequireScopesMiddleware.
+func RequireScopes(service *gauss.Service, scopes ...gauss.Scope) func(http.Handler) http.Handler {
+	return gauss.NewRequireScopesMiddleware(service, scopes...)
+}
+
+// RequireEmailDomain returns middleware that answers 403 Forbidden unless
+// the logged-in user's email address belongs to one of domains. See
+// gauss.RequireEmailDomain.
+func RequireEmailDomain(domains ...string) func(http.Handler) http.Handler {
+	return gauss.RequireEmailDomain(domains...)
+}
+
+// RequireEmailFunc returns middleware that answers 403 Forbidden unless
+// lookup allows the logged-in user's email address. See
+// gauss.RequireEmailFunc.
+func RequireEmailFunc(lookup gauss.EmailLookupFunc, options ...gauss.EmailAccessOption) func(http.Handler) http.Handler {
+	return gauss.RequireEmailFunc(lookup, options...)
+}
+
+// RequireEmails returns middleware that answers 403 Forbidden unless the
+// logged-in user's email address is one of emails. See gauss.RequireEmails.
+func RequireEmails(emails ...string) func(http.Handler) http.Handler {
+	return gauss.RequireEmails(emails...)
+}
+
+// RateLimit returns middleware that limits each client IP with limiter and
+// answers rejected requests with 429 Too Many Requests. See
+// gauss.NewRateLimitMiddleware.
+func RateLimit(limiter gauss.RateLimiter) func(http.Handler) http.Handler {
+	return gauss.NewRateLimitMiddleware(limiter)
+}
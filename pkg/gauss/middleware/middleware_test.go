@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+func newTestService(t *testing.T) *gauss.Service {
+	t.Helper()
+	session.NewSession([]byte("secret"))
+	svc, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", gauss.WithLoginPath("/account/signin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+// requestWithSession builds a GET request for /reports carrying a session
+// that holds values.
+func requestWithSession(t *testing.T, values map[string]string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	if len(values) == 0 {
+		return req
+	}
+	initRR := httptest.NewRecorder()
+	webSession, _ := session.Store().Get(req, session.Name())
+	for sessionKey, sessionValue := range values {
+		webSession.Values[sessionKey] = sessionValue
+	}
+	if err := webSession.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(initRR.Result().Cookies()[0])
+	return req
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestMiddleware(t *testing.T) {
+	auth := func(svc *gauss.Service) func(http.Handler) http.Handler { return Auth(svc) }
+	loggedIn := map[string]string{
+		constants.SessionKeyUserEmail:     "e@example.com",
+		constants.SessionKeyGrantedScopes: "openid https://www.googleapis.com/auth/userinfo.email",
+	}
+	testCases := []struct {
+		name             string
+		middleware       func(svc *gauss.Service) func(http.Handler) http.Handler
+		sessionValues    map[string]string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "auth redirects", middleware: auth, expectedStatus: http.StatusFound, expectedLocation: "/account/signin?next=%2Freports"},
+		{name: "auth allows", middleware: auth, sessionValues: loggedIn, expectedStatus: http.StatusOK},
+		{name: "token refresh without token", middleware: TokenRefresh, expectedStatus: http.StatusOK},
+		{name: "bearer token missing", middleware: BearerToken, sessionValues: loggedIn, expectedStatus: http.StatusUnauthorized},
+		{
+			name:           "required scope granted",
+			middleware:     func(svc *gauss.Service) func(http.Handler) http.Handler { return RequireScopes(svc, gauss.ScopeEmail) },
+			sessionValues:  loggedIn,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "required scope missing",
+			middleware: func(svc *gauss.Service) func(http.Handler) http.Handler {
+				return RequireScopes(svc, gauss.ScopeYouTubeReadonly)
+			},
+			sessionValues:  loggedIn,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:             "required scope without session",
+			middleware:       func(svc *gauss.Service) func(http.Handler) http.Handler { return RequireScopes(svc, gauss.ScopeEmail) },
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/account/signin?next=%2Freports",
+		},
+		{
+			name:           "email domain allowed",
+			middleware:     func(svc *gauss.Service) func(http.Handler) http.Handler { return RequireEmailDomain("example.com") },
+			sessionValues:  loggedIn,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "email domain denied",
+			middleware:     func(svc *gauss.Service) func(http.Handler) http.Handler { return RequireEmailDomain("corp.com") },
+			sessionValues:  loggedIn,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+			rr := httptest.NewRecorder()
+			testCase.middleware(svc)(okHandler).ServeHTTP(rr, requestWithSession(t, testCase.sessionValues))
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if location := rr.Header().Get("Location"); location != testCase.expectedLocation {
+				t.Fatalf("expected location %q, got %q", testCase.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestDeprecatedAuthMiddlewareMatchesAuth(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	defaultService, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name           string
+		sessionValues  map[string]string
+		expectedStatus int
+	}{
+		{name: "anonymous", expectedStatus: http.StatusFound},
+		{name: "logged in", sessionValues: map[string]string{constants.SessionKeyUserEmail: "e@example.com"}, expectedStatus: http.StatusOK},
+	}
+	for _, testCase := range testCases {
+		deprecatedRecorder := httptest.NewRecorder()
+		gauss.AuthMiddleware(okHandler).ServeHTTP(deprecatedRecorder, requestWithSession(t, testCase.sessionValues))
+		authRecorder := httptest.NewRecorder()
+		Auth(defaultService)(okHandler).ServeHTTP(authRecorder, requestWithSession(t, testCase.sessionValues))
+		if authRecorder.Code != testCase.expectedStatus {
+			t.Fatalf("%s: expected Auth to answer %d, got %d", testCase.name, testCase.expectedStatus, authRecorder.Code)
+		}
+		if deprecatedRecorder.Code != authRecorder.Code || deprecatedRecorder.Header().Get("Location") != authRecorder.Header().Get("Location") {
+			t.Fatalf("%s: expected AuthMiddleware to answer %d %q like Auth, got %d %q", testCase.name, authRecorder.Code, authRecorder.Header().Get("Location"), deprecatedRecorder.Code, deprecatedRecorder.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter, err := gauss.NewTokenBucketRateLimiter(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := RateLimit(limiter)(okHandler)
+	expectedStatuses := []int{http.StatusOK, http.StatusTooManyRequests}
+	for attempt, expectedStatus := range expectedStatuses {
+		rr := httptest.NewRecorder()
+		limited.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/reports", nil))
+		if rr.Code != expectedStatus {
+			t.Fatalf("attempt %d: expected status %d, got %d", attempt+1, expectedStatus, rr.Code)
+		}
+	}
+}
@@ -20,6 +20,9 @@ func TestAuthMiddlewareRedirects(t *testing.T) {
 	if rr.Code != http.StatusFound {
 		t.Fatalf("expected redirect, got %d", rr.Code)
 	}
+	if location := rr.Header().Get("Location"); location != constants.LoginPath+"?next=%2F" {
+		t.Fatalf("expected redirect to the default login path, got %q", location)
+	}
 }
 
 func TestAuthMiddlewarePasses(t *testing.T) {
@@ -40,3 +43,118 @@ func TestAuthMiddlewarePasses(t *testing.T) {
 		t.Fatalf("expected ok, got %d", rr.Code)
 	}
 }
+
+func TestAuthMiddlewareAttachesLoggedInUser(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	loginRecorder := loginAsUser(t, h)
+
+	var contextUser *User
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextUser, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, requestWithCookies("/account", lastCookies(loginRecorder)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected ok, got %d", rr.Code)
+	}
+	if contextUser == nil || contextUser.ID != "42" || contextUser.LoginTime.IsZero() {
+		t.Fatalf("expected the logged-in user in the context, got %+v", contextUser)
+	}
+}
+
+func TestNewAuthMiddlewareRedirectsToConfiguredLoginPath(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithLoginPath("/account/signin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewAuthMiddleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if location := rr.Header().Get("Location"); location != "/account/signin" {
+		t.Fatalf("expected redirect to /account/signin, got %q", location)
+	}
+}
+
+func TestSessionUserReadsStoredProfile(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, found := SessionUser(req); found {
+		t.Fatal("expected no user without a session")
+	}
+	rrInit := httptest.NewRecorder()
+	s, _ := session.Store().Get(req, constants.SessionName)
+	s.Values[constants.SessionKeyUserEmail] = "e@example.com"
+	s.Values[constants.SessionKeyUserName] = "tester"
+	s.Save(req, rrInit)
+	req.AddCookie(rrInit.Result().Cookies()[0])
+
+	user, found := SessionUser(req)
+	if !found || user.Email != "e@example.com" || user.Name != "tester" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestNewAuthMiddlewareUnauthenticatedHandler(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithAutoLogin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unauthenticated := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "login required", http.StatusUnauthorized)
+	})
+	handler := NewAuthMiddleware(svc, WithUnauthenticatedHandler(unauthenticated))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items", nil))
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("Location") != "" {
+		t.Fatalf("expected the unauthenticated handler to answer, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestNewAuthMiddlewareOptions(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithAutoLogin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name             string
+		options          []MiddlewareOption
+		method           string
+		target           string
+		probe            bool
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "skip by prefix", options: []MiddlewareOption{WithSkipPaths("/healthz", "/static/")}, method: "GET", target: "/static/app.css", expectedCode: http.StatusOK},
+		{name: "prefix does not match", options: []MiddlewareOption{WithSkipPaths("/static/")}, method: "GET", target: "/staticky", expectedCode: http.StatusFound, expectedLocation: "/account/signin?next=%2Fstaticky"},
+		{name: "skip by func", options: []MiddlewareOption{WithSkipFunc(func(r *http.Request) bool { return r.Header.Get("X-Probe") == "1" })}, method: "GET", target: "/", probe: true, expectedCode: http.StatusOK},
+		{name: "custom redirect", method: "GET", target: "/reports?page=2", expectedCode: http.StatusFound, expectedLocation: "/account/signin?next=%2Freports%3Fpage%3D2"},
+		{name: "custom redirect with query", options: []MiddlewareOption{WithRedirectTo("/account/signin?lang=de")}, method: "GET", target: "/", expectedCode: http.StatusFound, expectedLocation: "/account/signin?lang=de&next=%2F"},
+		{name: "custom redirect for POST", method: "POST", target: "/reports", expectedCode: http.StatusFound, expectedLocation: "/account/signin"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := append([]MiddlewareOption{WithRedirectTo("/account/signin")}, testCase.options...)
+			handler := NewAuthMiddleware(svc, options...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(testCase.method, testCase.target, nil)
+			if testCase.probe {
+				req.Header.Set("X-Probe", "1")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != testCase.expectedCode || rr.Header().Get("Location") != testCase.expectedLocation {
+				t.Fatalf("expected %d %q, got %d %q", testCase.expectedCode, testCase.expectedLocation, rr.Code, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
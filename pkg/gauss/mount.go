@@ -0,0 +1,108 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountPrefixContextKey stores the path prefix removed by http.StripPrefix in
+// a request context.
+type mountPrefixContextKey struct{}
+
+// Handler returns an http.Handler that serves every GAuss route from an
+// internal mux, so all of them can be mounted at once:
+//
+//	mux.Handle("/", authHandlers.Handler())
+//	mux.Handle("/auth/", http.StripPrefix("/auth", authHandlers.Handler()))
+//
+// When mounted behind http.StripPrefix the configured paths are matched after
+// the prefix is removed, and the prefix is added back to every URL that
+// points at a GAuss route: the OAuth2 callback URL, the links on the login
+// page and the redirects to the login page. Application URLs such as the
+// post-login destination are left unchanged.
+func (handlersInstance *Handlers) Handler() http.Handler {
+	return handlersInstance
+}
+
+// withMountPrefix records in the request context the prefix that
+// http.StripPrefix removed from request, which is detected by comparing the
+// path of the original request URI with the path being served. A prefix
+// stripped together with its trailing slash leaves a path without a leading
+// slash; the slash is restored so the path matches the configured routes.
+func withMountPrefix(request *http.Request) *http.Request {
+	if request.RequestURI == "" {
+		return request
+	}
+	originalURL, parseError := url.ParseRequestURI(request.RequestURI)
+	if parseError != nil || originalURL.Path == request.URL.Path {
+		return request
+	}
+	servedPath := request.URL.Path
+	if !strings.HasPrefix(servedPath, pathPrefix) {
+		servedPath = pathPrefix + servedPath
+	}
+	if !strings.HasSuffix(originalURL.Path, servedPath) {
+		return request
+	}
+	mountPrefix := strings.TrimSuffix(originalURL.Path, servedPath)
+	if mountPrefix == "" {
+		return request
+	}
+	if servedPath != request.URL.Path {
+		request = request.Clone(request.Context())
+		request.URL.Path = servedPath
+		request.URL.RawPath = ""
+	}
+	return request.WithContext(context.WithValue(request.Context(), mountPrefixContextKey{}, mountPrefix))
+}
+
+// mountPrefix returns the prefix recorded by withMountPrefix, or an empty
+// string when request was not served behind http.StripPrefix.
+func mountPrefix(request *http.Request) string {
+	prefix, _ := request.Context().Value(mountPrefixContextKey{}).(string)
+	return prefix
+}
+
+// mountedURL adds the mount prefix of request to target when target is the
+// path of a GAuss route, optionally followed by a query. Other targets are
+// returned unchanged.
+func (serviceInstance *Service) mountedURL(request *http.Request, target string) string {
+	prefix := mountPrefix(request)
+	if prefix == "" {
+		return target
+	}
+	targetPath, _, _ := strings.Cut(target, "?")
+	if !serviceInstance.isRoutePath(targetPath) {
+		return target
+	}
+	return prefix + target
+}
+
+// isRoutePath reports whether candidatePath is one of the paths served by
+// Handlers.
+func (serviceInstance *Service) isRoutePath(candidatePath string) bool {
+	routePaths := []string{
+		serviceInstance.loginPath,
+		serviceInstance.googleAuthPath,
+		serviceInstance.logoutPath,
+		serviceInstance.scopesPath,
+		serviceInstance.disconnectPath,
+	}
+	if serviceInstance.callbackPath != nil {
+		routePaths = append(routePaths, serviceInstance.callbackPath.Path)
+	}
+	for _, routePath := range routePaths {
+		if routePath != "" && routePath == candidatePath {
+			return true
+		}
+	}
+	return false
+}
+
+// originalRequestURI returns the request URI as the client sent it, including
+// any prefix removed by http.StripPrefix.
+func originalRequestURI(request *http.Request) string {
+	return mountPrefix(request) + request.URL.RequestURI()
+}
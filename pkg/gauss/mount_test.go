@@ -0,0 +1,111 @@
+package gauss
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestHandlerRunsFlowWhenMounted(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mountPattern string
+		stripPrefix  string
+		routePrefix  string
+	}{
+		{name: "root", mountPattern: "/", routePrefix: ""},
+		{name: "strip prefix", mountPattern: "/auth/", stripPrefix: "/auth", routePrefix: "/auth"},
+		{name: "strip prefix with slash", mountPattern: "/auth/", stripPrefix: "/auth/", routePrefix: "/auth"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			exchangedRedirectURI := ""
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					r.ParseForm()
+					exchangedRedirectURI = r.Form.Get("redirect_uri")
+					w.Header().Set("Content-Type", "application/json")
+					io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+				},
+			)
+			gaussHandler := h.Handler()
+			if testCase.stripPrefix != "" {
+				gaussHandler = http.StripPrefix(testCase.stripPrefix, gaussHandler)
+			}
+			appMux := http.NewServeMux()
+			appMux.Handle(testCase.mountPattern, gaussHandler)
+			server := httptest.NewServer(appMux)
+			t.Cleanup(server.Close)
+			cookieJar, _ := cookiejar.New(nil)
+			client := &http.Client{Jar: cookieJar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+			loginPageResponse, err := client.Get(server.URL + testCase.routePrefix + constants.LoginPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loginPage, _ := io.ReadAll(loginPageResponse.Body)
+			loginPageResponse.Body.Close()
+			if loginPageResponse.StatusCode != http.StatusOK {
+				t.Fatalf("expected the login page, got %d", loginPageResponse.StatusCode)
+			}
+			if !strings.Contains(string(loginPage), `href="`+testCase.routePrefix+constants.GoogleAuthPath+`"`) {
+				t.Fatalf("expected the login link to include the mount prefix, got %s", loginPage)
+			}
+
+			loginResponse, err := client.Get(server.URL + testCase.routePrefix + constants.GoogleAuthPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loginResponse.Body.Close()
+			authorizationURL, _ := url.Parse(loginResponse.Header.Get("Location"))
+			expectedRedirectURI := server.URL + testCase.routePrefix + constants.CallbackPath
+			if redirectURI := authorizationURL.Query().Get("redirect_uri"); redirectURI != expectedRedirectURI {
+				t.Fatalf("expected redirect_uri %q, got %q", expectedRedirectURI, redirectURI)
+			}
+
+			callbackQuery := url.Values{"state": {authorizationURL.Query().Get("state")}, "code": {"c1"}}
+			callbackResponse, err := client.Get(server.URL + testCase.routePrefix + constants.CallbackPath + "?" + callbackQuery.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			callbackResponse.Body.Close()
+			if location := callbackResponse.Header.Get("Location"); location != "/dashboard" {
+				t.Fatalf("expected the callback to land on /dashboard, got %d %q", callbackResponse.StatusCode, location)
+			}
+			if exchangedRedirectURI != expectedRedirectURI {
+				t.Fatalf("expected the token exchange to use %q, got %q", expectedRedirectURI, exchangedRedirectURI)
+			}
+
+			logoutResponse, err := client.Get(server.URL + testCase.routePrefix + constants.LogoutPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logoutResponse.Body.Close()
+			if location := logoutResponse.Header.Get("Location"); location != testCase.routePrefix+constants.LoginPath {
+				t.Fatalf("expected logout to redirect to %q, got %q", testCase.routePrefix+constants.LoginPath, location)
+			}
+		})
+	}
+}
+
+func TestHandlerMountedErrorRedirectKeepsPrefix(t *testing.T) {
+	h := newTestHandlers(t)
+	appMux := http.NewServeMux()
+	appMux.Handle("/auth/", http.StripPrefix("/auth", h.Handler()))
+	request := httptest.NewRequest(http.MethodGet, "/auth"+constants.CallbackPath+"?state=missing&code=c1", nil)
+	recorder := httptest.NewRecorder()
+	appMux.ServeHTTP(recorder, request)
+	if location := recorder.Header().Get("Location"); !strings.HasPrefix(location, "/auth"+constants.LoginPath+"?") {
+		t.Fatalf("expected a redirect to the mounted login page, got %d %q", recorder.Code, location)
+	}
+}
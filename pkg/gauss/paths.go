@@ -0,0 +1,106 @@
+package gauss
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+const pathPrefix = "/"
+
+// WithLoginPath returns a ServiceOption that serves the login page at
+// loginPath instead of constants.LoginPath. Authentication failures and the
+// default logout redirect send the client to this path.
+func WithLoginPath(loginPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.loginPath = strings.TrimSpace(loginPath)
+	}
+}
+
+// WithGoogleAuthPath returns a ServiceOption that starts the OAuth2 flow at
+// googleAuthPath instead of constants.GoogleAuthPath. The embedded login page
+// links to this path.
+func WithGoogleAuthPath(googleAuthPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.googleAuthPath = strings.TrimSpace(googleAuthPath)
+	}
+}
+
+// WithCallbackPath returns a ServiceOption that receives the OAuth2 redirect
+// at callbackPath instead of constants.CallbackPath. The path is resolved
+// against the public base URL to build the redirect URI sent to Google, so it
+// must also be registered in the Google Cloud console.
+func WithCallbackPath(callbackPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.callbackPath = &url.URL{Path: strings.TrimSpace(callbackPath)}
+	}
+}
+
+// WithScopesPath returns a ServiceOption that serves Handlers.RequestScopes
+// at scopesPath instead of constants.ScopesPath.
+func WithScopesPath(scopesPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.scopesPath = strings.TrimSpace(scopesPath)
+	}
+}
+
+// WithLogoutPath returns a ServiceOption that serves logout at logoutPath
+// instead of constants.LogoutPath.
+func WithLogoutPath(logoutPath string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.logoutPath = strings.TrimSpace(logoutPath)
+	}
+}
+
+// WithPaths returns a ServiceOption that serves the GAuss routes at paths.
+// Empty fields keep their current value, so a Paths value that only sets
+// LoginPath moves just the login page. Start from constants.DefaultPaths to
+// spell out every route. It is equivalent to the individual options such as
+// WithLoginPath and WithCallbackPath.
+func WithPaths(paths constants.Paths) ServiceOption {
+	return func(serviceInstance *Service) {
+		configuredPaths := []struct {
+			path       string
+			pathOption func(string) ServiceOption
+		}{
+			{path: paths.LoginPath, pathOption: WithLoginPath},
+			{path: paths.GoogleAuthPath, pathOption: WithGoogleAuthPath},
+			{path: paths.CallbackPath, pathOption: WithCallbackPath},
+			{path: paths.ScopesPath, pathOption: WithScopesPath},
+			{path: paths.DisconnectPath, pathOption: WithDisconnectPath},
+			{path: paths.LogoutPath, pathOption: WithLogoutPath},
+		}
+		for _, configuredPath := range configuredPaths {
+			if strings.TrimSpace(configuredPath.path) != "" {
+				configuredPath.pathOption(configuredPath.path)(serviceInstance)
+			}
+		}
+	}
+}
+
+// validatePaths reports an error when a configured route is not an absolute
+// path.
+func (serviceInstance *Service) validatePaths() error {
+	configuredPaths := map[string]string{
+		"login":       serviceInstance.loginPath,
+		"google auth": serviceInstance.googleAuthPath,
+		"callback":    serviceInstance.callbackPath.Path,
+		"logout":      serviceInstance.logoutPath,
+		"scopes":      serviceInstance.scopesPath,
+		"disconnect":  serviceInstance.disconnectPath,
+	}
+	for pathName, configuredPath := range configuredPaths {
+		if !strings.HasPrefix(configuredPath, pathPrefix) {
+			return fmt.Errorf("invalid %s path %q: must start with %q", pathName, configuredPath, pathPrefix)
+		}
+	}
+	return nil
+}
+
+// LoginPath returns the path that serves the login page, which is where
+// unauthenticated requests are sent.
+func (serviceInstance *Service) LoginPath() string {
+	return serviceInstance.loginPath
+}
@@ -0,0 +1,142 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+const (
+	customLoginPath      = "/account/signin"
+	customGoogleAuthPath = "/account/google"
+	customCallbackPath   = "/account/callback"
+	customLogoutPath     = "/account/signout"
+)
+
+// newCustomPathMux mounts each handler individually at nonstandard paths, as an
+// application using its own router would.
+func newCustomPathMux(t *testing.T) (*Handlers, *http.ServeMux) {
+	t.Helper()
+	h := newTestHandlers(t,
+		WithLoginPath(customLoginPath),
+		WithGoogleAuthPath(customGoogleAuthPath),
+		WithCallbackPath(customCallbackPath),
+		WithLogoutPath(customLogoutPath),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(customLoginPath, h.LoginPageHandler())
+	mux.Handle(customGoogleAuthPath, h.LoginHandler())
+	mux.Handle(customCallbackPath, h.CallbackHandler())
+	mux.Handle(customLogoutPath, h.LogoutHandler())
+	return h, mux
+}
+
+func TestHandlersCompleteFlowAtCustomPaths(t *testing.T) {
+	h, mux := newCustomPathMux(t)
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+
+	pageRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(pageRecorder, httptest.NewRequest(http.MethodGet, customLoginPath, nil))
+	if !strings.Contains(pageRecorder.Body.String(), `href="`+customGoogleAuthPath+`"`) {
+		t.Fatal("expected the login page to link to the custom Google auth path")
+	}
+
+	loginRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(loginRecorder, httptest.NewRequest(http.MethodGet, customGoogleAuthPath, nil))
+	authorizationURL, parseError := url.Parse(loginRecorder.Header().Get("Location"))
+	if parseError != nil {
+		t.Fatalf("failed to parse redirect: %v", parseError)
+	}
+	if redirectURI := authorizationURL.Query().Get("redirect_uri"); !strings.HasSuffix(redirectURI, customCallbackPath) {
+		t.Fatalf("expected redirect_uri to end with %s, got %s", customCallbackPath, redirectURI)
+	}
+
+	callbackQuery := url.Values{"state": {authorizationURL.Query().Get("state")}, "code": {"c1"}}
+	callbackRequest := httptest.NewRequest(http.MethodGet, customCallbackPath+"?"+callbackQuery.Encode(), nil)
+	for _, cookie := range lastCookies(loginRecorder) {
+		callbackRequest.AddCookie(cookie)
+	}
+	callbackRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(callbackRecorder, callbackRequest)
+	if location := callbackRecorder.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %q", location)
+	}
+
+	logoutRequest := httptest.NewRequest(http.MethodGet, customLogoutPath, nil)
+	for _, cookie := range lastCookies(callbackRecorder) {
+		logoutRequest.AddCookie(cookie)
+	}
+	logoutRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(logoutRecorder, logoutRequest)
+	if location := logoutRecorder.Header().Get("Location"); location != customLoginPath {
+		t.Fatalf("expected logout redirect to %s, got %q", customLoginPath, location)
+	}
+}
+
+func TestCallbackErrorsRedirectToCustomLoginPath(t *testing.T) {
+	_, mux := newCustomPathMux(t)
+
+	callbackRequest := httptest.NewRequest(http.MethodGet, customCallbackPath+"?state=other&code=c1", nil)
+	seedState(t, callbackRequest, "s123")
+	callbackRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(callbackRecorder, callbackRequest)
+
+	location := callbackRecorder.Header().Get("Location")
+	if !strings.HasPrefix(location, customLoginPath+"?") {
+		t.Fatalf("expected redirect to %s, got %q", customLoginPath, location)
+	}
+}
+
+func TestRegisterRoutesUsesConfiguredPaths(t *testing.T) {
+	h := newTestHandlers(t, WithLoginPath(customLoginPath))
+	mux := h.RegisterRoutes(http.NewServeMux())
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, customLoginPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the login page at %s, got status %d", customLoginPath, recorder.Code)
+	}
+}
+
+func TestNewServiceRejectsRelativePaths(t *testing.T) {
+	_, serviceError := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithCallbackPath("auth/callback"))
+	if serviceError == nil {
+		t.Fatal("expected an error for a path without a leading slash")
+	}
+}
+
+func TestWithPathsMovesRoutes(t *testing.T) {
+	customPaths := constants.DefaultPaths()
+	customPaths.LoginPath = customLoginPath
+	customPaths.CallbackPath = customCallbackPath
+	h := newTestHandlers(t, WithPaths(customPaths), WithPaths(constants.Paths{LogoutPath: customLogoutPath}))
+	mux := h.RegisterRoutes(http.NewServeMux())
+
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+	}{
+		{name: "custom login page", method: http.MethodGet, target: customLoginPath, expectedStatus: http.StatusOK},
+		{name: "default login path freed", method: http.MethodGet, target: constants.LoginPath, expectedStatus: http.StatusNotFound},
+		{name: "default google auth path kept", method: http.MethodGet, target: constants.GoogleAuthPath, expectedStatus: http.StatusFound},
+		{name: "custom logout from partial paths", method: http.MethodPost, target: customLogoutPath, expectedStatus: http.StatusFound},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(testCase.method, testCase.target, nil))
+			if recorder.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, recorder.Code)
+			}
+		})
+	}
+	if redirectURL := h.service.config.RedirectURL; redirectURL != "http://localhost:8080"+customCallbackPath {
+		t.Fatalf("expected the redirect URI to use the custom callback path, got %s", redirectURL)
+	}
+}
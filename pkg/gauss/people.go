@@ -0,0 +1,185 @@
+package gauss
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// UserInfoVersion2 selects the v2 userinfo endpoint used by GetUser. It is
+	// the default.
+	UserInfoVersion2 = 2
+	// UserInfoVersion3 selects the People API used by GetUserV3.
+	UserInfoVersion3 = 3
+
+	peopleResourcePrefix = "people/"
+	spanNameGetUserV3    = "gauss.GetUserV3"
+)
+
+// PersonFieldMetadata describes a People API field value.
+type PersonFieldMetadata struct {
+	Primary  bool `json:"primary"`
+	Verified bool `json:"verified"`
+}
+
+// PersonName is one of the names of a People API person.
+type PersonName struct {
+	Metadata    PersonFieldMetadata `json:"metadata"`
+	DisplayName string              `json:"displayName"`
+	GivenName   string              `json:"givenName"`
+	FamilyName  string              `json:"familyName"`
+}
+
+// PersonEmailAddress is one of the email addresses of a People API person.
+type PersonEmailAddress struct {
+	Metadata PersonFieldMetadata `json:"metadata"`
+	Value    string              `json:"value"`
+}
+
+// PersonPhoto is one of the photos of a People API person. Default is true
+// when Google generated the photo rather than the user uploading it.
+type PersonPhoto struct {
+	Metadata PersonFieldMetadata `json:"metadata"`
+	URL      string              `json:"url"`
+	Default  bool                `json:"default"`
+}
+
+// PersonLocale is one of the locales of a People API person.
+type PersonLocale struct {
+	Metadata PersonFieldMetadata `json:"metadata"`
+	Value    string              `json:"value"`
+}
+
+// GoogleUserV3 represents a user profile retrieved from the People API.
+type GoogleUserV3 struct {
+	// ResourceName is the People API resource, such as "people/1234".
+	ResourceName string `json:"resourceName"`
+	// ID is Google's stable subject identifier, taken from ResourceName.
+	ID             string               `json:"-"`
+	Names          []PersonName         `json:"names"`
+	EmailAddresses []PersonEmailAddress `json:"emailAddresses"`
+	Photos         []PersonPhoto        `json:"photos"`
+	Locales        []PersonLocale       `json:"locales"`
+}
+
+// PrimaryEmailAddress returns the email address marked primary, falling back
+// to the first one. The boolean result is false when there is none.
+func (user *GoogleUserV3) PrimaryEmailAddress() (PersonEmailAddress, bool) {
+	for _, emailAddress := range user.EmailAddresses {
+		if emailAddress.Metadata.Primary {
+			return emailAddress, true
+		}
+	}
+	if len(user.EmailAddresses) > 0 {
+		return user.EmailAddresses[0], true
+	}
+	return PersonEmailAddress{}, false
+}
+
+// PrimaryName returns the name marked primary, falling back to the first one.
+// The boolean result is false when there is none.
+func (user *GoogleUserV3) PrimaryName() (PersonName, bool) {
+	for _, personName := range user.Names {
+		if personName.Metadata.Primary {
+			return personName, true
+		}
+	}
+	if len(user.Names) > 0 {
+		return user.Names[0], true
+	}
+	return PersonName{}, false
+}
+
+// PrimaryPhoto returns the photo marked primary, falling back to the first
+// one. The boolean result is false when there is none.
+func (user *GoogleUserV3) PrimaryPhoto() (PersonPhoto, bool) {
+	for _, photo := range user.Photos {
+		if photo.Metadata.Primary {
+			return photo, true
+		}
+	}
+	if len(user.Photos) > 0 {
+		return user.Photos[0], true
+	}
+	return PersonPhoto{}, false
+}
+
+// PrimaryLocale returns the locale marked primary, falling back to the first
+// one. The boolean result is false when there is none.
+func (user *GoogleUserV3) PrimaryLocale() (PersonLocale, bool) {
+	for _, locale := range user.Locales {
+		if locale.Metadata.Primary {
+			return locale, true
+		}
+	}
+	if len(user.Locales) > 0 {
+		return user.Locales[0], true
+	}
+	return PersonLocale{}, false
+}
+
+// GoogleUser flattens the profile to the primary values, in the form stored in
+// the session and passed to hooks.
+func (user *GoogleUserV3) GoogleUser() *GoogleUser {
+	flattenedUser := &GoogleUser{ID: user.ID}
+	if emailAddress, found := user.PrimaryEmailAddress(); found {
+		flattenedUser.Email = emailAddress.Value
+		flattenedUser.VerifiedEmail = emailAddress.Metadata.Verified
+	}
+	if personName, found := user.PrimaryName(); found {
+		flattenedUser.Name = personName.DisplayName
+	}
+	if photo, found := user.PrimaryPhoto(); found {
+		flattenedUser.Picture = photo.URL
+	}
+	if locale, found := user.PrimaryLocale(); found {
+		flattenedUser.Locale = locale.Value
+	}
+	return flattenedUser
+}
+
+// WithUserInfoVersion returns a ServiceOption that selects how Callback
+// fetches the user profile: UserInfoVersion2 uses GetUser and
+// UserInfoVersion3 uses GetUserV3. NewService rejects other versions.
+func WithUserInfoVersion(version int) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.userInfoVersion = version
+	}
+}
+
+// GetUserV3 retrieves the profile associated with oauthToken from Google's
+// People API, which replaces the deprecated v2 userinfo endpoint.
+func (serviceInstance *Service) GetUserV3(ctx context.Context, oauthToken *oauth2.Token) (fetchedUser *GoogleUserV3, err error) {
+	ctx, span := serviceInstance.startSpan(ctx, spanNameGetUserV3)
+	startTime := serviceInstance.now()
+	defer func() {
+		serviceInstance.metrics.ObserveUserInfo(serviceInstance.now().Sub(startTime), err)
+		endSpan(span, err, "user info request failed")
+	}()
+
+	var user GoogleUserV3
+	if fetchError := serviceInstance.fetchProfile(ctx, oauthToken, serviceInstance.googleEndpoints.PeopleURL, &user); fetchError != nil {
+		return nil, fetchError
+	}
+	user.ID = strings.TrimPrefix(user.ResourceName, peopleResourcePrefix)
+	if user.ID == "" {
+		user.ID = subjectFromIDToken(oauthToken)
+	}
+
+	return &user, nil
+}
+
+// fetchGoogleUser retrieves the profile with the API selected by
+// WithUserInfoVersion.
+func (serviceInstance *Service) fetchGoogleUser(ctx context.Context, oauthToken *oauth2.Token) (*GoogleUser, error) {
+	if serviceInstance.userInfoVersion == UserInfoVersion3 {
+		peopleUser, peopleError := serviceInstance.GetUserV3(ctx, oauthToken)
+		if peopleError != nil {
+			return nil, peopleError
+		}
+		return peopleUser.GoogleUser(), nil
+	}
+	return serviceInstance.GetUserContext(ctx, oauthToken)
+}
@@ -0,0 +1,123 @@
+package gauss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+const peopleResponse = `{
+	"resourceName": "people/1234567890",
+	"names": [
+		{"metadata": {"primary": false}, "displayName": "Old Name"},
+		{"metadata": {"primary": true}, "displayName": "Ada Lovelace", "givenName": "Ada", "familyName": "Lovelace"}
+	],
+	"emailAddresses": [
+		{"metadata": {"primary": true, "verified": true}, "value": "ada@example.com"},
+		{"metadata": {"verified": false}, "value": "ada@work.example.com"}
+	],
+	"photos": [{"metadata": {"primary": true}, "url": "https://example.com/ada.png"}],
+	"locales": [{"metadata": {"primary": true}, "value": "en-GB"}]
+}`
+
+// usePeopleEndpoint serves responseBody as the People API response for the
+// duration of the test.
+func usePeopleEndpoint(t *testing.T, responseBody string) ServiceOption {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, responseBody)
+	}))
+	t.Cleanup(server.Close)
+	return WithGoogleEndpoints(GoogleEndpoints{PeopleURL: server.URL})
+}
+
+func TestGetUserV3(t *testing.T) {
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", ScopeStrings(DefaultScopes), "", usePeopleEndpoint(t, peopleResponse))
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+
+	user, err := svc.GetUserV3(context.Background(), &oauth2.Token{AccessToken: "abc"})
+	if err != nil {
+		t.Fatalf("GetUserV3 error: %v", err)
+	}
+	if user.ID != "1234567890" || len(user.EmailAddresses) != 2 || len(user.Names) != 2 {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if primaryName, _ := user.PrimaryName(); primaryName.GivenName != "Ada" {
+		t.Fatalf("expected the primary name, got %+v", primaryName)
+	}
+
+	flattenedUser := user.GoogleUser()
+	expectedUser := GoogleUser{
+		ID:            "1234567890",
+		Email:         "ada@example.com",
+		VerifiedEmail: true,
+		Name:          "Ada Lovelace",
+		Picture:       "https://example.com/ada.png",
+		Locale:        "en-GB",
+	}
+	if *flattenedUser != expectedUser {
+		t.Fatalf("expected %+v, got %+v", expectedUser, *flattenedUser)
+	}
+}
+
+func TestGetUserV3Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithGoogleEndpoints(GoogleEndpoints{PeopleURL: server.URL}))
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	if _, err := svc.GetUserV3(context.Background(), &oauth2.Token{AccessToken: "abc"}); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
+
+func TestCallbackUsesPeopleAPIWithUserInfoVersion3(t *testing.T) {
+	h := newTestHandlers(t, WithUserInfoVersion(UserInfoVersion3))
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Error("expected the v2 userinfo endpoint not to be called")
+		},
+	)
+	usePeopleEndpoint(t, peopleResponse)(h.service)
+
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+	values := sessionFromResponse(t, rr)
+	if values[constants.SessionKeyUserID] != "1234567890" || values[constants.SessionKeyUserEmail] != "ada@example.com" || values[constants.SessionKeyUserName] != "Ada Lovelace" {
+		t.Fatalf("expected the People API profile in the session, got %v", values)
+	}
+}
+
+func TestWithUserInfoVersionRejectsUnknownVersions(t *testing.T) {
+	for _, version := range []int{0, 1, 4} {
+		if _, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithUserInfoVersion(version)); err == nil {
+			t.Errorf("expected version %d to be rejected", version)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// maxPictureBytes bounds the size of a profile picture GetUserPicture reads.
+const maxPictureBytes = 5 << 20
+
+const spanNameGetUserPicture = "gauss.GetUserPicture"
+
+// googlePictureDomain is the domain Google serves profile pictures from.
+const googlePictureDomain = "googleusercontent.com"
+
+// WithPictureCacheTTL returns a ServiceOption that caches the pictures
+// returned by GetUserPicture for ttl, keyed by picture URL, so avatars shown
+// on every page are fetched from Google once. Expired entries are dropped when
+// read and swept periodically. NewService rejects a non-positive ttl.
+func WithPictureCacheTTL(ttl time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.pictureCacheTTL = ttl
+		serviceInstance.pictureCacheEnabled = true
+	}
+}
+
+// GetUserPicture downloads the profile picture at pictureURL, typically
+// GoogleUser.Picture, so applications can serve avatars without sending
+// browsers to Google's servers. It returns the image bytes and their content
+// type. The request is made with GetClient, so it carries oauthToken and uses
+// the client set with WithCustomHTTPClient or placed in ctx with
+// oauth2.HTTPClient. To keep the token from reaching other servers,
+// pictureURL must be an HTTPS URL on googleusercontent.com. Pictures larger
+// than 5 MiB are rejected.
+func (serviceInstance *Service) GetUserPicture(ctx context.Context, oauthToken *oauth2.Token, pictureURL string) (pictureBytes []byte, contentType string, err error) {
+	trimmedURL := strings.TrimSpace(pictureURL)
+	if trimmedURL == "" {
+		return nil, "", errors.New("picture URL is required")
+	}
+	if urlError := validatePictureURL(trimmedURL); urlError != nil {
+		return nil, "", urlError
+	}
+	if storedPicture, found := serviceInstance.pictureCache.load(trimmedURL, serviceInstance.now()); found {
+		return append([]byte(nil), storedPicture.data...), storedPicture.contentType, nil
+	}
+
+	ctx, span := serviceInstance.startSpan(ctx, spanNameGetUserPicture)
+	defer func() {
+		endSpan(span, err, "picture request failed")
+	}()
+
+	pictureRequest, requestError := http.NewRequestWithContext(ctx, http.MethodGet, trimmedURL, nil)
+	if requestError != nil {
+		return nil, "", fmt.Errorf("failed to build picture request: %w", requestError)
+	}
+	httpResponse, httpError := serviceInstance.GetClient(ctx, oauthToken).Do(pictureRequest)
+	if httpError != nil {
+		return nil, "", fmt.Errorf("failed to get picture: %w", httpError)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("picture request returned status %d", httpResponse.StatusCode)
+	}
+	pictureBytes, readError := io.ReadAll(io.LimitReader(httpResponse.Body, maxPictureBytes+1))
+	if readError != nil {
+		return nil, "", fmt.Errorf("failed to read picture: %w", readError)
+	}
+	if len(pictureBytes) > maxPictureBytes {
+		return nil, "", fmt.Errorf("picture exceeds %d bytes", maxPictureBytes)
+	}
+	contentType = httpResponse.Header.Get(headerContentType)
+	if contentType == "" {
+		contentType = http.DetectContentType(pictureBytes)
+	}
+
+	if serviceInstance.pictureCache != nil {
+		currentTime := serviceInstance.now()
+		storedPicture := cachedPicture{data: append([]byte(nil), pictureBytes...), contentType: contentType}
+		serviceInstance.pictureCache.store(trimmedURL, storedPicture, currentTime.Add(serviceInstance.pictureCacheTTL), currentTime)
+	}
+	return pictureBytes, contentType, nil
+}
+
+// cachedPicture is a picture stored in the picture cache.
+type cachedPicture struct {
+	data        []byte
+	contentType string
+}
+
+// validatePictureURL reports an error unless pictureURL is an HTTPS URL on
+// Google's picture host, googleusercontent.com, so the access token
+// GetUserPicture sends never reaches other servers.
+func validatePictureURL(pictureURL string) error {
+	parsedURL, parseError := url.Parse(pictureURL)
+	if parseError != nil {
+		return fmt.Errorf("invalid picture URL: %w", parseError)
+	}
+	pictureHost := strings.ToLower(parsedURL.Hostname())
+	if parsedURL.Scheme != "https" || parsedURL.User != nil || (pictureHost != googlePictureDomain && !strings.HasSuffix(pictureHost, "."+googlePictureDomain)) {
+		return fmt.Errorf("picture URL %q is not an HTTPS URL on %s", pictureURL, googlePictureDomain)
+	}
+	return nil
+}
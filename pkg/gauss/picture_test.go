@@ -0,0 +1,145 @@
+package gauss
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var testPictureBytes = []byte("\x89PNG\r\n\x1a\nfake-image")
+
+// testPictureHost is the Google picture host the tests request pictures from.
+const testPictureHost = "https://lh3.googleusercontent.com"
+
+// newPictureServer starts a TLS server running handler and returns a
+// ServiceOption whose client sends every request to it, so tests can request
+// pictures from Google's picture host.
+func newPictureServer(t *testing.T, handler http.HandlerFunc) ServiceOption {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	serverClient := server.Client()
+	serverTransport := serverClient.Transport.(*http.Transport)
+	serverTransport.TLSClientConfig.InsecureSkipVerify = true
+	serverTransport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return WithCustomHTTPClient(serverClient)
+}
+
+func TestGetUserPicture(t *testing.T) {
+	requestCount := 0
+	pictureServerOption := newPictureServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/typed.png":
+			w.Header().Set("Content-Type", "image/webp")
+			w.Write(testPictureBytes)
+		case "/untyped.png":
+			w.Header()["Content-Type"] = nil
+			w.Write(testPictureBytes)
+		case "/huge.png":
+			w.Write(make([]byte, maxPictureBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", pictureServerOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name                string
+		pictureURL          string
+		expectedContentType string
+		expectError         bool
+		expectNoRequest     bool
+	}{
+		{name: "content type from response", pictureURL: testPictureHost + "/typed.png", expectedContentType: "image/webp"},
+		{name: "detected content type", pictureURL: testPictureHost + "/untyped.png", expectedContentType: "image/png"},
+		{name: "missing picture", pictureURL: testPictureHost + "/missing.png", expectError: true},
+		{name: "oversized picture", pictureURL: testPictureHost + "/huge.png", expectError: true},
+		{name: "empty URL", pictureURL: " ", expectError: true},
+		{name: "plain HTTP", pictureURL: "http://lh3.googleusercontent.com/typed.png", expectError: true, expectNoRequest: true},
+		{name: "other host", pictureURL: "https://attacker.example/typed.png", expectError: true, expectNoRequest: true},
+		{name: "lookalike host", pictureURL: "https://evilgoogleusercontent.com/typed.png", expectError: true, expectNoRequest: true},
+		{name: "credentials in URL", pictureURL: "https://user@lh3.googleusercontent.com/typed.png", expectError: true, expectNoRequest: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			previousRequestCount := requestCount
+			pictureBytes, contentType, err := svc.GetUserPicture(context.Background(), &oauth2.Token{AccessToken: "abc"}, testCase.pictureURL)
+			if testCase.expectNoRequest && requestCount != previousRequestCount {
+				t.Fatal("expected the picture URL to be rejected before sending the token")
+			}
+			if testCase.expectError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetUserPicture error: %v", err)
+			}
+			if !bytes.Equal(pictureBytes, testPictureBytes) || contentType != testCase.expectedContentType {
+				t.Fatalf("expected %q as %s, got %q as %s", testPictureBytes, testCase.expectedContentType, pictureBytes, contentType)
+			}
+		})
+	}
+}
+
+func TestPictureCache(t *testing.T) {
+	requestCount := 0
+	pictureServerOption := newPictureServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testPictureBytes)
+	})
+	currentTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithPictureCacheTTL(time.Hour), pictureServerOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.now = func() time.Time { return currentTime }
+
+	testSteps := []struct {
+		name         string
+		pictureURL   string
+		advance      time.Duration
+		wantRequests int
+	}{
+		{name: "first fetch", pictureURL: testPictureHost + "/a.png", wantRequests: 1},
+		{name: "cached fetch", pictureURL: testPictureHost + "/a.png", advance: 30 * time.Minute, wantRequests: 1},
+		{name: "other URL", pictureURL: testPictureHost + "/b.png", wantRequests: 2},
+		{name: "expired entry", pictureURL: testPictureHost + "/a.png", advance: 30 * time.Minute, wantRequests: 3},
+	}
+	for _, testStep := range testSteps {
+		currentTime = currentTime.Add(testStep.advance)
+		pictureBytes, contentType, err := svc.GetUserPicture(context.Background(), &oauth2.Token{AccessToken: "abc"}, testStep.pictureURL)
+		if err != nil {
+			t.Fatalf("%s: GetUserPicture error: %v", testStep.name, err)
+		}
+		if !bytes.Equal(pictureBytes, testPictureBytes) || contentType != "image/png" {
+			t.Fatalf("%s: unexpected picture %q as %s", testStep.name, pictureBytes, contentType)
+		}
+		pictureBytes[0] = 'X'
+		if requestCount != testStep.wantRequests {
+			t.Fatalf("%s: expected %d requests, got %d", testStep.name, testStep.wantRequests, requestCount)
+		}
+	}
+
+	if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithPictureCacheTTL(0)); err == nil {
+		t.Fatal("expected NewService to reject a non-positive picture cache TTL")
+	}
+}
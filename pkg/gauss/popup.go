@@ -0,0 +1,106 @@
+package gauss
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	popupMessageType       = "gauss:login"
+	popupWildcardOrigin    = "*"
+	headerCacheControl     = "Cache-Control"
+	headerPragma           = "Pragma"
+	headerContentType      = "Content-Type"
+	cacheControlNoStore    = "no-store"
+	pragmaNoCache          = "no-cache"
+	popupNonceByteLength   = 16
+	contentTypeHTMLUTF8    = "text/html; charset=utf-8"
+	popupResultTemplateRaw = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Signing in</title></head>
+<body>
+<script nonce="{{ .Nonce }}">
+if (window.opener) {
+    window.opener.postMessage({{ .Message }}, {{ .TargetOrigin }});
+}
+window.close();
+</script>
+</body>
+</html>
+`
+)
+
+// popupResultTemplate renders the page that reports the login result to the
+// window that opened the popup.
+var popupResultTemplate = template.Must(template.New("popup").Parse(popupResultTemplateRaw))
+
+// popupMessage is the payload posted to the opener window.
+type popupMessage struct {
+	Type  string `json:"type"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// WithPopupCallback returns a ServiceOption for single-page applications that
+// open the Google login in a popup. Instead of redirecting, Callback renders a
+// page that posts {type: "gauss:login", ok: true} to window.opener, or ok:
+// false with the error code on failure, and closes the popup. Messages are
+// only delivered to targetOrigin, which must be a scheme and host such as
+// "https://app.example.com"; the "*" wildcard is rejected by NewService.
+// Failures are still sent to a handler configured with WithErrorHandler.
+func WithPopupCallback(targetOrigin string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.popupTargetOrigin = strings.TrimSpace(targetOrigin)
+		serviceInstance.popupCallback = true
+	}
+}
+
+// validatePopupTargetOrigin reports an error when popup mode is enabled with a
+// target origin that is not a concrete origin.
+func (serviceInstance *Service) validatePopupTargetOrigin() error {
+	if !serviceInstance.popupCallback {
+		return nil
+	}
+	targetOrigin := serviceInstance.popupTargetOrigin
+	if targetOrigin == popupWildcardOrigin {
+		return fmt.Errorf("invalid popup target origin %q: a concrete origin is required", targetOrigin)
+	}
+	originURL, parseError := url.Parse(targetOrigin)
+	if parseError != nil || originURL.Scheme == "" || originURL.Host == "" || strings.TrimSuffix(originURL.Path, "/") != "" || originURL.RawQuery != "" || originURL.Fragment != "" {
+		return fmt.Errorf("invalid popup target origin %q: expected scheme://host[:port]", targetOrigin)
+	}
+	return nil
+}
+
+// renderPopupResult writes the popup completion page carrying message. The
+// response must never be cached because it reflects a single login attempt.
+// Its script is allowed by a nonce generated for the response, and nothing
+// else may run or load.
+func (serviceInstance *Service) renderPopupResult(responseWriter http.ResponseWriter, request *http.Request, message popupMessage) {
+	message.Type = popupMessageType
+	scriptNonce, nonceError := randomToken(popupNonceByteLength)
+	if nonceError != nil {
+		logRequestf(request, "Failed to generate popup script nonce: %v", nonceError)
+		http.Error(responseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	setFramingHeaders(responseWriter, "default-src 'none'; script-src 'nonce-"+scriptNonce+"'; frame-ancestors 'none'")
+	responseWriter.Header().Set(headerContentType, contentTypeHTMLUTF8)
+	responseWriter.Header().Set(headerCacheControl, cacheControlNoStore)
+	responseWriter.Header().Set(headerPragma, pragmaNoCache)
+	templateData := struct {
+		Message      popupMessage
+		TargetOrigin string
+		Nonce        string
+	}{
+		Message:      message,
+		TargetOrigin: strings.TrimSuffix(serviceInstance.popupTargetOrigin, "/"),
+		Nonce:        scriptNonce,
+	}
+	if executeError := popupResultTemplate.Execute(responseWriter, templateData); executeError != nil {
+		logRequestf(request, "Failed to render popup result: %v", executeError)
+	}
+}
@@ -0,0 +1,67 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+const popupTestOrigin = "https://app.example.com"
+
+func assertPopupResponse(t *testing.T, rr *httptest.ResponseRecorder, wantPayload string) {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Fatalf("expected no redirect, got %s", location)
+	}
+	if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "no-store" {
+		t.Fatalf("expected Cache-Control no-store, got %q", cacheControl)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `postMessage(`+wantPayload+`, "`+popupTestOrigin+`")`) {
+		t.Fatalf("expected payload %s for origin %s, got %s", wantPayload, popupTestOrigin, body)
+	}
+	if !strings.Contains(body, "window.close()") {
+		t.Fatal("expected the popup to close itself")
+	}
+	policy := rr.Header().Get("Content-Security-Policy")
+	nonceStart := strings.Index(policy, "'nonce-")
+	if nonceStart < 0 || !strings.Contains(policy, "frame-ancestors 'none'") {
+		t.Fatalf("expected a nonce policy that forbids framing, got %q", policy)
+	}
+	scriptNonce := strings.TrimSuffix(policy[nonceStart+len("'nonce-"):], "'; frame-ancestors 'none'")
+	if !strings.Contains(body, `<script nonce="`+scriptNonce+`">`) {
+		t.Fatalf("expected the script to carry nonce %q, got %s", scriptNonce, body)
+	}
+}
+
+func TestPopupCallbackPostsSuccess(t *testing.T) {
+	h := newTestHandlers(t, WithPopupCallback(popupTestOrigin))
+	rr := runSuccessfulCallback(t, h)
+	assertPopupResponse(t, rr, `{"type":"gauss:login","ok":true}`)
+	if sessionFromResponse(t, rr)[constants.SessionKeyUserEmail] != "e@example.com" {
+		t.Fatal("expected the session to be established")
+	}
+}
+
+func TestPopupCallbackPostsErrorCode(t *testing.T) {
+	h := newTestHandlers(t, WithPopupCallback(popupTestOrigin))
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	assertPopupResponse(t, rr, `{"type":"gauss:login","ok":false,"error":"invalid_state"}`)
+}
+
+func TestPopupCallbackRejectsInvalidOrigins(t *testing.T) {
+	for _, targetOrigin := range []string{"*", "", "app.example.com", "https://app.example.com/path"} {
+		if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithPopupCallback(targetOrigin)); err == nil {
+			t.Fatalf("expected target origin %q to be rejected", targetOrigin)
+		}
+	}
+}
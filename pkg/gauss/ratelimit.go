@@ -0,0 +1,222 @@
+package gauss
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerRetryAfter       = "Retry-After"
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimiter decides whether a client may make another request to the login,
+// callback and logout endpoints. key identifies the client, by default its IP
+// address. Implementations must be safe for concurrent use.
+type RateLimiter interface {
+	Allow(key string) bool
+}
+
+// retryingRateLimiter is implemented by limiters that can tell a rejected
+// client how long to wait, which is reported in the Retry-After header.
+type retryingRateLimiter interface {
+	allow(key string) (bool, time.Duration)
+}
+
+// RateLimitKeyFunc returns the key that identifies the client a request is
+// counted against.
+type RateLimitKeyFunc func(request *http.Request) string
+
+// WithRateLimit returns a ServiceOption that limits each client to
+// requestsPerMinute requests to the login, callback and logout endpoints,
+// allowing bursts of up to burst requests. Clients over the limit receive 429
+// Too Many Requests with a Retry-After header. Clients are keyed by ClientIP
+// unless WithRateLimitKeyFunc is used. NewService rejects non-positive values.
+func WithRateLimit(requestsPerMinute int, burst int) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.rateLimitPerMinute = requestsPerMinute
+		serviceInstance.rateLimitBurst = burst
+		serviceInstance.rateLimitEnabled = true
+	}
+}
+
+// WithRateLimiter returns a ServiceOption that rate limits the login, callback
+// and logout endpoints with limiter, answering rejected requests with 429 Too
+// Many Requests. Clients are keyed by ClientIP unless WithRateLimitKeyFunc is
+// used. NewService returns an error when WithRateLimit is also used.
+func WithRateLimiter(limiter RateLimiter) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.rateLimiter = limiter
+	}
+}
+
+// WithRateLimitKeyFunc returns a ServiceOption that replaces ClientIP as the
+// way WithRateLimit, WithRateLimiter and WithBruteForceProtection identify
+// clients, for example to read the address set by a trusted reverse proxy.
+func WithRateLimitKeyFunc(keyFunc RateLimitKeyFunc) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.rateLimitKeyFunc = keyFunc
+	}
+}
+
+// ClientIP returns the IP address of the peer that sent request. Forwarding
+// headers are ignored because clients can forge them.
+func ClientIP(request *http.Request) string {
+	host, _, splitError := net.SplitHostPort(request.RemoteAddr)
+	if splitError != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// tokenBucket tracks the tokens left for one client.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucketRateLimiter is a RateLimiter that gives every key its own token
+// bucket. Buckets that have refilled completely are indistinguishable from new
+// ones, so they are evicted periodically to keep memory bounded by the number
+// of recently active clients.
+type TokenBucketRateLimiter struct {
+	mutex           sync.Mutex
+	buckets         map[string]*tokenBucket
+	tokensPerSecond float64
+	burst           float64
+	now             func() time.Time
+	lastSweep       time.Time
+}
+
+// NewTokenBucketRateLimiter returns a TokenBucketRateLimiter that allows each
+// key rate requests per second on average and bursts of up to burst requests.
+// It returns an error if rate or burst is not positive.
+func NewTokenBucketRateLimiter(rate float64, burst int) (*TokenBucketRateLimiter, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, fmt.Errorf("invalid token bucket rate %g and burst %d: both must be positive", rate, burst)
+	}
+	return newTokenBucketRateLimiter(rate, burst, time.Now), nil
+}
+
+// newRateLimiter validates the limits set by WithRateLimit and constructs a
+// TokenBucketRateLimiter.
+func newRateLimiter(requestsPerMinute int, burst int, now func() time.Time) (*TokenBucketRateLimiter, error) {
+	if requestsPerMinute <= 0 || burst <= 0 {
+		return nil, errors.New("rate limit requests per minute and burst must be positive")
+	}
+	return newTokenBucketRateLimiter(float64(requestsPerMinute)/60, burst, now), nil
+}
+
+// newTokenBucketRateLimiter constructs a TokenBucketRateLimiter that reads the
+// time from now.
+func newTokenBucketRateLimiter(tokensPerSecond float64, burst int, now func() time.Time) *TokenBucketRateLimiter {
+	return &TokenBucketRateLimiter{
+		buckets:         make(map[string]*tokenBucket),
+		tokensPerSecond: tokensPerSecond,
+		burst:           float64(burst),
+		now:             now,
+		lastSweep:       now(),
+	}
+}
+
+// Allow consumes a token for key and reports whether one was available.
+func (limiter *TokenBucketRateLimiter) Allow(key string) bool {
+	allowed, _ := limiter.allow(key)
+	return allowed
+}
+
+// allow consumes a token for key. When none is left it reports how long the
+// client must wait for the next one.
+func (limiter *TokenBucketRateLimiter) allow(key string) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	currentTime := limiter.now()
+	limiter.sweep(currentTime)
+
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: limiter.burst, updated: currentTime}
+		limiter.buckets[key] = bucket
+	}
+	elapsedSeconds := currentTime.Sub(bucket.updated).Seconds()
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+elapsedSeconds*limiter.tokensPerSecond)
+	bucket.updated = currentTime
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	missingSeconds := (1 - bucket.tokens) / limiter.tokensPerSecond
+	return false, time.Duration(missingSeconds * float64(time.Second))
+}
+
+// sweep drops buckets that would be full by now. It runs at most once per
+// rateLimitSweepInterval.
+func (limiter *TokenBucketRateLimiter) sweep(currentTime time.Time) {
+	if currentTime.Sub(limiter.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	limiter.lastSweep = currentTime
+	refillDuration := time.Duration(limiter.burst / limiter.tokensPerSecond * float64(time.Second))
+	for key, bucket := range limiter.buckets {
+		if currentTime.Sub(bucket.updated) >= refillDuration {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// rateLimited wraps handler with the limiter configured by WithRateLimit or
+// WithRateLimiter. It returns handler unchanged when rate limiting is
+// disabled.
+func (serviceInstance *Service) rateLimited(handler http.HandlerFunc) http.HandlerFunc {
+	limiter := serviceInstance.rateLimiter
+	if limiter == nil {
+		return handler
+	}
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if rejectRateLimited(responseWriter, limiter, serviceInstance.clientKey(request)) {
+			return
+		}
+		handler(responseWriter, request)
+	}
+}
+
+// NewRateLimitMiddleware returns middleware that limits each client, keyed by
+// ClientIP, with limiter and answers rejected requests with 429 Too Many
+// Requests. It protects application routes the way WithRateLimiter protects
+// the GAuss endpoints.
+func NewRateLimitMiddleware(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if rejectRateLimited(responseWriter, limiter, ClientIP(request)) {
+				return
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// rejectRateLimited answers with 429 Too Many Requests, including a
+// Retry-After header when limiter can report one, and reports true when
+// limiter rejects clientKey.
+func rejectRateLimited(responseWriter http.ResponseWriter, limiter RateLimiter, clientKey string) bool {
+	if retryingLimiter, retrying := limiter.(retryingRateLimiter); retrying {
+		allowed, retryAfter := retryingLimiter.allow(clientKey)
+		if allowed {
+			return false
+		}
+		retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+		responseWriter.Header().Set(headerRetryAfter, strconv.Itoa(retryAfterSeconds))
+	} else if limiter.Allow(clientKey) {
+		return false
+	}
+	http.Error(responseWriter, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	return true
+}
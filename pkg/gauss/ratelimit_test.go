@@ -0,0 +1,159 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestRateLimitRejectsAndRecovers(t *testing.T) {
+	h := newTestHandlers(t, WithRateLimit(6, 2))
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.service.rateLimiter.(*TokenBucketRateLimiter).now = func() time.Time { return currentTime }
+	server := h.RegisterRoutes(http.NewServeMux())
+
+	sendRequest := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if rr := sendRequest("192.0.2.1:1234"); rr.Code != http.StatusFound {
+			t.Fatalf("attempt %d: expected 302, got %d", attempt, rr.Code)
+		}
+	}
+	limited := sendRequest("192.0.2.1:1234")
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", limited.Code)
+	}
+	if retryAfter := limited.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Fatalf("expected Retry-After 10, got %q", retryAfter)
+	}
+	if rr := sendRequest("192.0.2.2:1234"); rr.Code != http.StatusFound {
+		t.Fatalf("expected another client to be unaffected, got %d", rr.Code)
+	}
+
+	currentTime = currentTime.Add(10 * time.Second)
+	if rr := sendRequest("192.0.2.1:5678"); rr.Code != http.StatusFound {
+		t.Fatalf("expected recovery after the window, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitAppliesToCallbackAndLogout(t *testing.T) {
+	h := newTestHandlers(t, WithRateLimit(1, 1), WithRateLimitKeyFunc(func(request *http.Request) string { return "shared" }))
+	for _, handler := range []http.Handler{h.CallbackHandler(), h.LogoutHandler()} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	rr := httptest.NewRecorder()
+	h.LoginHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the shared key to be limited, got %d", rr.Code)
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter, err := newRateLimiter(60, 5, func() time.Time { return currentTime })
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.allow("idle")
+	currentTime = currentTime.Add(2 * time.Minute)
+	limiter.allow("active")
+	if _, present := limiter.buckets["idle"]; present {
+		t.Fatal("expected the idle bucket to be evicted")
+	}
+	if _, present := limiter.buckets["active"]; !present {
+		t.Fatal("expected the active bucket to remain")
+	}
+}
+
+func TestWithRateLimitRejectsInvalidLimits(t *testing.T) {
+	if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithRateLimit(0, 1)); err == nil {
+		t.Fatal("expected an error for a zero rate")
+	}
+}
+
+// denyListRateLimiter is a RateLimiter that rejects the listed keys.
+type denyListRateLimiter map[string]bool
+
+func (limiter denyListRateLimiter) Allow(key string) bool {
+	return !limiter[key]
+}
+
+func TestWithRateLimiterUsesCustomLimiter(t *testing.T) {
+	h := newTestHandlers(t, WithRateLimiter(denyListRateLimiter{"192.0.2.9": true}))
+	testCases := []struct {
+		name       string
+		handler    http.Handler
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "login allowed", handler: h.LoginHandler(), remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusFound},
+		{name: "login denied", handler: h.LoginHandler(), remoteAddr: "192.0.2.9:1234", wantStatus: http.StatusTooManyRequests},
+		{name: "callback denied", handler: h.CallbackHandler(), remoteAddr: "192.0.2.9:1234", wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = testCase.remoteAddr
+			rr := httptest.NewRecorder()
+			testCase.handler.ServeHTTP(rr, req)
+			if rr.Code != testCase.wantStatus {
+				t.Fatalf("expected %d, got %d", testCase.wantStatus, rr.Code)
+			}
+			if rr.Header().Get("Retry-After") != "" {
+				t.Fatal("expected no Retry-After header from a limiter that cannot compute it")
+			}
+		})
+	}
+}
+
+func TestTokenBucketRateLimiterRejectsAfterBurst(t *testing.T) {
+	limiter, err := NewTokenBucketRateLimiter(0.5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, WithRateLimiter(limiter))
+	for attempt := 0; attempt < 3; attempt++ {
+		rr := httptest.NewRecorder()
+		h.LoginHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+		if rr.Code != http.StatusFound {
+			t.Fatalf("attempt %d: expected 302, got %d", attempt, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	h.CallbackHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.CallbackPath, nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the burst, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestNewTokenBucketRateLimiterRejectsInvalidLimits(t *testing.T) {
+	for _, limits := range []struct {
+		rate  float64
+		burst int
+	}{{rate: 0, burst: 1}, {rate: 1, burst: 0}, {rate: -1, burst: -1}} {
+		if limiter, err := NewTokenBucketRateLimiter(limits.rate, limits.burst); err == nil || limiter != nil {
+			t.Fatalf("expected rate %g and burst %d to be rejected", limits.rate, limits.burst)
+		}
+	}
+}
+
+func TestRateLimitOptionsAreMutuallyExclusive(t *testing.T) {
+	limiter, _ := NewTokenBucketRateLimiter(1, 1)
+	_, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithRateLimit(6, 2), WithRateLimiter(limiter))
+	if err == nil {
+		t.Fatal("expected an error when both rate limit options are used")
+	}
+}
@@ -0,0 +1,87 @@
+package gauss
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// queryParameterRemember is set to rememberMeValue on the Google auth path
+	// by the login page's "keep me signed in" checkbox.
+	queryParameterRemember = "remember"
+	rememberMeValue        = "1"
+	sessionKeyRememberMe   = "oauth_remember_me"
+	sessionKeyCookieMaxAge = "cookie_max_age"
+)
+
+// WithRememberMeDuration returns a ServiceOption that adds a "keep me signed
+// in" checkbox to the login page. Sessions of users who tick it last for
+// duration; the others use a session cookie that expires when the browser
+// closes. The session store's MaxAge still bounds how long a cookie is
+// accepted, so configure it to be at least duration. NewService rejects a
+// duration shorter than one second.
+func WithRememberMeDuration(duration time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.rememberMeDuration = duration
+		serviceInstance.rememberMeEnabled = true
+	}
+}
+
+// rememberLoginChoice stores in webSession whether request asked to be
+// remembered. Like rememberReturnTo it keeps the choice of the original login
+// during the consent retry made from the callback.
+func (serviceInstance *Service) rememberLoginChoice(webSession *sessions.Session, request *http.Request) {
+	if !serviceInstance.rememberMeEnabled {
+		return
+	}
+	if request.URL.Query().Get(queryParameterRemember) == rememberMeValue {
+		webSession.Values[sessionKeyRememberMe] = true
+		return
+	}
+	if _, consentRetry := webSession.Values[sessionKeyConsentRetry].(bool); !consentRetry {
+		delete(webSession.Values, sessionKeyRememberMe)
+	}
+}
+
+// applySessionLifetime records in a freshly regenerated webSession the cookie
+// lifetime chosen with the remember-me checkbox, which saveSession applies on
+// every later save.
+func (serviceInstance *Service) applySessionLifetime(webSession *sessions.Session, rememberMe bool) {
+	if !serviceInstance.rememberMeEnabled {
+		return
+	}
+	cookieMaxAge := 0
+	if rememberMe {
+		cookieMaxAge = int(serviceInstance.rememberMeDuration / time.Second)
+	}
+	webSession.Values[sessionKeyCookieMaxAge] = cookieMaxAge
+}
+
+// allowCrossSiteCallback issues the cookie of webSession with SameSite=None
+// and Secure when WithFormPostResponseMode is configured, so the browser
+// sends it with the form POST that Google makes to the callback from another
+// site. Other response modes keep the options of the store.
+func (serviceInstance *Service) allowCrossSiteCallback(webSession *sessions.Session) {
+	if serviceInstance.responseMode != responseModeFormPost {
+		return
+	}
+	sessionOptions := *webSession.Options
+	sessionOptions.SameSite = http.SameSiteNoneMode
+	sessionOptions.Secure = true
+	webSession.Options = &sessionOptions
+}
+
+// saveSession saves webSession with the cookie lifetime chosen at login,
+// which the store does not remember because it creates sessions with its
+// default options. Every save of a session in GAuss goes through it, so no
+// save silently falls back to the store's MaxAge.
+func saveSession(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) error {
+	if cookieMaxAge, found := webSession.Values[sessionKeyCookieMaxAge].(int); found {
+		sessionOptions := *webSession.Options
+		sessionOptions.MaxAge = cookieMaxAge
+		webSession.Options = &sessionOptions
+	}
+	return webSession.Save(request, responseWriter)
+}
@@ -0,0 +1,111 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// sessionCookie returns the session cookie set by rr.
+func sessionCookie(t *testing.T, rr *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range lastCookies(rr) {
+		if cookie.Name == session.Name() {
+			return cookie
+		}
+	}
+	t.Fatal("expected a session cookie")
+	return nil
+}
+
+func TestRememberMeControlsSessionLifetime(t *testing.T) {
+	testCases := []struct {
+		name             string
+		authStartTarget  string
+		expectedMaxAge   int
+		expectPersistent bool
+	}{
+		{name: "remembered", authStartTarget: constants.GoogleAuthPath + "?remember=1", expectedMaxAge: 30 * 24 * 60 * 60, expectPersistent: true},
+		{name: "not remembered", authStartTarget: constants.GoogleAuthPath},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithRememberMeDuration(30*24*time.Hour))
+			useMockGoogleUser(t, h)
+
+			authStartRecorder := httptest.NewRecorder()
+			h.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, testCase.authStartTarget, nil))
+			googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+
+			callbackRecorder := httptest.NewRecorder()
+			h.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder)))
+			if callbackRecorder.Code != http.StatusFound {
+				t.Fatalf("expected a successful login, got %d", callbackRecorder.Code)
+			}
+
+			cookie := sessionCookie(t, callbackRecorder)
+			if cookie.MaxAge != testCase.expectedMaxAge {
+				t.Fatalf("expected Max-Age %d, got %d", testCase.expectedMaxAge, cookie.MaxAge)
+			}
+			if persistent := !cookie.Expires.IsZero(); persistent != testCase.expectPersistent {
+				t.Fatalf("expected persistent=%v, got Expires %v", testCase.expectPersistent, cookie.Expires)
+			}
+			if !sessionIsActive(h, callbackRecorder) {
+				t.Fatal("expected the session to be usable")
+			}
+
+			laterSaveRecorder := httptest.NewRecorder()
+			h.Login(laterSaveRecorder, requestWithCookies(testCase.authStartTarget, lastCookies(callbackRecorder)))
+			if laterCookie := sessionCookie(t, laterSaveRecorder); laterCookie.MaxAge != testCase.expectedMaxAge {
+				t.Fatalf("expected a later save to keep Max-Age %d, got %d", testCase.expectedMaxAge, laterCookie.MaxAge)
+			}
+		})
+	}
+}
+
+func TestRememberMeLeavesDefaultLifetimeWhenDisabled(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	if cookie := sessionCookie(t, loginAsUser(t, h)); cookie.MaxAge != session.Store().Options.MaxAge {
+		t.Fatalf("expected the store's Max-Age %d, got %d", session.Store().Options.MaxAge, cookie.MaxAge)
+	}
+}
+
+func TestLoginPageRendersRememberMeCheckbox(t *testing.T) {
+	testCases := []struct {
+		name           string
+		options        []ServiceOption
+		expectCheckbox bool
+	}{
+		{name: "enabled", options: []ServiceOption{WithRememberMeDuration(time.Hour)}, expectCheckbox: true},
+		{name: "disabled"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			rr := httptest.NewRecorder()
+			h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath+"?next=%2Freports", nil))
+			body := rr.Body.String()
+			if hasCheckbox := strings.Contains(body, `name="remember" value="1"`); hasCheckbox != testCase.expectCheckbox {
+				t.Fatalf("expected checkbox=%v in %s", testCase.expectCheckbox, body)
+			}
+			if testCase.expectCheckbox && !strings.Contains(body, `name="next" value="/reports"`) {
+				t.Fatalf("expected the return path in the form, got %s", body)
+			}
+		})
+	}
+}
+
+func TestWithRememberMeDurationRejectsShortDurations(t *testing.T) {
+	for _, duration := range []time.Duration{0, -time.Hour, time.Millisecond} {
+		if _, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithRememberMeDuration(duration)); err == nil {
+			t.Errorf("expected duration %s to be rejected", duration)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package gauss
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+const (
+	headerRequestID        = "X-Request-ID"
+	requestIDByteLength    = 16
+	maximumRequestIDLength = 128
+	logFieldRequestID      = "request_id="
+)
+
+// requestIDContextKey stores the request ID in a request context.
+type requestIDContextKey struct{}
+
+// RequestIDFunc extracts a request ID assigned upstream, for example by a
+// load balancer or tracing middleware. It returns an empty string when the
+// request carries none.
+type RequestIDFunc func(request *http.Request) string
+
+// WithRequestIDFunc returns a ServiceOption that replaces the X-Request-ID
+// header as the source of request IDs. Login, Callback and Logout prefix
+// every log line with request_id=<id>, generate an ID when none is found and
+// echo it in the X-Request-ID response header so failures can be matched to
+// access logs.
+func WithRequestIDFunc(requestIDFunc RequestIDFunc) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.requestIDFunc = requestIDFunc
+	}
+}
+
+// RequestIDFromContext returns the request ID GAuss assigned to the request
+// whose context is ctx, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// withRequestID ensures request carries a request ID in its context, echoes
+// it in the response headers and returns the updated request. An ID already
+// present in the context is reused so nested handlers share it.
+func (serviceInstance *Service) withRequestID(responseWriter http.ResponseWriter, request *http.Request) *http.Request {
+	requestID := RequestIDFromContext(request.Context())
+	if requestID == "" {
+		requestID = serviceInstance.incomingRequestID(request)
+	}
+	if requestID == "" {
+		randomBytes := make([]byte, requestIDByteLength)
+		if _, readError := rand.Read(randomBytes); readError != nil {
+			log.Printf("Failed to generate request ID: %v", readError)
+			return request
+		}
+		requestID = hex.EncodeToString(randomBytes)
+	}
+	responseWriter.Header().Set(headerRequestID, requestID)
+	return request.WithContext(context.WithValue(request.Context(), requestIDContextKey{}, requestID))
+}
+
+// incomingRequestID returns the upstream request ID, discarding values that
+// are too long or contain characters that could forge log lines.
+func (serviceInstance *Service) incomingRequestID(request *http.Request) string {
+	var requestID string
+	if serviceInstance.requestIDFunc != nil {
+		requestID = serviceInstance.requestIDFunc(request)
+	} else {
+		requestID = request.Header.Get(headerRequestID)
+	}
+	if len(requestID) > maximumRequestIDLength {
+		return ""
+	}
+	for _, character := range requestID {
+		if character <= ' ' || character > '~' {
+			return ""
+		}
+	}
+	return requestID
+}
+
+// logRequestf logs a message prefixed with the request ID of request, when it
+// has one. The ID is passed as an argument rather than joined into format, so
+// a client-supplied ID containing verbs cannot garble the message.
+func logRequestf(request *http.Request, format string, arguments ...interface{}) {
+	if requestID := RequestIDFromContext(request.Context()); requestID != "" {
+		log.Printf("%s%s "+format, append([]interface{}{logFieldRequestID, requestID}, arguments...)...)
+		return
+	}
+	log.Printf(format, arguments...)
+}
@@ -0,0 +1,97 @@
+package gauss
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// captureLog redirects the standard logger into a buffer for the duration of
+// the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logBuffer bytes.Buffer
+	originalWriter := log.Writer()
+	log.SetOutput(&logBuffer)
+	t.Cleanup(func() { log.SetOutput(originalWriter) })
+	return &logBuffer
+}
+
+func TestCallbackLogsIncomingRequestID(t *testing.T) {
+	logBuffer := captureLog(t)
+	h := newTestHandlers(t)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	if echoed := rr.Header().Get("X-Request-ID"); echoed != "req-123" {
+		t.Fatalf("expected the request ID to be echoed, got %q", echoed)
+	}
+	if !strings.Contains(logBuffer.String(), "request_id=req-123 State mismatch") {
+		t.Fatalf("expected the failure log to carry the request ID, got %q", logBuffer.String())
+	}
+}
+
+func TestRequestIDWithFormatVerbsIsLoggedVerbatim(t *testing.T) {
+	logBuffer := captureLog(t)
+	h := newTestHandlers(t)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+	req.Header.Set("X-Request-ID", "%s%s%v")
+	seedState(t, req, "s123")
+	h.Callback(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logBuffer.String(), "request_id=%s%s%v State mismatch: stored s123 vs received other") {
+		t.Fatalf("expected the request ID and message to be logged intact, got %q", logBuffer.String())
+	}
+}
+
+func TestRequestIDGeneratedWhenAbsentOrUnsafe(t *testing.T) {
+	for _, incomingID := range []string{"", "forged\nrequest_id=other"} {
+		logBuffer := captureLog(t)
+		h := newTestHandlers(t)
+		req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=other&code=c1", nil)
+		if incomingID != "" {
+			req.Header.Set("X-Request-ID", incomingID)
+		}
+		seedState(t, req, "s123")
+		rr := httptest.NewRecorder()
+		h.Callback(rr, req)
+
+		generatedID := rr.Header().Get("X-Request-ID")
+		if len(generatedID) != 2*requestIDByteLength {
+			t.Fatalf("expected a generated request ID, got %q", generatedID)
+		}
+		if !strings.Contains(logBuffer.String(), "request_id="+generatedID+" ") {
+			t.Fatalf("expected the generated ID in the log, got %q", logBuffer.String())
+		}
+	}
+}
+
+func TestWithRequestIDFuncExtractsID(t *testing.T) {
+	logBuffer := captureLog(t)
+	var handlerRequestID string
+	h := newTestHandlers(t,
+		WithRequestIDFunc(func(request *http.Request) string { return request.Header.Get("X-Correlation-ID") }),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, authError *AuthError) {
+			handlerRequestID = RequestIDFromContext(r.Context())
+		}),
+	)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	req.Header.Set("X-Correlation-ID", "corr-7")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	if handlerRequestID != "corr-7" || rr.Header().Get("X-Request-ID") != "corr-7" {
+		t.Fatalf("expected corr-7, got %q and %q", handlerRequestID, rr.Header().Get("X-Request-ID"))
+	}
+	if !strings.Contains(logBuffer.String(), "request_id=corr-7 ") {
+		t.Fatalf("expected the extracted ID in the log, got %q", logBuffer.String())
+	}
+}
@@ -0,0 +1,132 @@
+package gauss
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/session/cookie"
+)
+
+const (
+	// queryParameterNext carries the page to return to after login on the
+	// login page and the Google auth path.
+	queryParameterNext = "next"
+	sessionKeyReturnTo = "oauth_return_to"
+)
+
+// WithAllowedReturnHosts returns a ServiceOption that lets the next parameter
+// name absolute http and https URLs on hosts, such as "app.example.com" or
+// "app.example.com:8443", in addition to local paths. Host names are compared
+// case-insensitively; a host without a port matches any port.
+func WithAllowedReturnHosts(hosts ...string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.allowedReturnHosts = append(serviceInstance.allowedReturnHosts, hosts...)
+	}
+}
+
+// validReturnTo reports whether rawTarget is safe to redirect to after login
+// and returns it. It accepts what cookie.ValidReturnURL accepts with the hosts
+// allowed by WithAllowedReturnHosts.
+func (serviceInstance *Service) validReturnTo(rawTarget string) (string, bool) {
+	if !cookie.ValidReturnURL(rawTarget, serviceInstance.allowedReturnHosts...) {
+		return "", false
+	}
+	return rawTarget, true
+}
+
+// requestedReturnTo returns the return-to URL in the next query parameter of
+// request, or an empty string when it is absent or unsafe. Unsafe values are
+// logged.
+func (serviceInstance *Service) requestedReturnTo(request *http.Request) string {
+	rawTarget := request.URL.Query().Get(queryParameterNext)
+	if rawTarget == "" {
+		return ""
+	}
+	returnTo, valid := serviceInstance.validReturnTo(rawTarget)
+	if !valid {
+		logRequestf(request, "Ignoring unsafe return-to URL %q", rawTarget)
+	}
+	return returnTo
+}
+
+// withReturnTo appends returnTo to targetPath as the next query parameter,
+// keeping any query targetPath already has.
+func withReturnTo(targetPath string, returnTo string) string {
+	if returnTo == "" {
+		return targetPath
+	}
+	querySeparator := "?"
+	if strings.Contains(targetPath, "?") {
+		querySeparator = "&"
+	}
+	return targetPath + querySeparator + url.Values{queryParameterNext: {returnTo}}.Encode()
+}
+
+// rememberReturnTo stores the return-to URL requested by request in
+// webSession, falling back to the URL recorded by rememberRequestedURL or
+// cookie.SetReturnURL, which is consumed either way. Without one any stale
+// URL from an abandoned login is removed, unless the request is the consent
+// retry made from the callback, which keeps the URL stored by the original
+// login.
+func (serviceInstance *Service) rememberReturnTo(webSession *sessions.Session, request *http.Request) {
+	requestedURL := cookie.ReturnURL(webSession)
+	cookie.PutReturnURL(webSession, "")
+	if returnTo := serviceInstance.requestedReturnTo(request); returnTo != "" {
+		webSession.Values[sessionKeyReturnTo] = returnTo
+		return
+	}
+	if requestedURL != "" {
+		webSession.Values[sessionKeyReturnTo] = requestedURL
+		return
+	}
+	if _, consentRetry := webSession.Values[sessionKeyConsentRetry].(bool); !consentRetry {
+		delete(webSession.Values, sessionKeyReturnTo)
+	}
+}
+
+// rememberRequestedURL stores returnTo, the validated URL of a GET or HEAD
+// request turned away by NewAuthMiddleware, in the session, so Login returns
+// to it even when the login page links to the Google auth path without the
+// next parameter. The callback validates it again before redirecting.
+func (serviceInstance *Service) rememberRequestedURL(responseWriter http.ResponseWriter, request *http.Request, returnTo string) {
+	if returnTo == "" || (request.Method != http.MethodGet && request.Method != http.MethodHead) {
+		return
+	}
+	webSession := serviceInstance.webSession(request)
+	if putError := cookie.PutReturnURL(webSession, returnTo, serviceInstance.allowedReturnHosts...); putError != nil {
+		logRequestf(request, "Failed to remember the requested URL: %v", putError)
+		return
+	}
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to remember the requested URL: %v", sessionSaveError)
+	}
+}
+
+// loginRedirectTarget returns the URL to send the client to after a
+// successful login: the return-to URL stored in webSession, validated again,
+// or the configured post-login URL.
+func (serviceInstance *Service) loginRedirectTarget(request *http.Request, webSession *sessions.Session) string {
+	storedReturnTo, _ := webSession.Values[sessionKeyReturnTo].(string)
+	if storedReturnTo == "" {
+		return serviceInstance.localRedirectURL
+	}
+	if returnTo, valid := serviceInstance.validReturnTo(storedReturnTo); valid {
+		return returnTo
+	}
+	logRequestf(request, "Ignoring unsafe stored return-to URL %q", storedReturnTo)
+	return serviceInstance.localRedirectURL
+}
+
+// LoginURL returns the URL to send the unauthenticated request to: the login
+// path with the URL of request in the next query parameter, so the user
+// returns to it after logging in. Requests other than GET and HEAD cannot be
+// repeated by a redirect and get the bare login path.
+func (serviceInstance *Service) LoginURL(request *http.Request) string {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return serviceInstance.mountedURL(request, serviceInstance.loginPath)
+	}
+	returnTo, _ := serviceInstance.validReturnTo(originalRequestURI(request))
+	return withReturnTo(serviceInstance.mountedURL(request, serviceInstance.loginPath), returnTo)
+}
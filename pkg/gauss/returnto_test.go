@@ -0,0 +1,227 @@
+package gauss
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session/cookie"
+)
+
+func TestDeepLinkRoundTripsThroughGoogle(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	deepLinkRecorder := httptest.NewRecorder()
+	protected.ServeHTTP(deepLinkRecorder, httptest.NewRequest(http.MethodGet, "/reports/42?week=12", nil))
+	loginURL := deepLinkRecorder.Header().Get("Location")
+	if loginURL != constants.LoginPath+"?next=%2Freports%2F42%3Fweek%3D12" {
+		t.Fatalf("expected a login redirect carrying the deep link, got %q", loginURL)
+	}
+
+	loginPageRecorder := httptest.NewRecorder()
+	h.loginHandler(loginPageRecorder, httptest.NewRequest(http.MethodGet, loginURL, nil))
+	authStartURL := constants.GoogleAuthPath + "?next=%2Freports%2F42%3Fweek%3D12"
+	if body := html.UnescapeString(loginPageRecorder.Body.String()); !strings.Contains(body, `href="`+authStartURL+`"`) {
+		t.Fatalf("expected the login button to link to %s, got %s", authStartURL, body)
+	}
+
+	authStartRecorder := httptest.NewRecorder()
+	h.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, authStartURL, nil))
+	googleURL, err := url.Parse(authStartRecorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+
+	callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+	callbackRecorder := httptest.NewRecorder()
+	h.Callback(callbackRecorder, callbackRequest)
+	if location := callbackRecorder.Header().Get("Location"); location != "/reports/42?week=12" {
+		t.Fatalf("expected to land back on the deep link, got %q", location)
+	}
+	if values := sessionFromResponse(t, callbackRecorder); values[sessionKeyReturnTo] != nil {
+		t.Fatalf("expected the return path to be cleared from the session, got %v", values[sessionKeyReturnTo])
+	}
+}
+
+func TestDeepLinkSurvivesLoginLinkWithoutNext(t *testing.T) {
+	testCases := []struct {
+		name             string
+		method           string
+		expectedLocation string
+	}{
+		{name: "GET is remembered", method: http.MethodGet, expectedLocation: "/reports/42?week=12"},
+		{name: "POST is not remembered", method: http.MethodPost, expectedLocation: "/dashboard"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			useMockGoogleUser(t, h)
+			protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			deepLinkRecorder := httptest.NewRecorder()
+			protected.ServeHTTP(deepLinkRecorder, httptest.NewRequest(testCase.method, "/reports/42?week=12", nil))
+			if deepLinkRecorder.Code != http.StatusFound {
+				t.Fatalf("expected a login redirect, got %d", deepLinkRecorder.Code)
+			}
+
+			authStartRecorder := httptest.NewRecorder()
+			h.Login(authStartRecorder, requestWithCookies(constants.GoogleAuthPath, lastCookies(deepLinkRecorder)))
+			googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+			if values := sessionFromResponse(t, authStartRecorder); values[constants.SessionKeyReturnURL] != nil {
+				t.Fatalf("expected Login to consume the requested URL, got %v", values[constants.SessionKeyReturnURL])
+			}
+
+			callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+			callbackRecorder := httptest.NewRecorder()
+			h.Callback(callbackRecorder, callbackRequest)
+			if location := callbackRecorder.Header().Get("Location"); location != testCase.expectedLocation {
+				t.Fatalf("expected to land on %q, got %q", testCase.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestCookieSetReturnURLIsHonouredByLogin(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+
+	appRecorder := httptest.NewRecorder()
+	if err := cookie.SetReturnURL(appRecorder, httptest.NewRequest(http.MethodGet, "/reports", nil), "/reports/42"); err != nil {
+		t.Fatalf("SetReturnURL: %v", err)
+	}
+
+	authStartRecorder := httptest.NewRecorder()
+	h.Login(authStartRecorder, requestWithCookies(constants.GoogleAuthPath, lastCookies(appRecorder)))
+	googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+
+	callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+	callbackRecorder := httptest.NewRecorder()
+	h.Callback(callbackRecorder, callbackRequest)
+	if location := callbackRecorder.Header().Get("Location"); location != "/reports/42" {
+		t.Fatalf("expected to land on the URL set by cookie.SetReturnURL, got %q", location)
+	}
+}
+
+func TestRememberedDeepLinkKeepsMountPrefix(t *testing.T) {
+	h := newTestHandlers(t)
+	protected := NewAuthMiddleware(h.service)(okTestHandler())
+	deepLinkRequest := httptest.NewRequest(http.MethodGet, "/reports/42", nil)
+	deepLinkRequest = deepLinkRequest.WithContext(context.WithValue(deepLinkRequest.Context(), mountPrefixContextKey{}, "/app"))
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, deepLinkRequest)
+	if remembered := sessionFromResponse(t, rr)[constants.SessionKeyReturnURL]; remembered != "/app/reports/42" {
+		t.Fatalf("expected the remembered URL to keep the mount prefix, got %v", remembered)
+	}
+}
+
+func TestLoginIgnoresUnsafeReturnTo(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	for _, returnTo := range []string{"https://evil.example/", "//evil.example/", "/\\evil.example", "reports", "/a\nb"} {
+		t.Run(returnTo, func(t *testing.T) {
+			authStartRecorder := httptest.NewRecorder()
+			h.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath+"?next="+url.QueryEscape(returnTo), nil))
+			googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+
+			callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+			callbackRecorder := httptest.NewRecorder()
+			h.Callback(callbackRecorder, callbackRequest)
+			if location := callbackRecorder.Header().Get("Location"); location != "/dashboard" {
+				t.Fatalf("expected the configured redirect, got %q", location)
+			}
+		})
+	}
+}
+
+func TestLoginClearsStaleReturnTo(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+
+	abandonedRecorder := httptest.NewRecorder()
+	h.Login(abandonedRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath+"?next=%2Freports", nil))
+
+	authStartRecorder := httptest.NewRecorder()
+	h.Login(authStartRecorder, requestWithCookies(constants.GoogleAuthPath, lastCookies(abandonedRecorder)))
+	googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+
+	callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+	callbackRecorder := httptest.NewRecorder()
+	h.Callback(callbackRecorder, callbackRequest)
+	if location := callbackRecorder.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected the configured redirect, got %q", location)
+	}
+}
+
+func TestValidReturnTo(t *testing.T) {
+	h := newTestHandlers(t, WithAllowedReturnHosts("App.Example.com", "admin.example.com:8443"))
+	testCases := []struct {
+		name      string
+		target    string
+		wantValid bool
+	}{
+		{name: "rooted path", target: "/reports/42?week=12#top", wantValid: true},
+		{name: "root", target: "/", wantValid: true},
+		{name: "encoded query slashes", target: "/search?q=%2F%2Fevil.com", wantValid: true},
+		{name: "allowed host", target: "https://app.example.com/reports", wantValid: true},
+		{name: "allowed host any port", target: "http://APP.example.com:8080/", wantValid: true},
+		{name: "allowed host and port", target: "https://admin.example.com:8443/", wantValid: true},
+		{name: "empty", target: ""},
+		{name: "relative path", target: "reports"},
+		{name: "absolute url", target: "https://evil.com/"},
+		{name: "protocol relative", target: "//evil.com"},
+		{name: "protocol relative with path", target: "//evil.com/reports"},
+		{name: "leading backslash", target: "/\\evil.com"},
+		{name: "double backslash", target: "\\\\evil.com"},
+		{name: "backslash scheme", target: "https:\\\\evil.com"},
+		{name: "encoded slashes", target: "/%2F%2Fevil.com"},
+		{name: "encoded backslash", target: "/%5Cevil.com"},
+		{name: "mixed encoded slash", target: "/%2fevil.com"},
+		{name: "tab", target: "/\t/evil.com"},
+		{name: "newline", target: "/\n/evil.com"},
+		{name: "userinfo on evil host", target: "https://app.example.com@evil.com/"},
+		{name: "userinfo on allowed host", target: "https://evil.com@app.example.com/"},
+		{name: "allowed host wrong port", target: "https://admin.example.com:9000/"},
+		{name: "subdomain of allowed host", target: "https://app.example.com.evil.com/"},
+		{name: "javascript", target: "javascript:alert(1)"},
+		{name: "data", target: "data:text/html,hi"},
+		{name: "missing host", target: "https:/evil.com"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			returnTo, valid := h.service.validReturnTo(testCase.target)
+			if valid != testCase.wantValid {
+				t.Fatalf("expected valid=%v for %q", testCase.wantValid, testCase.target)
+			}
+			if valid && returnTo != testCase.target {
+				t.Fatalf("expected %q to be returned unchanged, got %q", testCase.target, returnTo)
+			}
+		})
+	}
+}
+
+func TestAllowedReturnHostRoundTrip(t *testing.T) {
+	h := newTestHandlers(t, WithAllowedReturnHosts("app.example.com"))
+	useMockGoogleUser(t, h)
+
+	authStartRecorder := httptest.NewRecorder()
+	h.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath+"?next="+url.QueryEscape("https://app.example.com/reports"), nil))
+	googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+
+	callbackRequest := requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder))
+	callbackRecorder := httptest.NewRecorder()
+	h.Callback(callbackRecorder, callbackRequest)
+	if location := callbackRecorder.Header().Get("Location"); location != "https://app.example.com/reports" {
+		t.Fatalf("expected the allowed absolute URL, got %q", location)
+	}
+}
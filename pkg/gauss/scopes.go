@@ -1,9 +1,21 @@
 package gauss
 
+import (
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenFieldScope is the token response field that lists the granted scopes.
+const tokenFieldScope = "scope"
+
 // Scope represents a Google OAuth2 scope string.
 type Scope string
 
 const (
+	// ScopeOpenID asks Google for an OpenID Connect ID token identifying the
+	// user.
+	ScopeOpenID Scope = "openid"
 	// ScopeEmail allows retrieving the user's email address.
 	ScopeEmail Scope = "email"
 	// ScopeProfile allows retrieving basic profile information.
@@ -14,6 +26,30 @@ const (
 	ScopeYouTube Scope = "https://www.googleapis.com/auth/youtube" // manage account (needed)
 	// ScopeYouTubeUpload allows video upload to YouTube resources.
 	ScopeYouTubeUpload Scope = "https://www.googleapis.com/auth/youtube.upload"
+	// ScopeDrive allows full access to the user's Google Drive files.
+	ScopeDrive Scope = "https://www.googleapis.com/auth/drive"
+	// ScopeAdminDirectoryUser allows managing the users of a Google Workspace
+	// domain through the Admin SDK. Service.IsWorkspaceAdmin needs it.
+	ScopeAdminDirectoryUser Scope = "https://www.googleapis.com/auth/admin.directory.user"
+	// ScopeAdminDirectoryGroup allows managing the groups of a Google
+	// Workspace domain through the Admin SDK.
+	ScopeAdminDirectoryGroup Scope = "https://www.googleapis.com/auth/admin.directory.group"
+	// ScopeAdminDirectoryOrgUnit allows managing the organizational units of a
+	// Google Workspace domain through the Admin SDK.
+	ScopeAdminDirectoryOrgUnit Scope = "https://www.googleapis.com/auth/admin.directory.orgunit"
+	// ScopeAdminReports allows reading the audit reports of a Google Workspace
+	// domain through the Admin SDK.
+	ScopeAdminReports Scope = "https://www.googleapis.com/auth/admin.reports.audit.readonly"
+	// ScopeCalendar allows full access to the user's Google Calendars.
+	ScopeCalendar Scope = "https://www.googleapis.com/auth/calendar"
+	// ScopeCalendarReadonly allows read-only access to the user's Google
+	// Calendars. Service.GetCalendarList needs it or ScopeCalendar.
+	ScopeCalendarReadonly Scope = "https://www.googleapis.com/auth/calendar.readonly"
+	// ScopeCalendarEvents allows managing the events on the user's calendars.
+	ScopeCalendarEvents Scope = "https://www.googleapis.com/auth/calendar.events"
+	// ScopeCalendarEventsReadonly allows read-only access to the events on the
+	// user's calendars.
+	ScopeCalendarEventsReadonly Scope = "https://www.googleapis.com/auth/calendar.events.readonly"
 )
 
 // DefaultScopes lists the scopes used when none are provided to NewService.
@@ -27,3 +63,167 @@ func ScopeStrings(scopes []Scope) []string {
 	}
 	return out
 }
+
+// knownScopes lists the Scope constants recognized by ParseScopes.
+var knownScopes = map[Scope]struct{}{
+	ScopeOpenID:          {},
+	ScopeEmail:           {},
+	ScopeProfile:         {},
+	ScopeYouTubeReadonly: {},
+	ScopeYouTube:         {},
+	ScopeYouTubeUpload:   {},
+	ScopeDrive:           {},
+
+	ScopeAdminDirectoryUser:    {},
+	ScopeAdminDirectoryGroup:   {},
+	ScopeAdminDirectoryOrgUnit: {},
+	ScopeAdminReports:          {},
+
+	ScopeCalendar:               {},
+	ScopeCalendarReadonly:       {},
+	ScopeCalendarEvents:         {},
+	ScopeCalendarEventsReadonly: {},
+}
+
+// scopeDescriptions holds the user-facing text returned by ScopeDescription
+// for every known scope.
+var scopeDescriptions = map[Scope]string{
+	ScopeOpenID:          "Confirm your identity with your Google Account",
+	ScopeEmail:           "View your email address",
+	ScopeProfile:         "View your name and profile picture",
+	ScopeYouTubeReadonly: "View your YouTube account",
+	ScopeYouTube:         "Manage your YouTube account",
+	ScopeYouTubeUpload:   "Upload and manage your YouTube videos",
+	ScopeDrive:           "View and manage your Google Drive files",
+
+	ScopeAdminDirectoryUser:    "View and manage the users on your domain",
+	ScopeAdminDirectoryGroup:   "View and manage the groups on your domain",
+	ScopeAdminDirectoryOrgUnit: "View and manage the organization units on your domain",
+	ScopeAdminReports:          "View audit reports for your Google Workspace domain",
+
+	ScopeCalendar:               "See, edit, share and delete all the calendars you can access using Google Calendar",
+	ScopeCalendarReadonly:       "See and download any calendar you can access using Google Calendar",
+	ScopeCalendarEvents:         "View and edit events on all your calendars",
+	ScopeCalendarEventsReadonly: "View events on all your calendars",
+}
+
+// ScopeDescription returns a user-facing sentence describing what scope
+// grants, for consent pages that list the requested scopes. The userinfo
+// scope URLs are described like ScopeEmail and ScopeProfile. Unknown scopes
+// are returned as the raw scope string.
+func ScopeDescription(scope Scope) string {
+	if alias, found := scopeAliases[string(scope)]; found {
+		scope = alias
+	}
+	if description, found := scopeDescriptions[scope]; found {
+		return description
+	}
+	return string(scope)
+}
+
+// ScopeDescriptions returns ScopeDescription for each scope in scopes.
+func ScopeDescriptions(scopes []Scope) []string {
+	descriptions := make([]string, len(scopes))
+	for index, scope := range scopes {
+		descriptions[index] = ScopeDescription(scope)
+	}
+	return descriptions
+}
+
+// ParseScopes converts scope strings, such as those loaded from a
+// configuration file, into Scope values. Strings naming one of the Scope
+// constants are returned in known, with the userinfo scope URLs mapped to
+// ScopeEmail and ScopeProfile; surrounding whitespace is ignored. Every other
+// string is returned unchanged in unknown, so callers can reject typos when
+// loading configuration.
+func ParseScopes(raw []string) (known []Scope, unknown []string) {
+	for _, rawScope := range raw {
+		candidateScope := Scope(strings.TrimSpace(rawScope))
+		if alias, found := scopeAliases[string(candidateScope)]; found {
+			candidateScope = alias
+		}
+		if _, found := knownScopes[candidateScope]; found {
+			known = append(known, candidateScope)
+			continue
+		}
+		unknown = append(unknown, rawScope)
+	}
+	return known, unknown
+}
+
+// scopeAliases maps the scope URLs Google reports in token responses to the
+// short names used when requesting them.
+var scopeAliases = map[string]Scope{
+	"https://www.googleapis.com/auth/userinfo.email":   ScopeEmail,
+	"https://www.googleapis.com/auth/userinfo.profile": ScopeProfile,
+}
+
+// grantedScopes returns the scopes Google granted with oauthToken, falling
+// back to requestedScopes when the token response does not list them.
+func grantedScopes(oauthToken *oauth2.Token, requestedScopes []string) []string {
+	if grantedScopeList, _ := oauthToken.Extra(tokenFieldScope).(string); grantedScopeList != "" {
+		return strings.Fields(grantedScopeList)
+	}
+	return append([]string(nil), requestedScopes...)
+}
+
+// hasScopes reports whether grantedScopeList, a space separated list of
+// scopes, contains every scope in requiredScopes.
+func hasScopes(grantedScopeList string, requiredScopes []Scope) bool {
+	granted := make(map[Scope]struct{})
+	for _, grantedScope := range strings.Fields(grantedScopeList) {
+		if alias, found := scopeAliases[grantedScope]; found {
+			granted[alias] = struct{}{}
+		}
+		granted[Scope(grantedScope)] = struct{}{}
+	}
+	for _, requiredScope := range requiredScopes {
+		if alias, found := scopeAliases[string(requiredScope)]; found {
+			requiredScope = alias
+		}
+		if _, found := granted[requiredScope]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Scopes returns a copy of the scopes the Service requests at login.
+func (serviceInstance *Service) Scopes() []string {
+	return append([]string(nil), serviceInstance.config.Scopes...)
+}
+
+// HasScope reports whether the Service requests scope at login. The short
+// names email and profile match their userinfo scope URLs and vice versa.
+func (serviceInstance *Service) HasScope(scope Scope) bool {
+	return hasScopes(strings.Join(serviceInstance.config.Scopes, " "), []Scope{scope})
+}
+
+// WithAdditionalScopes returns a ServiceOption that adds scopes to the scopes
+// requested at login, skipping any already present. An integration layer set
+// up after NewService, for example one that needs Drive access, can call
+// AddScopes instead.
+func WithAdditionalScopes(scopes ...Scope) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.AddScopes(scopes...)
+	}
+}
+
+// AddScopes adds scopes to the scopes requested at login, skipping empty
+// scopes and any already present. Call it before the Service handles
+// requests, as it is not safe for concurrent use.
+func (serviceInstance *Service) AddScopes(scopes ...Scope) {
+	mergedScopes := append([]string(nil), serviceInstance.config.Scopes...)
+	presentScopes := make(map[string]struct{}, len(mergedScopes)+len(scopes))
+	for _, presentScope := range mergedScopes {
+		presentScopes[presentScope] = struct{}{}
+	}
+	for _, additionalScope := range scopes {
+		if _, present := presentScopes[string(additionalScope)]; present || additionalScope == "" {
+			continue
+		}
+		presentScopes[string(additionalScope)] = struct{}{}
+		mergedScopes = append(mergedScopes, string(additionalScope))
+	}
+	serviceInstance.config.Scopes = mergedScopes
+}
@@ -0,0 +1,140 @@
+package gauss
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceScopes(t *testing.T) {
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", []string{string(ScopeEmail), string(ScopeYouTubeReadonly)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopes := svc.Scopes()
+	if len(scopes) != 2 || scopes[0] != string(ScopeEmail) || scopes[1] != string(ScopeYouTubeReadonly) {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+	scopes[0] = "mutated"
+	if svc.Scopes()[0] != string(ScopeEmail) {
+		t.Fatal("expected Scopes to return a copy")
+	}
+}
+
+func TestServiceHasScope(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configured []string
+		scope      Scope
+		expected   bool
+	}{
+		{name: "configured", configured: []string{string(ScopeYouTubeReadonly)}, scope: ScopeYouTubeReadonly, expected: true},
+		{name: "not configured", configured: []string{string(ScopeYouTubeReadonly)}, scope: ScopeYouTube, expected: false},
+		{name: "default scopes", scope: ScopeProfile, expected: true},
+		{name: "short name matches URL", configured: []string{"https://www.googleapis.com/auth/userinfo.email"}, scope: ScopeEmail, expected: true},
+		{name: "URL matches short name", configured: []string{string(ScopeProfile)}, scope: "https://www.googleapis.com/auth/userinfo.profile", expected: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", testCase.configured, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasScope := svc.HasScope(testCase.scope); hasScope != testCase.expected {
+				t.Fatalf("expected HasScope(%s) to be %v", testCase.scope, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestWithAdditionalScopes(t *testing.T) {
+	configuredScopes := make([]string, 1, 4)
+	configuredScopes[0] = string(ScopeEmail)
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", configuredScopes, "",
+		WithAdditionalScopes(ScopeProfile, ScopeEmail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.AddScopes(ScopeYouTubeReadonly, ScopeProfile, ScopeYouTubeReadonly)
+
+	expectedScopes := []string{string(ScopeEmail), string(ScopeProfile), string(ScopeYouTubeReadonly)}
+	scopes := svc.Scopes()
+	if len(scopes) != len(expectedScopes) {
+		t.Fatalf("expected scopes %v, got %v", expectedScopes, scopes)
+	}
+	for index, expectedScope := range expectedScopes {
+		if scopes[index] != expectedScope {
+			t.Fatalf("expected scopes %v, got %v", expectedScopes, scopes)
+		}
+	}
+	if configuredScopes[:cap(configuredScopes)][1] != "" {
+		t.Fatal("expected the caller's scope slice to be left untouched")
+	}
+
+	rawURL, err := svc.AuthorizationURL("state-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rawURL, "youtube.readonly") {
+		t.Fatalf("expected the added scope in the authorization URL, got %s", rawURL)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	testCases := []struct {
+		name            string
+		raw             []string
+		expectedKnown   []Scope
+		expectedUnknown []string
+	}{
+		{name: "empty"},
+		{name: "known", raw: []string{"openid", "email", string(ScopeYouTubeUpload)}, expectedKnown: []Scope{ScopeOpenID, ScopeEmail, ScopeYouTubeUpload}},
+		{name: "userinfo aliases", raw: []string{"https://www.googleapis.com/auth/userinfo.profile"}, expectedKnown: []Scope{ScopeProfile}},
+		{name: "surrounding whitespace", raw: []string{" profile\n"}, expectedKnown: []Scope{ScopeProfile}},
+		{name: "typo", raw: []string{"email", "profil", ""}, expectedKnown: []Scope{ScopeEmail}, expectedUnknown: []string{"profil", ""}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			known, unknown := ParseScopes(testCase.raw)
+			if strings.Join(ScopeStrings(known), " ") != strings.Join(ScopeStrings(testCase.expectedKnown), " ") {
+				t.Fatalf("expected known %v, got %v", testCase.expectedKnown, known)
+			}
+			if len(unknown) != len(testCase.expectedUnknown) {
+				t.Fatalf("expected unknown %q, got %q", testCase.expectedUnknown, unknown)
+			}
+			for index := range unknown {
+				if unknown[index] != testCase.expectedUnknown[index] {
+					t.Fatalf("expected unknown %q, got %q", testCase.expectedUnknown, unknown)
+				}
+			}
+		})
+	}
+}
+
+func TestScopeDescription(t *testing.T) {
+	testCases := []struct {
+		scope    Scope
+		expected string
+	}{
+		{scope: ScopeEmail, expected: "View your email address"},
+		{scope: ScopeDrive, expected: "View and manage your Google Drive files"},
+		{scope: "https://www.googleapis.com/auth/userinfo.profile", expected: scopeDescriptions[ScopeProfile]},
+		{scope: "https://www.googleapis.com/auth/tasks", expected: "https://www.googleapis.com/auth/tasks"},
+	}
+	for _, testCase := range testCases {
+		if description := ScopeDescription(testCase.scope); description != testCase.expected {
+			t.Fatalf("%s: expected %q, got %q", testCase.scope, testCase.expected, description)
+		}
+	}
+	for knownScope := range knownScopes {
+		if _, described := scopeDescriptions[knownScope]; !described {
+			t.Fatalf("expected a description for %s", knownScope)
+		}
+	}
+}
+
+func TestScopeDescriptions(t *testing.T) {
+	descriptions := ScopeDescriptions([]Scope{ScopeEmail, "custom"})
+	if len(descriptions) != 2 || descriptions[0] != "View your email address" || descriptions[1] != "custom" {
+		t.Fatalf("unexpected descriptions %q", descriptions)
+	}
+}
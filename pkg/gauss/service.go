@@ -7,21 +7,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gorilla/sessions"
 	"github.com/temirov/GAuss/pkg/constants"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
-// userInfoEndpoint specifies the URL used to retrieve profile information from
-// Google. It is a variable rather than a constant so tests can replace it with
-// a mock server endpoint.
-var userInfoEndpoint = "https://www.googleapis.com/oauth2/v2/userinfo"
-
 const (
+	responseModeParameter  = "response_mode"
+	responseModeFormPost   = "form_post"
 	headerForwarded        = "Forwarded"
 	headerXForwardedProto  = "X-Forwarded-Proto"
 	headerXForwardedScheme = "X-Forwarded-Scheme"
@@ -32,13 +35,21 @@ const (
 	headerValueSeparator   = ","
 	forwardedPairSeparator = ";"
 	defaultHTTPScheme      = "https"
+	idTokenField           = "id_token"
+	defaultStateByteLength = 32
+	minimumStateByteLength = 16
 )
 
 // GoogleUser represents a user profile retrieved from Google.
 type GoogleUser struct {
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+	// ID is Google's stable subject identifier. Unlike Email it never changes,
+	// so prefer it as the primary key for users.
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	Locale        string `json:"locale"`
 }
 
 // Service encapsulates OAuth2 configuration and redirection settings used by
@@ -48,12 +59,93 @@ type GoogleUser struct {
 // The LoginTemplate field, if non-empty, specifies the HTML template filename
 // to be used for the login page instead of the embedded "login.html".
 type Service struct {
-	config            *oauth2.Config
-	publicBaseURL     *url.URL
-	callbackPath      *url.URL
-	localRedirectURL  string
-	logoutRedirectURL string
-	LoginTemplate     string
+	config                   *oauth2.Config
+	publicBaseURL            *url.URL
+	loginPath                string
+	googleAuthPath           string
+	scopesPath               string
+	callbackPath             *url.URL
+	logoutPath               string
+	disconnectPath           string
+	localRedirectURL         string
+	logoutRedirectURL        string
+	googleFrontChannelLogout bool
+	contentSecurityPolicy    string
+	wellKnown                bool
+	debugEndpoint            bool
+	disconnectRedirectURL    string
+	devFakeUser              *GoogleUser
+	responseMode             string
+	allowMissingRefreshToken bool
+	onlineAccess             bool
+	errorHandler             ErrorHandler
+	flashMessages            bool
+	customTemplateData       map[string]interface{}
+	loginTemplateData        LoginTemplateDataFunc
+	userInfoVersion          int
+	googleEndpoints          GoogleEndpoints
+	customHTTPClient         *http.Client
+	userInfoCacheEnabled     bool
+	userInfoCacheTTL         time.Duration
+	userInfoCache            *ttlCache[GoogleUser]
+	pictureCacheEnabled      bool
+	pictureCacheTTL          time.Duration
+	pictureCache             *ttlCache[cachedPicture]
+	tokenInfoCacheTTL        time.Duration
+	tokenInfoCache           *ttlCache[TokenInfo]
+	sessionStore             sessions.Store
+	sessionName              string
+	sessionNamespace         string
+	templateFuncs            template.FuncMap
+	templateFileSystem       fs.FS
+	templatePatterns         []string
+	loginTemplateName        string
+	injectedTemplates        *template.Template
+	templateReload           bool
+	loginSuccessHook         LoginSuccessHook
+	loginFailureHook         LoginFailureHook
+	logoutHook               LogoutHook
+	popupCallback            bool
+	popupTargetOrigin        string
+	stateByteLength          int
+	baseURLDetection         bool
+	detectedBaseURL          *url.URL
+	detectedBaseURLMutex     sync.Mutex
+	plainRoutePatterns       bool
+	rateLimitEnabled         bool
+	rateLimitPerMinute       int
+	rateLimitBurst           int
+	rateLimitKeyFunc         RateLimitKeyFunc
+	rateLimiter              RateLimiter
+	bruteForceEnabled        bool
+	bruteForceMaxFailures    int
+	bruteForceResetAfter     time.Duration
+	bruteForceProtector      *BruteForceProtector
+	sessionBinding           SessionBinding
+	sessionBindingAction     SessionBindingAction
+	idleTimeout              time.Duration
+	maxSessionLifetime       time.Duration
+	revalidationInterval     time.Duration
+	revalidationFailClosed   bool
+	autoLogin                bool
+	maxConcurrentSessions    int
+	maxConcurrentSessionsSet bool
+	sessionRegistry          SessionRegistry
+	sessionEvictionPolicy    SessionEvictionPolicy
+	errorMessageOverrides    map[AuthErrorCode]string
+	localizedErrorMessages   map[string]map[AuthErrorCode]string
+	csrfProtection           bool
+	allowedReturnHosts       []string
+	rememberMeEnabled        bool
+	rememberMeDuration       time.Duration
+	consentMode              ConsentMode
+	incrementalScopes        map[string]struct{}
+	requestIDFunc            RequestIDFunc
+	metrics                  Metrics
+	tracer                   Tracer
+	allowUnverifiedEmail     bool
+	now                      func() time.Time
+	LoginTemplate            string
 }
 
 // ServiceOption customizes optional behavior when creating a Service.
@@ -73,6 +165,133 @@ func WithLogoutRedirectURL(redirectURL string) ServiceOption {
 	}
 }
 
+// WithStateLength returns a ServiceOption that sets how many random bytes
+// GenerateState reads for the OAuth state parameter. The default is 32 bytes,
+// which encodes to 44 characters; shorter values keep authorization URLs
+// compact. NewService rejects lengths below 16 bytes.
+func WithStateLength(byteLength int) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.stateByteLength = byteLength
+	}
+}
+
+// WithBaseURLDetection returns a ServiceOption for deployments whose public
+// base URL is unknown at startup, such as review apps or tests on random
+// ports. The scheme and host of the first request, honoring the same
+// forwarding headers as usual, become the base URL for every later redirect
+// URI; DetectedBaseURL reports the result. A warning is logged when the URL is
+// detected because a spoofed Host header on that first request would be
+// pinned.
+func WithBaseURLDetection() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.baseURLDetection = true
+	}
+}
+
+// WithPlainRoutePatterns returns a ServiceOption that registers routes by
+// bare path, as before Go 1.22, instead of method patterns like
+// "GET /login". Use it with muxes that do not understand method patterns,
+// such as when GODEBUG=httpmuxgo121=1 is set. Every method then reaches the
+// handlers.
+func WithPlainRoutePatterns() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.plainRoutePatterns = true
+	}
+}
+
+// WithAllowUnverifiedEmail returns a ServiceOption that controls whether users
+// whose Google email address is not verified may log in. They are rejected
+// with error=email_not_verified by default; allowing them is intended for
+// testing environments.
+func WithAllowUnverifiedEmail(allowed bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.allowUnverifiedEmail = allowed
+	}
+}
+
+// WithFormPostResponseMode returns a ServiceOption that asks Google to deliver
+// the authorization response as an HTML form POST (response_mode=form_post)
+// instead of query parameters, keeping the authorization code out of server
+// logs and Referer headers. Because the POST arrives cross-site, Login and
+// RequestScopes issue the session cookie that holds the state with
+// SameSite=None and Secure, so the browser sends it with the POST; the
+// callback must therefore be served over HTTPS.
+func WithFormPostResponseMode() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.responseMode = responseModeFormPost
+	}
+}
+
+// WithAllowMissingRefreshToken returns a ServiceOption that lets a login
+// complete without a refresh token when Google still withholds one after the
+// consent screen has been shown again, as happens for Workspace accounts whose
+// administrators disable offline access. Without this option such logins fail
+// with error=refresh_token_unavailable.
+func WithAllowMissingRefreshToken() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.allowMissingRefreshToken = true
+	}
+}
+
+// WithOfflineAccess returns a ServiceOption that requests access_type=offline,
+// so Google issues a refresh token. This is the default.
+func WithOfflineAccess() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.onlineAccess = false
+	}
+}
+
+// WithOnlineAccess returns a ServiceOption that requests access_type=online.
+// Google then issues no refresh token, so logins complete without one instead
+// of re-requesting consent.
+func WithOnlineAccess() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.onlineAccess = true
+	}
+}
+
+// WithFlashMessages returns a ServiceOption that controls how authentication
+// errors reach the login page. When enabled, the error is stored as a one-time
+// flash message in the session and the client is redirected to the login page
+// without query parameters, keeping error details out of browser history and
+// server logs.
+func WithFlashMessages(enabled bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.flashMessages = enabled
+	}
+}
+
+// WithCustomTemplateData returns a ServiceOption that injects additional values,
+// such as an application name or logo URL, into the data passed to the login
+// template. The values are merged with the built-in data; keys used by the
+// built-in data, such as "error", are ignored. The map is copied, so later
+// changes by the caller have no effect.
+func WithCustomTemplateData(data map[string]interface{}) ServiceOption {
+	return func(serviceInstance *Service) {
+		copiedData := make(map[string]interface{}, len(data))
+		for dataKey, dataValue := range data {
+			copiedData[dataKey] = dataValue
+		}
+		serviceInstance.customTemplateData = copiedData
+	}
+}
+
+// LoginTemplateDataFunc returns values to merge into the login template data
+// for request.
+type LoginTemplateDataFunc func(request *http.Request) map[string]interface{}
+
+// WithLoginTemplateData returns a ServiceOption that calls dataFunc each time
+// the login page is rendered and merges the returned values into the template
+// data, after those set with WithCustomTemplateData. As with
+// WithCustomTemplateData, keys used by the built-in data are ignored. The
+// embedded template renders "appName", "logoURL", "tagline" and
+// "supportEmail" when present.
+func WithLoginTemplateData(dataFunc LoginTemplateDataFunc) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.loginTemplateData = dataFunc
+	}
+}
+
 // NewService initializes a Service with Google OAuth credentials and the local
 // redirect URL where authenticated users will be sent after logging in.
 // googleOAuthBase should point to the publicly reachable URL of your GAuss
@@ -88,29 +307,18 @@ func NewService(clientID string, clientSecret string, googleOAuthBase string, lo
 	if googleOAuthBaseErr != nil {
 		return nil, errors.New("invalid Google OAuth base URL")
 	}
-	relativePath, _ := url.Parse(constants.CallbackPath)
-	redirectURL := baseURL.ResolveReference(relativePath)
-
 	if len(scopes) == 0 {
 		scopes = ScopeStrings(DefaultScopes)
 	}
 
 	baseConfig := &oauth2.Config{
-		RedirectURL:  redirectURL.String(),
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Scopes:       scopes,
 		Endpoint:     google.Endpoint,
 	}
 
-	serviceInstance := &Service{
-		config:            baseConfig,
-		publicBaseURL:     baseURL,
-		callbackPath:      relativePath,
-		localRedirectURL:  localRedirectURL,
-		logoutRedirectURL: constants.LoginPath,
-		LoginTemplate:     customLoginTemplate,
-	}
+	serviceInstance := newDefaultService(baseConfig, baseURL, localRedirectURL, customLoginTemplate)
 
 	for _, option := range options {
 		if option == nil {
@@ -119,46 +327,252 @@ func NewService(clientID string, clientSecret string, googleOAuthBase string, lo
 		option(serviceInstance)
 	}
 
+	if serviceInstance.stateByteLength < minimumStateByteLength {
+		return nil, fmt.Errorf("invalid state length %d: must be at least %d bytes", serviceInstance.stateByteLength, minimumStateByteLength)
+	}
+	if pathError := serviceInstance.validatePaths(); pathError != nil {
+		return nil, pathError
+	}
+	if endpointError := serviceInstance.validateGoogleEndpoints(); endpointError != nil {
+		return nil, endpointError
+	}
+	if originError := serviceInstance.validatePopupTargetOrigin(); originError != nil {
+		return nil, originError
+	}
+	if funcsError := validateTemplateFuncs(serviceInstance.templateFuncs); funcsError != nil {
+		return nil, funcsError
+	}
+	if sourceError := serviceInstance.validateTemplateSource(); sourceError != nil {
+		return nil, sourceError
+	}
+	if serviceInstance.userInfoVersion != UserInfoVersion2 && serviceInstance.userInfoVersion != UserInfoVersion3 {
+		return nil, fmt.Errorf("unsupported user info version %d", serviceInstance.userInfoVersion)
+	}
+	if serviceInstance.userInfoCacheEnabled {
+		if serviceInstance.userInfoCacheTTL <= 0 {
+			return nil, fmt.Errorf("invalid user info cache TTL %s: must be positive", serviceInstance.userInfoCacheTTL)
+		}
+		serviceInstance.userInfoCache = newTTLCache[GoogleUser](serviceInstance.userInfoCacheTTL)
+	}
+	if namespaceError := serviceInstance.validateSessionNamespace(); namespaceError != nil {
+		return nil, namespaceError
+	}
+	if serviceInstance.pictureCacheEnabled {
+		if serviceInstance.pictureCacheTTL <= 0 {
+			return nil, fmt.Errorf("invalid picture cache TTL %s: must be positive", serviceInstance.pictureCacheTTL)
+		}
+		serviceInstance.pictureCache = newTTLCache[cachedPicture](serviceInstance.pictureCacheTTL)
+	}
+	if serviceInstance.tokenInfoCacheTTL > 0 {
+		serviceInstance.tokenInfoCache = newTTLCache[TokenInfo](serviceInstance.tokenInfoCacheTTL)
+	}
+	if consentError := serviceInstance.validateConsentMode(); consentError != nil {
+		return nil, consentError
+	}
+	if logoutError := serviceInstance.validateGoogleFrontChannelLogout(); logoutError != nil {
+		return nil, logoutError
+	}
+	if serviceInstance.rememberMeEnabled && serviceInstance.rememberMeDuration < time.Second {
+		return nil, fmt.Errorf("invalid remember-me duration %s: must be at least one second", serviceInstance.rememberMeDuration)
+	}
+	if registryError := serviceInstance.configureSessionRegistry(); registryError != nil {
+		return nil, registryError
+	}
+	if serviceInstance.bruteForceEnabled {
+		protector, protectorError := newBruteForceProtector(serviceInstance.bruteForceMaxFailures, serviceInstance.bruteForceResetAfter, serviceInstance.now)
+		if protectorError != nil {
+			return nil, protectorError
+		}
+		serviceInstance.bruteForceProtector = protector
+	}
+	if serviceInstance.rateLimitEnabled && serviceInstance.rateLimiter != nil {
+		return nil, errors.New("WithRateLimit and WithRateLimiter are mutually exclusive")
+	}
+	if serviceInstance.rateLimitEnabled {
+		limiter, limiterError := newRateLimiter(serviceInstance.rateLimitPerMinute, serviceInstance.rateLimitBurst, serviceInstance.now)
+		if limiterError != nil {
+			return nil, limiterError
+		}
+		serviceInstance.rateLimiter = limiter
+	}
+	if devFakeAuthError := serviceInstance.checkDevFakeAuth(); devFakeAuthError != nil {
+		return nil, devFakeAuthError
+	}
+	baseConfig.RedirectURL = baseURL.ResolveReference(serviceInstance.callbackPath).String()
+	if serviceInstance.logoutRedirectURL == "" {
+		serviceInstance.logoutRedirectURL = serviceInstance.loginPath
+	}
+	if serviceInstance.disconnectRedirectURL == "" {
+		serviceInstance.disconnectRedirectURL = serviceInstance.logoutRedirectURL
+	}
+
 	return serviceInstance, nil
 }
 
+// newDefaultService returns a Service with the default paths, clock, metrics,
+// tracer and Google endpoints, before options are applied. It stores sessions
+// in the package-level store of pkg/session.
+func newDefaultService(baseConfig *oauth2.Config, baseURL *url.URL, localRedirectURL string, customLoginTemplate string) *Service {
+	return &Service{
+		config:                baseConfig,
+		publicBaseURL:         baseURL,
+		loginPath:             constants.LoginPath,
+		googleAuthPath:        constants.GoogleAuthPath,
+		scopesPath:            constants.ScopesPath,
+		callbackPath:          &url.URL{Path: constants.CallbackPath},
+		logoutPath:            constants.LogoutPath,
+		disconnectPath:        constants.DisconnectPath,
+		localRedirectURL:      localRedirectURL,
+		stateByteLength:       defaultStateByteLength,
+		contentSecurityPolicy: defaultContentSecurityPolicy,
+		googleEndpoints:       defaultGoogleEndpoints(),
+		userInfoVersion:       UserInfoVersion2,
+		tokenInfoCacheTTL:     defaultTokenInfoCacheTTL,
+		metrics:               noopMetrics{},
+		tracer:                noopTracer{},
+		now:                   time.Now,
+		LoginTemplate:         customLoginTemplate,
+	}
+}
+
 // GenerateState returns a cryptographically secure random string that is used
 // as the OAuth2 state parameter to protect against cross-site request forgery.
 func (serviceInstance *Service) GenerateState() (string, error) {
-	randomBytes := make([]byte, 32)
-	_, readError := rand.Read(randomBytes)
-	if readError != nil {
-		return "", fmt.Errorf("failed to generate state: %w", readError)
+	stateValue, randomError := randomToken(serviceInstance.stateByteLength)
+	if randomError != nil {
+		return "", fmt.Errorf("failed to generate state: %w", randomError)
+	}
+	return stateValue, nil
+}
+
+// randomToken returns byteLength cryptographically secure random bytes encoded
+// as URL-safe base64.
+func randomToken(byteLength int) (string, error) {
+	randomBytes := make([]byte, byteLength)
+	if _, readError := rand.Read(randomBytes); readError != nil {
+		return "", readError
 	}
 	return base64.URLEncoding.EncodeToString(randomBytes), nil
 }
 
+// AuthorizationURL builds the Google authorization URL for the provided state
+// using the statically configured redirect URL. It is intended for callers that
+// need the URL outside of an HTTP handler, such as command-line tools or tests.
+// Additional oauth2.AuthCodeOption values are applied after the defaults used
+// by Login and therefore take precedence.
+func (serviceInstance *Service) AuthorizationURL(state string, options ...oauth2.AuthCodeOption) (string, error) {
+	if state == "" {
+		return "", errors.New("missing OAuth state")
+	}
+	return serviceInstance.buildAuthorizationURL(serviceInstance.config, state, serviceInstance.defaultPrompt(), options...), nil
+}
+
 // GetUser contacts Google's userinfo endpoint to retrieve the profile
-// associated with the provided OAuth2 token.
+// associated with the provided OAuth2 token. It is equivalent to
+// GetUserContext with context.Background().
 func (serviceInstance *Service) GetUser(oauthToken *oauth2.Token) (*GoogleUser, error) {
-	httpClient := serviceInstance.config.Client(context.Background(), oauthToken)
-	httpResponse, httpError := httpClient.Get(userInfoEndpoint)
+	return serviceInstance.GetUserContext(context.Background(), oauthToken)
+}
+
+// GetUserContext is like GetUser but issues the request with ctx, so that
+// cancellation and trace context propagate to the Google API call. With
+// WithUserInfoCacheTTL a cached profile is returned without a request.
+func (serviceInstance *Service) GetUserContext(ctx context.Context, oauthToken *oauth2.Token) (fetchedUser *GoogleUser, err error) {
+	if serviceInstance.userInfoCache != nil {
+		if cachedUser, found := serviceInstance.userInfoCache.load(accessTokenCacheKey(oauthToken.AccessToken), serviceInstance.now()); found {
+			return &cachedUser, nil
+		}
+	}
+
+	ctx, span := serviceInstance.startSpan(ctx, spanNameGetUser)
+	startTime := serviceInstance.now()
+	defer func() {
+		serviceInstance.metrics.ObserveUserInfo(serviceInstance.now().Sub(startTime), err)
+		endSpan(span, err, "user info request failed")
+	}()
+
+	var user GoogleUser
+	if fetchError := serviceInstance.fetchProfile(ctx, oauthToken, serviceInstance.googleEndpoints.UserInfoURL, &user); fetchError != nil {
+		return nil, fetchError
+	}
+	if user.ID == "" {
+		user.ID = subjectFromIDToken(oauthToken)
+	}
+	if serviceInstance.userInfoCache != nil {
+		currentTime := serviceInstance.now()
+		serviceInstance.userInfoCache.store(accessTokenCacheKey(oauthToken.AccessToken), user, currentTime.Add(serviceInstance.userInfoCacheTTL), currentTime)
+	}
+
+	return &user, nil
+}
+
+// fetchProfile requests profileEndpoint with oauthToken and decodes the JSON
+// response into profile.
+func (serviceInstance *Service) fetchProfile(ctx context.Context, oauthToken *oauth2.Token, profileEndpoint string, profile interface{}) error {
+	profileRequest, requestError := http.NewRequestWithContext(ctx, http.MethodGet, profileEndpoint, nil)
+	if requestError != nil {
+		return fmt.Errorf("failed to build user info request: %w", requestError)
+	}
+	httpClient := serviceInstance.config.Client(serviceInstance.oauthContext(ctx), oauthToken)
+	httpResponse, httpError := httpClient.Do(profileRequest)
 	if httpError != nil {
-		return nil, fmt.Errorf("failed to get user info: %w", httpError)
+		return fmt.Errorf("failed to get user info: %w", httpError)
 	}
 	defer httpResponse.Body.Close()
 
 	if httpResponse.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google API returned status %d", httpResponse.StatusCode)
+		return fmt.Errorf("google API returned status %d", httpResponse.StatusCode)
 	}
 
-	var user GoogleUser
-	if decodeError := json.NewDecoder(httpResponse.Body).Decode(&user); decodeError != nil {
-		return nil, fmt.Errorf("failed to decode user info: %w", decodeError)
+	if decodeError := json.NewDecoder(httpResponse.Body).Decode(profile); decodeError != nil {
+		return fmt.Errorf("failed to decode user info: %w", decodeError)
 	}
+	return nil
+}
 
-	return &user, nil
+// subjectFromIDToken returns the sub claim of the ID token that accompanies
+// oauthToken, or an empty string. The token is read without verifying its
+// signature, which is safe only because it was received directly from
+// Google's token endpoint over TLS.
+func subjectFromIDToken(oauthToken *oauth2.Token) string {
+	idToken, _ := oauthToken.Extra(idTokenField).(string)
+	tokenParts := strings.Split(idToken, ".")
+	if len(tokenParts) != 3 {
+		return ""
+	}
+	payload, decodeError := base64.RawURLEncoding.DecodeString(tokenParts[1])
+	if decodeError != nil {
+		return ""
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if unmarshalError := json.Unmarshal(payload, &claims); unmarshalError != nil {
+		return ""
+	}
+	return claims.Subject
 }
 
 // GetClient creates an authenticated http.Client using the service's OAuth2
 // configuration and the provided token.
 func (serviceInstance *Service) GetClient(ctx context.Context, token *oauth2.Token) *http.Client {
-	return serviceInstance.config.Client(ctx, token)
+	return serviceInstance.config.Client(serviceInstance.oauthContext(ctx), token)
+}
+
+func (serviceInstance *Service) buildAuthorizationURL(oauthConfig *oauth2.Config, state string, prompt string, options ...oauth2.AuthCodeOption) string {
+	accessType := oauth2.AccessTypeOffline
+	if serviceInstance.onlineAccess {
+		accessType = oauth2.AccessTypeOnline
+	}
+	authCodeOptions := []oauth2.AuthCodeOption{
+		accessType,
+		oauth2.SetAuthURLParam(promptParameter, prompt),
+	}
+	if serviceInstance.responseMode != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(responseModeParameter, serviceInstance.responseMode))
+	}
+	authCodeOptions = append(authCodeOptions, options...)
+	return oauthConfig.AuthCodeURL(state, authCodeOptions...)
 }
 
 func (serviceInstance *Service) authorizationConfigForRequest(request *http.Request) *oauth2.Config {
@@ -176,35 +590,88 @@ func (serviceInstance *Service) redirectURLForRequest(request *http.Request) str
 	if baseURL == nil {
 		return serviceInstance.config.RedirectURL
 	}
-	callback := baseURL.ResolveReference(serviceInstance.callbackPath)
+	callbackPath := *serviceInstance.callbackPath
+	callbackPath.Path = mountPrefix(request) + callbackPath.Path
+	callback := baseURL.ResolveReference(&callbackPath)
 	return callback.String()
 }
 
 func (serviceInstance *Service) effectiveBaseURL(request *http.Request) *url.URL {
+	baseURL, _ := serviceInstance.resolveBaseURL(request)
+	return baseURL
+}
+
+// resolveBaseURL returns the base URL for request together with the
+// baseURLSource it was taken from.
+func (serviceInstance *Service) resolveBaseURL(request *http.Request) (*url.URL, string) {
 	if serviceInstance.publicBaseURL == nil {
-		return nil
+		return nil, baseURLSourceStatic
 	}
 
 	if request == nil {
-		return serviceInstance.publicBaseURL
+		return serviceInstance.publicBaseURL, baseURLSourceStatic
 	}
 
-	scheme := serviceInstance.resolveScheme(request)
-	host := serviceInstance.resolveHost(request)
-	if host == "" {
-		return serviceInstance.publicBaseURL
+	if serviceInstance.baseURLDetection {
+		return serviceInstance.detectBaseURL(request), baseURLSourceDetected
 	}
 
-	port := serviceInstance.resolvePort(request)
-	if port != "" && !strings.Contains(host, ":") {
-		host = host + ":" + port
+	host := serviceInstance.requestHostWithPort(request)
+	if host == "" {
+		return serviceInstance.publicBaseURL, baseURLSourceStatic
 	}
 
 	baseCopy := *serviceInstance.publicBaseURL
-	baseCopy.Scheme = scheme
+	baseCopy.Scheme = serviceInstance.resolveScheme(request)
 	baseCopy.Host = host
 
-	return &baseCopy
+	return &baseCopy, baseURLSourceDynamic
+}
+
+// detectBaseURL derives the base URL from the first request that carries a
+// host and reuses it for every later request.
+func (serviceInstance *Service) detectBaseURL(request *http.Request) *url.URL {
+	serviceInstance.detectedBaseURLMutex.Lock()
+	defer serviceInstance.detectedBaseURLMutex.Unlock()
+
+	if serviceInstance.detectedBaseURL == nil {
+		host := serviceInstance.requestHostWithPort(request)
+		if host == "" {
+			return serviceInstance.publicBaseURL
+		}
+		serviceInstance.detectedBaseURL = &url.URL{Scheme: serviceInstance.resolveScheme(request), Host: host}
+		log.Printf("Warning: public base URL auto-detected as %s from the first request; configure it explicitly in production", serviceInstance.detectedBaseURL)
+	}
+
+	detectedCopy := *serviceInstance.detectedBaseURL
+	return &detectedCopy
+}
+
+// DetectedBaseURL returns the base URL derived by WithBaseURLDetection, or nil
+// when detection is disabled or no request has been served yet.
+func (serviceInstance *Service) DetectedBaseURL() *url.URL {
+	serviceInstance.detectedBaseURLMutex.Lock()
+	defer serviceInstance.detectedBaseURLMutex.Unlock()
+
+	if serviceInstance.detectedBaseURL == nil {
+		return nil
+	}
+	detectedCopy := *serviceInstance.detectedBaseURL
+	return &detectedCopy
+}
+
+// requestHostWithPort returns the host that served request, including a
+// forwarded port when the host does not already carry one.
+func (serviceInstance *Service) requestHostWithPort(request *http.Request) string {
+	host := serviceInstance.resolveHost(request)
+	if host == "" {
+		return ""
+	}
+	port := serviceInstance.resolvePort(request)
+	if port != "" && !strings.Contains(host, ":") {
+		host = host + ":" + port
+	}
+	return host
 }
 
 func (serviceInstance *Service) resolveScheme(request *http.Request) string {
@@ -2,9 +2,11 @@ package gauss
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/temirov/GAuss/pkg/constants"
@@ -29,22 +31,62 @@ func TestGenerateStateUnique(t *testing.T) {
 	}
 }
 
+func TestWithStateLength(t *testing.T) {
+	testCases := []struct {
+		name         string
+		options      []ServiceOption
+		wantLength   int
+		wantRejected bool
+	}{
+		{name: "default", wantLength: 44},
+		{name: "minimum", options: []ServiceOption{WithStateLength(16)}, wantLength: 24},
+		{name: "longer", options: []ServiceOption{WithStateLength(48)}, wantLength: 64},
+		{name: "too short", options: []ServiceOption{WithStateLength(15)}, wantRejected: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", testCase.options...)
+			if testCase.wantRejected {
+				if err == nil {
+					t.Fatal("expected NewService to reject the state length")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewService error: %v", err)
+			}
+			seenStates := map[string]bool{}
+			for attempt := 0; attempt < 10; attempt++ {
+				state, stateErr := svc.GenerateState()
+				if stateErr != nil {
+					t.Fatalf("GenerateState error: %v", stateErr)
+				}
+				if len(state) != testCase.wantLength {
+					t.Fatalf("expected state length %d, got %d", testCase.wantLength, len(state))
+				}
+				if seenStates[state] {
+					t.Fatalf("duplicate state %s", state)
+				}
+				seenStates[state] = true
+			}
+		})
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"email":   "e@example.com",
-			"name":    "tester",
-			"picture": "img",
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"email":          "e@example.com",
+			"verified_email": true,
+			"name":           "tester",
+			"picture":        "img",
+			"locale":         "en-GB",
 		})
 	}))
 	defer server.Close()
 
-	orig := userInfoEndpoint
-	userInfoEndpoint = server.URL
-	defer func() { userInfoEndpoint = orig }()
-
-	svc, err := NewService("id", "secret", "http://example.com", "/dash", ScopeStrings(DefaultScopes), "")
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", ScopeStrings(DefaultScopes), "", WithGoogleEndpoints(GoogleEndpoints{UserInfoURL: server.URL}))
 	if err != nil {
 		t.Fatalf("NewService error: %v", err)
 	}
@@ -53,7 +95,7 @@ func TestGetUser(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetUser error: %v", err)
 	}
-	if user.Email != "e@example.com" || user.Name != "tester" {
+	if user.Email != "e@example.com" || user.Name != "tester" || !user.VerifiedEmail || user.Locale != "en-GB" {
 		t.Fatalf("unexpected user: %+v", user)
 	}
 }
@@ -104,3 +146,144 @@ func TestNewServiceWithLogoutRedirectOption(t *testing.T) {
 		t.Fatalf("expected logout redirect /landing, got %s", svc.logoutRedirectURL)
 	}
 }
+
+func TestAuthorizationURL(t *testing.T) {
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "")
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	rawURL, err := svc.AuthorizationURL("state-value", oauth2.SetAuthURLParam("login_hint", "e@example.com"))
+	if err != nil {
+		t.Fatalf("AuthorizationURL error: %v", err)
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("failed to parse authorization URL: %v", err)
+	}
+	query := parsedURL.Query()
+	if query.Get("state") != "state-value" {
+		t.Fatalf("expected state state-value, got %s", query.Get("state"))
+	}
+	if query.Get("redirect_uri") != svc.config.RedirectURL {
+		t.Fatalf("expected redirect_uri %s, got %s", svc.config.RedirectURL, query.Get("redirect_uri"))
+	}
+	if query.Get("login_hint") != "e@example.com" {
+		t.Fatalf("expected login_hint to be applied, got %s", query.Get("login_hint"))
+	}
+}
+
+func TestAuthorizationURLRequiresState(t *testing.T) {
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "")
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	if _, err := svc.AuthorizationURL(""); err == nil {
+		t.Fatal("expected error for empty state")
+	}
+}
+
+func TestWithBaseURLDetectionPinsFirstRequest(t *testing.T) {
+	svc, err := NewService("id", "secret", "", "/dash", nil, "", WithBaseURLDetection())
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	if detected := svc.DetectedBaseURL(); detected != nil {
+		t.Fatalf("expected no detected URL before the first request, got %s", detected)
+	}
+
+	firstRequest := httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil)
+	firstRequest.Host = "review-42.example.com"
+	firstRequest.Header.Set("X-Forwarded-Proto", "https")
+	if redirectURL := svc.redirectURLForRequest(firstRequest); redirectURL != "https://review-42.example.com/auth/google/callback" {
+		t.Fatalf("unexpected redirect URL %s", redirectURL)
+	}
+
+	secondRequest := httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil)
+	secondRequest.Host = "other.example.com"
+	if redirectURL := svc.redirectURLForRequest(secondRequest); redirectURL != "https://review-42.example.com/auth/google/callback" {
+		t.Fatalf("expected the detected base URL to be reused, got %s", redirectURL)
+	}
+	if detected := svc.DetectedBaseURL(); detected == nil || detected.String() != "https://review-42.example.com" {
+		t.Fatalf("unexpected detected URL %v", detected)
+	}
+}
+
+func TestGetUserReadsSubjectIdentifier(t *testing.T) {
+	idTokenPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1098765","email":"e@example.com"}`))
+	testCases := []struct {
+		name     string
+		userInfo map[string]string
+		token    *oauth2.Token
+		wantID   string
+	}{
+		{name: "userinfo id", userInfo: map[string]string{"id": "1234567", "email": "e@example.com"}, token: &oauth2.Token{AccessToken: "abc"}, wantID: "1234567"},
+		{name: "id token sub", userInfo: map[string]string{"email": "e@example.com"}, token: (&oauth2.Token{AccessToken: "abc"}).WithExtra(map[string]interface{}{"id_token": "header." + idTokenPayload + ".signature"}), wantID: "1098765"},
+		{name: "neither", userInfo: map[string]string{"email": "e@example.com"}, token: &oauth2.Token{AccessToken: "abc"}, wantID: ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(testCase.userInfo)
+			}))
+			defer server.Close()
+			svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithGoogleEndpoints(GoogleEndpoints{UserInfoURL: server.URL}))
+			if err != nil {
+				t.Fatalf("NewService error: %v", err)
+			}
+			user, err := svc.GetUser(testCase.token)
+			if err != nil {
+				t.Fatalf("GetUser error: %v", err)
+			}
+			if user.ID != testCase.wantID {
+				t.Fatalf("expected ID %q, got %q", testCase.wantID, user.ID)
+			}
+		})
+	}
+}
+
+func TestAccessTypeOptions(t *testing.T) {
+	testCases := []struct {
+		name               string
+		options            []ServiceOption
+		expectedAccessType string
+	}{
+		{name: "default", expectedAccessType: "offline"},
+		{name: "offline", options: []ServiceOption{WithOfflineAccess()}, expectedAccessType: "offline"},
+		{name: "online", options: []ServiceOption{WithOnlineAccess()}, expectedAccessType: "online"},
+		{name: "last option wins", options: []ServiceOption{WithOnlineAccess(), WithOfflineAccess()}, expectedAccessType: "offline"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			rr := httptest.NewRecorder()
+			h.Login(rr, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("failed to parse redirect: %v", err)
+			}
+			if accessType := location.Query().Get("access_type"); accessType != testCase.expectedAccessType {
+				t.Fatalf("expected access_type %s, got %s", testCase.expectedAccessType, accessType)
+			}
+		})
+	}
+}
+
+func TestOnlineAccessCompletesLoginWithoutRefreshToken(t *testing.T) {
+	h := newTestHandlers(t, WithOnlineAccess())
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"abc","token_type":"bearer"}`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":"42","email":"e@example.com","verified_email":true}`))
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s1&code=c1", nil)
+	seedState(t, req, "s1")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected the login to complete without consent retry, got %d %q", rr.Code, location)
+	}
+}
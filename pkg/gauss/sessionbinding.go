@@ -0,0 +1,156 @@
+package gauss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// SessionBinding selects the request attributes a session is bound to at
+// login. Values can be combined, as in BindIP | BindUserAgent.
+type SessionBinding uint8
+
+const (
+	// BindIP binds the session to the client address, as identified for rate
+	// limiting.
+	BindIP SessionBinding = 1 << iota
+	// BindUserAgent binds the session to the User-Agent header.
+	BindUserAgent
+)
+
+// SessionBindingAction decides what happens when a request does not match
+// the attributes its session is bound to.
+type SessionBindingAction int
+
+const (
+	// SessionBindingReauthenticate invalidates the session and sends the user
+	// to the login page. It is the default.
+	SessionBindingReauthenticate SessionBindingAction = iota
+	// SessionBindingAudit only logs the mismatch and lets the request through.
+	SessionBindingAudit
+)
+
+// WithSessionBinding returns a ServiceOption that records the attributes
+// selected by binding when a user logs in. NewAuthMiddleware and
+// Service.AuthenticatedUser compare them with every later request to detect
+// sessions replayed from another browser or network; the package level
+// AuthMiddleware does not. Sessions created without a binding count as
+// mismatched once it is enabled.
+func WithSessionBinding(binding SessionBinding) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionBinding = binding
+	}
+}
+
+// WithSessionBindingAction returns a ServiceOption that selects how
+// WithSessionBinding treats a mismatch.
+func WithSessionBindingAction(action SessionBindingAction) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionBindingAction = action
+	}
+}
+
+// AuthenticatedUser is like SessionUser but also enforces WithSessionBinding,
+// WithMaxConcurrentSessions, WithIdleTimeout, WithMaxSessionLifetime and
+// WithTokenRevalidation. When a session has been evicted from the session
+// registry, or no longer matches its binding and the action is
+// SessionBindingReauthenticate, the session is invalidated through
+// responseWriter and the boolean result is false. An expired session, or one
+// whose token Google revoked, loses its token and profile in the same way.
+func (serviceInstance *Service) AuthenticatedUser(responseWriter http.ResponseWriter, request *http.Request) (*GoogleUser, bool) {
+	user, _ := serviceInstance.authenticatedUser(responseWriter, request)
+	return user, user != nil
+}
+
+// authenticatedUser implements AuthenticatedUser. The user is nil when the
+// request is unauthenticated; sessionExpired is true when that is because the
+// session exceeded the idle timeout or the maximum lifetime.
+func (serviceInstance *Service) authenticatedUser(responseWriter http.ResponseWriter, request *http.Request) (user *GoogleUser, sessionExpired bool) {
+	user, authenticated := serviceInstance.sessionUser(request)
+	if !authenticated {
+		return nil, false
+	}
+	if serviceInstance.sessionBinding == 0 && serviceInstance.sessionRegistry == nil && serviceInstance.idleTimeout <= 0 && serviceInstance.maxSessionLifetime <= 0 && serviceInstance.revalidationInterval <= 0 {
+		return user, false
+	}
+
+	webSession := serviceInstance.webSession(request)
+	if !serviceInstance.sessionRegistered(request, webSession, user) {
+		logRequestf(request, "Session of user %s is no longer registered; invalidating session", user.ID)
+		invalidateSession(responseWriter, request, webSession)
+		return nil, false
+	}
+	if serviceInstance.sessionBinding != 0 && !serviceInstance.sessionMatchesBinding(webSession, request) {
+		if serviceInstance.sessionBindingAction != SessionBindingAudit {
+			logRequestf(request, "Session binding mismatch for user %s; invalidating session", user.ID)
+			invalidateSession(responseWriter, request, webSession)
+			return nil, false
+		}
+		logRequestf(request, "Session binding mismatch for user %s; allowing request", user.ID)
+	}
+	if serviceInstance.maxSessionLifetime > 0 && !serviceInstance.sessionWithinLifetime(webSession) {
+		logRequestf(request, "Session of user %s is older than %s; signing out", user.ID, serviceInstance.maxSessionLifetime)
+		serviceInstance.clearAuthSession(responseWriter, request, webSession)
+		return nil, true
+	}
+	if serviceInstance.idleTimeout > 0 && !serviceInstance.touchSession(responseWriter, request, webSession) {
+		logRequestf(request, "Session of user %s was idle for longer than %s; signing out", user.ID, serviceInstance.idleTimeout)
+		serviceInstance.clearAuthSession(responseWriter, request, webSession)
+		return nil, true
+	}
+	if serviceInstance.revalidationInterval > 0 && !serviceInstance.revalidateSessionToken(responseWriter, request, webSession) {
+		logRequestf(request, "OAuth token of user %s is no longer valid; signing out", user.ID)
+		serviceInstance.clearAuthSession(responseWriter, request, webSession)
+		return nil, false
+	}
+	return user, false
+}
+
+// invalidateSession deletes webSession, logging a failure to save it.
+func invalidateSession(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) {
+	if deleteError := session.DeleteWebSession(responseWriter, request, webSession); deleteError != nil {
+		logRequestf(request, "Failed to invalidate session: %v", deleteError)
+	}
+}
+
+// bindSession stores the fingerprints of the attributes selected by
+// WithSessionBinding in webSession.
+func (serviceInstance *Service) bindSession(webSession *sessions.Session, request *http.Request) {
+	for sessionKey, value := range serviceInstance.bindingValues(request) {
+		webSession.Values[sessionKey] = bindingFingerprint(value)
+	}
+}
+
+// sessionMatchesBinding reports whether request carries the attributes
+// webSession was bound to.
+func (serviceInstance *Service) sessionMatchesBinding(webSession *sessions.Session, request *http.Request) bool {
+	for sessionKey, value := range serviceInstance.bindingValues(request) {
+		storedFingerprint, _ := webSession.Values[sessionKey].(string)
+		if storedFingerprint == "" || storedFingerprint != bindingFingerprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// bindingValues returns the bound attributes of request by session key.
+func (serviceInstance *Service) bindingValues(request *http.Request) map[string]string {
+	values := make(map[string]string, 2)
+	if serviceInstance.sessionBinding&BindIP != 0 {
+		values[constants.SessionKeyBoundIP] = serviceInstance.clientKey(request)
+	}
+	if serviceInstance.sessionBinding&BindUserAgent != 0 {
+		values[constants.SessionKeyBoundUserAgent] = request.UserAgent()
+	}
+	return values
+}
+
+// bindingFingerprint hashes value so the cookie does not carry it in clear.
+func bindingFingerprint(value string) string {
+	valueHash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(valueHash[:])
+}
@@ -0,0 +1,91 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// loginWithBinding logs a user in from remoteAddr with userAgent and returns
+// the resulting session cookies.
+func loginWithBinding(t *testing.T, h *Handlers, remoteAddr string, userAgent string) []*http.Cookie {
+	t.Helper()
+	useMockGoogle(t, h, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected a successful login, got %d %s", rr.Code, location)
+	}
+	return lastCookies(rr)
+}
+
+func TestSessionBindingMiddleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		binding      SessionBinding
+		action       SessionBindingAction
+		remoteAddr   string
+		userAgent    string
+		wantStatus   int
+		wantDeletion bool
+	}{
+		{name: "matching request", binding: BindIP | BindUserAgent, remoteAddr: "192.0.2.1:1234", userAgent: "browser/1", wantStatus: http.StatusOK},
+		{name: "new port from the same address", binding: BindIP, remoteAddr: "192.0.2.1:9999", userAgent: "browser/1", wantStatus: http.StatusOK},
+		{name: "different address", binding: BindIP, remoteAddr: "198.51.100.7:1234", userAgent: "browser/1", wantStatus: http.StatusFound, wantDeletion: true},
+		{name: "different user agent", binding: BindUserAgent, remoteAddr: "198.51.100.7:1234", userAgent: "curl/8", wantStatus: http.StatusFound, wantDeletion: true},
+		{name: "unbound attribute ignored", binding: BindUserAgent, remoteAddr: "198.51.100.7:1234", userAgent: "browser/1", wantStatus: http.StatusOK},
+		{name: "audit only", binding: BindIP | BindUserAgent, action: SessionBindingAudit, remoteAddr: "198.51.100.7:1234", userAgent: "curl/8", wantStatus: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithSessionBinding(testCase.binding), WithSessionBindingAction(testCase.action))
+			cookies := loginWithBinding(t, h, "192.0.2.1:1234", "browser/1")
+
+			req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+			req.RemoteAddr = testCase.remoteAddr
+			req.Header.Set("User-Agent", testCase.userAgent)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr := httptest.NewRecorder()
+			NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != testCase.wantStatus {
+				t.Fatalf("expected %d, got %d", testCase.wantStatus, rr.Code)
+			}
+			sessionDeleted := false
+			for _, cookie := range rr.Result().Cookies() {
+				if cookie.Name == constants.SessionName && cookie.MaxAge < 0 {
+					sessionDeleted = true
+				}
+			}
+			if sessionDeleted != testCase.wantDeletion {
+				t.Fatalf("expected session deletion %t, got %t", testCase.wantDeletion, sessionDeleted)
+			}
+		})
+	}
+}
+
+func TestSessionBindingRejectsUnboundSessions(t *testing.T) {
+	unboundHandlers := newTestHandlers(t)
+	cookies := loginWithBinding(t, unboundHandlers, "192.0.2.1:1234", "browser/1")
+
+	boundHandlers := newTestHandlers(t, WithSessionBinding(BindUserAgent))
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.Header.Set("User-Agent", "browser/1")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if _, authenticated := boundHandlers.service.AuthenticatedUser(httptest.NewRecorder(), req); authenticated {
+		t.Fatal("expected a session without binding values to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package gauss
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// maxLastSeenGranularity bounds how stale the last-seen time of a session may
+// get before it is rewritten, so an active session does not rewrite its
+// cookie on every request.
+const maxLastSeenGranularity = time.Minute
+
+// WithIdleTimeout returns a ServiceOption that signs users out once their
+// session has been unused for longer than idleTimeout. NewAuthMiddleware and
+// Service.AuthenticatedUser record the time of each authenticated request in
+// the session, at most once per minute or per tenth of idleTimeout, whichever
+// is shorter, and refuse a session whose last request is older than
+// idleTimeout: its token and profile are removed and NewAuthMiddleware sends
+// the user to the login page with error=session_expired. Sessions created
+// before the timeout was enabled count as expired. Zero or negative values
+// disable the timeout, which is the default.
+func WithIdleTimeout(idleTimeout time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.idleTimeout = idleTimeout
+	}
+}
+
+// touchSession reports whether webSession was used within the idle timeout
+// and, if so, records the current time as its last use once the stored time
+// is older than the granularity window.
+func (serviceInstance *Service) touchSession(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) bool {
+	lastSeenUnixTime, stored := webSession.Values[constants.SessionKeyLastSeen].(int64)
+	if !stored {
+		return false
+	}
+	currentTime := serviceInstance.now()
+	idleDuration := currentTime.Sub(time.Unix(lastSeenUnixTime, 0))
+	if idleDuration > serviceInstance.idleTimeout {
+		return false
+	}
+	if idleDuration < min(maxLastSeenGranularity, serviceInstance.idleTimeout/10) {
+		return true
+	}
+	webSession.Values[constants.SessionKeyLastSeen] = currentTime.Unix()
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to record session activity: %v", sessionSaveError)
+	}
+	return true
+}
+
+// sessionExpiredLoginURL returns the login page URL reporting
+// ErrCodeSessionExpired, carrying the URL of a GET or HEAD request in the
+// next query parameter.
+func (serviceInstance *Service) sessionExpiredLoginURL(request *http.Request) string {
+	errorQuery := url.Values{
+		queryParameterError:     {string(ErrCodeSessionExpired)},
+		queryParameterErrorCode: {string(ErrCodeSessionExpired)},
+	}
+	loginURL := serviceInstance.mountedURL(request, serviceInstance.loginPath+"?"+errorQuery.Encode())
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return loginURL
+	}
+	returnTo, _ := serviceInstance.validReturnTo(originalRequestURI(request))
+	return withReturnTo(loginURL, returnTo)
+}
+
+// WithMaxSessionLifetime returns a ServiceOption that signs users out once
+// maxLifetime has passed since they authenticated, however active the
+// session is. The login time is stored under
+// constants.SessionKeyAuthenticatedAt and exposed as User.LoginTime.
+// NewAuthMiddleware and Service.AuthenticatedUser refuse an older session
+// like WithIdleTimeout refuses an idle one: its token and profile are removed
+// and NewAuthMiddleware sends the user to the login page with
+// error=session_expired. Sessions without a login time count as expired. Zero
+// or negative values disable the limit, which is the default.
+func WithMaxSessionLifetime(maxLifetime time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.maxSessionLifetime = maxLifetime
+	}
+}
+
+// sessionWithinLifetime reports whether webSession was authenticated no
+// longer than the maximum session lifetime ago.
+func (serviceInstance *Service) sessionWithinLifetime(webSession *sessions.Session) bool {
+	authenticatedUnixTime, stored := webSession.Values[constants.SessionKeyAuthenticatedAt].(int64)
+	if !stored {
+		return false
+	}
+	return serviceInstance.now().Sub(time.Unix(authenticatedUnixTime, 0)) <= serviceInstance.maxSessionLifetime
+}
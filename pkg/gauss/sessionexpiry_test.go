@@ -0,0 +1,212 @@
+package gauss
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// idleSessionCookies returns the cookies of a logged-in session whose last
+// activity was at lastSeen, or that records none when lastSeen is zero.
+func idleSessionCookies(t *testing.T, lastSeen time.Time) []*http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	initRR := httptest.NewRecorder()
+	sess, _ := session.Store().Get(req, constants.SessionName)
+	sess.Values[constants.SessionKeyUserEmail] = "e@example.com"
+	sess.Values[constants.SessionKeyOAuthToken] = `{"access_token":"abc"}`
+	if !lastSeen.IsZero() {
+		sess.Values[constants.SessionKeyLastSeen] = lastSeen.Unix()
+	}
+	if err := sess.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	return initRR.Result().Cookies()
+}
+
+// storedLastSeen returns the last-seen time stored in the session carried by
+// cookies.
+func storedLastSeen(t *testing.T, cookies []*http.Cookie) (time.Time, bool) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	sess, _ := session.Store().Get(req, constants.SessionName)
+	lastSeenUnixTime, stored := sess.Values[constants.SessionKeyLastSeen].(int64)
+	return time.Unix(lastSeenUnixTime, 0), stored
+}
+
+// okTestHandler answers every request with 200 OK.
+func okTestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+}
+
+func TestIdleTimeout(t *testing.T) {
+	currentTime := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name             string
+		idleFor          time.Duration
+		noLastSeen       bool
+		expectedStatus   int
+		expectedLocation string
+		expectRewrite    bool
+	}{
+		{name: "recent activity passes without rewriting the cookie", idleFor: 30 * time.Second, expectedStatus: http.StatusOK},
+		{name: "older activity slides the timeout", idleFor: 20 * time.Minute, expectedStatus: http.StatusOK, expectRewrite: true},
+		{name: "idle session expires", idleFor: 31 * time.Minute, expectedStatus: http.StatusFound, expectedLocation: "/login?error=session_expired&error_code=session_expired&next=%2Freports"},
+		{name: "session without activity expires", noLastSeen: true, expectedStatus: http.StatusFound, expectedLocation: "/login?error=session_expired&error_code=session_expired&next=%2Freports"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithIdleTimeout(30*time.Minute), WithAutoLogin())
+			h.service.now = func() time.Time { return currentTime }
+			lastSeen := currentTime.Add(-testCase.idleFor)
+			if testCase.noLastSeen {
+				lastSeen = time.Time{}
+			}
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			for _, cookie := range idleSessionCookies(t, lastSeen) {
+				req.AddCookie(cookie)
+			}
+			rr := httptest.NewRecorder()
+			NewAuthMiddleware(h.service)(okTestHandler()).ServeHTTP(rr, req)
+
+			if rr.Code != testCase.expectedStatus || rr.Header().Get("Location") != testCase.expectedLocation {
+				t.Fatalf("expected %d %q, got %d %q", testCase.expectedStatus, testCase.expectedLocation, rr.Code, rr.Header().Get("Location"))
+			}
+			responseCookies := rr.Result().Cookies()
+			switch {
+			case testCase.expectedStatus == http.StatusFound:
+				followUp := httptest.NewRequest(http.MethodGet, "/reports", nil)
+				for _, cookie := range responseCookies {
+					followUp.AddCookie(cookie)
+				}
+				if _, authenticated := SessionUser(followUp); authenticated {
+					t.Fatal("expected the idle session to be signed out")
+				}
+			case testCase.expectRewrite:
+				if renewed, stored := storedLastSeen(t, responseCookies); !stored || !renewed.Equal(currentTime) {
+					t.Fatalf("expected the last-seen time to move to %v, got %v", currentTime, renewed)
+				}
+			case len(responseCookies) != 0:
+				t.Fatal("expected the cookie not to be rewritten within the granularity window")
+			}
+		})
+	}
+}
+
+func TestIdleTimeoutSlidesWithActivity(t *testing.T) {
+	currentTime := time.Unix(1700000000, 0)
+	h := newTestHandlers(t, WithIdleTimeout(30*time.Minute))
+	h.service.now = func() time.Time { return currentTime }
+	cookies := idleSessionCookies(t, currentTime)
+	protected := NewAuthMiddleware(h.service)(okTestHandler())
+
+	for _, gap := range []time.Duration{20 * time.Minute, 25 * time.Minute, 29 * time.Minute, 31 * time.Minute} {
+		currentTime = currentTime.Add(gap)
+		req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		protected.ServeHTTP(rr, req)
+		expectedStatus := http.StatusOK
+		if gap > 30*time.Minute {
+			expectedStatus = http.StatusFound
+		}
+		if rr.Code != expectedStatus {
+			t.Fatalf("after %s idle: expected status %d, got %d", gap, expectedStatus, rr.Code)
+		}
+		if responseCookies := rr.Result().Cookies(); len(responseCookies) > 0 {
+			cookies = responseCookies
+		}
+	}
+}
+
+func TestCallbackRecordsLastSeen(t *testing.T) {
+	h := newTestHandlers(t, WithIdleTimeout(time.Hour))
+	loginTime := time.Unix(1700000000, 0)
+	h.service.now = func() time.Time { return loginTime }
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s1&code=c1", nil)
+	seedState(t, req, "s1")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	if lastSeen, stored := storedLastSeen(t, rr.Result().Cookies()); !stored || !lastSeen.Equal(loginTime) {
+		t.Fatalf("expected the login to record activity at %v, got %v", loginTime, lastSeen)
+	}
+}
+
+func TestMaxSessionLifetime(t *testing.T) {
+	loginTime := time.Unix(1700000000, 0)
+	currentTime := loginTime
+	h := newTestHandlers(t, WithMaxSessionLifetime(12*time.Hour), WithIdleTimeout(time.Hour))
+	h.service.now = func() time.Time { return currentTime }
+	cookies := loggedInRequest(t, loginTime).Cookies()
+	var seenUsers []*User
+	protected := NewAuthMiddleware(h.service)(contextUserRecorder(&seenUsers))
+
+	for _, elapsed := range []time.Duration{time.Hour, 6 * time.Hour, 11*time.Hour + 59*time.Minute, 12*time.Hour + time.Minute} {
+		currentTime = loginTime.Add(elapsed)
+		req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		sess, _ := session.Store().Get(req, constants.SessionName)
+		sess.Values[constants.SessionKeyLastSeen] = currentTime.Unix()
+		rr := httptest.NewRecorder()
+		if err := sess.Save(req, rr); err != nil {
+			t.Fatal(err)
+		}
+		activeReq := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		for _, cookie := range rr.Result().Cookies() {
+			activeReq.AddCookie(cookie)
+		}
+		seenUsers = nil
+		rr = httptest.NewRecorder()
+		protected.ServeHTTP(rr, activeReq)
+
+		if elapsed <= 12*time.Hour {
+			if len(seenUsers) != 1 || seenUsers[0] == nil || !seenUsers[0].LoginTime.Equal(loginTime) {
+				t.Fatalf("after %s: expected the user logged in since %v, got %+v", elapsed, loginTime, seenUsers)
+			}
+			continue
+		}
+		expectedLocation := "/login?error=session_expired&error_code=session_expired&next=%2Freports"
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != expectedLocation {
+			t.Fatalf("after %s: expected a redirect to %q, got %d %q", elapsed, expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+		followUp := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		for _, cookie := range rr.Result().Cookies() {
+			followUp.AddCookie(cookie)
+		}
+		if _, authenticated := SessionUser(followUp); authenticated {
+			t.Fatal("expected the session to be signed out once its lifetime passed")
+		}
+	}
+}
+
+func TestMaxSessionLifetimeRejectsSessionWithoutLoginTime(t *testing.T) {
+	h := newTestHandlers(t, WithMaxSessionLifetime(12*time.Hour))
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	for _, cookie := range idleSessionCookies(t, time.Time{}) {
+		req.AddCookie(cookie)
+	}
+	if _, authenticated := h.service.AuthenticatedUser(httptest.NewRecorder(), req); authenticated {
+		t.Fatal("expected a session without a login time to count as expired")
+	}
+}
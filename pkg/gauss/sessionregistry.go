@@ -0,0 +1,225 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// ActiveSession describes a login session recorded in a SessionRegistry.
+type ActiveSession struct {
+	// ID is the value stored under constants.SessionKeySessionID.
+	ID        string
+	CreatedAt time.Time
+	LastUsed  time.Time
+}
+
+// SessionRegistry records the sessions each user has open so that
+// WithMaxConcurrentSessions can count and evict them. Because session cookies
+// are self-contained, a session removed from the registry is rejected by
+// NewAuthMiddleware even though its cookie remains valid. Implementations
+// must be safe for concurrent use; a database backed registry lets several
+// instances share the limit.
+type SessionRegistry interface {
+	// Register records a new session for userID.
+	Register(ctx context.Context, userID string, activeSession ActiveSession) error
+	// Sessions returns the sessions registered for userID.
+	Sessions(ctx context.Context, userID string) ([]ActiveSession, error)
+	// Touch records that the session was used at usedAt. The boolean result
+	// is false when the session is not registered.
+	Touch(ctx context.Context, userID string, sessionID string, usedAt time.Time) (bool, error)
+	// Remove deletes the session. Removing an unknown session is not an error.
+	Remove(ctx context.Context, userID string, sessionID string) error
+}
+
+// SessionEvictionPolicy decides what happens when a user who already has the
+// maximum number of sessions logs in again.
+type SessionEvictionPolicy int
+
+const (
+	// SessionEvictionLRU removes the least recently used sessions to make
+	// room for the new one. It is the default.
+	SessionEvictionLRU SessionEvictionPolicy = iota
+	// SessionEvictionBlockNew rejects the new login with ErrCodeSessionLimit.
+	SessionEvictionBlockNew
+)
+
+// WithMaxConcurrentSessions returns a ServiceOption that limits every user to
+// limit active sessions, identified by GoogleUser.ID. Sessions are tracked in
+// the registry set with WithSessionRegistry, or in memory for this process
+// when none is set. Logins without a user ID are not limited. NewService
+// rejects a non-positive limit.
+func WithMaxConcurrentSessions(limit int) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.maxConcurrentSessions = limit
+		serviceInstance.maxConcurrentSessionsSet = true
+	}
+}
+
+// WithSessionRegistry returns a ServiceOption that tracks sessions for
+// WithMaxConcurrentSessions in registry. NewService returns an error when it is
+// used without WithMaxConcurrentSessions.
+func WithSessionRegistry(registry SessionRegistry) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionRegistry = registry
+	}
+}
+
+// WithSessionEvictionPolicy returns a ServiceOption that selects what
+// WithMaxConcurrentSessions does when the limit is reached.
+func WithSessionEvictionPolicy(policy SessionEvictionPolicy) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionEvictionPolicy = policy
+	}
+}
+
+// configureSessionRegistry validates the concurrent session settings and
+// installs the default registry.
+func (serviceInstance *Service) configureSessionRegistry() error {
+	if !serviceInstance.maxConcurrentSessionsSet {
+		if serviceInstance.sessionRegistry != nil {
+			return errors.New("WithSessionRegistry requires WithMaxConcurrentSessions")
+		}
+		return nil
+	}
+	if serviceInstance.maxConcurrentSessions <= 0 {
+		return fmt.Errorf("invalid maximum concurrent sessions %d: must be positive", serviceInstance.maxConcurrentSessions)
+	}
+	if serviceInstance.sessionRegistry == nil {
+		serviceInstance.sessionRegistry = NewMemorySessionRegistry()
+	}
+	return nil
+}
+
+// registerSession enforces the concurrent session limit for userID and
+// registers the session held in webSession. It returns an AuthError when the
+// login has to be refused.
+func (serviceInstance *Service) registerSession(ctx context.Context, userID string, webSession *sessions.Session) *AuthError {
+	registry := serviceInstance.sessionRegistry
+	if registry == nil || userID == "" {
+		return nil
+	}
+	activeSessions, listError := registry.Sessions(ctx, userID)
+	if listError != nil {
+		return newAuthError(ErrCodeSessionSave, "Failed to list active sessions", listError)
+	}
+	if excessSessions := len(activeSessions) - serviceInstance.maxConcurrentSessions + 1; excessSessions > 0 {
+		if serviceInstance.sessionEvictionPolicy == SessionEvictionBlockNew {
+			return newAuthError(ErrCodeSessionLimit, "Maximum concurrent sessions reached", nil)
+		}
+		sort.Slice(activeSessions, func(first, second int) bool {
+			return activeSessions[first].LastUsed.Before(activeSessions[second].LastUsed)
+		})
+		for _, evictedSession := range activeSessions[:excessSessions] {
+			if removeError := registry.Remove(ctx, userID, evictedSession.ID); removeError != nil {
+				return newAuthError(ErrCodeSessionSave, "Failed to evict session", removeError)
+			}
+		}
+	}
+
+	currentTime := serviceInstance.now()
+	sessionID, _ := webSession.Values[constants.SessionKeySessionID].(string)
+	activeSession := ActiveSession{ID: sessionID, CreatedAt: currentTime, LastUsed: currentTime}
+	if registerError := registry.Register(ctx, userID, activeSession); registerError != nil {
+		return newAuthError(ErrCodeSessionSave, "Failed to register session", registerError)
+	}
+	return nil
+}
+
+// sessionRegistered reports whether the session held in webSession is still
+// registered for user, recording the use. It always reports true when no
+// limit is configured.
+func (serviceInstance *Service) sessionRegistered(request *http.Request, webSession *sessions.Session, user *GoogleUser) bool {
+	registry := serviceInstance.sessionRegistry
+	if registry == nil || user.ID == "" {
+		return true
+	}
+	sessionID, _ := webSession.Values[constants.SessionKeySessionID].(string)
+	registered, touchError := registry.Touch(request.Context(), user.ID, sessionID, serviceInstance.now())
+	if touchError != nil {
+		logRequestf(request, "Failed to check the session registry: %v", touchError)
+		return false
+	}
+	return registered
+}
+
+// unregisterSession removes the session held in webSession from the registry.
+func (serviceInstance *Service) unregisterSession(request *http.Request, webSession *sessions.Session) {
+	registry := serviceInstance.sessionRegistry
+	if registry == nil {
+		return
+	}
+	userID, _ := webSession.Values[constants.SessionKeyUserID].(string)
+	sessionID, _ := webSession.Values[constants.SessionKeySessionID].(string)
+	if userID == "" || sessionID == "" {
+		return
+	}
+	if removeError := registry.Remove(request.Context(), userID, sessionID); removeError != nil {
+		logRequestf(request, "Failed to remove session from the registry: %v", removeError)
+	}
+}
+
+// MemorySessionRegistry is a SessionRegistry that keeps sessions in memory. It
+// only limits sessions served by the current process.
+type MemorySessionRegistry struct {
+	mutex    sync.Mutex
+	sessions map[string][]ActiveSession
+}
+
+// NewMemorySessionRegistry returns an empty MemorySessionRegistry.
+func NewMemorySessionRegistry() *MemorySessionRegistry {
+	return &MemorySessionRegistry{sessions: make(map[string][]ActiveSession)}
+}
+
+// Register records a new session for userID.
+func (registry *MemorySessionRegistry) Register(ctx context.Context, userID string, activeSession ActiveSession) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.sessions[userID] = append(registry.sessions[userID], activeSession)
+	return nil
+}
+
+// Sessions returns a copy of the sessions registered for userID.
+func (registry *MemorySessionRegistry) Sessions(ctx context.Context, userID string) ([]ActiveSession, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return append([]ActiveSession(nil), registry.sessions[userID]...), nil
+}
+
+// Touch records that the session was used at usedAt.
+func (registry *MemorySessionRegistry) Touch(ctx context.Context, userID string, sessionID string, usedAt time.Time) (bool, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for sessionIndex := range registry.sessions[userID] {
+		if registry.sessions[userID][sessionIndex].ID == sessionID {
+			registry.sessions[userID][sessionIndex].LastUsed = usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remove deletes the session.
+func (registry *MemorySessionRegistry) Remove(ctx context.Context, userID string, sessionID string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	remainingSessions := registry.sessions[userID][:0]
+	for _, activeSession := range registry.sessions[userID] {
+		if activeSession.ID != sessionID {
+			remainingSessions = append(remainingSessions, activeSession)
+		}
+	}
+	if len(remainingSessions) == 0 {
+		delete(registry.sessions, userID)
+		return nil
+	}
+	registry.sessions[userID] = remainingSessions
+	return nil
+}
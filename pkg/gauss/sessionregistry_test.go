@@ -0,0 +1,135 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// loginAsUser completes a callback against the mock Google server and returns
+// the recorder.
+func loginAsUser(t *testing.T, h *Handlers) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s123&code=c1", nil)
+	seedState(t, req, "s123")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+	return rr
+}
+
+// useMockGoogleUser serves a verified profile with Google ID "42".
+func useMockGoogleUser(t *testing.T, h *Handlers) {
+	t.Helper()
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":"42","email":"e@example.com","verified_email":true}`))
+		},
+	)
+}
+
+// sessionIsActive reports whether NewAuthMiddleware lets a request carrying
+// the cookies set by rr through.
+func sessionIsActive(h *Handlers, rr *httptest.ResponseRecorder) bool {
+	protected := NewAuthMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	checkRecorder := httptest.NewRecorder()
+	protected.ServeHTTP(checkRecorder, requestWithCookies("/dashboard", lastCookies(rr)))
+	return checkRecorder.Code == http.StatusOK
+}
+
+func TestMaxConcurrentSessionsEvictsLeastRecentlyUsed(t *testing.T) {
+	h := newTestHandlers(t, WithMaxConcurrentSessions(2))
+	currentTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.service.now = func() time.Time { return currentTime }
+	useMockGoogleUser(t, h)
+
+	firstLogin := loginAsUser(t, h)
+	currentTime = currentTime.Add(time.Minute)
+	secondLogin := loginAsUser(t, h)
+	currentTime = currentTime.Add(time.Minute)
+	if !sessionIsActive(h, firstLogin) {
+		t.Fatal("expected the first session to be active")
+	}
+
+	currentTime = currentTime.Add(time.Minute)
+	thirdLogin := loginAsUser(t, h)
+	if location := thirdLogin.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected the third login to succeed, got %s", location)
+	}
+	if sessionIsActive(h, secondLogin) {
+		t.Fatal("expected the least recently used session to be evicted")
+	}
+	if !sessionIsActive(h, firstLogin) || !sessionIsActive(h, thirdLogin) {
+		t.Fatal("expected the recently used sessions to remain active")
+	}
+}
+
+func TestMaxConcurrentSessionsBlockNew(t *testing.T) {
+	h := newTestHandlers(t, WithMaxConcurrentSessions(1), WithSessionEvictionPolicy(SessionEvictionBlockNew))
+	useMockGoogleUser(t, h)
+
+	firstLogin := loginAsUser(t, h)
+	assertErrorRedirect(t, loginAsUser(t, h), ErrCodeSessionLimit)
+	if !sessionIsActive(h, firstLogin) {
+		t.Fatal("expected the existing session to remain active")
+	}
+}
+
+func TestLogoutUnregistersSession(t *testing.T) {
+	registry := NewMemorySessionRegistry()
+	h := newTestHandlers(t, WithMaxConcurrentSessions(1), WithSessionRegistry(registry), WithSessionEvictionPolicy(SessionEvictionBlockNew))
+	useMockGoogleUser(t, h)
+
+	login := loginAsUser(t, h)
+	h.Logout(httptest.NewRecorder(), requestWithCookies(constants.LogoutPath, lastCookies(login)))
+
+	if activeSessions, _ := registry.Sessions(context.Background(), "42"); len(activeSessions) != 0 {
+		t.Fatalf("expected logout to unregister the session, got %v", activeSessions)
+	}
+	if location := loginAsUser(t, h).Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected a new login after logout, got %s", location)
+	}
+}
+
+func TestSessionIDChangesWithLogin(t *testing.T) {
+	h := newTestHandlers(t)
+	useMockGoogleUser(t, h)
+	firstID, firstError := session.SessionID(requestWithCookies("/", lastCookies(loginAsUser(t, h))))
+	secondID, secondError := session.SessionID(requestWithCookies("/", lastCookies(loginAsUser(t, h))))
+	if firstError != nil || secondError != nil {
+		t.Fatalf("unexpected errors %v, %v", firstError, secondError)
+	}
+	if firstID == "" || firstID == secondID {
+		t.Fatalf("expected a distinct identifier per login, got %q and %q", firstID, secondID)
+	}
+	if anonymousID, _ := session.SessionID(httptest.NewRequest(http.MethodGet, "/", nil)); anonymousID != "" {
+		t.Fatalf("expected no identifier without a session, got %q", anonymousID)
+	}
+}
+
+func TestSessionRegistryOptionValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []ServiceOption
+	}{
+		{name: "non-positive limit", options: []ServiceOption{WithMaxConcurrentSessions(0)}},
+		{name: "registry without limit", options: []ServiceOption{WithSessionRegistry(NewMemorySessionRegistry())}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", testCase.options...); err == nil {
+				t.Fatal("expected NewService to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package gauss
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// WithSessionNamespace returns a ServiceOption that names the session cookie
+// of the Service sessionName instead of the name configured in pkg/session,
+// so several Services, for example with different Google clients for admins
+// and regular users, keep separate sessions in one application. Every
+// handler and middleware of the Service uses the name. NewService rejects
+// names that are not valid cookie names; an empty name keeps the default.
+func WithSessionNamespace(sessionName string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionNamespace = strings.TrimSpace(sessionName)
+	}
+}
+
+// WithSessionStore returns a ServiceOption that keeps the sessions of the
+// Service in sessionStore, for example a store returned by
+// session.NewSQLStore or a CookieStore with keys of its own, instead of the
+// package-level store of pkg/session. A non-empty sessionName names the
+// session like WithSessionNamespace. A nil store keeps the package-level
+// store.
+func WithSessionStore(sessionStore sessions.Store, sessionName string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.sessionStore = sessionStore
+		if trimmedName := strings.TrimSpace(sessionName); trimmedName != "" {
+			serviceInstance.sessionNamespace = trimmedName
+		}
+	}
+}
+
+// validateSessionNamespace reports an error when the name set with
+// WithSessionNamespace or WithSessionStore cannot name a cookie.
+func (serviceInstance *Service) validateSessionNamespace() error {
+	if serviceInstance.sessionNamespace == "" {
+		return nil
+	}
+	if cookieError := (&http.Cookie{Name: serviceInstance.sessionNamespace}).Valid(); cookieError != nil {
+		return fmt.Errorf("invalid session namespace %q: %w", serviceInstance.sessionNamespace, cookieError)
+	}
+	return nil
+}
+
+// sessionCookieName returns the name set with WithSessionNamespace or
+// WithSessionStore, or the name configured in pkg/session.
+func (serviceInstance *Service) sessionCookieName() string {
+	if serviceInstance.sessionNamespace != "" {
+		return serviceInstance.sessionNamespace
+	}
+	return session.Name()
+}
+
+// bindSessionStore fixes the store and session name the Service reads and
+// writes sessions with. A Service without WithSessionStore is bound to the
+// package-level store of pkg/session configured when NewHandlers runs.
+func (serviceInstance *Service) bindSessionStore() {
+	if serviceInstance.sessionStore == nil {
+		serviceInstance.sessionStore = session.Store()
+	}
+	serviceInstance.sessionName = serviceInstance.sessionCookieName()
+}
+
+// webSession returns the session of request from the store set with
+// WithSessionStore or bound by NewHandlers, or from the package-level store
+// of pkg/session for a Service used without either.
+func (serviceInstance *Service) webSession(request *http.Request) *sessions.Session {
+	sessionStore := serviceInstance.sessionStore
+	if sessionStore == nil {
+		sessionStore = session.Store()
+	}
+	sessionName := serviceInstance.sessionName
+	if sessionName == "" {
+		sessionName = serviceInstance.sessionCookieName()
+	}
+	webSession, _ := sessionStore.Get(request, sessionName)
+	return webSession
+}
+
+// sessionUser is SessionUser reading the session of request from the
+// Service's store.
+func (serviceInstance *Service) sessionUser(request *http.Request) (*GoogleUser, bool) {
+	return userFromSession(serviceInstance.webSession(request))
+}
+
+// Middleware returns the middleware of NewAuthMiddleware bound to the
+// Service of these Handlers, and so to the session store, cookie name or
+// WithSessionNamespace and login path they were created with. Unlike the package-level
+// AuthMiddleware it keeps working when several Handlers with different
+// session configurations run in one process.
+func (handlersInstance *Handlers) Middleware(middlewareOptions ...MiddlewareOption) func(http.Handler) http.Handler {
+	return NewAuthMiddleware(handlersInstance.service, middlewareOptions...)
+}
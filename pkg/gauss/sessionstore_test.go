@@ -0,0 +1,118 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// newHandlersWithSession creates Handlers whose Service keeps its sessions in
+// a cookie store keyed with authKey under cookieName.
+func newHandlersWithSession(t *testing.T, authKey string, cookieName string, loginPath string) *Handlers {
+	t.Helper()
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "",
+		WithLoginPath(loginPath), WithSessionStore(sessions.NewCookieStore([]byte(authKey)), cookieName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers
+}
+
+// loggedInCookies returns the cookies of a logged-in session saved with the
+// store of handlers.
+func loggedInCookies(t *testing.T, handlers *Handlers) []*http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	initRR := httptest.NewRecorder()
+	sess, _ := handlers.store.Get(req, handlers.sessionName)
+	sess.Values[constants.SessionKeyUserEmail] = "e@example.com"
+	if err := sess.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	return initRR.Result().Cookies()
+}
+
+func TestHandlersMiddlewareHonorsOwnSession(t *testing.T) {
+	firstHandlers := newHandlersWithSession(t, "first-secret", "first_session", "/first/login")
+	secondHandlers := newHandlersWithSession(t, "second-secret", "second_session", "/second/login")
+	handlersByName := map[string]*Handlers{"first": firstHandlers, "second": secondHandlers}
+
+	testCases := []struct {
+		name             string
+		middleware       string
+		cookies          string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "first accepts its cookie", middleware: "first", cookies: "first", expectedStatus: http.StatusOK},
+		{name: "first ignores the second cookie", middleware: "first", cookies: "second", expectedStatus: http.StatusFound, expectedLocation: "/first/login?next=%2Freports"},
+		{name: "second accepts its cookie", middleware: "second", cookies: "second", expectedStatus: http.StatusOK},
+		{name: "second ignores the first cookie", middleware: "second", cookies: "first", expectedStatus: http.StatusFound, expectedLocation: "/second/login?next=%2Freports"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := requestWithCookies("/reports", loggedInCookies(t, handlersByName[testCase.cookies]))
+			var seenUsers []*User
+			rr := httptest.NewRecorder()
+			handlersByName[testCase.middleware].Middleware()(contextUserRecorder(&seenUsers)).ServeHTTP(rr, req)
+			if testCase.expectedStatus == http.StatusOK {
+				if len(seenUsers) != 1 || seenUsers[0] == nil || seenUsers[0].Email != "e@example.com" {
+					t.Fatalf("expected the user to be let through, got %d %+v", rr.Code, seenUsers)
+				}
+				return
+			}
+			if rr.Code != testCase.expectedStatus || rr.Header().Get("Location") != testCase.expectedLocation {
+				t.Fatalf("expected %d %q, got %d %q", testCase.expectedStatus, testCase.expectedLocation, rr.Code, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestSessionNamespacesDoNotInterfere(t *testing.T) {
+	adminHandlers := newTestHandlers(t, WithSessionNamespace("admin_session"))
+	userHandlers := newTestHandlers(t, WithSessionNamespace("user_session"))
+	useMockGoogleUser(t, adminHandlers)
+
+	authStartRecorder := httptest.NewRecorder()
+	adminHandlers.Login(authStartRecorder, httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	googleURL, _ := url.Parse(authStartRecorder.Header().Get("Location"))
+	callbackRecorder := httptest.NewRecorder()
+	adminHandlers.Callback(callbackRecorder, requestWithCookies(constants.CallbackPath+"?code=c1&state="+url.QueryEscape(googleURL.Query().Get("state")), lastCookies(authStartRecorder)))
+	adminCookies := lastCookies(callbackRecorder)
+	for _, cookie := range adminCookies {
+		if cookie.Name != "admin_session" {
+			t.Fatalf("expected only the admin_session cookie, got %q", cookie.Name)
+		}
+	}
+
+	if !sessionIsActive(adminHandlers, callbackRecorder) {
+		t.Fatal("expected the admin service to accept its session")
+	}
+	if sessionIsActive(userHandlers, callbackRecorder) {
+		t.Fatal("expected the user service to ignore the admin session")
+	}
+
+	logoutRecorder := httptest.NewRecorder()
+	userHandlers.Logout(logoutRecorder, requestWithCookies(constants.LogoutPath, adminCookies))
+	for _, cookie := range logoutRecorder.Result().Cookies() {
+		if cookie.Name == "admin_session" {
+			t.Fatal("expected logging out of the user service to leave the admin session alone")
+		}
+	}
+}
+
+func TestWithSessionNamespaceRejectsInvalidNames(t *testing.T) {
+	for _, option := range []ServiceOption{WithSessionNamespace("admin session"), WithSessionStore(sessions.NewCookieStore([]byte("key")), "admin session")} {
+		if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", option); err == nil {
+			t.Fatal("expected NewService to reject a session name that is not a cookie name")
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Toggle between light and dark classes on <body> using the checked/unchecked
+// state of the theme switch on the login page, and remember the choice.
+document.addEventListener('DOMContentLoaded', () => {
+    const themeSwitch = document.getElementById('theme-switch');
+    const savedTheme = localStorage.getItem('theme') || 'light';
+    document.body.className = savedTheme;
+    if (!themeSwitch) {
+        return;
+    }
+    themeSwitch.checked = savedTheme === 'dark';
+    themeSwitch.addEventListener('change', () => {
+        const isDark = themeSwitch.checked;
+        document.body.classList.toggle('dark', isDark);
+        document.body.classList.toggle('light', !isDark);
+        localStorage.setItem('theme', isDark ? 'dark' : 'light');
+    });
+});
@@ -0,0 +1,186 @@
+package gauss
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// WithTemplateFS returns a ServiceOption that loads the login templates
+// from fsys using template.ParseFS with the given glob patterns, which allows
+// templates embedded with go:embed to be used. Unless WithLoginTemplateName
+// is used, the first file matching the first pattern renders the login page.
+// NewService returns an error when a login template path is also passed or no
+// pattern is given.
+func WithTemplateFS(fsys fs.FS, patterns ...string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.templateFileSystem = fsys
+		serviceInstance.templatePatterns = append([]string(nil), patterns...)
+	}
+}
+
+// WithLoginTemplateName returns a ServiceOption that selects the parsed
+// template that renders the login page, such as a template defined with
+// {{define}} or one of several files matched by WithTemplateFS.
+// NewHandlers returns an error when no template has that name.
+func WithLoginTemplateName(name string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.loginTemplateName = name
+	}
+}
+
+// WithTemplates returns a ServiceOption that renders the login page with the
+// template named loginTemplateName from parsedTemplates, an application's
+// already parsed template set, so the page can share its layouts and
+// partials. No templates are parsed by GAuss. NewService returns an error when
+// a login template path, WithTemplateFS or WithTemplateFuncMap is also
+// used, and NewHandlers when the set has no template with that name.
+func WithTemplates(parsedTemplates *template.Template, loginTemplateName string) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.injectedTemplates = parsedTemplates
+		serviceInstance.loginTemplateName = loginTemplateName
+	}
+}
+
+// WithTemplateReload returns a ServiceOption that re-parses the login
+// templates on every request to the login page, so edits to a template file
+// show up without restarting the server. Parse errors are written to the
+// response. It is meant for development only: it slows every page view and
+// exposes template errors to visitors.
+func WithTemplateReload() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.templateReload = true
+	}
+}
+
+// writeTemplateReloadError reports a template parse error found by
+// WithTemplateReload as a readable HTML page.
+func writeTemplateReloadError(responseWriter http.ResponseWriter, request *http.Request, parseError error) {
+	logRequestf(request, "Failed to reload login templates: %v", parseError)
+	responseWriter.Header().Set(headerContentType, contentTypeHTMLUTF8)
+	responseWriter.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(responseWriter, "<!DOCTYPE html><title>Login template error</title><h1>Login template error</h1><pre>%s</pre>", template.HTMLEscapeString(parseError.Error()))
+}
+
+// validateTemplateSource reports conflicting or incomplete login template
+// settings.
+func (serviceInstance *Service) validateTemplateSource() error {
+	if serviceInstance.injectedTemplates != nil {
+		switch {
+		case serviceInstance.LoginTemplate != "" || serviceInstance.templateFileSystem != nil:
+			return errors.New("WithTemplates cannot be combined with a login template path or file system")
+		case len(serviceInstance.templateFuncs) > 0:
+			return errors.New("WithTemplates cannot be combined with WithTemplateFuncMap; register functions on the injected templates")
+		case serviceInstance.loginTemplateName == "":
+			return errors.New("WithTemplates requires a login template name")
+		}
+		return nil
+	}
+	if serviceInstance.templateFileSystem == nil {
+		return nil
+	}
+	if serviceInstance.LoginTemplate != "" {
+		return errors.New("a login template path and a login template file system are mutually exclusive")
+	}
+	if len(serviceInstance.templatePatterns) == 0 {
+		return errors.New("a login template file system requires at least one pattern")
+	}
+	return nil
+}
+
+// builtInTemplateFuncNames lists the functions predefined by html/template.
+// WithTemplateFuncMap may not replace them.
+var builtInTemplateFuncNames = map[string]struct{}{
+	"and": {}, "call": {}, "html": {}, "index": {}, "slice": {}, "js": {}, "len": {}, "not": {}, "or": {},
+	"print": {}, "printf": {}, "println": {}, "urlquery": {}, "eq": {}, "ge": {}, "gt": {}, "le": {}, "lt": {}, "ne": {},
+}
+
+// WithTemplateFuncMap returns a ServiceOption that registers custom functions,
+// such as translation or asset path helpers, with the login templates before
+// they are parsed. It applies to both the embedded and custom templates and
+// may be passed more than once. NewService returns an error when a name
+// clashes with a function built into html/template or a value is not a
+// function.
+func WithTemplateFuncMap(funcs template.FuncMap) ServiceOption {
+	return func(serviceInstance *Service) {
+		if serviceInstance.templateFuncs == nil {
+			serviceInstance.templateFuncs = make(template.FuncMap, len(funcs))
+		}
+		for funcName, funcValue := range funcs {
+			serviceInstance.templateFuncs[funcName] = funcValue
+		}
+	}
+}
+
+// validateTemplateFuncs reports functions that html/template would reject or
+// that would shadow its built-in functions.
+func validateTemplateFuncs(funcs template.FuncMap) error {
+	funcNames := make([]string, 0, len(funcs))
+	for funcName := range funcs {
+		funcNames = append(funcNames, funcName)
+	}
+	sort.Strings(funcNames)
+	for _, funcName := range funcNames {
+		if _, builtIn := builtInTemplateFuncNames[funcName]; builtIn {
+			return fmt.Errorf("template function %q conflicts with a built-in function", funcName)
+		}
+		if reflect.ValueOf(funcs[funcName]).Kind() != reflect.Func {
+			return fmt.Errorf("template function %q is not a function", funcName)
+		}
+	}
+	return nil
+}
+
+// parseLoginTemplates parses the login templates from the configured source and
+// returns them together with the name of the template that renders the login
+// page.
+func (serviceInstance *Service) parseLoginTemplates() (*template.Template, string, error) {
+	parsedTemplates, loginTemplateName, parseError := serviceInstance.parseTemplateSource()
+	if parseError != nil {
+		return nil, "", parseError
+	}
+	if serviceInstance.loginTemplateName != "" {
+		loginTemplateName = serviceInstance.loginTemplateName
+	}
+	if parsedTemplates.Lookup(loginTemplateName) == nil {
+		return nil, "", fmt.Errorf("login template %q not found", loginTemplateName)
+	}
+	return parsedTemplates, loginTemplateName, nil
+}
+
+// parseTemplateSource returns the templates injected with WithTemplates or
+// parses them from the file system configured with WithTemplateFS, the
+// login template path or the embedded templates, returning the name of the
+// default login template for that source.
+func (serviceInstance *Service) parseTemplateSource() (*template.Template, string, error) {
+	if serviceInstance.injectedTemplates != nil {
+		return serviceInstance.injectedTemplates, serviceInstance.loginTemplateName, nil
+	}
+	baseTemplate := template.New("").Funcs(serviceInstance.templateFuncs)
+	switch {
+	case serviceInstance.templateFileSystem != nil:
+		matchingFiles, globError := fs.Glob(serviceInstance.templateFileSystem, serviceInstance.templatePatterns[0])
+		if globError != nil {
+			return nil, "", globError
+		}
+		if len(matchingFiles) == 0 {
+			return nil, "", fmt.Errorf("template: pattern matches no files: %#q", serviceInstance.templatePatterns[0])
+		}
+		parsedTemplates, parseError := baseTemplate.ParseFS(serviceInstance.templateFileSystem, serviceInstance.templatePatterns...)
+		return parsedTemplates, path.Base(matchingFiles[0]), parseError
+	case serviceInstance.LoginTemplate != "":
+		parsedTemplates, parseError := baseTemplate.ParseFiles(serviceInstance.LoginTemplate)
+		return parsedTemplates, filepath.Base(serviceInstance.LoginTemplate), parseError
+	default:
+		parsedTemplates, parseError := baseTemplate.ParseFS(templatesFileSystem, constants.TemplatesPath)
+		return parsedTemplates, constants.DefaultTemplateName, parseError
+	}
+}
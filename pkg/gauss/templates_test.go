@@ -0,0 +1,263 @@
+package gauss
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+//go:embed testdata/templates/*.html
+var testTemplateFiles embed.FS
+
+// renderLoginPage constructs handlers for the service and renders the login
+// page.
+func renderLoginPage(t *testing.T, svc *Service) *httptest.ResponseRecorder {
+	t.Helper()
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatalf("NewHandlers error: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	return rr
+}
+
+func TestTemplateFSRendersLoginPage(t *testing.T) {
+	templateFiles := fstest.MapFS{
+		"views/login.html": {Data: []byte(`<p>embedded login</p>`)},
+	}
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplateFS(templateFiles, "views/*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := renderLoginPage(t, svc)
+	if !strings.Contains(rr.Body.String(), "embedded login") {
+		t.Fatalf("expected template from file system, got %s", rr.Body.String())
+	}
+}
+
+func TestLoginTemplateFSRejectsTemplatePath(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "disk_login.html")
+	if err := os.WriteFile(templatePath, []byte(`<p>disk login</p>`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	templateFiles := fstest.MapFS{"login.html": {Data: []byte(`<p>fs login</p>`)}}
+	_, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath, WithTemplateFS(templateFiles, "*.html"))
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("expected a mutual exclusion error, got %v", err)
+	}
+}
+
+func TestLoginTemplateFSRequiresPattern(t *testing.T) {
+	templateFiles := fstest.MapFS{"login.html": {Data: []byte(`<p>fs login</p>`)}}
+	if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplateFS(templateFiles)); err == nil {
+		t.Fatal("expected an error without patterns")
+	}
+}
+
+func TestLoginTemplateFSWithEmbeddedFiles(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "",
+		WithTemplateFS(testTemplateFiles, "testdata/templates/layout.html", "testdata/templates/signin.html"),
+		WithLoginTemplateName("signin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := renderLoginPage(t, svc)
+	expected := `<html><body><a href="` + constants.GoogleAuthPath + `">embedded sign in</a></body></html>`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Fatalf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+func TestLoginTemplateNameFromMapFS(t *testing.T) {
+	templateFiles := fstest.MapFS{
+		"partials/footer.html": {Data: []byte(`{{ define "footer" }}<footer>help</footer>{{ end }}`)},
+		"pages/index.html":     {Data: []byte(`<p>index</p>`)},
+		"pages/login.html":     {Data: []byte(`<p>map login</p>{{ template "footer" }}`)},
+	}
+	testCases := []struct {
+		name         string
+		templateName string
+		wantBody     string
+	}{
+		{name: "first match by default", wantBody: "<p>index</p>"},
+		{name: "named template", templateName: "login.html", wantBody: "<p>map login</p><footer>help</footer>"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			session.NewSession([]byte("secret"))
+			svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "",
+				WithTemplateFS(templateFiles, "pages/*.html", "partials/*.html"),
+				WithLoginTemplateName(testCase.templateName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := renderLoginPage(t, svc)
+			if rr.Body.String() != testCase.wantBody {
+				t.Fatalf("expected %q, got %q", testCase.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoginTemplateNameMustExist(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithLoginTemplateName("missing.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHandlers(svc); err == nil {
+		t.Fatal("expected an error for an unknown login template name")
+	}
+}
+
+func TestTemplateFSWithoutMatchesFails(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplateFS(fstest.MapFS{}, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHandlers(svc); err == nil {
+		t.Fatal("expected an error when the pattern matches no files")
+	}
+}
+
+// applicationTemplates parses a template set with a shared layout, as an
+// application would at startup.
+func applicationTemplates(t *testing.T) *template.Template {
+	t.Helper()
+	return template.Must(template.New("app").Funcs(template.FuncMap{"brand": func() string { return "Acme" }}).Parse(`
+{{ define "layout" }}<header>{{ brand }}</header>{{ template "body" . }}<footer>shared footer</footer>{{ end }}
+{{ define "dashboard" }}dashboard{{ end }}
+{{ define "auth/login" }}{{ template "layout" . }}{{ end }}
+{{ define "body" }}<a href="{{ .googleAuthPath }}">Sign in</a>{{ .error }}{{ end }}`))
+}
+
+func TestWithTemplatesRendersLoginFromApplicationSet(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplates(applicationTemplates(t), "auth/login"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := renderLoginPage(t, svc)
+	expected := `<header>Acme</header><a href="` + constants.GoogleAuthPath + `">Sign in</a><footer>shared footer</footer>`
+	if rr.Body.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, rr.Body.String())
+	}
+}
+
+func TestWithTemplatesValidation(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "disk_login.html")
+	if err := os.WriteFile(templatePath, []byte(`<p>disk login</p>`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	testCases := []struct {
+		name         string
+		templatePath string
+		options      []ServiceOption
+	}{
+		{name: "missing name", options: []ServiceOption{WithTemplates(applicationTemplates(t), "")}},
+		{name: "with template path", templatePath: templatePath, options: []ServiceOption{WithTemplates(applicationTemplates(t), "auth/login")}},
+		{name: "with file system", options: []ServiceOption{
+			WithTemplates(applicationTemplates(t), "auth/login"),
+			WithTemplateFS(fstest.MapFS{"login.html": {Data: []byte(`x`)}}, "*.html"),
+		}},
+		{name: "with template funcs", options: []ServiceOption{
+			WithTemplates(applicationTemplates(t), "auth/login"),
+			WithTemplateFuncMap(template.FuncMap{"upper": strings.ToUpper}),
+		}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, testCase.templatePath, testCase.options...); err == nil {
+				t.Fatal("expected NewService to fail")
+			}
+		})
+	}
+}
+
+func TestWithTemplatesRequiresKnownName(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTemplates(applicationTemplates(t), "auth/missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHandlers(svc); err == nil {
+		t.Fatal("expected NewHandlers to reject an unknown template name")
+	}
+}
+
+func TestTemplateReloadPicksUpChanges(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "reload_login.html")
+	writeTemplate := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(templatePath, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+	writeTemplate(`<p>first version</p>`)
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath, WithTemplateReload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderPage := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+		return rr
+	}
+
+	if body := renderPage().Body.String(); body != "<p>first version</p>" {
+		t.Fatalf("expected the first version, got %q", body)
+	}
+	writeTemplate(`<p>second version</p>`)
+	if body := renderPage().Body.String(); body != "<p>second version</p>" {
+		t.Fatalf("expected the edited template, got %q", body)
+	}
+	writeTemplate(`<p>{{ .broken </p>`)
+	rr := renderPage()
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Login template error") {
+		t.Fatalf("expected a readable parse error, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTemplatesAreCachedWithoutReload(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "cached_login.html")
+	if err := os.WriteFile(templatePath, []byte(`<p>first version</p>`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	session.NewSession([]byte("secret"))
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, templatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandlers(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(templatePath, []byte(`<p>second version</p>`), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	h.loginHandler(rr, httptest.NewRequest(http.MethodGet, constants.LoginPath, nil))
+	if rr.Body.String() != "<p>first version</p>" {
+		t.Fatalf("expected the template parsed at startup, got %q", rr.Body.String())
+	}
+}
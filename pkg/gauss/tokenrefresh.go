@@ -0,0 +1,94 @@
+package gauss
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshMargin is how long before its expiry a stored token is
+// refreshed, so it does not expire while the next handler uses it.
+const tokenRefreshMargin = time.Minute
+
+// revokedGrantErrorCode is the token endpoint error Google returns when the
+// refresh token was revoked or has expired.
+const revokedGrantErrorCode = "invalid_grant"
+
+// NewTokenRefreshMiddleware returns middleware that refreshes the OAuth token
+// stored in the session once it has expired or is about to, using its refresh
+// token, and writes the new token back to the session before calling the next
+// handler, so handlers using GetClient keep working after the first hour.
+// When Google reports the grant as revoked, the token and profile are removed
+// from the session, as Disconnect does, and the request is sent to the login
+// page as unauthenticated. Other refresh failures also send the request to the
+// login page. Requests without a stored token, or with one that is still
+// valid, pass through unchanged.
+func NewTokenRefreshMiddleware(serviceInstance *Service) func(http.Handler) http.Handler {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			webSession := serviceInstance.webSession(request)
+			storedTokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string)
+			var storedToken oauth2.Token
+			if storedTokenJSON == "" || json.Unmarshal([]byte(storedTokenJSON), &storedToken) != nil || !serviceInstance.tokenExpiresWithin(&storedToken, tokenRefreshMargin) || storedToken.RefreshToken == "" {
+				nextHandler.ServeHTTP(responseWriter, request)
+				return
+			}
+
+			// Only the refresh token is passed, so the token source refreshes a
+			// token that is about to expire but still valid.
+			refreshedToken, refreshError := serviceInstance.config.TokenSource(serviceInstance.oauthContext(request.Context()), &oauth2.Token{RefreshToken: storedToken.RefreshToken}).Token()
+			if refreshError != nil {
+				logRequestf(request, "Failed to refresh OAuth token: %v", refreshError)
+				if grantRevoked(refreshError) {
+					serviceInstance.clearAuthSession(responseWriter, request, webSession)
+				}
+				http.Redirect(responseWriter, request, serviceInstance.LoginURL(request), http.StatusFound)
+				return
+			}
+			if refreshedToken.RefreshToken == "" {
+				refreshedToken.RefreshToken = storedToken.RefreshToken
+			}
+			tokenBytes, marshalError := json.Marshal(refreshedToken)
+			if marshalError != nil {
+				logRequestf(request, "Failed to marshal refreshed token: %v", marshalError)
+				nextHandler.ServeHTTP(responseWriter, request)
+				return
+			}
+			webSession.Values[constants.SessionKeyOAuthToken] = string(tokenBytes)
+			if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+				logRequestf(request, "Failed to save refreshed token: %v", sessionSaveError)
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// tokenExpiresWithin reports whether oauthToken has an expiry that has passed
+// or falls within margin.
+func (serviceInstance *Service) tokenExpiresWithin(oauthToken *oauth2.Token, margin time.Duration) bool {
+	return !oauthToken.Expiry.IsZero() && !serviceInstance.now().Add(margin).Before(oauthToken.Expiry)
+}
+
+// grantRevoked reports whether refreshError is Google's answer for a revoked
+// or expired refresh token.
+func grantRevoked(refreshError error) bool {
+	var retrieveError *oauth2.RetrieveError
+	return errors.As(refreshError, &retrieveError) && retrieveError.ErrorCode == revokedGrantErrorCode
+}
+
+// clearAuthSession removes the token and profile from webSession, as
+// Disconnect does, so later requests are treated as unauthenticated.
+func (serviceInstance *Service) clearAuthSession(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) {
+	serviceInstance.unregisterSession(request, webSession)
+	for _, sessionKey := range disconnectedSessionKeys {
+		delete(webSession.Values, sessionKey)
+	}
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to clear the session: %v", sessionSaveError)
+	}
+}
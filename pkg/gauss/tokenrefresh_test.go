@@ -0,0 +1,105 @@
+package gauss
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenRefreshMiddleware(t *testing.T) {
+	testCases := []struct {
+		name              string
+		expiry            time.Duration
+		tokenStatus       int
+		tokenError        string
+		expectedStatus    int
+		expectedAccess    string
+		expectedRefreshes int
+		expectCleared     bool
+	}{
+		{name: "valid token passes through", expiry: time.Hour, expectedStatus: http.StatusOK, expectedAccess: "old"},
+		{name: "expired token is refreshed", expiry: -time.Minute, tokenStatus: http.StatusOK, expectedStatus: http.StatusOK, expectedAccess: "new", expectedRefreshes: 1},
+		{name: "nearly expired token is refreshed", expiry: 30 * time.Second, tokenStatus: http.StatusOK, expectedStatus: http.StatusOK, expectedAccess: "new", expectedRefreshes: 1},
+		{name: "revoked grant clears the session", expiry: -time.Minute, tokenStatus: http.StatusBadRequest, tokenError: "invalid_grant", expectedStatus: http.StatusFound, expectedRefreshes: 1, expectCleared: true},
+		{name: "failed refresh redirects to login", expiry: -time.Minute, tokenStatus: http.StatusInternalServerError, tokenError: "server_error", expectedStatus: http.StatusFound, expectedRefreshes: 1},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t)
+			refreshCount := 0
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					refreshCount++
+					if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "rtok" {
+						t.Errorf("unexpected token request %v", r.Form)
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(testCase.tokenStatus)
+					if testCase.tokenStatus == http.StatusOK {
+						io.WriteString(w, `{"access_token":"new","token_type":"bearer","expires_in":3600}`)
+					} else {
+						io.WriteString(w, `{"error":"`+testCase.tokenError+`"}`)
+					}
+				},
+				func(w http.ResponseWriter, r *http.Request) {},
+			)
+
+			storedToken, _ := json.Marshal(&oauth2.Token{AccessToken: "old", TokenType: "bearer", RefreshToken: "rtok", Expiry: time.Now().Add(testCase.expiry)})
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			initRR := httptest.NewRecorder()
+			webSession, _ := session.Store().Get(req, session.Name())
+			webSession.Values[constants.SessionKeyOAuthToken] = string(storedToken)
+			webSession.Values[constants.SessionKeyUserEmail] = "e@example.com"
+			if err := webSession.Save(req, initRR); err != nil {
+				t.Fatal(err)
+			}
+			req.AddCookie(initRR.Result().Cookies()[0])
+
+			var seenAccessToken string
+			rr := httptest.NewRecorder()
+			NewTokenRefreshMiddleware(h.service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				refreshedSession, _ := session.Store().Get(r, session.Name())
+				var seenToken oauth2.Token
+				json.Unmarshal([]byte(refreshedSession.Values[constants.SessionKeyOAuthToken].(string)), &seenToken)
+				seenAccessToken = seenToken.AccessToken
+				if seenToken.RefreshToken != "rtok" {
+					t.Errorf("expected the refresh token to be kept, got %q", seenToken.RefreshToken)
+				}
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != testCase.expectedStatus || seenAccessToken != testCase.expectedAccess {
+				t.Fatalf("expected %d with access token %q, got %d with %q", testCase.expectedStatus, testCase.expectedAccess, rr.Code, seenAccessToken)
+			}
+			if refreshCount != testCase.expectedRefreshes {
+				t.Fatalf("expected %d refreshes, got %d", testCase.expectedRefreshes, refreshCount)
+			}
+			if testCase.expectedAccess == "new" && len(rr.Result().Cookies()) == 0 {
+				t.Fatal("expected the refreshed token to be saved to the session")
+			}
+			if testCase.expectCleared {
+				if len(rr.Result().Cookies()) == 0 {
+					t.Fatal("expected the cleared session to be saved")
+				}
+				clearedReq := httptest.NewRequest(http.MethodGet, "/reports", nil)
+				clearedReq.AddCookie(rr.Result().Cookies()[0])
+				clearedSession, _ := session.Store().Get(clearedReq, session.Name())
+				if _, found := clearedSession.Values[constants.SessionKeyOAuthToken]; found {
+					t.Fatal("expected the revoked token to be removed from the session")
+				}
+				if _, authenticated := SessionUser(clearedReq); authenticated {
+					t.Fatal("expected the session to be unauthenticated after revocation")
+				}
+			} else if testCase.expectedStatus == http.StatusFound && len(rr.Result().Cookies()) != 0 {
+				t.Fatal("expected the session to be kept after a transient refresh failure")
+			}
+		})
+	}
+}
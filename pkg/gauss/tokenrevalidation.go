@@ -0,0 +1,112 @@
+package gauss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+// WithTokenRevalidation returns a ServiceOption that makes
+// NewAuthMiddleware and Service.AuthenticatedUser check with Google, once per
+// interval for each session, that the OAuth token stored at login is still
+// valid, so a user who removes the application's access in their Google
+// account is signed out within interval rather than when the cookie expires.
+// An unexpired access token is checked with the tokeninfo endpoint; an
+// expired one is refreshed with its refresh token, and the new token is
+// stored. When Google reports the token or grant as revoked, the token and
+// profile are removed from the session and the request is treated as
+// unauthenticated. The time of the last check is stored under
+// constants.SessionKeyTokenValidatedAt. Sessions without a stored token are
+// not checked. Zero or negative values disable the check, which is the
+// default.
+func WithTokenRevalidation(interval time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.revalidationInterval = interval
+	}
+}
+
+// WithTokenRevalidationFailClosed returns a ServiceOption that signs users
+// out when WithTokenRevalidation cannot reach Google or gets an unexpected
+// answer. By default such failures are logged and the session is kept, then
+// checked again on its next request.
+func WithTokenRevalidationFailClosed() ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.revalidationFailClosed = true
+	}
+}
+
+// errTokenRevoked reports that Google no longer accepts a stored token.
+var errTokenRevoked = errors.New("token revoked")
+
+// revalidateSessionToken reports whether the token stored in webSession may
+// still be used, checking it with Google when the last check is older than the
+// revalidation interval and recording the time of a successful check.
+func (serviceInstance *Service) revalidateSessionToken(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) bool {
+	storedTokenJSON, _ := webSession.Values[constants.SessionKeyOAuthToken].(string)
+	var storedToken oauth2.Token
+	if storedTokenJSON == "" || json.Unmarshal([]byte(storedTokenJSON), &storedToken) != nil {
+		return true
+	}
+	currentTime := serviceInstance.now()
+	if validatedUnixTime, stored := webSession.Values[constants.SessionKeyTokenValidatedAt].(int64); stored && currentTime.Sub(time.Unix(validatedUnixTime, 0)) < serviceInstance.revalidationInterval {
+		return true
+	}
+
+	refreshedToken, validationError := serviceInstance.validateToken(request.Context(), &storedToken)
+	if errors.Is(validationError, errTokenRevoked) {
+		return false
+	}
+	if validationError != nil {
+		logRequestf(request, "Failed to revalidate OAuth token: %v", validationError)
+		return !serviceInstance.revalidationFailClosed
+	}
+	if refreshedToken != nil {
+		tokenBytes, marshalError := json.Marshal(refreshedToken)
+		if marshalError != nil {
+			logRequestf(request, "Failed to marshal refreshed token: %v", marshalError)
+			return true
+		}
+		webSession.Values[constants.SessionKeyOAuthToken] = string(tokenBytes)
+	}
+	webSession.Values[constants.SessionKeyTokenValidatedAt] = currentTime.Unix()
+	if sessionSaveError := saveSession(responseWriter, request, webSession); sessionSaveError != nil {
+		logRequestf(request, "Failed to record token validation: %v", sessionSaveError)
+	}
+	return true
+}
+
+// validateToken asks Google whether storedToken is still valid. An expired
+// access token is refreshed instead of checked, and the refreshed token is
+// returned. The error wraps errTokenRevoked when Google refuses the token.
+func (serviceInstance *Service) validateToken(ctx context.Context, storedToken *oauth2.Token) (*oauth2.Token, error) {
+	if !serviceInstance.tokenExpiresWithin(storedToken, 0) {
+		if _, fetchError := serviceInstance.fetchTokenInfo(ctx, storedToken.AccessToken); fetchError != nil {
+			if errors.Is(fetchError, ErrInvalidAccessToken) {
+				return nil, fmt.Errorf("%w: %v", errTokenRevoked, fetchError)
+			}
+			return nil, fetchError
+		}
+		return nil, nil
+	}
+	if storedToken.RefreshToken == "" {
+		return nil, nil
+	}
+	refreshedToken, refreshError := serviceInstance.config.TokenSource(serviceInstance.oauthContext(ctx), &oauth2.Token{RefreshToken: storedToken.RefreshToken}).Token()
+	if refreshError != nil {
+		if grantRevoked(refreshError) {
+			return nil, fmt.Errorf("%w: %v", errTokenRevoked, refreshError)
+		}
+		return nil, refreshError
+	}
+	if refreshedToken.RefreshToken == "" {
+		refreshedToken.RefreshToken = storedToken.RefreshToken
+	}
+	return refreshedToken, nil
+}
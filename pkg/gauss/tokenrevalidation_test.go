@@ -0,0 +1,159 @@
+package gauss
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// revalidationTestTime is the login time used by the token revalidation
+// tests.
+var revalidationTestTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// useMockTokenInfoStatus serves tokeninfo with the status stored in
+// tokenInfoStatus and returns the number of calls made.
+func useMockTokenInfoStatus(t *testing.T, h *Handlers, tokenInfoStatus *int) *int {
+	t.Helper()
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		w.WriteHeader(*tokenInfoStatus)
+		io.WriteString(w, `{"aud":"id","sub":"42","exp":"9999999999"}`)
+	}))
+	t.Cleanup(server.Close)
+	WithGoogleEndpoints(GoogleEndpoints{TokenInfoURL: server.URL})(h.service)
+	return &callCount
+}
+
+// protectedRequestAt sends cookies to a route guarded by NewAuthMiddleware at
+// currentTime and returns the response with the cookies to send next.
+func protectedRequestAt(h *Handlers, cookies []*http.Cookie, currentTime time.Time) (*httptest.ResponseRecorder, []*http.Cookie) {
+	h.service.now = func() time.Time { return currentTime }
+	rr := httptest.NewRecorder()
+	NewAuthMiddleware(h.service)(okTestHandler()).ServeHTTP(rr, requestWithCookies("/dashboard", cookies))
+	if updatedCookies := lastCookies(rr); len(updatedCookies) > 0 {
+		return rr, updatedCookies
+	}
+	return rr, cookies
+}
+
+func TestTokenRevalidationDetectsRevocation(t *testing.T) {
+	h := newTestHandlers(t, WithTokenRevalidation(15*time.Minute))
+	useMockGoogleUser(t, h)
+	tokenInfoStatus := http.StatusOK
+	callCount := useMockTokenInfoStatus(t, h, &tokenInfoStatus)
+	h.service.now = func() time.Time { return revalidationTestTime }
+	cookies := lastCookies(loginAsUser(t, h))
+
+	steps := []struct {
+		name              string
+		elapsed           time.Duration
+		revoked           bool
+		expectedStatus    int
+		expectedCallCount int
+	}{
+		{name: "within interval", elapsed: time.Minute, expectedStatus: http.StatusOK, expectedCallCount: 0},
+		{name: "interval elapsed", elapsed: 16 * time.Minute, expectedStatus: http.StatusOK, expectedCallCount: 1},
+		{name: "revoked within interval", elapsed: 20 * time.Minute, revoked: true, expectedStatus: http.StatusOK, expectedCallCount: 1},
+		{name: "revoked after interval", elapsed: 32 * time.Minute, revoked: true, expectedStatus: http.StatusFound, expectedCallCount: 2},
+		{name: "signed out", elapsed: 33 * time.Minute, revoked: true, expectedStatus: http.StatusFound, expectedCallCount: 2},
+	}
+	for _, step := range steps {
+		if step.revoked {
+			tokenInfoStatus = http.StatusBadRequest
+		}
+		var rr *httptest.ResponseRecorder
+		rr, cookies = protectedRequestAt(h, cookies, revalidationTestTime.Add(step.elapsed))
+		if rr.Code != step.expectedStatus {
+			t.Fatalf("%s: expected status %d, got %d", step.name, step.expectedStatus, rr.Code)
+		}
+		if *callCount != step.expectedCallCount {
+			t.Fatalf("%s: expected %d tokeninfo calls, got %d", step.name, step.expectedCallCount, *callCount)
+		}
+	}
+}
+
+func TestTokenRevalidationFailures(t *testing.T) {
+	testCases := []struct {
+		name           string
+		options        []ServiceOption
+		expectedStatus int
+	}{
+		{name: "fail open by default", options: []ServiceOption{WithTokenRevalidation(time.Minute)}, expectedStatus: http.StatusOK},
+		{name: "fail closed", options: []ServiceOption{WithTokenRevalidation(time.Minute), WithTokenRevalidationFailClosed()}, expectedStatus: http.StatusFound},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, testCase.options...)
+			useMockGoogleUser(t, h)
+			tokenInfoStatus := http.StatusInternalServerError
+			callCount := useMockTokenInfoStatus(t, h, &tokenInfoStatus)
+			h.service.now = func() time.Time { return revalidationTestTime }
+			login := loginAsUser(t, h)
+
+			rr, _ := protectedRequestAt(h, lastCookies(login), revalidationTestTime.Add(2*time.Minute))
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if *callCount != 1 {
+				t.Fatalf("expected one tokeninfo call, got %d", *callCount)
+			}
+		})
+	}
+}
+
+func TestTokenRevalidationRefreshesExpiredToken(t *testing.T) {
+	testCases := []struct {
+		name           string
+		refreshStatus  int
+		refreshBody    string
+		expectedStatus int
+	}{
+		{name: "grant valid", refreshStatus: http.StatusOK, refreshBody: `{"access_token":"fresh","token_type":"bearer","expires_in":3600}`, expectedStatus: http.StatusOK},
+		{name: "grant revoked", refreshStatus: http.StatusBadRequest, refreshBody: `{"error":"invalid_grant"}`, expectedStatus: http.StatusFound},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithTokenRevalidation(15*time.Minute))
+			tokenRequests := 0
+			useMockGoogleHandlers(t, h,
+				func(w http.ResponseWriter, r *http.Request) {
+					tokenRequests++
+					w.Header().Set("Content-Type", "application/json")
+					if tokenRequests == 1 {
+						io.WriteString(w, `{"access_token":"abc","token_type":"bearer","refresh_token":"rtok","expires_in":3600}`)
+						return
+					}
+					w.WriteHeader(testCase.refreshStatus)
+					io.WriteString(w, testCase.refreshBody)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, `{"id":"42","email":"e@example.com","verified_email":true}`)
+				},
+			)
+			tokenInfoStatus := http.StatusOK
+			tokenInfoCalls := useMockTokenInfoStatus(t, h, &tokenInfoStatus)
+			h.service.now = func() time.Time { return revalidationTestTime }
+			login := loginAsUser(t, h)
+
+			rr, _ := protectedRequestAt(h, lastCookies(login), time.Now().Add(2*time.Hour))
+			if rr.Code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, rr.Code)
+			}
+			if tokenRequests != 2 || *tokenInfoCalls != 0 {
+				t.Fatalf("expected a refresh instead of a tokeninfo call, got %d token requests and %d tokeninfo calls", tokenRequests, *tokenInfoCalls)
+			}
+			if testCase.expectedStatus == http.StatusOK {
+				storedToken, _ := sessionFromResponse(t, rr)[constants.SessionKeyOAuthToken].(string)
+				if !strings.Contains(storedToken, `"fresh"`) {
+					t.Fatalf("expected the refreshed token to be stored, got %q", storedToken)
+				}
+			}
+		})
+	}
+}
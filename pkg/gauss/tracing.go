@@ -0,0 +1,91 @@
+package gauss
+
+import (
+	"context"
+)
+
+const (
+	spanNameLogin         = "gauss.Login"
+	spanNameCallback      = "gauss.Callback"
+	spanNameTokenExchange = "gauss.TokenExchange"
+	spanNameGetUser       = "gauss.GetUser"
+)
+
+// Tracer starts the spans GAuss records for Login, Callback and its requests
+// to Google. Implementations must be safe for concurrent use. The
+// pkg/tracing/opentelemetry module provides an OpenTelemetry implementation,
+// so applications that do not trace do not depend on OpenTelemetry.
+type Tracer interface {
+	// Start starts a span named spanName as a child of the span in ctx and
+	// returns a context carrying the new span.
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. Spans never receive tokens,
+// authorization codes or state values.
+type Span interface {
+	// RecordErrorCode marks the span as a failed login with code.
+	RecordErrorCode(code AuthErrorCode)
+	// RecordFailure marks the span failed with description.
+	RecordFailure(description string)
+	// End ends the span.
+	End()
+}
+
+// spanContextKey stores the Span started by startSpan in a context.
+type spanContextKey struct{}
+
+// WithTracer returns a ServiceOption that records spans for Login, Callback,
+// the token exchange and GetUser with tracer. Without it spans are discarded
+// at no cost.
+func WithTracer(tracer Tracer) ServiceOption {
+	return func(serviceInstance *Service) {
+		if tracer != nil {
+			serviceInstance.tracer = tracer
+		}
+	}
+}
+
+// noopTracer is the default Tracer and starts spans that record nothing.
+type noopTracer struct{}
+
+// Start returns ctx and a span that records nothing.
+func (noopTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// noopSpan is the Span started by noopTracer.
+type noopSpan struct{}
+
+// RecordErrorCode discards code.
+func (noopSpan) RecordErrorCode(code AuthErrorCode) {}
+
+// RecordFailure discards description.
+func (noopSpan) RecordFailure(description string) {}
+
+// End does nothing.
+func (noopSpan) End() {}
+
+// startSpan starts a span named spanName as a child of ctx and returns a
+// context carrying it for recordSpanFailure.
+func (serviceInstance *Service) startSpan(ctx context.Context, spanName string) (context.Context, Span) {
+	spanContext, span := serviceInstance.tracer.Start(ctx, spanName)
+	return context.WithValue(spanContext, spanContextKey{}, span), span
+}
+
+// recordSpanFailure marks the span started in ctx as failed. Only the error
+// code is recorded because error messages may include state values.
+func recordSpanFailure(ctx context.Context, code AuthErrorCode) {
+	if span, found := ctx.Value(spanContextKey{}).(Span); found {
+		span.RecordErrorCode(code)
+	}
+}
+
+// endSpan ends span, marking it failed with description when err is non-nil.
+// The error itself is not recorded since it may quote Google's response.
+func endSpan(span Span, err error, description string) {
+	if err != nil {
+		span.RecordFailure(description)
+	}
+	span.End()
+}
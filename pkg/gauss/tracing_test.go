@@ -0,0 +1,138 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// recordedSpan is a span captured by recordingTracer.
+type recordedSpan struct {
+	name               string
+	parentName         string
+	errorCode          AuthErrorCode
+	failureDescription string
+	ended              bool
+}
+
+// recordingTracer is a Tracer that keeps every span it starts.
+type recordingTracer struct {
+	mutex sync.Mutex
+	spans []*recordedSpan
+}
+
+// recordingSpanKey stores the recordedSpan active in a context.
+type recordingSpanKey struct{}
+
+func (tracer *recordingTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	span := &recordingTracerSpan{tracer: tracer, recorded: &recordedSpan{name: spanName}}
+	if parentSpan, found := ctx.Value(recordingSpanKey{}).(*recordedSpan); found {
+		span.recorded.parentName = parentSpan.name
+	}
+	tracer.mutex.Lock()
+	tracer.spans = append(tracer.spans, span.recorded)
+	tracer.mutex.Unlock()
+	return context.WithValue(ctx, recordingSpanKey{}, span.recorded), span
+}
+
+// spansByName indexes the recorded spans by name.
+func (tracer *recordingTracer) spansByName() map[string]recordedSpan {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	indexedSpans := make(map[string]recordedSpan)
+	for _, span := range tracer.spans {
+		indexedSpans[span.name] = *span
+	}
+	return indexedSpans
+}
+
+// recordingTracerSpan is the Span started by recordingTracer.
+type recordingTracerSpan struct {
+	tracer   *recordingTracer
+	recorded *recordedSpan
+}
+
+func (span *recordingTracerSpan) RecordErrorCode(code AuthErrorCode) {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	span.recorded.errorCode = code
+}
+
+func (span *recordingTracerSpan) RecordFailure(description string) {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	span.recorded.failureDescription = description
+}
+
+func (span *recordingTracerSpan) End() {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	span.recorded.ended = true
+}
+
+// newTracedHandlers returns test handlers whose spans are captured by the
+// returned tracer.
+func newTracedHandlers(t *testing.T) (*Handlers, *recordingTracer) {
+	t.Helper()
+	tracer := &recordingTracer{}
+	return newTestHandlers(t, WithTracer(tracer)), tracer
+}
+
+func TestTracingSuccessfulCallback(t *testing.T) {
+	h, tracer := newTracedHandlers(t)
+	rr := runSuccessfulCallback(t, h)
+	if location := rr.Header().Get("Location"); location != "/dashboard" {
+		t.Fatalf("expected redirect to /dashboard, got %s", location)
+	}
+
+	recordedSpans := tracer.spansByName()
+	callbackSpan, found := recordedSpans[spanNameCallback]
+	if !found || !callbackSpan.ended {
+		t.Fatalf("expected an ended %s span, got %v", spanNameCallback, recordedSpans)
+	}
+	for _, childName := range []string{spanNameTokenExchange, spanNameGetUser} {
+		childSpan, childFound := recordedSpans[childName]
+		if !childFound || !childSpan.ended {
+			t.Fatalf("expected an ended %s span", childName)
+		}
+		if childSpan.parentName != spanNameCallback {
+			t.Fatalf("expected %s to be a child of the callback span, got %q", childName, childSpan.parentName)
+		}
+		if childSpan.failureDescription != "" || childSpan.errorCode != "" {
+			t.Fatalf("expected %s to succeed, got %+v", childName, childSpan)
+		}
+	}
+}
+
+func TestTracingLoginSpan(t *testing.T) {
+	h, tracer := newTracedHandlers(t)
+	h.Login(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	if _, found := tracer.spansByName()[spanNameLogin]; !found {
+		t.Fatalf("expected a %s span", spanNameLogin)
+	}
+}
+
+func TestTracingFailureRecordsOnlyErrorCode(t *testing.T) {
+	h, tracer := newTracedHandlers(t)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=received-state&code=secret-code", nil)
+	seedState(t, req, "stored-state")
+	h.Callback(httptest.NewRecorder(), req)
+
+	callbackSpan, found := tracer.spansByName()[spanNameCallback]
+	if !found {
+		t.Fatalf("expected a %s span", spanNameCallback)
+	}
+	if callbackSpan.errorCode != ErrCodeStateMismatch {
+		t.Fatalf("expected error code %s, got %q", ErrCodeStateMismatch, callbackSpan.errorCode)
+	}
+	for _, secretValue := range []string{"received-state", "stored-state", "secret-code"} {
+		if strings.Contains(callbackSpan.failureDescription, secretValue) {
+			t.Fatalf("span failure leaks %q", secretValue)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gauss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// ttlCache is a concurrency-safe map from keys to values that expire. Expired
+// entries are dropped when read and swept at most once per sweep interval,
+// so keys that are never read again do not accumulate. Callers pass the
+// current time, so the cache follows the clock of its owner. A nil cache
+// stores nothing.
+type ttlCache[Value any] struct {
+	entries       sync.Map
+	sweepInterval time.Duration
+	sweepMutex    sync.Mutex
+	lastSweep     time.Time
+}
+
+// ttlCacheEntry is a cached value and the time it expires. Entries are
+// stored as pointers, as sync.Map compares them when deleting.
+type ttlCacheEntry[Value any] struct {
+	value   Value
+	expires time.Time
+}
+
+// newTTLCache constructs a ttlCache that sweeps expired entries at most once
+// per sweepInterval.
+func newTTLCache[Value any](sweepInterval time.Duration) *ttlCache[Value] {
+	return &ttlCache[Value]{sweepInterval: sweepInterval}
+}
+
+// load returns the value stored for key unless it has expired by
+// currentTime.
+func (cache *ttlCache[Value]) load(key string, currentTime time.Time) (Value, bool) {
+	var missingValue Value
+	if cache == nil {
+		return missingValue, false
+	}
+	storedEntry, found := cache.entries.Load(key)
+	if !found {
+		return missingValue, false
+	}
+	cacheEntry := storedEntry.(*ttlCacheEntry[Value])
+	if !currentTime.Before(cacheEntry.expires) {
+		cache.entries.CompareAndDelete(key, storedEntry)
+		return missingValue, false
+	}
+	return cacheEntry.value, true
+}
+
+// store stores value for key until expires and sweeps expired entries.
+func (cache *ttlCache[Value]) store(key string, value Value, expires time.Time, currentTime time.Time) {
+	if cache == nil {
+		return
+	}
+	cache.entries.Store(key, &ttlCacheEntry[Value]{value: value, expires: expires})
+	cache.sweep(currentTime)
+}
+
+// loadOrStore returns the unexpired value stored for key, or stores value
+// until expires and returns it when there is none.
+func (cache *ttlCache[Value]) loadOrStore(key string, value Value, expires time.Time, currentTime time.Time) Value {
+	for {
+		storedEntry, loaded := cache.entries.LoadOrStore(key, &ttlCacheEntry[Value]{value: value, expires: expires})
+		if !loaded {
+			cache.sweep(currentTime)
+			return value
+		}
+		cacheEntry := storedEntry.(*ttlCacheEntry[Value])
+		if currentTime.Before(cacheEntry.expires) {
+			return cacheEntry.value
+		}
+		cache.entries.CompareAndDelete(key, storedEntry)
+	}
+}
+
+// delete removes the value stored for key.
+func (cache *ttlCache[Value]) delete(key string) {
+	if cache != nil {
+		cache.entries.Delete(key)
+	}
+}
+
+// sweep deletes the entries expired by currentTime. It runs at most once per
+// sweep interval.
+func (cache *ttlCache[Value]) sweep(currentTime time.Time) {
+	cache.sweepMutex.Lock()
+	if currentTime.Sub(cache.lastSweep) < cache.sweepInterval {
+		cache.sweepMutex.Unlock()
+		return
+	}
+	cache.lastSweep = currentTime
+	cache.sweepMutex.Unlock()
+
+	cache.entries.Range(func(cacheKey, storedEntry interface{}) bool {
+		if !currentTime.Before(storedEntry.(*ttlCacheEntry[Value]).expires) {
+			cache.entries.CompareAndDelete(cacheKey, storedEntry)
+		}
+		return true
+	})
+}
+
+// accessTokenCacheKey returns the hex encoded SHA-256 hash of accessToken,
+// so caches keyed by tokens never hold usable tokens.
+func accessTokenCacheKey(accessToken string) string {
+	tokenHash := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(tokenHash[:])
+}
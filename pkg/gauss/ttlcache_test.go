@@ -0,0 +1,77 @@
+package gauss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	currentTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTTLCache[string](time.Minute)
+	cache.store("short", "first", currentTime.Add(10*time.Second), currentTime)
+	cache.store("long", "second", currentTime.Add(time.Hour), currentTime)
+
+	testSteps := []struct {
+		name      string
+		advance   time.Duration
+		key       string
+		wantValue string
+		wantFound bool
+	}{
+		{name: "unexpired entry", key: "short", wantValue: "first", wantFound: true},
+		{name: "missing key", key: "other"},
+		{name: "expired entry", advance: 10 * time.Second, key: "short"},
+		{name: "entry with a later expiry", key: "long", wantValue: "second", wantFound: true},
+	}
+	for _, testStep := range testSteps {
+		currentTime = currentTime.Add(testStep.advance)
+		value, found := cache.load(testStep.key, currentTime)
+		if value != testStep.wantValue || found != testStep.wantFound {
+			t.Fatalf("%s: expected %q (found %t), got %q (found %t)", testStep.name, testStep.wantValue, testStep.wantFound, value, found)
+		}
+	}
+
+	cache.delete("long")
+	if _, found := cache.load("long", currentTime); found {
+		t.Fatal("expected delete to remove the entry")
+	}
+
+	var nilCache *ttlCache[string]
+	nilCache.store("key", "value", currentTime.Add(time.Hour), currentTime)
+	if _, found := nilCache.load("key", currentTime); found {
+		t.Fatal("expected a nil cache to store nothing")
+	}
+}
+
+func TestTTLCacheLoadOrStoreReplacesExpiredEntries(t *testing.T) {
+	currentTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTTLCache[string](time.Minute)
+	if value := cache.loadOrStore("key", "first", currentTime.Add(time.Minute), currentTime); value != "first" {
+		t.Fatalf("expected the new value to be stored, got %q", value)
+	}
+	if value := cache.loadOrStore("key", "second", currentTime.Add(time.Minute), currentTime); value != "first" {
+		t.Fatalf("expected the stored value, got %q", value)
+	}
+	currentTime = currentTime.Add(time.Minute)
+	if value := cache.loadOrStore("key", "third", currentTime.Add(time.Minute), currentTime); value != "third" {
+		t.Fatalf("expected the expired value to be replaced, got %q", value)
+	}
+}
+
+func TestTTLCacheSweepsExpiredEntries(t *testing.T) {
+	currentTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTTLCache[string](time.Minute)
+	cache.store("stale", "1", currentTime.Add(time.Minute), currentTime)
+
+	currentTime = currentTime.Add(2 * time.Minute)
+	cache.store("fresh", "2", currentTime.Add(time.Minute), currentTime)
+
+	entryCount := 0
+	cache.entries.Range(func(cacheKey, storedEntry interface{}) bool {
+		entryCount++
+		return true
+	})
+	if entryCount != 1 {
+		t.Fatalf("expected the expired entry to be swept, got %d entries", entryCount)
+	}
+}
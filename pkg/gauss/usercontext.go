@@ -0,0 +1,82 @@
+package gauss
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// User is the logged-in user that NewAuthMiddleware and
+// NewUserContextMiddleware attach to the request context. LoginTime is zero
+// for sessions created before GAuss recorded it. Scopes lists the OAuth2
+// scopes granted to the session, or to the access token for requests
+// authenticated by NewBearerTokenMiddleware.
+type User struct {
+	ID        string
+	Email     string
+	Name      string
+	Picture   string
+	LoginTime time.Time
+	Scopes    []string
+}
+
+// userContextKey stores the User in a request context.
+type userContextKey struct{}
+
+// UserFromContext returns the User attached to ctx by NewAuthMiddleware or
+// NewUserContextMiddleware, so handlers do not need to read the session. The
+// boolean result is false when ctx carries no user.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, found := ctx.Value(userContextKey{}).(*User)
+	return user, found
+}
+
+// NewUserContextMiddleware returns middleware that attaches the logged-in user
+// to the request context, like NewAuthMiddleware, but lets requests without a
+// session through unchanged. It suits pages that adapt to a signed-in user
+// without requiring one. Sessions are checked as NewAuthMiddleware checks
+// them: WithSessionBinding, WithMaxConcurrentSessions, WithIdleTimeout,
+// WithMaxSessionLifetime and WithTokenRevalidation are enforced and the
+// session's last-seen time is recorded, while a session that fails a check is
+// signed out and the request continues anonymously. Wrap the next handler in
+// NewTokenRefreshMiddleware to keep the stored token fresh, as with
+// NewAuthMiddleware:
+//
+//	mux.Handle("/", gauss.NewUserContextMiddleware(svc)(homeHandler))
+func NewUserContextMiddleware(serviceInstance *Service) func(http.Handler) http.Handler {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if googleUser, authenticated := serviceInstance.AuthenticatedUser(responseWriter, request); authenticated {
+				request = serviceInstance.withSessionUser(request, googleUser)
+			}
+			nextHandler.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+// OptionalAuth returns middleware that puts the logged-in user, if any, into
+// the request context and always calls the next handler. It is
+// NewUserContextMiddleware under the name that pairs it with the login
+// enforcing NewAuthMiddleware:
+//
+//	mux.Handle("/", gauss.OptionalAuth(svc)(homeHandler))
+func OptionalAuth(serviceInstance *Service) func(http.Handler) http.Handler {
+	return NewUserContextMiddleware(serviceInstance)
+}
+
+// withSessionUser returns a copy of request whose context carries googleUser
+// and the login time and granted scopes stored in the session.
+func (serviceInstance *Service) withSessionUser(request *http.Request, googleUser *GoogleUser) *http.Request {
+	user := &User{ID: googleUser.ID, Email: googleUser.Email, Name: googleUser.Name, Picture: googleUser.Picture}
+	webSession := serviceInstance.webSession(request)
+	if loginUnixTime, stored := webSession.Values[constants.SessionKeyLoginTime].(int64); stored {
+		user.LoginTime = time.Unix(loginUnixTime, 0)
+	}
+	if grantedScopeList, stored := webSession.Values[constants.SessionKeyGrantedScopes].(string); stored {
+		user.Scopes = strings.Fields(grantedScopeList)
+	}
+	return request.WithContext(context.WithValue(request.Context(), userContextKey{}, user))
+}
@@ -0,0 +1,159 @@
+package gauss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// loggedInRequest returns a request carrying a session for e@example.com
+// that was created at loginTime.
+func loggedInRequest(t *testing.T, loginTime time.Time) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	initRR := httptest.NewRecorder()
+	sess, _ := session.Store().Get(req, constants.SessionName)
+	sess.Values[constants.SessionKeyUserID] = "42"
+	sess.Values[constants.SessionKeyUserEmail] = "e@example.com"
+	sess.Values[constants.SessionKeyUserName] = "tester"
+	sess.Values[constants.SessionKeyLoginTime] = loginTime.Unix()
+	if err := sess.Save(req, initRR); err != nil {
+		t.Fatal(err)
+	}
+	loggedIn := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range initRR.Result().Cookies() {
+		loggedIn.AddCookie(cookie)
+	}
+	return loggedIn
+}
+
+// contextUserRecorder records the user found in the context of each request.
+func contextUserRecorder(seenUsers *[]*User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		*seenUsers = append(*seenUsers, user)
+	})
+}
+
+func TestAuthMiddlewareAttachesUserToContext(t *testing.T) {
+	h := newTestHandlers(t)
+	loginTime := time.Unix(1700000000, 0)
+	var seenUsers []*User
+	handler := NewAuthMiddleware(h.service)(contextUserRecorder(&seenUsers))
+	handler.ServeHTTP(httptest.NewRecorder(), loggedInRequest(t, loginTime))
+	if len(seenUsers) != 1 || seenUsers[0] == nil {
+		t.Fatal("expected the handler to see a user")
+	}
+	user := seenUsers[0]
+	if user.ID != "42" || user.Email != "e@example.com" || user.Name != "tester" || !user.LoginTime.Equal(loginTime) {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestUserFromContextWithoutMiddleware(t *testing.T) {
+	h := newTestHandlers(t)
+	req := loggedInRequest(t, time.Now())
+	if _, found := UserFromContext(req.Context()); found {
+		t.Fatal("expected no user without the middleware")
+	}
+	var seenUsers []*User
+	contextUserRecorder(&seenUsers).ServeHTTP(httptest.NewRecorder(), req)
+	NewAuthMiddleware(h.service, WithSkipPaths("/"))(contextUserRecorder(&seenUsers)).ServeHTTP(httptest.NewRecorder(), req)
+	for _, user := range seenUsers {
+		if user != nil {
+			t.Fatalf("expected no user, got %+v", user)
+		}
+	}
+}
+
+func TestUserContextMiddlewareDoesNotLeakBetweenRequests(t *testing.T) {
+	h := newTestHandlers(t)
+	var seenUsers []*User
+	handler := NewUserContextMiddleware(h.service)(contextUserRecorder(&seenUsers))
+	handler.ServeHTTP(httptest.NewRecorder(), loggedInRequest(t, time.Now()))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(seenUsers) != 2 {
+		t.Fatalf("expected two requests, got %d", len(seenUsers))
+	}
+	if seenUsers[0] == nil || seenUsers[0].Email != "e@example.com" {
+		t.Fatalf("expected the first request to carry the user, got %+v", seenUsers[0])
+	}
+	if seenUsers[1] != nil {
+		t.Fatalf("expected the anonymous request to carry no user, got %+v", seenUsers[1])
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	currentTime := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name                  string
+		idleFor               time.Duration
+		expectedEmail         string
+		expectedLastSeenMoved bool
+	}{
+		{name: "anonymous"},
+		{name: "active session", idleFor: 5 * time.Minute, expectedEmail: "e@example.com", expectedLastSeenMoved: true},
+		{name: "idle session", idleFor: 2 * time.Hour},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			h := newTestHandlers(t, WithIdleTimeout(time.Hour))
+			h.service.now = func() time.Time { return currentTime }
+			var cookies []*http.Cookie
+			if testCase.idleFor > 0 {
+				cookies = idleSessionCookies(t, currentTime.Add(-testCase.idleFor))
+			}
+			var seenUsers []*User
+			rr := httptest.NewRecorder()
+			OptionalAuth(h.service)(contextUserRecorder(&seenUsers)).ServeHTTP(rr, requestWithCookies("/", cookies))
+			if rr.Code != http.StatusOK || len(seenUsers) != 1 {
+				t.Fatalf("expected the handler to run, got %d with %d calls", rr.Code, len(seenUsers))
+			}
+			if testCase.expectedEmail == "" {
+				if seenUsers[0] != nil {
+					t.Fatalf("expected no user, got %+v", seenUsers[0])
+				}
+				return
+			}
+			if seenUsers[0] == nil || seenUsers[0].Email != testCase.expectedEmail {
+				t.Fatalf("expected user %q, got %+v", testCase.expectedEmail, seenUsers[0])
+			}
+			if lastSeen, stored := storedLastSeen(t, lastCookies(rr)); testCase.expectedLastSeenMoved && (!stored || !lastSeen.Equal(currentTime)) {
+				t.Fatalf("expected the last-seen time to be recorded, got %v", lastSeen)
+			}
+		})
+	}
+}
+
+func TestCallbackRecordsLoginTime(t *testing.T) {
+	h := newTestHandlers(t)
+	loginTime := time.Unix(1700000000, 0)
+	h.service.now = func() time.Time { return loginTime }
+	useMockGoogleHandlers(t, h,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"abc","token_type":"bearer","refresh_token":"rtok"}`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":"42","email":"e@example.com","verified_email":true}`))
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, constants.CallbackPath+"?state=s1&code=c1", nil)
+	seedState(t, req, "s1")
+	rr := httptest.NewRecorder()
+	h.Callback(rr, req)
+
+	followUp := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		followUp.AddCookie(cookie)
+	}
+	var seenUsers []*User
+	NewAuthMiddleware(h.service)(contextUserRecorder(&seenUsers)).ServeHTTP(httptest.NewRecorder(), followUp)
+	if len(seenUsers) != 1 || seenUsers[0] == nil || !seenUsers[0].LoginTime.Equal(loginTime) {
+		t.Fatalf("expected the login time %v, got %+v", loginTime, seenUsers)
+	}
+}
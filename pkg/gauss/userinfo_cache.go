@@ -0,0 +1,17 @@
+package gauss
+
+import (
+	"time"
+)
+
+// WithUserInfoCacheTTL returns a ServiceOption that caches the profiles
+// returned by GetUser and GetUserContext for ttl, keyed by access token, so
+// that repeated lookups for the same token do not call Google again. Expired
+// entries are dropped when read and swept periodically. NewService rejects a
+// non-positive ttl.
+func WithUserInfoCacheTTL(ttl time.Duration) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.userInfoCacheTTL = ttl
+		serviceInstance.userInfoCacheEnabled = true
+	}
+}
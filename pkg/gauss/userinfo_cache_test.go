@@ -0,0 +1,67 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestUserInfoCache(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "42", "email": "e@example.com", "verified_email": true})
+	}))
+	defer server.Close()
+	currentTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	svc, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithUserInfoCacheTTL(time.Minute), WithGoogleEndpoints(GoogleEndpoints{UserInfoURL: server.URL}))
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	svc.now = func() time.Time { return currentTime }
+
+	firstToken := &oauth2.Token{AccessToken: "first"}
+	testSteps := []struct {
+		name           string
+		token          *oauth2.Token
+		advance        time.Duration
+		wantRequests   int
+		mutatePrevious bool
+	}{
+		{name: "first lookup", token: firstToken, wantRequests: 1},
+		{name: "cached lookup", token: firstToken, advance: 30 * time.Second, wantRequests: 1, mutatePrevious: true},
+		{name: "other token", token: &oauth2.Token{AccessToken: "second"}, wantRequests: 2},
+		{name: "expired entry", token: firstToken, advance: time.Minute, wantRequests: 3},
+	}
+
+	var previousUser *GoogleUser
+	for _, testStep := range testSteps {
+		currentTime = currentTime.Add(testStep.advance)
+		if testStep.mutatePrevious {
+			previousUser.Email = "changed@example.com"
+		}
+		user, err := svc.GetUser(testStep.token)
+		if err != nil {
+			t.Fatalf("%s: GetUser error: %v", testStep.name, err)
+		}
+		if user.ID != "42" || user.Email != "e@example.com" {
+			t.Fatalf("%s: unexpected user %+v", testStep.name, user)
+		}
+		if requestCount != testStep.wantRequests {
+			t.Fatalf("%s: expected %d requests, got %d", testStep.name, testStep.wantRequests, requestCount)
+		}
+		previousUser = user
+	}
+}
+
+func TestWithUserInfoCacheTTLRejectsNonPositiveValues(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, err := NewService("id", "secret", "http://example.com", "/dash", nil, "", WithUserInfoCacheTTL(ttl)); err == nil {
+			t.Errorf("expected TTL %s to be rejected", ttl)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// googleJWKSURI is where Google publishes the keys that sign its ID tokens.
+const googleJWKSURI = "https://www.googleapis.com/oauth2/v3/certs"
+
+// discoveryDocument is the simplified OpenID Connect discovery document
+// served by WellKnownHandler.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// WithWellKnown returns a ServiceOption that makes RegisterRoutes serve
+// WellKnownHandler at constants.WellKnownPath.
+func WithWellKnown(enabled bool) ServiceOption {
+	return func(serviceInstance *Service) {
+		serviceInstance.wellKnown = enabled
+	}
+}
+
+// WellKnownHandler returns a handler that serves a simplified OpenID Connect
+// discovery document for applications that advertise their authentication
+// endpoints. The issuer is the public base URL of the Service; the
+// authorization, token, userinfo and key set endpoints are Google's.
+func (handlersInstance *Handlers) WellKnownHandler() http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		setSecurityHeaders(responseWriter)
+		serviceInstance := handlersInstance.service
+		document := discoveryDocument{
+			AuthorizationEndpoint: serviceInstance.config.Endpoint.AuthURL,
+			TokenEndpoint:         serviceInstance.config.Endpoint.TokenURL,
+			UserInfoEndpoint:      serviceInstance.googleEndpoints.UserInfoURL,
+			JWKSURI:               googleJWKSURI,
+		}
+		if issuerURL := serviceInstance.publicBaseURL; issuerURL != nil {
+			document.Issuer = strings.TrimSuffix(issuerURL.String(), "/")
+		}
+		responseWriter.Header().Set(headerContentType, "application/json")
+		if encodeError := json.NewEncoder(responseWriter).Encode(document); encodeError != nil {
+			logRequestf(request, "Failed to write the discovery document: %v", encodeError)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package gauss
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+func TestWellKnownServesDiscoveryDocument(t *testing.T) {
+	h := newTestHandlers(t, WithWellKnown(true))
+	mux := h.RegisterRoutes(http.NewServeMux())
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.WellKnownPath, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a JSON document, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var document map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &document); err != nil {
+		t.Fatalf("failed to decode the document: %v", err)
+	}
+	expectedDocument := map[string]string{
+		"issuer":                 "http://localhost:8080",
+		"authorization_endpoint": "https://accounts.google.com/o/oauth2/auth",
+		"token_endpoint":         "https://oauth2.googleapis.com/token",
+		"userinfo_endpoint":      "https://www.googleapis.com/oauth2/v2/userinfo",
+		"jwks_uri":               "https://www.googleapis.com/oauth2/v3/certs",
+	}
+	for documentKey, expectedValue := range expectedDocument {
+		if document[documentKey] != expectedValue {
+			t.Fatalf("expected %s %q, got %q", documentKey, expectedValue, document[documentKey])
+		}
+	}
+}
+
+func TestWellKnownIsOptIn(t *testing.T) {
+	h := newTestHandlers(t)
+	rr := httptest.NewRecorder()
+	h.RegisterRoutes(http.NewServeMux()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, constants.WellKnownPath, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the document to be unregistered by default, got %d", rr.Code)
+	}
+}
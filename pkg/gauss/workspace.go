@@ -0,0 +1,38 @@
+package gauss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const spanNameIsWorkspaceAdmin = "gauss.IsWorkspaceAdmin"
+
+// IsWorkspaceAdmin reports whether the Google Workspace user with userEmail is
+// a super administrator of their domain, as the isAdmin field of the Admin
+// SDK Directory API. oauthToken must have been granted
+// ScopeAdminDirectoryUser by a user allowed to read the directory, typically
+// an administrator of the same domain; otherwise Google answers with an
+// error.
+func (serviceInstance *Service) IsWorkspaceAdmin(ctx context.Context, oauthToken *oauth2.Token, userEmail string) (isAdmin bool, err error) {
+	ctx, span := serviceInstance.startSpan(ctx, spanNameIsWorkspaceAdmin)
+	defer func() {
+		endSpan(span, err, "workspace admin request failed")
+	}()
+
+	trimmedEmail := strings.TrimSpace(userEmail)
+	if trimmedEmail == "" {
+		return false, errors.New("user email is required")
+	}
+	var directoryUser struct {
+		IsAdmin bool `json:"isAdmin"`
+	}
+	if fetchError := serviceInstance.fetchProfile(ctx, oauthToken, serviceInstance.googleEndpoints.AdminDirectoryUsersURL+url.PathEscape(trimmedEmail), &directoryUser); fetchError != nil {
+		return false, fmt.Errorf("failed to check Workspace admin status: %w", fetchError)
+	}
+	return directoryUser.IsAdmin, nil
+}
@@ -0,0 +1,68 @@
+package gauss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// useAdminDirectoryEndpoint serves the Admin SDK users API for the duration
+// of the test, answering with the JSON in users keyed by email address and
+// with 404 for other users, and returns the option pointing a Service at it.
+func useAdminDirectoryEndpoint(t *testing.T, users map[string]string) ServiceOption {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		userJSON, found := users[r.URL.Path[len("/users/"):]]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, userJSON)
+	}))
+	t.Cleanup(server.Close)
+	return WithGoogleEndpoints(GoogleEndpoints{AdminDirectoryUsersURL: server.URL + "/users/"})
+}
+
+func TestIsWorkspaceAdmin(t *testing.T) {
+	adminDirectoryOption := useAdminDirectoryEndpoint(t, map[string]string{
+		"boss@corp.com":  `{"primaryEmail":"boss@corp.com","isAdmin":true,"isDelegatedAdmin":false}`,
+		"staff@corp.com": `{"primaryEmail":"staff@corp.com","isAdmin":false,"isDelegatedAdmin":true}`,
+	})
+	svc, err := NewService("id", "secret", "http://localhost:8080", "/dashboard", ScopeStrings([]Scope{ScopeEmail, ScopeAdminDirectoryUser}), "", adminDirectoryOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name          string
+		accessToken   string
+		userEmail     string
+		expectedAdmin bool
+		expectError   bool
+	}{
+		{name: "super admin", accessToken: "admin-token", userEmail: "boss@corp.com", expectedAdmin: true},
+		{name: "delegated admin is not super admin", accessToken: "admin-token", userEmail: "staff@corp.com"},
+		{name: "unknown user", accessToken: "admin-token", userEmail: "nobody@corp.com", expectError: true},
+		{name: "unauthorized token", accessToken: "user-token", userEmail: "boss@corp.com", expectError: true},
+		{name: "missing email", accessToken: "admin-token", userEmail: " ", expectError: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isAdmin, err := svc.IsWorkspaceAdmin(context.Background(), &oauth2.Token{AccessToken: testCase.accessToken}, testCase.userEmail)
+			if (err != nil) != testCase.expectError {
+				t.Fatalf("expected error %v, got %v", testCase.expectError, err)
+			}
+			if isAdmin != testCase.expectedAdmin {
+				t.Fatalf("expected isAdmin %v, got %v", testCase.expectedAdmin, isAdmin)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package prometheus reports GAuss authentication metrics to Prometheus.
+package prometheus
+
+import (
+	"fmt"
+	"time"
+
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+const (
+	metricNamespace = "gauss"
+	labelCode       = "code"
+	labelOutcome    = "outcome"
+	outcomeSuccess  = "success"
+	outcomeFailure  = "failure"
+)
+
+// Metrics implements gauss.Metrics with Prometheus counters and histograms.
+type Metrics struct {
+	loginsStarted   prometheusclient.Counter
+	loginsSucceeded prometheusclient.Counter
+	loginsFailed    *prometheusclient.CounterVec
+	logouts         prometheusclient.Counter
+	tokenExchange   *prometheusclient.HistogramVec
+	userInfo        *prometheusclient.HistogramVec
+}
+
+// NewMetrics creates the GAuss collectors and registers them with registerer.
+// Pass prometheus.DefaultRegisterer to expose them on the default /metrics
+// handler.
+func NewMetrics(registerer prometheusclient.Registerer) (*Metrics, error) {
+	metrics := &Metrics{
+		loginsStarted: prometheusclient.NewCounter(prometheusclient.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "login_started_total",
+			Help:      "Redirects to the Google consent screen.",
+		}),
+		loginsSucceeded: prometheusclient.NewCounter(prometheusclient.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "login_succeeded_total",
+			Help:      "Callbacks that established a session.",
+		}),
+		loginsFailed: prometheusclient.NewCounterVec(prometheusclient.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "login_failed_total",
+			Help:      "Failed login attempts by error code.",
+		}, []string{labelCode}),
+		logouts: prometheusclient.NewCounter(prometheusclient.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "logout_total",
+			Help:      "Completed logouts.",
+		}),
+		tokenExchange: prometheusclient.NewHistogramVec(prometheusclient.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "token_exchange_duration_seconds",
+			Help:      "Latency of authorization code exchanges with Google.",
+			Buckets:   prometheusclient.DefBuckets,
+		}, []string{labelOutcome}),
+		userInfo: prometheusclient.NewHistogramVec(prometheusclient.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "userinfo_duration_seconds",
+			Help:      "Latency of Google userinfo requests.",
+			Buckets:   prometheusclient.DefBuckets,
+		}, []string{labelOutcome}),
+	}
+
+	collectors := []prometheusclient.Collector{
+		metrics.loginsStarted,
+		metrics.loginsSucceeded,
+		metrics.loginsFailed,
+		metrics.logouts,
+		metrics.tokenExchange,
+		metrics.userInfo,
+	}
+	for _, collector := range collectors {
+		if registerError := registerer.Register(collector); registerError != nil {
+			return nil, fmt.Errorf("failed to register gauss metrics: %w", registerError)
+		}
+	}
+	return metrics, nil
+}
+
+// LoginStarted implements gauss.Metrics.
+func (metrics *Metrics) LoginStarted() {
+	metrics.loginsStarted.Inc()
+}
+
+// LoginSucceeded implements gauss.Metrics.
+func (metrics *Metrics) LoginSucceeded() {
+	metrics.loginsSucceeded.Inc()
+}
+
+// LoginFailed implements gauss.Metrics.
+func (metrics *Metrics) LoginFailed(code gauss.AuthErrorCode) {
+	metrics.loginsFailed.WithLabelValues(string(code)).Inc()
+}
+
+// LoggedOut implements gauss.Metrics.
+func (metrics *Metrics) LoggedOut() {
+	metrics.logouts.Inc()
+}
+
+// ObserveTokenExchange implements gauss.Metrics.
+func (metrics *Metrics) ObserveTokenExchange(duration time.Duration, err error) {
+	metrics.tokenExchange.WithLabelValues(outcome(err)).Observe(duration.Seconds())
+}
+
+// ObserveUserInfo implements gauss.Metrics.
+func (metrics *Metrics) ObserveUserInfo(duration time.Duration, err error) {
+	metrics.userInfo.WithLabelValues(outcome(err)).Observe(duration.Seconds())
+}
+
+// outcome labels an observation by whether it failed.
+func outcome(err error) string {
+	if err != nil {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+var _ gauss.Metrics = (*Metrics)(nil)
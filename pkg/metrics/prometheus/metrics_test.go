@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/temirov/GAuss/pkg/gauss"
+)
+
+func TestMetricsRecordFailuresByCode(t *testing.T) {
+	registry := prometheusclient.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.LoginFailed(gauss.ErrCodeStateMismatch)
+	metrics.LoginFailed(gauss.ErrCodeStateMismatch)
+	metrics.LoginFailed(gauss.ErrCodeTokenExchange)
+	metrics.ObserveUserInfo(50*time.Millisecond, errors.New("boom"))
+
+	if got := testutil.ToFloat64(metrics.loginsFailed.WithLabelValues(string(gauss.ErrCodeStateMismatch))); got != 2 {
+		t.Fatalf("expected 2 invalid_state failures, got %v", got)
+	}
+	if count := testutil.CollectAndCount(metrics.userInfo); count != 1 {
+		t.Fatalf("expected one userinfo series, got %d", count)
+	}
+}
+
+func TestNewMetricsRejectsDuplicateRegistration(t *testing.T) {
+	registry := prometheusclient.NewRegistry()
+	if _, err := NewMetrics(registry); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMetrics(registry); err == nil {
+		t.Fatal("expected registering twice to fail")
+	}
+}
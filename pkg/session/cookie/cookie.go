@@ -0,0 +1,164 @@
+// Package cookie offers small helpers for values applications keep in the
+// GAuss session cookie: one-time flash messages and a URL to return to after
+// login. The request helpers open the session configured in package session
+// by name, perform their operation and, when they change the session, save
+// it. The session helpers operate on a session already opened from any
+// store; the GAuss handlers use them.
+package cookie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// ErrUnsafeReturnURL is returned for return URLs that are neither local
+// paths nor absolute URLs on an allowed host, such as protocol-relative URLs.
+var ErrUnsafeReturnURL = errors.New("return url must be a local path or on an allowed host")
+
+// SetFlash adds message to the flash messages of the session and saves it.
+func SetFlash(responseWriter http.ResponseWriter, request *http.Request, message string) error {
+	webSession, sessionError := openSession(request)
+	if sessionError != nil {
+		return sessionError
+	}
+	webSession.AddFlash(message)
+	return saveSession(responseWriter, request, webSession)
+}
+
+// GetFlashMessages returns the flash messages of the session in the order
+// they were added and removes them from the session loaded for request.
+// Having no response writer it cannot save the session, so the removal only
+// lasts if the session is saved later in the same request, for example by
+// SetFlash or SetReturnURL.
+func GetFlashMessages(request *http.Request) ([]string, error) {
+	webSession, sessionError := openSession(request)
+	if sessionError != nil {
+		return nil, sessionError
+	}
+	return TakeFlashMessages(webSession), nil
+}
+
+// SetReturnURL stores rawURL in the session as the URL GAuss returns to after
+// the next login, unless that login names another in its next parameter, and
+// saves it. rawURL must be a local path such as "/reports?week=12";
+// ErrUnsafeReturnURL is returned otherwise, so the stored value cannot be
+// used as an open redirect. An empty rawURL removes the stored URL.
+func SetReturnURL(responseWriter http.ResponseWriter, request *http.Request, rawURL string) error {
+	webSession, sessionError := openSession(request)
+	if sessionError != nil {
+		return sessionError
+	}
+	if putError := PutReturnURL(webSession, rawURL); putError != nil {
+		return putError
+	}
+	return saveSession(responseWriter, request, webSession)
+}
+
+// GetReturnURL returns the URL stored by SetReturnURL or the GAuss auth
+// middleware, or an empty string when there is none or a login consumed it.
+func GetReturnURL(request *http.Request) (string, error) {
+	webSession, sessionError := openSession(request)
+	if sessionError != nil {
+		return "", sessionError
+	}
+	return ReturnURL(webSession), nil
+}
+
+// TakeFlashMessages returns the string flash messages stored in webSession
+// under flashKeys, or under gorilla's default key when none is given, and
+// removes them from webSession.
+func TakeFlashMessages(webSession *sessions.Session, flashKeys ...string) []string {
+	flashes := webSession.Flashes(flashKeys...)
+	messages := make([]string, 0, len(flashes))
+	for _, flash := range flashes {
+		if message, isString := flash.(string); isString {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// PutReturnURL stores rawURL in webSession as the URL to return to after the
+// next login, or removes the stored URL when rawURL is empty. It returns
+// ErrUnsafeReturnURL, leaving webSession unchanged, unless ValidReturnURL
+// accepts rawURL with allowedHosts.
+func PutReturnURL(webSession *sessions.Session, rawURL string, allowedHosts ...string) error {
+	if rawURL == "" {
+		delete(webSession.Values, constants.SessionKeyReturnURL)
+		return nil
+	}
+	if !ValidReturnURL(rawURL, allowedHosts...) {
+		return fmt.Errorf("%w: %q", ErrUnsafeReturnURL, rawURL)
+	}
+	webSession.Values[constants.SessionKeyReturnURL] = rawURL
+	return nil
+}
+
+// ReturnURL returns the URL to return to after the next login stored in
+// webSession, or an empty string when there is none.
+func ReturnURL(webSession *sessions.Session) string {
+	returnURL, _ := webSession.Values[constants.SessionKeyReturnURL].(string)
+	return returnURL
+}
+
+// ValidReturnURL reports whether rawURL is safe to redirect to after login.
+// Rooted relative paths such as "/reports/42?week=12" are accepted, as are
+// absolute http and https URLs on allowedHosts, such as "app.example.com" or
+// "app.example.com:8443". Host names are compared case-insensitively; a host
+// without a port matches any port. Protocol-relative URLs, backslashes,
+// control characters, encoded leading slashes and user info are rejected so
+// the URL cannot be used as an open redirect.
+func ValidReturnURL(rawURL string, allowedHosts ...string) bool {
+	if rawURL == "" || strings.ContainsRune(rawURL, '\\') {
+		return false
+	}
+	for _, urlRune := range rawURL {
+		if urlRune < ' ' || urlRune == 0x7f {
+			return false
+		}
+	}
+	parsedURL, parseError := url.Parse(rawURL)
+	if parseError != nil || parsedURL.User != nil || parsedURL.Opaque != "" {
+		return false
+	}
+
+	if parsedURL.Scheme == "" && parsedURL.Host == "" {
+		decodedPath, unescapeError := url.PathUnescape(parsedURL.EscapedPath())
+		return unescapeError == nil && strings.HasPrefix(rawURL, "/") && !strings.HasPrefix(decodedPath, "//") && !strings.HasPrefix(decodedPath, "/\\")
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return false
+	}
+	for _, allowedHost := range allowedHosts {
+		allowedHost = strings.ToLower(strings.TrimSpace(allowedHost))
+		if allowedHost == strings.ToLower(parsedURL.Host) || allowedHost == strings.ToLower(parsedURL.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// openSession returns the GAuss session for request.
+func openSession(request *http.Request) (*sessions.Session, error) {
+	webSession, sessionError := session.Store().Get(request, session.Name())
+	if sessionError != nil {
+		return nil, fmt.Errorf("failed to read session: %w", sessionError)
+	}
+	return webSession, nil
+}
+
+// saveSession writes webSession to responseWriter.
+func saveSession(responseWriter http.ResponseWriter, request *http.Request, webSession *sessions.Session) error {
+	if saveError := webSession.Save(request, responseWriter); saveError != nil {
+		return fmt.Errorf("failed to save session: %w", saveError)
+	}
+	return nil
+}
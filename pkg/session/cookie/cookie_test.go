@@ -0,0 +1,108 @@
+package cookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/session"
+)
+
+// followUp returns a request carrying the session cookie last set in rr.
+func followUp(rr *httptest.ResponseRecorder) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	cookies := rr.Result().Cookies()
+	if len(cookies) > 0 {
+		request.AddCookie(cookies[len(cookies)-1])
+	}
+	return request
+}
+
+func TestFlashRoundTrip(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	firstRecorder := httptest.NewRecorder()
+	firstRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := SetFlash(firstRecorder, firstRequest, "Saved"); err != nil {
+		t.Fatalf("SetFlash failed: %v", err)
+	}
+	if err := SetFlash(firstRecorder, firstRequest, "Welcome back"); err != nil {
+		t.Fatalf("SetFlash failed: %v", err)
+	}
+
+	secondRequest := followUp(firstRecorder)
+	messages, err := GetFlashMessages(secondRequest)
+	if err != nil || !reflect.DeepEqual(messages, []string{"Saved", "Welcome back"}) {
+		t.Fatalf("expected both messages, got %v %v", messages, err)
+	}
+	if repeated, _ := GetFlashMessages(secondRequest); len(repeated) != 0 {
+		t.Fatalf("expected the messages to be consumed within the request, got %v", repeated)
+	}
+
+	secondRecorder := httptest.NewRecorder()
+	if err := SetReturnURL(secondRecorder, secondRequest, "/after"); err != nil {
+		t.Fatalf("SetReturnURL failed: %v", err)
+	}
+	if remaining, _ := GetFlashMessages(followUp(secondRecorder)); len(remaining) != 0 {
+		t.Fatalf("expected a saved session to drop consumed messages, got %v", remaining)
+	}
+}
+
+func TestReturnURLRoundTrip(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	rr := httptest.NewRecorder()
+	if err := SetReturnURL(rr, httptest.NewRequest(http.MethodGet, "/", nil), "/reports/42?week=12"); err != nil {
+		t.Fatalf("SetReturnURL failed: %v", err)
+	}
+	returnURL, err := GetReturnURL(followUp(rr))
+	if err != nil || returnURL != "/reports/42?week=12" {
+		t.Fatalf("expected the stored URL, got %q %v", returnURL, err)
+	}
+
+	clearRecorder := httptest.NewRecorder()
+	if err := SetReturnURL(clearRecorder, followUp(rr), ""); err != nil {
+		t.Fatalf("clearing failed: %v", err)
+	}
+	if cleared, _ := GetReturnURL(followUp(clearRecorder)); cleared != "" {
+		t.Fatalf("expected the URL to be removed, got %q", cleared)
+	}
+}
+
+func TestSetReturnURLRejectsUnsafeURLs(t *testing.T) {
+	session.NewSession([]byte("secret"))
+	for _, rawURL := range []string{"https://evil.example", "//evil.example", "/\\evil.example", "/%2F%2Fevil.example", "https://user@evil.example", "reports", "/a\r\nb"} {
+		t.Run(rawURL, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := SetReturnURL(rr, httptest.NewRequest(http.MethodGet, "/", nil), rawURL); !errors.Is(err, ErrUnsafeReturnURL) {
+				t.Fatalf("expected ErrUnsafeReturnURL, got %v", err)
+			}
+			if len(rr.Result().Cookies()) != 0 {
+				t.Fatal("expected the session not to be saved")
+			}
+		})
+	}
+}
+
+func TestValidReturnURLAllowedHosts(t *testing.T) {
+	testCases := []struct {
+		name         string
+		rawURL       string
+		allowedHosts []string
+		expected     bool
+	}{
+		{name: "local path", rawURL: "/reports", expected: true},
+		{name: "allowed host", rawURL: "https://App.Example.com/reports", allowedHosts: []string{"app.example.com"}, expected: true},
+		{name: "allowed host and port", rawURL: "https://app.example.com:8443/", allowedHosts: []string{"app.example.com:8443"}, expected: true},
+		{name: "other port", rawURL: "https://app.example.com:9000/", allowedHosts: []string{"app.example.com:8443"}, expected: false},
+		{name: "other host", rawURL: "https://evil.example/", allowedHosts: []string{"app.example.com"}, expected: false},
+		{name: "other scheme", rawURL: "ftp://app.example.com/", allowedHosts: []string{"app.example.com"}, expected: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if valid := ValidReturnURL(testCase.rawURL, testCase.allowedHosts...); valid != testCase.expected {
+				t.Fatalf("expected %v for %q, got %v", testCase.expected, testCase.rawURL, valid)
+			}
+		})
+	}
+}
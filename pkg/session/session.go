@@ -1,21 +1,118 @@
 package session
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+
 	gsessions "github.com/gorilla/sessions"
+	"github.com/temirov/GAuss/pkg/constants"
+)
+
+// defaultMaxAge keeps sessions for seven days.
+const defaultMaxAge = 86400 * 7
+
+var (
+	store      *gsessions.CookieStore
+	cookieName = constants.SessionName
 )
 
-var store *gsessions.CookieStore
+// Options configures the cookie store created by NewSessionWithOptions.
+type Options struct {
+	// AuthKeys authenticate cookies with HMAC. The first key signs new
+	// cookies; the remaining keys are still accepted, which allows rotating
+	// keys without logging users out. At least one key is required.
+	AuthKeys [][]byte
+	// EncryptKeys optionally encrypt cookie values. EncryptKeys[i] pairs with
+	// AuthKeys[i] and must be 16, 24 or 32 bytes long to select AES-128,
+	// AES-192 or AES-256.
+	EncryptKeys [][]byte
+	// CookieName names the session cookie. It defaults to
+	// constants.SessionName.
+	CookieName string
+	// MaxAge is the cookie lifetime in seconds. Zero selects seven days.
+	MaxAge int
+	// SameSite sets the SameSite attribute of the session cookie. The zero
+	// value leaves it to the browser, which treats it as Lax.
+	SameSite http.SameSite
+	// Secure restricts the session cookie to HTTPS. Enable it in production;
+	// browsers require it for SameSite=None.
+	Secure bool
+}
 
 // NewSession initializes the package-level cookie store with the given secret.
 // It should be called once at application startup.
 func NewSession(secret []byte) {
-	store = gsessions.NewCookieStore(secret)
-	store.Options = &gsessions.Options{
+	configureStore(gsessions.NewCookieStore(secret), Options{CookieName: constants.SessionName, MaxAge: defaultMaxAge})
+}
+
+// NewSessionWithEncryption initializes the package-level cookie store so that
+// cookies are signed with authKey and their values encrypted with AES using
+// encryptKey, keeping the stored OAuth token unreadable to anyone holding the
+// cookie. encryptKey must be 32 bytes for AES-256; 16 and 24 byte keys select
+// AES-128 and AES-192.
+func NewSessionWithEncryption(authKey []byte, encryptKey []byte) error {
+	return NewSessionWithOptions(Options{
+		AuthKeys:    [][]byte{authKey},
+		EncryptKeys: [][]byte{encryptKey},
+	})
+}
+
+// NewSessionWithOptions initializes the package-level cookie store from
+// options. Use it instead of NewSession to rotate keys, encrypt cookies or
+// rename the session cookie.
+func NewSessionWithOptions(options Options) error {
+	if len(options.AuthKeys) == 0 {
+		return errors.New("at least one session authentication key is required")
+	}
+	if len(options.EncryptKeys) > len(options.AuthKeys) {
+		return errors.New("each session encryption key needs a matching authentication key")
+	}
+
+	keyPairs := make([][]byte, 0, 2*len(options.AuthKeys))
+	for keyIndex, authKey := range options.AuthKeys {
+		if len(authKey) == 0 {
+			return fmt.Errorf("session authentication key %d is empty", keyIndex)
+		}
+		var encryptKey []byte
+		if keyIndex < len(options.EncryptKeys) {
+			encryptKey = options.EncryptKeys[keyIndex]
+			switch len(encryptKey) {
+			case 16, 24, 32:
+			default:
+				return fmt.Errorf("session encryption key %d must be 16, 24 or 32 bytes, got %d", keyIndex, len(encryptKey))
+			}
+		}
+		keyPairs = append(keyPairs, authKey, encryptKey)
+	}
+
+	if options.CookieName == "" {
+		options.CookieName = constants.SessionName
+	}
+	if options.MaxAge == 0 {
+		options.MaxAge = defaultMaxAge
+	}
+	if options.SameSite == http.SameSiteNoneMode && !options.Secure {
+		return errors.New("SameSite=None session cookies must be Secure")
+	}
+	cookieStore := gsessions.NewCookieStore(keyPairs...)
+	configureStore(cookieStore, options)
+	cookieStore.MaxAge(options.MaxAge)
+	return nil
+}
+
+// configureStore installs cookieStore as the package-level store with the
+// cookie name, lifetime, SameSite and Secure attributes of options.
+func configureStore(cookieStore *gsessions.CookieStore, options Options) {
+	cookieStore.Options = &gsessions.Options{
 		Path:     "/",
-		MaxAge:   86400 * 7,
+		MaxAge:   options.MaxAge,
 		HttpOnly: true,
-		Secure:   false, // Set to true in production
+		Secure:   options.Secure,
+		SameSite: options.SameSite,
 	}
+	store = cookieStore
+	cookieName = options.CookieName
 }
 
 // Store returns the global session store previously created with NewSession.
@@ -26,3 +123,60 @@ func Store() *gsessions.CookieStore {
 	}
 	return store
 }
+
+// Name returns the session cookie name configured by NewSession or
+// NewSessionWithOptions.
+func Name() string {
+	return cookieName
+}
+
+// DeleteSession deletes the session of request configured with NewSession or
+// NewSessionWithOptions: it is read from the store, cleared and saved with a
+// negative MaxAge, so the browser discards its cookie. Use it when
+// implementing a custom logout instead of saving the session with a negative
+// MaxAge by hand.
+func DeleteSession(responseWriter http.ResponseWriter, request *http.Request) error {
+	webSession, sessionError := Store().Get(request, Name())
+	if sessionError != nil {
+		return fmt.Errorf("failed to read session %q: %w", Name(), sessionError)
+	}
+	return DeleteWebSession(responseWriter, request, webSession)
+}
+
+// DeleteWebSession removes webSession, read from any store: its values are
+// cleared and it is saved with a negative MaxAge, so the store drops it and
+// the browser discards its cookie. It is DeleteSession for sessions that do
+// not come from the package store, such as one set with
+// gauss.WithSessionStore.
+func DeleteWebSession(responseWriter http.ResponseWriter, request *http.Request, webSession *gsessions.Session) error {
+	webSession.Values = make(map[interface{}]interface{})
+	webSession.Options.MaxAge = -1
+	if saveError := webSession.Save(request, responseWriter); saveError != nil {
+		return fmt.Errorf("failed to delete session %q: %w", webSession.Name(), saveError)
+	}
+	return nil
+}
+
+// DeleteAllSessions deletes every session cookie the request carries that
+// was issued by the package store, whatever its name, such as the sessions
+// of several services configured with gauss.WithSessionNamespace. The cookie
+// store cannot list sessions on the server, so the request's cookies serve as
+// the list; cookies the store cannot decode are left alone.
+func DeleteAllSessions(responseWriter http.ResponseWriter, request *http.Request) error {
+	var deleteErrors []error
+	deletedNames := make(map[string]struct{})
+	for _, requestCookie := range request.Cookies() {
+		if _, deleted := deletedNames[requestCookie.Name]; deleted {
+			continue
+		}
+		deletedNames[requestCookie.Name] = struct{}{}
+		webSession, sessionError := Store().Get(request, requestCookie.Name)
+		if sessionError != nil || webSession.IsNew {
+			continue
+		}
+		if deleteError := DeleteWebSession(responseWriter, request, webSession); deleteError != nil {
+			deleteErrors = append(deleteErrors, deleteError)
+		}
+	}
+	return errors.Join(deleteErrors...)
+}
@@ -1,7 +1,13 @@
 package session
 
 import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	gsessions "github.com/gorilla/sessions"
 )
 
 func TestStorePanicsWithoutInit(t *testing.T) {
@@ -20,3 +26,190 @@ func TestNewSessionAndStore(t *testing.T) {
 		t.Fatal("store should not be nil after initialization")
 	}
 }
+
+func TestNewSessionWithOptionsValidates(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options Options
+	}{
+		{name: "no auth keys", options: Options{}},
+		{name: "empty auth key", options: Options{AuthKeys: [][]byte{{}}}},
+		{name: "unpaired encryption key", options: Options{AuthKeys: [][]byte{[]byte("a")}, EncryptKeys: [][]byte{make([]byte, 32), make([]byte, 32)}}},
+		{name: "bad encryption key length", options: Options{AuthKeys: [][]byte{[]byte("a")}, EncryptKeys: [][]byte{make([]byte, 10)}}},
+		{name: "insecure SameSite None", options: Options{AuthKeys: [][]byte{[]byte("a")}, SameSite: http.SameSiteNoneMode}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := NewSessionWithOptions(testCase.options); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewSessionWithOptionsRotatesKeys(t *testing.T) {
+	oldKey := []byte("old-authentication-key")
+	newKey := []byte("new-authentication-key")
+	encryptKey := []byte("0123456789abcdef0123456789abcdef")
+
+	if err := NewSessionWithOptions(Options{AuthKeys: [][]byte{oldKey}, EncryptKeys: [][]byte{encryptKey}, CookieName: "custom_session", MaxAge: 3600}); err != nil {
+		t.Fatalf("NewSessionWithOptions error: %v", err)
+	}
+	if Name() != "custom_session" || Store().Options.MaxAge != 3600 {
+		t.Fatalf("unexpected name %q or max age %d", Name(), Store().Options.MaxAge)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	oldSession, _ := Store().Get(req, Name())
+	oldSession.Values["user"] = "e@example.com"
+	if err := oldSession.Save(req, rr); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	if err := NewSessionWithOptions(Options{AuthKeys: [][]byte{newKey, oldKey}, EncryptKeys: [][]byte{encryptKey, encryptKey}, CookieName: "custom_session"}); err != nil {
+		t.Fatalf("NewSessionWithOptions error: %v", err)
+	}
+	rotatedRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		rotatedRequest.AddCookie(cookie)
+	}
+	rotatedSession, err := Store().Get(rotatedRequest, Name())
+	if err != nil {
+		t.Fatalf("expected the old cookie to decode after rotation: %v", err)
+	}
+	if rotatedSession.Values["user"] != "e@example.com" {
+		t.Fatal("expected values signed with the old key to survive rotation")
+	}
+}
+
+func TestNewSessionWithOptionsSetsCookieAttributes(t *testing.T) {
+	if err := NewSessionWithOptions(Options{AuthKeys: [][]byte{[]byte("authentication-key")}, SameSite: http.SameSiteNoneMode, Secure: true}); err != nil {
+		t.Fatalf("NewSessionWithOptions error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	webSession, _ := Store().Get(req, Name())
+	if err := webSession.Save(req, rr); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	sessionCookie := rr.Result().Cookies()[0]
+	if sessionCookie.SameSite != http.SameSiteNoneMode || !sessionCookie.Secure {
+		t.Fatalf("expected a SameSite=None Secure cookie, got %q", rr.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestNewSessionWithEncryptionHidesValues(t *testing.T) {
+	if err := NewSessionWithEncryption([]byte("authentication-key"), []byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatalf("NewSessionWithEncryption error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	webSession, _ := Store().Get(req, Name())
+	webSession.Values["token"] = "secret-token-value"
+	if err := webSession.Save(req, rr); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	cookie := rr.Result().Cookies()[0]
+	decoded, _ := base64.URLEncoding.DecodeString(cookie.Value)
+	if strings.Contains(cookie.Value, "secret-token-value") || strings.Contains(string(decoded), "secret-token-value") {
+		t.Fatal("expected the cookie value to be encrypted")
+	}
+
+	readRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	readRequest.AddCookie(cookie)
+	readSession, err := Store().Get(readRequest, Name())
+	if err != nil || readSession.Values["token"] != "secret-token-value" {
+		t.Fatalf("expected the value to decrypt, got %v (%v)", readSession.Values["token"], err)
+	}
+}
+
+func TestNewSessionWithEncryptionRejectsBadKeys(t *testing.T) {
+	if err := NewSessionWithEncryption([]byte("authentication-key"), []byte("short")); err == nil {
+		t.Fatal("expected an error for an invalid encryption key length")
+	}
+}
+
+// sessionCookies saves a session holding a value under each of names and
+// returns the resulting cookies.
+func sessionCookies(t *testing.T, names ...string) []*http.Cookie {
+	t.Helper()
+	seedRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	for _, name := range names {
+		webSession, _ := Store().Get(seedRequest, name)
+		webSession.Values["user_email"] = "e@example.com"
+		if err := webSession.Save(seedRequest, rr); err != nil {
+			t.Fatalf("save error: %v", err)
+		}
+	}
+	return rr.Result().Cookies()
+}
+
+// deletedCookieNames returns the names of the cookies rr deletes, failing
+// when a Set-Cookie header lacks Max-Age=0.
+func deletedCookieNames(t *testing.T, rr *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var names []string
+	for _, setCookie := range rr.Header().Values("Set-Cookie") {
+		if !strings.Contains(setCookie, "Max-Age=0") {
+			t.Fatalf("expected Max-Age=0 in %q", setCookie)
+		}
+		names = append(names, strings.SplitN(setCookie, "=", 2)[0])
+	}
+	return names
+}
+
+func TestDeleteSession(t *testing.T) {
+	NewSession([]byte("secret"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range sessionCookies(t, Name()) {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	if err := DeleteSession(rr, req); err != nil {
+		t.Fatalf("DeleteSession error: %v", err)
+	}
+	if names := deletedCookieNames(t, rr); len(names) != 1 || names[0] != Name() {
+		t.Fatalf("expected the session cookie to be deleted, got %v", names)
+	}
+
+	deletedRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		deletedRequest.AddCookie(cookie)
+	}
+	if email, _ := GetUserEmail(deletedRequest); email != "" {
+		t.Fatalf("expected the deleted cookie to hold no values, got %q", email)
+	}
+}
+
+func TestDeleteWebSession(t *testing.T) {
+	NewSession([]byte("secret"))
+	otherStore := gsessions.NewCookieStore([]byte("other-secret"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	webSession, _ := otherStore.Get(req, "custom_session")
+	rr := httptest.NewRecorder()
+	if err := DeleteWebSession(rr, req, webSession); err != nil {
+		t.Fatalf("DeleteWebSession error: %v", err)
+	}
+	if names := deletedCookieNames(t, rr); len(names) != 1 || names[0] != "custom_session" {
+		t.Fatalf("expected the custom session cookie to be deleted, got %v", names)
+	}
+}
+
+func TestDeleteAllSessions(t *testing.T) {
+	NewSession([]byte("secret"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range sessionCookies(t, Name(), "admin_session") {
+		req.AddCookie(cookie)
+	}
+	req.AddCookie(&http.Cookie{Name: "preferences", Value: "dark"})
+	rr := httptest.NewRecorder()
+	if err := DeleteAllSessions(rr, req); err != nil {
+		t.Fatalf("DeleteAllSessions error: %v", err)
+	}
+	names := deletedCookieNames(t, rr)
+	if len(names) != 2 || names[0] != Name() || names[1] != "admin_session" {
+		t.Fatalf("expected both session cookies and nothing else to be deleted, got %v", names)
+	}
+}
@@ -0,0 +1,219 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// SQLStoreMigration creates the table used by NewSQLStore under its default
+// name "sessions". Use SQLStoreMigrationForTable for another name.
+// PostgreSQL has no BLOB type; replace it with BYTEA there.
+const SQLStoreMigration = `CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+)`
+
+const (
+	defaultSQLTableName   = "sessions"
+	sqlSessionIDByteCount = 32
+)
+
+// sqlTableNamePattern accepts plain and schema qualified SQL identifiers, so
+// the table name can be interpolated into queries safely.
+var sqlTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLStoreMigrationForTable returns SQLStoreMigration for tableName.
+func SQLStoreMigrationForTable(tableName string) string {
+	return strings.Replace(SQLStoreMigration, defaultSQLTableName, tableName, 1)
+}
+
+// SQLStore is a gorilla sessions.Store that keeps session values in a SQL
+// table and only a signed session ID in the cookie. It uses database/sql with
+// $1 style placeholders and an INSERT ... ON CONFLICT upsert, which PostgreSQL
+// and SQLite both understand.
+type SQLStore struct {
+	// Codecs sign, and optionally encrypt, the session ID cookie and the
+	// stored values.
+	Codecs []securecookie.Codec
+	// Options are the default cookie options of new sessions.
+	Options *gsessions.Options
+
+	database  *sql.DB
+	tableName string
+	now       func() time.Time
+}
+
+var _ gsessions.Store = (*SQLStore)(nil)
+
+// NewSQLStore returns an SQLStore that keeps sessions in tableName of
+// database. keyPairs are used like in gorilla's NewCookieStore: authentication
+// keys, each optionally followed by an encryption key. An empty tableName
+// selects "sessions". Create the table with SQLStoreMigrationForTable, and
+// call GC periodically to delete expired rows. Pass the store to
+// gauss.WithSessionStore to keep GAuss sessions in it.
+func NewSQLStore(database *sql.DB, tableName string, keyPairs ...[]byte) (*SQLStore, error) {
+	if database == nil {
+		return nil, errors.New("a database is required")
+	}
+	if tableName == "" {
+		tableName = defaultSQLTableName
+	}
+	if !sqlTableNamePattern.MatchString(tableName) {
+		return nil, fmt.Errorf("invalid session table name %q", tableName)
+	}
+	if len(keyPairs) == 0 || len(keyPairs[0]) == 0 {
+		return nil, errors.New("at least one session authentication key is required")
+	}
+	sqlStore := &SQLStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &gsessions.Options{
+			Path:     "/",
+			HttpOnly: true,
+		},
+		database:  database,
+		tableName: tableName,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+	for _, codec := range sqlStore.Codecs {
+		if secureCodec, isSecureCookie := codec.(*securecookie.SecureCookie); isSecureCookie {
+			// Stored values are not limited by the cookie size.
+			secureCodec.MaxLength(0)
+		}
+	}
+	sqlStore.MaxAge(defaultMaxAge)
+	return sqlStore, nil
+}
+
+// Get returns the session called name for request, loading it once per
+// request through the gorilla registry.
+func (sqlStore *SQLStore) Get(request *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(request).Get(sqlStore, name)
+}
+
+// New returns the session called name for request. The session is loaded
+// from the table when request carries a valid, unexpired session ID;
+// otherwise a new session is returned with IsNew set.
+func (sqlStore *SQLStore) New(request *http.Request, name string) (*gsessions.Session, error) {
+	webSession := gsessions.NewSession(sqlStore, name)
+	sessionOptions := *sqlStore.Options
+	webSession.Options = &sessionOptions
+	webSession.IsNew = true
+
+	sessionCookie, cookieError := request.Cookie(name)
+	if cookieError != nil {
+		return webSession, nil
+	}
+	if decodeError := securecookie.DecodeMulti(name, sessionCookie.Value, &webSession.ID, sqlStore.Codecs...); decodeError != nil {
+		return webSession, decodeError
+	}
+	found, loadError := sqlStore.load(request, webSession)
+	if loadError != nil {
+		return webSession, loadError
+	}
+	if !found {
+		// Expired or deleted sessions start over with a fresh ID.
+		webSession.ID = ""
+		return webSession, nil
+	}
+	webSession.IsNew = false
+	return webSession, nil
+}
+
+// Save writes webSession to the table and sets the session ID cookie. A
+// negative MaxAge deletes the row and the cookie.
+func (sqlStore *SQLStore) Save(request *http.Request, responseWriter http.ResponseWriter, webSession *gsessions.Session) error {
+	if webSession.Options.MaxAge < 0 {
+		if webSession.ID != "" {
+			if _, deleteError := sqlStore.database.ExecContext(request.Context(), "DELETE FROM "+sqlStore.tableName+" WHERE id = $1", webSession.ID); deleteError != nil {
+				return fmt.Errorf("failed to delete session: %w", deleteError)
+			}
+		}
+		http.SetCookie(responseWriter, gsessions.NewCookie(webSession.Name(), "", webSession.Options))
+		return nil
+	}
+
+	if webSession.ID == "" {
+		webSession.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(sqlSessionIDByteCount)), "=")
+	}
+	encodedValues, encodeError := securecookie.EncodeMulti(webSession.Name(), webSession.Values, sqlStore.Codecs...)
+	if encodeError != nil {
+		return fmt.Errorf("failed to encode session values: %w", encodeError)
+	}
+	currentTime := sqlStore.now()
+	if _, upsertError := sqlStore.database.ExecContext(request.Context(),
+		"INSERT INTO "+sqlStore.tableName+" (id, data, created_at, expires_at) VALUES ($1, $2, $3, $4) "+
+			"ON CONFLICT (id) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at",
+		webSession.ID, []byte(encodedValues), currentTime, currentTime.Add(sqlStore.rowLifetime(webSession.Options.MaxAge)),
+	); upsertError != nil {
+		return fmt.Errorf("failed to save session: %w", upsertError)
+	}
+
+	encodedID, encodeError := securecookie.EncodeMulti(webSession.Name(), webSession.ID, sqlStore.Codecs...)
+	if encodeError != nil {
+		return fmt.Errorf("failed to encode session ID: %w", encodeError)
+	}
+	http.SetCookie(responseWriter, gsessions.NewCookie(webSession.Name(), encodedID, webSession.Options))
+	return nil
+}
+
+// GC deletes the rows of expired sessions.
+func (sqlStore *SQLStore) GC(ctx context.Context) error {
+	if _, deleteError := sqlStore.database.ExecContext(ctx, "DELETE FROM "+sqlStore.tableName+" WHERE expires_at <= $1", sqlStore.now()); deleteError != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", deleteError)
+	}
+	return nil
+}
+
+// MaxAge sets the lifetime in seconds of new sessions and of the codecs, as
+// CookieStore.MaxAge does.
+func (sqlStore *SQLStore) MaxAge(maxAge int) {
+	sqlStore.Options.MaxAge = maxAge
+	for _, codec := range sqlStore.Codecs {
+		if secureCodec, isSecureCookie := codec.(*securecookie.SecureCookie); isSecureCookie {
+			secureCodec.MaxAge(maxAge)
+		}
+	}
+}
+
+// load reads the values of webSession from the table and reports whether an
+// unexpired row was found.
+func (sqlStore *SQLStore) load(request *http.Request, webSession *gsessions.Session) (bool, error) {
+	var encodedValues []byte
+	queryError := sqlStore.database.QueryRowContext(request.Context(),
+		"SELECT data FROM "+sqlStore.tableName+" WHERE id = $1 AND expires_at > $2", webSession.ID, sqlStore.now(),
+	).Scan(&encodedValues)
+	if errors.Is(queryError, sql.ErrNoRows) {
+		return false, nil
+	}
+	if queryError != nil {
+		return false, fmt.Errorf("failed to load session: %w", queryError)
+	}
+	if decodeError := securecookie.DecodeMulti(webSession.Name(), string(encodedValues), &webSession.Values, sqlStore.Codecs...); decodeError != nil {
+		return false, decodeError
+	}
+	return true, nil
+}
+
+// rowLifetime returns how long a row saved with cookieMaxAge stays valid.
+// Browser-session cookies, with a zero MaxAge, keep the store's default.
+func (sqlStore *SQLStore) rowLifetime(cookieMaxAge int) time.Duration {
+	if cookieMaxAge == 0 {
+		cookieMaxAge = sqlStore.Options.MaxAge
+	}
+	if cookieMaxAge <= 0 {
+		cookieMaxAge = defaultMaxAge
+	}
+	return time.Duration(cookieMaxAge) * time.Second
+}
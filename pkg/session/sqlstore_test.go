@@ -0,0 +1,246 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gsessions "github.com/gorilla/sessions"
+)
+
+// memoryRow is a row of the in-memory sessions table.
+type memoryRow struct {
+	data      []byte
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+// memoryDriver is a database/sql driver that understands exactly the
+// statements issued by SQLStore, keeping one table per data source name.
+type memoryDriver struct {
+	mutex  sync.Mutex
+	tables map[string]map[string]memoryRow
+}
+
+var testDriver = &memoryDriver{tables: map[string]map[string]memoryRow{}}
+
+func init() {
+	sql.Register("gauss-memory", testDriver)
+}
+
+func (memory *memoryDriver) Open(name string) (driver.Conn, error) {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	if memory.tables[name] == nil {
+		memory.tables[name] = map[string]memoryRow{}
+	}
+	return &memoryConn{memory: memory, name: name}, nil
+}
+
+type memoryConn struct {
+	memory *memoryDriver
+	name   string
+}
+
+func (conn *memoryConn) Prepare(query string) (driver.Stmt, error) {
+	return &memoryStmt{conn: conn, query: query}, nil
+}
+func (conn *memoryConn) Close() error { return nil }
+func (conn *memoryConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type memoryStmt struct {
+	conn  *memoryConn
+	query string
+}
+
+func (stmt *memoryStmt) Close() error  { return nil }
+func (stmt *memoryStmt) NumInput() int { return -1 }
+
+func (stmt *memoryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	memory := stmt.conn.memory
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	table := memory.tables[stmt.conn.name]
+	switch {
+	case strings.HasPrefix(stmt.query, "INSERT INTO sessions "):
+		row, exists := table[args[0].(string)]
+		if !exists {
+			row.createdAt = args[2].(time.Time)
+		}
+		row.data, row.expiresAt = args[1].([]byte), args[3].(time.Time)
+		table[args[0].(string)] = row
+	case strings.HasPrefix(stmt.query, "DELETE FROM sessions WHERE id = "):
+		delete(table, args[0].(string))
+	case strings.HasPrefix(stmt.query, "DELETE FROM sessions WHERE expires_at <= "):
+		for id, row := range table {
+			if !row.expiresAt.After(args[0].(time.Time)) {
+				delete(table, id)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected statement %q", stmt.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (stmt *memoryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(stmt.query, "SELECT data FROM sessions WHERE id = ") {
+		return nil, fmt.Errorf("unexpected query %q", stmt.query)
+	}
+	memory := stmt.conn.memory
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	row, exists := memory.tables[stmt.conn.name][args[0].(string)]
+	if !exists || !row.expiresAt.After(args[1].(time.Time)) {
+		return &memoryRows{}, nil
+	}
+	return &memoryRows{data: [][]byte{row.data}}, nil
+}
+
+type memoryRows struct {
+	data [][]byte
+}
+
+func (rows *memoryRows) Columns() []string { return []string{"data"} }
+func (rows *memoryRows) Close() error      { return nil }
+func (rows *memoryRows) Next(dest []driver.Value) error {
+	if len(rows.data) == 0 {
+		return io.EOF
+	}
+	dest[0], rows.data = rows.data[0], rows.data[1:]
+	return nil
+}
+
+// newTestSQLStore returns an SQLStore backed by a fresh in-memory table and
+// the table itself.
+func newTestSQLStore(t *testing.T) (*SQLStore, map[string]memoryRow) {
+	t.Helper()
+	database, openError := sql.Open("gauss-memory", t.Name())
+	if openError != nil {
+		t.Fatal(openError)
+	}
+	t.Cleanup(func() { database.Close() })
+	store, storeError := NewSQLStore(database, "", []byte("authentication-key"))
+	if storeError != nil {
+		t.Fatal(storeError)
+	}
+	if pingError := database.Ping(); pingError != nil {
+		t.Fatal(pingError)
+	}
+	return store, testDriver.tables[t.Name()]
+}
+
+// requestWithResponseCookies returns a request carrying the cookies set in rr.
+func requestWithResponseCookies(rr *httptest.ResponseRecorder) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestSQLStoreRoundTrip(t *testing.T) {
+	sqlStore, table := newTestSQLStore(t)
+	webSession, _ := sqlStore.Get(httptest.NewRequest(http.MethodGet, "/", nil), "gauss_session")
+	if !webSession.IsNew {
+		t.Fatal("expected a new session without a cookie")
+	}
+	webSession.Values["user_email"] = "e@example.com"
+	webSession.Values["oauth_token"] = strings.Repeat("t", 8000)
+	saveRecorder := httptest.NewRecorder()
+	if saveError := webSession.Save(httptest.NewRequest(http.MethodGet, "/", nil), saveRecorder); saveError != nil {
+		t.Fatalf("save failed: %v", saveError)
+	}
+	if len(table) != 1 {
+		t.Fatalf("expected one stored row, got %d", len(table))
+	}
+	if cookieValue := saveRecorder.Result().Cookies()[0].Value; strings.Contains(cookieValue, "example") || len(cookieValue) > 512 {
+		t.Fatalf("expected only the signed session ID in the cookie, got %q", cookieValue)
+	}
+
+	loadedSession, loadError := sqlStore.Get(requestWithResponseCookies(saveRecorder), "gauss_session")
+	if loadError != nil || loadedSession.IsNew || loadedSession.ID != webSession.ID {
+		t.Fatalf("expected the stored session, got %v %+v", loadError, loadedSession)
+	}
+	if loadedSession.Values["user_email"] != "e@example.com" || loadedSession.Values["oauth_token"] != webSession.Values["oauth_token"] {
+		t.Fatalf("unexpected values %v", loadedSession.Values["user_email"])
+	}
+
+	loadedSession.Options.MaxAge = -1
+	deleteRecorder := httptest.NewRecorder()
+	if saveError := loadedSession.Save(requestWithResponseCookies(saveRecorder), deleteRecorder); saveError != nil {
+		t.Fatalf("delete failed: %v", saveError)
+	}
+	if len(table) != 0 || deleteRecorder.Result().Cookies()[0].MaxAge >= 0 {
+		t.Fatalf("expected the row and the cookie to be deleted, got %d rows", len(table))
+	}
+	if reloadedSession, _ := sqlStore.Get(requestWithResponseCookies(saveRecorder), "gauss_session"); !reloadedSession.IsNew || reloadedSession.ID != "" {
+		t.Fatalf("expected a deleted session to start over, got %+v", reloadedSession)
+	}
+}
+
+func TestSQLStoreRejectsTamperedCookie(t *testing.T) {
+	sqlStore, _ := newTestSQLStore(t)
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: "gauss_session", Value: "forged"})
+	if webSession, loadError := sqlStore.New(request, "gauss_session"); loadError == nil || !webSession.IsNew {
+		t.Fatal("expected a forged session ID to be rejected")
+	}
+}
+
+func TestSQLStoreGC(t *testing.T) {
+	sqlStore, table := newTestSQLStore(t)
+	currentTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sqlStore.now = func() time.Time { return currentTime }
+	for _, maxAge := range []int{60, 3600} {
+		webSession := gsessions.NewSession(sqlStore, "gauss_session")
+		webSession.Options = &gsessions.Options{Path: "/", MaxAge: maxAge}
+		if saveError := sqlStore.Save(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder(), webSession); saveError != nil {
+			t.Fatal(saveError)
+		}
+	}
+
+	currentTime = currentTime.Add(time.Minute)
+	if gcError := sqlStore.GC(context.Background()); gcError != nil {
+		t.Fatalf("GC failed: %v", gcError)
+	}
+	if len(table) != 1 {
+		t.Fatalf("expected only the unexpired row to remain, got %d", len(table))
+	}
+}
+
+func TestNewSQLStoreValidates(t *testing.T) {
+	database, _ := sql.Open("gauss-memory", t.Name())
+	defer database.Close()
+	testCases := []struct {
+		name      string
+		database  *sql.DB
+		tableName string
+		keyPairs  [][]byte
+	}{
+		{name: "no database", tableName: "sessions", keyPairs: [][]byte{[]byte("key")}},
+		{name: "unsafe table name", database: database, tableName: "sessions; DROP TABLE users", keyPairs: [][]byte{[]byte("key")}},
+		{name: "no keys", database: database, tableName: "sessions"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, storeError := NewSQLStore(testCase.database, testCase.tableName, testCase.keyPairs...); storeError == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	if migration := SQLStoreMigrationForTable("app.user_sessions"); !strings.HasPrefix(migration, "CREATE TABLE IF NOT EXISTS app.user_sessions (") {
+		t.Fatalf("unexpected migration %q", migration)
+	}
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenNotFound is returned by GetToken when the session holds no OAuth
+// token, for example because the user has not logged in.
+var ErrTokenNotFound = errors.New("oauth token not found in session")
+
+// GetToken returns the OAuth2 token GAuss stored in the session for request.
+// It returns ErrTokenNotFound when no token is present and a wrapped error
+// when the session or the token cannot be decoded.
+func GetToken(request *http.Request) (*oauth2.Token, error) {
+	tokenJSON, err := getString(request, constants.SessionKeyOAuthToken)
+	if err != nil {
+		return nil, err
+	}
+	if tokenJSON == "" {
+		return nil, ErrTokenNotFound
+	}
+	var oauthToken oauth2.Token
+	if unmarshalError := json.Unmarshal([]byte(tokenJSON), &oauthToken); unmarshalError != nil {
+		return nil, fmt.Errorf("failed to decode oauth token: %w", unmarshalError)
+	}
+	return &oauthToken, nil
+}
+
+// GetUserID returns the logged-in user's stable Google subject identifier, or
+// an empty string when the session has none.
+func GetUserID(request *http.Request) (string, error) {
+	return getString(request, constants.SessionKeyUserID)
+}
+
+// GetUserEmail returns the logged-in user's email, or an empty string when
+// the session has none.
+func GetUserEmail(request *http.Request) (string, error) {
+	return getString(request, constants.SessionKeyUserEmail)
+}
+
+// GetUserName returns the logged-in user's display name, or an empty string
+// when the session has none.
+func GetUserName(request *http.Request) (string, error) {
+	return getString(request, constants.SessionKeyUserName)
+}
+
+// GetUserPicture returns the logged-in user's profile image URL, or an empty
+// string when the session has none.
+func GetUserPicture(request *http.Request) (string, error) {
+	return getString(request, constants.SessionKeyUserPicture)
+}
+
+// SessionID returns the opaque identifier of the request's session, or an
+// empty string when the session has none. The cookie store keeps the whole
+// session in the cookie and leaves the gorilla Session.ID empty, so the
+// identifier is the random value GAuss assigns at login under
+// constants.SessionKeySessionID. It stays the same for the life of the
+// session and changes on every login, which makes it suitable for keying
+// rate limits, audit logs and server-side session records.
+func SessionID(request *http.Request) (string, error) {
+	return getString(request, constants.SessionKeySessionID)
+}
+
+// getString reads the string stored under sessionKey in the request's
+// session.
+func getString(request *http.Request, sessionKey string) (string, error) {
+	webSession, sessionError := Store().Get(request, Name())
+	if sessionError != nil {
+		return "", fmt.Errorf("failed to read session: %w", sessionError)
+	}
+	storedValue, _ := webSession.Values[sessionKey].(string)
+	return storedValue, nil
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"golang.org/x/oauth2"
+)
+
+// requestWithSession saves values in a real session and returns a request
+// carrying the resulting cookie.
+func requestWithSession(t *testing.T, values map[string]string) *http.Request {
+	t.Helper()
+	NewSession([]byte("secret"))
+	seedRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	webSession, _ := Store().Get(seedRequest, Name())
+	for sessionKey, sessionValue := range values {
+		webSession.Values[sessionKey] = sessionValue
+	}
+	if err := webSession.Save(seedRequest, rr); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestGettersRoundTrip(t *testing.T) {
+	storedToken := &oauth2.Token{AccessToken: "abc", RefreshToken: "rtok", TokenType: "Bearer", Expiry: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tokenJSON, _ := json.Marshal(storedToken)
+	req := requestWithSession(t, map[string]string{
+		constants.SessionKeyOAuthToken:  string(tokenJSON),
+		constants.SessionKeyUserID:      "1234567",
+		constants.SessionKeyUserEmail:   "e@example.com",
+		constants.SessionKeyUserName:    "tester",
+		constants.SessionKeyUserPicture: "pic",
+		constants.SessionKeySessionID:   "opaque-id",
+	})
+
+	oauthToken, err := GetToken(req)
+	if err != nil {
+		t.Fatalf("GetToken error: %v", err)
+	}
+	if oauthToken.AccessToken != "abc" || oauthToken.RefreshToken != "rtok" || !oauthToken.Expiry.Equal(storedToken.Expiry) {
+		t.Fatalf("unexpected token %+v", oauthToken)
+	}
+
+	testCases := []struct {
+		getter func(*http.Request) (string, error)
+		want   string
+	}{
+		{getter: GetUserID, want: "1234567"},
+		{getter: GetUserEmail, want: "e@example.com"},
+		{getter: GetUserName, want: "tester"},
+		{getter: GetUserPicture, want: "pic"},
+		{getter: SessionID, want: "opaque-id"},
+	}
+	for _, testCase := range testCases {
+		if got, getErr := testCase.getter(req); getErr != nil || got != testCase.want {
+			t.Fatalf("expected %q, got %q (%v)", testCase.want, got, getErr)
+		}
+	}
+}
+
+func TestGetTokenErrors(t *testing.T) {
+	if _, err := GetToken(requestWithSession(t, map[string]string{})); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+	if _, err := GetToken(requestWithSession(t, map[string]string{constants.SessionKeyOAuthToken: "{broken"})); err == nil || errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package opentelemetry records GAuss spans with OpenTelemetry. It is a
+// separate module so that applications which do not trace do not depend on
+// OpenTelemetry.
+package opentelemetry
+
+import (
+	"context"
+
+	"github.com/temirov/GAuss/pkg/gauss"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/temirov/GAuss/pkg/gauss"
+
+	// attributeErrorCode carries the AuthErrorCode of a failed login. Spans
+	// never carry tokens, authorization codes or state values.
+	attributeErrorCode = attribute.Key("gauss.error_code")
+)
+
+// Tracer implements gauss.Tracer with an OpenTelemetry tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a Tracer that records GAuss spans with tracerProvider,
+// parented to the span in the incoming request context.
+func NewTracer(tracerProvider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: tracerProvider.Tracer(tracerName)}
+}
+
+// WithTracerProvider returns a gauss.ServiceOption that records OpenTelemetry
+// spans for Login, Callback, the token exchange and GetUser using
+// tracerProvider. A nil tracerProvider leaves spans disabled.
+func WithTracerProvider(tracerProvider trace.TracerProvider) gauss.ServiceOption {
+	if tracerProvider == nil {
+		return gauss.WithTracer(nil)
+	}
+	return gauss.WithTracer(NewTracer(tracerProvider))
+}
+
+// Start implements gauss.Tracer.
+func (tracer *Tracer) Start(ctx context.Context, spanName string) (context.Context, gauss.Span) {
+	spanContext, openTelemetrySpan := tracer.tracer.Start(ctx, spanName)
+	return spanContext, &span{span: openTelemetrySpan}
+}
+
+// span implements gauss.Span with an OpenTelemetry span.
+type span struct {
+	span trace.Span
+}
+
+// RecordErrorCode implements gauss.Span. The code is set as the
+// gauss.error_code attribute and as the description of the error status.
+func (recordingSpan *span) RecordErrorCode(code gauss.AuthErrorCode) {
+	recordingSpan.span.SetAttributes(attributeErrorCode.String(string(code)))
+	recordingSpan.span.SetStatus(codes.Error, string(code))
+}
+
+// RecordFailure implements gauss.Span.
+func (recordingSpan *span) RecordFailure(description string) {
+	recordingSpan.span.SetStatus(codes.Error, description)
+}
+
+// End implements gauss.Span.
+func (recordingSpan *span) End() {
+	recordingSpan.span.End()
+}
+
+var _ gauss.Tracer = (*Tracer)(nil)
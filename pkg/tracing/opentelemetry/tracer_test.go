@@ -0,0 +1,91 @@
+package opentelemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/temirov/GAuss/pkg/constants"
+	"github.com/temirov/GAuss/pkg/gauss"
+	"github.com/temirov/GAuss/pkg/session"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newExportingTracerProvider returns a tracer provider whose spans are
+// captured by the returned in-memory exporter.
+func newExportingTracerProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { tracerProvider.Shutdown(context.Background()) })
+	return tracerProvider, exporter
+}
+
+// spansByName indexes the exported spans by name.
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	indexedSpans := make(map[string]tracetest.SpanStub)
+	for _, exportedSpan := range exporter.GetSpans() {
+		indexedSpans[exportedSpan.Name] = exportedSpan
+	}
+	return indexedSpans
+}
+
+func TestTracerRecordsParentsAndFailures(t *testing.T) {
+	tracerProvider, exporter := newExportingTracerProvider(t)
+	tracer := NewTracer(tracerProvider)
+
+	parentContext, parentSpan := tracer.Start(context.Background(), "parent")
+	_, childSpan := tracer.Start(parentContext, "child")
+	childSpan.RecordFailure("child failed")
+	childSpan.End()
+	parentSpan.RecordErrorCode(gauss.ErrCodeStateMismatch)
+	parentSpan.End()
+
+	recordedSpans := spansByName(exporter)
+	parentStub, childStub := recordedSpans["parent"], recordedSpans["child"]
+	if childStub.Parent.SpanID() != parentStub.SpanContext.SpanID() {
+		t.Fatal("expected the child span to be parented to the parent span")
+	}
+	if childStub.Status.Code != codes.Error || childStub.Status.Description != "child failed" {
+		t.Fatalf("expected the child to fail with its description, got %+v", childStub.Status)
+	}
+	if parentStub.Status.Code != codes.Error || parentStub.Status.Description != string(gauss.ErrCodeStateMismatch) {
+		t.Fatalf("expected the parent to fail with the error code, got %+v", parentStub.Status)
+	}
+	recordedCode := ""
+	for _, spanAttribute := range parentStub.Attributes {
+		if spanAttribute.Key == attributeErrorCode {
+			recordedCode = spanAttribute.Value.AsString()
+		}
+	}
+	if recordedCode != string(gauss.ErrCodeStateMismatch) {
+		t.Fatalf("expected error code %s, got %q", gauss.ErrCodeStateMismatch, recordedCode)
+	}
+}
+
+func TestWithTracerProviderTracesLogin(t *testing.T) {
+	tracerProvider, exporter := newExportingTracerProvider(t)
+	session.NewSession([]byte("secret"))
+	service, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTracerProvider(tracerProvider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers, err := gauss.NewHandlers(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handlers.Login(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, constants.GoogleAuthPath, nil))
+	if _, found := spansByName(exporter)["gauss.Login"]; !found {
+		t.Fatal("expected a gauss.Login span")
+	}
+}
+
+func TestWithTracerProviderIgnoresNil(t *testing.T) {
+	if _, err := gauss.NewService("id", "secret", "http://localhost:8080", "/dashboard", nil, "", WithTracerProvider(nil)); err != nil {
+		t.Fatalf("expected a nil tracer provider to be ignored, got %v", err)
+	}
+}